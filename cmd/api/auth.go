@@ -0,0 +1,270 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+	"github.com/google/uuid"
+)
+
+// A sessionTokens carries the access and refresh tokens of a session.
+type sessionTokens struct {
+	SessionID             uuid.UUID `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// A loginResponse carries the tokens issued for a new session.
+type loginResponse struct {
+	sessionTokens
+	User userResponse `json:"user"`
+}
+
+// clientIP returns the IP address of the client that sent r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// newSessionTokens creates an access/refresh token pair for userID and the
+// parameters of the session that stores the refresh token's hash. familyID
+// is the ID of the first session of a login; uuid.Nil starts a new family.
+func (app *KeyKeeper) newSessionTokens(r *http.Request, userID int64, familyID uuid.UUID) (sessionTokens, db.CreateSessionParams, error) {
+	sessionID, err := uuid.NewRandom()
+	if err != nil {
+		return sessionTokens{}, db.CreateSessionParams{}, err
+	}
+
+	accessToken, accessPayload, err := app.TokenMaker.CreateToken(token.TypeAccess, app.Config.AccessTokenDuration, userID, sessionID)
+	if err != nil {
+		return sessionTokens{}, db.CreateSessionParams{}, err
+	}
+
+	refreshToken, refreshPayload, err := app.TokenMaker.CreateToken(token.TypeRefresh, app.Config.SessionTokenDuration, userID, sessionID)
+	if err != nil {
+		return sessionTokens{}, db.CreateSessionParams{}, err
+	}
+
+	if familyID == uuid.Nil {
+		familyID = sessionID
+	}
+
+	tokens := sessionTokens{
+		SessionID:             sessionID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+	}
+
+	arg := db.CreateSessionParams{
+		ID:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: util.HashToken(refreshToken),
+		UserAgent:        r.UserAgent(),
+		ClientIp:         clientIP(r),
+		IsBlocked:        false,
+		ExpiresAt:        refreshPayload.ExpiredAt,
+		FamilyID:         familyID,
+	}
+
+	return tokens, arg, nil
+}
+
+func (app *KeyKeeper) loginUser(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validateEmail(v, input.Email)
+	validatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user, err := app.Store.GetUserByEmail(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = util.VerifyPassword(user.HashedPassword, input.Password)
+	if err != nil {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	if !user.IsActivated {
+		app.inactiveAccountResponse(w, r)
+		return
+	}
+
+	tokens, arg, err := app.newSessionTokens(r, user.ID, uuid.Nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.Store.CreateSession(r.Context(), arg)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	res := loginResponse{
+		sessionTokens: tokens,
+		User:          newUserResponse(user),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, res, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) refreshSession(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.RefreshToken != "", "refresh_token", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	payload, err := app.TokenMaker.VerifyToken(input.RefreshToken)
+	if err != nil || payload.Type != token.TypeRefresh {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	session, err := app.Store.GetSession(r.Context(), payload.SessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if session.UserID != payload.UserID || !util.VerifyTokenHash(session.RefreshTokenHash, input.RefreshToken) {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	revoked, err := app.tokenRevoked(r, payload)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if revoked {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	// A refresh token is good for one rotation only. Seeing it again means
+	// that it leaked, so every session descending from the same login is
+	// blocked.
+	if session.RotatedAt.Valid {
+		app.revokeSessionFamily(w, r, session.FamilyID)
+		return
+	}
+
+	if session.IsBlocked || time.Now().After(session.ExpiresAt) {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	tokens, arg, err := app.newSessionTokens(r, session.UserID, session.FamilyID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.Store.RefreshSessionTx(r.Context(), db.RefreshSessionTxParams{
+		SessionID:  session.ID,
+		NewSession: arg,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// A concurrent request rotated or blocked the session first.
+			app.revokeSessionFamily(w, r, session.FamilyID)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, tokens, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeSessionFamily blocks every session of a token family after refresh
+// token reuse and rejects the request.
+func (app *KeyKeeper) revokeSessionFamily(w http.ResponseWriter, r *http.Request, familyID uuid.UUID) {
+	err := app.Store.BlockSessionFamily(r.Context(), familyID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.invalidAuthenticationTokenResponse(w, r)
+}
+
+// tokenRevoked reports whether a token was issued before its user last
+// changed their password, or its user was deactivated or no longer exists.
+// Such tokens must not be accepted.
+func (app *KeyKeeper) tokenRevoked(r *http.Request, payload *token.Payload) (bool, error) {
+	user, err := app.Store.GetUser(r.Context(), payload.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return !user.IsActivated || payload.IssuedAt.Before(user.PasswordChangedAt), nil
+}
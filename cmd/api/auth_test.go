@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginUser(t *testing.T) {
+	store := newMemStore()
+	password := util.RandomString(12)
+	user := store.addUserWithPassword(t, password)
+
+	inactive := store.addUserWithPassword(t, password)
+	inactive.IsActivated = false
+	store.users[inactive.ID] = inactive
+
+	app := newTestApp(t, store)
+
+	testCases := []struct {
+		name   string
+		email  string
+		pass   string
+		status int
+	}{
+		{name: "OK", email: user.Email, pass: password, status: http.StatusOK},
+		{name: "WrongPassword", email: user.Email, pass: util.RandomString(12), status: http.StatusUnauthorized},
+		{name: "UnknownEmail", email: util.RandomEmail(), pass: password, status: http.StatusUnauthorized},
+		{name: "Deactivated", email: inactive.Email, pass: password, status: http.StatusForbidden},
+		{name: "InvalidEmail", email: "not-an-email", pass: password, status: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"email": %q, "password": %q}`, tc.email, tc.pass)
+			rec := serve(t, app, http.MethodPost, "/v1/auth/login", []byte(body))
+			if tc.status != http.StatusOK {
+				requireErrorResponse(t, rec, tc.status)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.NotContains(t, rec.Body.String(), "hashed_password")
+
+			var res loginResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			require.NotEmpty(t, res.AccessToken)
+			require.NotEmpty(t, res.RefreshToken)
+			require.Equal(t, user.ID, res.User.ID)
+			require.True(t, res.RefreshTokenExpiresAt.After(res.AccessTokenExpiresAt))
+
+			// The refresh token is persisted as a session along with the
+			// request's client details.
+			session, ok := store.sessions[res.SessionID]
+			require.True(t, ok)
+			require.Equal(t, user.ID, session.UserID)
+			require.Equal(t, res.SessionID, session.FamilyID)
+			require.NotEqual(t, res.RefreshToken, session.RefreshTokenHash)
+			require.True(t, util.VerifyTokenHash(session.RefreshTokenHash, res.RefreshToken))
+			require.Equal(t, "192.0.2.1", session.ClientIp)
+			require.False(t, session.IsBlocked)
+		})
+	}
+}
+
+// login signs user in and returns the issued tokens.
+func login(t *testing.T, app *KeyKeeper, email, password string) loginResponse {
+	body := fmt.Sprintf(`{"email": %q, "password": %q}`, email, password)
+	rec := serve(t, app, http.MethodPost, "/v1/auth/login", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res loginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	return res
+}
+
+// refresh exchanges a refresh token and returns the recorded response.
+func refresh(t *testing.T, app *KeyKeeper, refreshToken string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"refresh_token": %q}`, refreshToken)
+	return serve(t, app, http.MethodPost, "/v1/auth/refresh", []byte(body))
+}
+
+func TestRefreshSession(t *testing.T) {
+	store := newMemStore()
+	password := util.RandomString(12)
+	user := store.addUserWithPassword(t, password)
+	app := newTestApp(t, store)
+
+	first := login(t, app, user.Email, password)
+
+	// Rotating a refresh token returns a new pair in the same family.
+	rec := refresh(t, app, first.RefreshToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var second sessionTokens
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &second))
+	require.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	require.NotEqual(t, first.SessionID, second.SessionID)
+	require.Equal(t, first.SessionID, store.sessions[second.SessionID].FamilyID)
+	require.True(t, store.sessions[first.SessionID].RotatedAt.Valid)
+
+	rec = refresh(t, app, second.RefreshToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var third sessionTokens
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &third))
+
+	// Presenting an already rotated token blocks the whole family, including
+	// the latest session.
+	rec = refresh(t, app, first.RefreshToken)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	for _, id := range []uuid.UUID{first.SessionID, second.SessionID, third.SessionID} {
+		require.True(t, store.sessions[id].IsBlocked)
+	}
+
+	rec = refresh(t, app, third.RefreshToken)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	// Other logins are unaffected.
+	other := login(t, app, user.Email, password)
+	rec = refresh(t, app, other.RefreshToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRefreshSessionInvalidToken(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	// A validly signed token without a session.
+	orphan, _, err := app.TokenMaker.CreateToken(token.TypeRefresh, time.Hour, user.ID, uuid.New())
+	require.NoError(t, err)
+
+	rec := refresh(t, app, orphan)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	// An access token cannot renew its session.
+	session := newTestSession(t, app, user.ID)
+	rec = refresh(t, app, session.AccessToken)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	rec = refresh(t, app, "invalid")
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	rec = refresh(t, app, "")
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+}
+
+func TestPasswordChangeInvalidatesTokens(t *testing.T) {
+	store := newMemStore()
+	password := util.RandomString(12)
+	user := store.addUserWithPassword(t, password)
+	app := newTestApp(t, store)
+
+	path := fmt.Sprintf("/v1/users/%d", user.ID)
+
+	first := login(t, app, user.Email, password)
+	second := login(t, app, user.Email, password)
+
+	newPassword := util.RandomString(12)
+	body := fmt.Sprintf(`{"password": %q}`, newPassword)
+	rec := serveWithToken(t, app, first.AccessToken, http.MethodPatch, path+"/change-password", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Tokens issued before the change are rejected, including those of the
+	// session that changed the password.
+	for _, tokens := range []loginResponse{first, second} {
+		rec = serveWithToken(t, app, tokens.AccessToken, http.MethodGet, path, nil)
+		requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+		rec = refresh(t, app, tokens.RefreshToken)
+		requireErrorResponse(t, rec, http.StatusUnauthorized)
+	}
+
+	// Tokens issued afterwards work.
+	third := login(t, app, user.Email, newPassword)
+	rec = serveWithToken(t, app, third.AccessToken, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = refresh(t, app, third.RefreshToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestDeactivationInvalidatesTokens(t *testing.T) {
+	store := newMemStore()
+	password := util.RandomString(12)
+	user := store.addUserWithPassword(t, password)
+	app := newTestApp(t, store)
+
+	path := fmt.Sprintf("/v1/users/%d", user.ID)
+
+	first := login(t, app, user.Email, password)
+	second := login(t, app, user.Email, password)
+
+	body := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, password)
+	rec := serveWithToken(t, app, first.AccessToken, http.MethodPatch, path+"/deactivate", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Every session of the user is blocked.
+	for _, session := range store.sessions {
+		if session.UserID == user.ID {
+			require.True(t, session.IsBlocked)
+		}
+	}
+
+	for _, tokens := range []loginResponse{first, second} {
+		rec = serveWithToken(t, app, tokens.AccessToken, http.MethodGet, path, nil)
+		requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+		rec = refresh(t, app, tokens.RefreshToken)
+		requireErrorResponse(t, rec, http.StatusUnauthorized)
+	}
+
+	// Sessions that are somehow still open are rejected too.
+	tokens := newTestSession(t, app, user.ID)
+	rec = serveWithToken(t, app, tokens.AccessToken, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	rec = refresh(t, app, tokens.RefreshToken)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// A breachResponse is the public representation of a data breach of the
+// website of a reminder.
+type breachResponse struct {
+	Name        string     `json:"name"`
+	Title       string     `json:"title"`
+	Domain      string     `json:"domain"`
+	BreachDate  string     `json:"breach_date"`
+	PwnCount    int64      `json:"pwn_count"`
+	DataClasses []string   `json:"data_classes"`
+	IsVerified  bool       `json:"is_verified"`
+	FlaggedAt   time.Time  `json:"flagged_at"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+}
+
+func newBreachResponse(breach db.ListReminderBreachesRow) breachResponse {
+	res := breachResponse{
+		Name:        breach.Name,
+		Title:       breach.Title,
+		Domain:      breach.Domain,
+		BreachDate:  breach.BreachDate.Format("2006-01-02"),
+		PwnCount:    breach.PwnCount,
+		DataClasses: breach.DataClasses,
+		IsVerified:  breach.IsVerified,
+		FlaggedAt:   breach.FlaggedAt,
+	}
+	if res.DataClasses == nil {
+		res.DataClasses = []string{}
+	}
+	if breach.ResolvedAt.Valid {
+		res.ResolvedAt = &breach.ResolvedAt.Time
+	}
+
+	return res
+}
+
+// listReminderBreaches returns the data breaches that flagged a reminder,
+// latest first. A breach is resolved once the reminder is rotated.
+func (app *KeyKeeper) listReminderBreaches(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	breaches, err := app.Store.ListReminderBreaches(r.Context(), reminder.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]breachResponse, len(breaches))
+	for i, breach := range breaches {
+		data[i] = newBreachResponse(breach)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListReminderBreaches(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	reminder, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "https://adobe.com",
+		Interval:   "P1Y",
+		UpdatedAt:  time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, "/breaches"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"data": []}`, rec.Body.String())
+
+	flaggedAt := time.Date(2013, 12, 4, 0, 0, 0, 0, time.UTC)
+	store.breaches[reminder.ID] = []db.ListReminderBreachesRow{{
+		ID:          1,
+		Name:        "Adobe",
+		Title:       "Adobe",
+		Domain:      "adobe.com",
+		BreachDate:  time.Date(2013, 10, 4, 0, 0, 0, 0, time.UTC),
+		PwnCount:    152445165,
+		DataClasses: []string{"Email addresses", "Passwords"},
+		IsVerified:  true,
+		FlaggedAt:   flaggedAt,
+	}}
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, "/breaches"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"data": [{
+		"name": "Adobe",
+		"title": "Adobe",
+		"domain": "adobe.com",
+		"breach_date": "2013-10-04",
+		"pwn_count": 152445165,
+		"data_classes": ["Email addresses", "Passwords"],
+		"is_verified": true,
+		"flagged_at": "2013-12-04T00:00:00Z",
+		"resolved_at": null
+	}]}`, rec.Body.String())
+
+	// Rotating the reminder resolves the breach.
+	rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/rotated"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, "/breaches"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got struct {
+		Data []breachResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got.Data, 1)
+	require.NotNil(t, got.Data[0].ResolvedAt)
+
+	other := store.addUser(t)
+	rec = serveAs(t, app, other.ID, http.MethodGet, reminderPath(reminder, "/breaches"), nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+}
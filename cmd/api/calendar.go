@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/ical"
+	"github.com/OCD-Labs/KeyKeeper/internal/interval"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// calendarRefreshInterval is how often calendar applications are asked
+	// to poll a feed.
+	calendarRefreshInterval = time.Hour
+
+	// calendarAlarmTime is when, on the day a rotation is due, calendar
+	// applications alert the user.
+	calendarAlarmTime = 9 * time.Hour
+)
+
+// A calendarFeedResponse describes a user's calendar feed. The token, and
+// with it the path of the feed, is only included when the feed is created.
+type calendarFeedResponse struct {
+	Token     string    `json:"token,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// calendarFeedPath returns the path of the feed with the given token.
+func calendarFeedPath(token string) string {
+	return "/v1/calendar/" + token + ".ics"
+}
+
+// newReminderEvent returns the calendar event of a reminder: an all-day
+// event on the day, in loc, that its password is due for rotation,
+// repeating at its interval as if each rotation happened on its due day.
+// Intervals that no recurrence rule can express, such as "1 month and 14
+// days", only show the next rotation. It returns false if the reminder's
+// interval does not parse.
+func newReminderEvent(reminder db.Reminder, now time.Time, loc *time.Location) (ical.Event, bool) {
+	i, err := interval.Parse(reminder.Interval)
+	if err != nil {
+		return ical.Event{}, false
+	}
+
+	due := i.AddIn(reminder.UpdatedAt, loc)
+	rule, _ := i.RRule(due)
+
+	event := ical.Event{
+		UID:     fmt.Sprintf("reminder-%d@keykeeper", reminder.ID),
+		Stamp:   now,
+		Date:    due,
+		RRule:   rule,
+		Summary: "Change your password for " + reminder.WebsiteUrl,
+		Description: fmt.Sprintf("Your password for %s was last changed on %s. Your reminder interval is %s.",
+			reminder.WebsiteUrl, reminder.UpdatedAt.In(loc).Format("2 January 2006"), i.Describe()),
+		LastModified: reminder.UpdatedAt,
+		Alarms: []ical.Alarm{{
+			Trigger:     calendarAlarmTime,
+			Description: "Time to change your password for " + reminder.WebsiteUrl,
+		}},
+	}
+
+	if u, err := url.Parse(reminder.WebsiteUrl); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		event.URL = u.String()
+	}
+
+	return event, true
+}
+
+// getCalendarFeed serves a user's reminders as an iCalendar feed. The feed
+// token in the URL is the only credential, so that calendar applications
+// can subscribe to it.
+func (app *KeyKeeper) getCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("feed")
+	token := strings.TrimSuffix(name, ".ics")
+
+	// Keep the token out of the error log.
+	r = r.Clone(r.Context())
+	r.URL.Path = calendarFeedPath("REDACTED")
+
+	if token == "" || token == name {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	feed, err := app.Store.GetCalendarFeedByTokenHash(r.Context(), util.HashToken(token))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.Store.GetUser(r.Context(), feed.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !user.IsActivated {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reminders, err := app.Store.ListAllReminders(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cal := ical.Calendar{
+		ProdID:          "-//OCD Labs//KeyKeeper//EN",
+		Name:            "KeyKeeper password rotations",
+		RefreshInterval: calendarRefreshInterval,
+	}
+
+	now := time.Now()
+	for _, reminder := range reminders {
+		event, ok := newReminderEvent(reminder, now, prefs.Location)
+		if !ok {
+			log.Printf("calendar: reminder %d has an invalid interval %q", reminder.ID, reminder.Interval)
+			continue
+		}
+		cal.Events = append(cal.Events, event)
+	}
+
+	var buf bytes.Buffer
+	err = cal.Encode(&buf)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="keykeeper.ics"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write(buf.Bytes())
+}
+
+func (app *KeyKeeper) getCalendarFeedInfo(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := app.Store.GetCalendarFeed(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, calendarFeedResponse{CreatedAt: feed.CreatedAt}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createCalendarFeed creates the caller's calendar feed, or regenerates its
+// token if the feed exists. The old token stops working immediately.
+func (app *KeyKeeper) createCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := util.GenerateToken()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	feed, err := app.Store.CreateCalendarFeed(r.Context(), db.CreateCalendarFeedParams{
+		UserID:    id,
+		TokenHash: util.HashToken(token),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	res := calendarFeedResponse{
+		Token:     token,
+		Path:      calendarFeedPath(token),
+		CreatedAt: feed.CreatedAt,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, res, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) deleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Store.DeleteCalendarFeed(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestCalendarFeed creates or regenerates the calendar feed of userID
+// through the API.
+func createTestCalendarFeed(t *testing.T, app *KeyKeeper, userID int64) calendarFeedResponse {
+	rec := serveAs(t, app, userID, http.MethodPost, fmt.Sprintf("/v1/users/%d/calendar-feed", userID), nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var feed calendarFeedResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &feed))
+
+	return feed
+}
+
+func TestGetCalendarFeed(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	updatedAt := time.Date(2023, time.January, 31, 10, 0, 0, 0, time.UTC)
+	for _, arg := range []db.CreateReminderParams{
+		{UserID: user.ID, WebsiteUrl: "https://example.com/login", Interval: "P3M", UpdatedAt: updatedAt},
+		{UserID: user.ID, WebsiteUrl: "bank, inc; online", Interval: "P1M14D", UpdatedAt: updatedAt},
+		{UserID: store.addUser(t).ID, WebsiteUrl: "other.com", Interval: "P1D", UpdatedAt: updatedAt},
+	} {
+		_, err := store.CreateReminder(context.Background(), arg)
+		require.NoError(t, err)
+	}
+
+	feed := createTestCalendarFeed(t, app, user.ID)
+	require.NotEmpty(t, feed.Token)
+	require.Equal(t, "/v1/calendar/"+feed.Token+".ics", feed.Path)
+
+	rec := serve(t, app, http.MethodGet, feed.Path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	require.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n"))
+	require.Equal(t, 2, strings.Count(body, "BEGIN:VEVENT"))
+	require.Equal(t, 2, strings.Count(body, "BEGIN:VALARM"))
+	require.NotContains(t, body, "other.com")
+
+	// Three months after January 31 is April 30. Rotations after that are
+	// on the 30th, or the last day of shorter months.
+	require.Contains(t, body, "DTSTART;VALUE=DATE:20230430\r\n")
+	require.Contains(t, body, "RRULE:FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=28,29,30;BYSETPOS=-1\r\n")
+	require.Contains(t, body, "URL:https://example.com/login\r\n")
+
+	// An interval no rule can express only shows the next rotation.
+	require.Contains(t, body, "DTSTART;VALUE=DATE:20230314\r\n")
+	require.Equal(t, 1, strings.Count(body, "RRULE:"))
+	require.Contains(t, body, `SUMMARY:Change your password for bank\, inc\; online`)
+
+	for _, path := range []string{
+		"/v1/calendar/" + feed.Token,
+		"/v1/calendar/" + feed.Token + "x.ics",
+		"/v1/calendar/.ics",
+	} {
+		rec = serve(t, app, http.MethodGet, path, nil)
+		requireErrorResponse(t, rec, http.StatusNotFound)
+	}
+}
+
+func TestRegenerateCalendarFeed(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/calendar-feed", user.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	old := createTestCalendarFeed(t, app, user.ID)
+	feed := createTestCalendarFeed(t, app, user.ID)
+	require.NotEqual(t, old.Token, feed.Token)
+
+	rec = serve(t, app, http.MethodGet, old.Path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+	rec = serve(t, app, http.MethodGet, feed.Path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The token is not shown again.
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), feed.Token)
+
+	other := store.addUser(t)
+	rec = serveAs(t, app, other.ID, http.MethodPost, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(t, app, http.MethodGet, feed.Path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
+
+func TestCalendarFeedDeactivatedUser(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	feed := createTestCalendarFeed(t, app, user.ID)
+
+	_, err := store.DeactivateUser(context.Background(), db.DeactivateUserParams{ID: user.ID, Email: user.Email})
+	require.NoError(t, err)
+
+	rec := serve(t, app, http.MethodGet, feed.Path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
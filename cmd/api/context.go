@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
+)
+
+type contextKey string
+
+const authPayloadContextKey = contextKey("auth_payload")
+
+// contextSetAuthPayload returns a copy of r carrying the token payload of
+// the authenticated caller.
+func (app *KeyKeeper) contextSetAuthPayload(r *http.Request, payload *token.Payload) *http.Request {
+	ctx := context.WithValue(r.Context(), authPayloadContextKey, payload)
+	return r.WithContext(ctx)
+}
+
+// contextGetAuthPayload returns the token payload stored by
+// requireAuthentication. It panics when called on an unauthenticated route.
+func (app *KeyKeeper) contextGetAuthPayload(r *http.Request) *token.Payload {
+	payload, ok := r.Context().Value(authPayloadContextKey).(*token.Payload)
+	if !ok {
+		panic("missing auth payload in request context")
+	}
+
+	return payload
+}
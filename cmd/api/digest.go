@@ -0,0 +1,133 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/digest"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+)
+
+// digestOff is the frequency of users who get no digest.
+const digestOff = "off"
+
+// A digestResponse describes a user's digest settings. The times are null
+// when the digest is off.
+type digestResponse struct {
+	Frequency  string     `json:"frequency"`
+	NextSendAt *time.Time `json:"next_send_at"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+}
+
+func newDigestResponse(subscription db.DigestSubscription) digestResponse {
+	return digestResponse{
+		Frequency:  subscription.Frequency,
+		NextSendAt: &subscription.NextSendAt,
+		LastSentAt: &subscription.LastSentAt,
+	}
+}
+
+func (app *KeyKeeper) getDigest(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	res := digestResponse{Frequency: digestOff}
+
+	subscription, err := app.Store.GetDigestSubscription(r.Context(), id)
+	switch {
+	case err == nil:
+		res = newDigestResponse(subscription)
+	case !errors.Is(err, sql.ErrNoRows):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, res, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateDigest sets how often the caller gets a digest. While a digest is
+// on, reminder alerts are held back from individual emails for the digest
+// to carry. Turning it off sends a last digest with the alerts held back so
+// far.
+func (app *KeyKeeper) updateDigest(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Frequency string `json:"frequency"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Frequency != "", "frequency", "must be provided")
+	v.Check(validator.PermittedValue(input.Frequency, append(digest.Frequencies, digestOff)...), "frequency", "must be one of daily, weekly or off")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if input.Frequency == digestOff {
+		subscription, err := app.Store.DeleteDigestSubscription(r.Context(), id)
+		switch {
+		case err == nil:
+			_, err = jobs.Enqueue(r.Context(), app.Store, digest.Args{
+				UserID:    id,
+				Frequency: subscription.Frequency,
+				Since:     subscription.LastSentAt,
+				Until:     time.Now(),
+			})
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, digestResponse{Frequency: digestOff}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	subscription, err := app.Store.UpsertDigestSubscription(r.Context(), db.UpsertDigestSubscriptionParams{
+		UserID:     id,
+		Frequency:  input.Frequency,
+		NextSendAt: digest.Next(input.Frequency, time.Now(), prefs),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newDigestResponse(subscription), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
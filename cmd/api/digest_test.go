@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/digest"
+	"github.com/stretchr/testify/require"
+)
+
+// updateTestDigest sets the digest frequency of userID through the API.
+func updateTestDigest(t *testing.T, app *KeyKeeper, userID int64, frequency string) digestResponse {
+	body := []byte(fmt.Sprintf(`{"frequency": %q}`, frequency))
+	rec := serveAs(t, app, userID, http.MethodPut, fmt.Sprintf("/v1/users/%d/digest", userID), body)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res digestResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	return res
+}
+
+func TestDigest(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/digest", user.ID)
+
+	// The digest is off until the user opts in.
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"frequency": "off", "next_send_at": null, "last_sent_at": null}`, rec.Body.String())
+
+	res := updateTestDigest(t, app, user.ID, digest.FrequencyWeekly)
+	require.Equal(t, digest.FrequencyWeekly, res.Frequency)
+	require.NotNil(t, res.NextSendAt)
+	require.Equal(t, time.Monday, res.NextSendAt.Weekday())
+	require.NotNil(t, res.LastSentAt)
+
+	res = updateTestDigest(t, app, user.ID, digest.FrequencyDaily)
+	require.Equal(t, digest.FrequencyDaily, res.Frequency)
+	require.WithinDuration(t, time.Now(), *res.NextSendAt, 24*time.Hour)
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got digestResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, digest.FrequencyDaily, got.Frequency)
+	require.True(t, res.NextSendAt.Equal(*got.NextSendAt))
+
+	// Turning the digest off sends a last one with the alerts held back so
+	// far.
+	res = updateTestDigest(t, app, user.ID, "off")
+	require.Equal(t, digestResponse{Frequency: "off"}, res)
+	require.Empty(t, store.digests)
+	require.Len(t, store.jobs, 1)
+
+	for _, job := range store.jobs {
+		require.Equal(t, "digest.send", job.Kind)
+
+		var args digest.Args
+		require.NoError(t, json.Unmarshal(job.Args, &args))
+		require.Equal(t, user.ID, args.UserID)
+		require.Equal(t, digest.FrequencyDaily, args.Frequency)
+		require.True(t, args.Until.After(args.Since))
+	}
+
+	// Turning it off again has nothing left to send.
+	updateTestDigest(t, app, user.ID, "off")
+	require.Len(t, store.jobs, 1)
+}
+
+func TestUpdateDigestValidation(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/digest", user.ID)
+
+	for _, body := range []string{`{}`, `{"frequency": "hourly"}`, `{"frequency": "daily", "hour": 7}`} {
+		rec := serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	require.Empty(t, store.digests)
+}
+
+func TestDigestOtherUser(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/digest", other.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(`{"frequency": "daily"}`))
+	requireErrorResponse(t, rec, http.StatusForbidden)
+	require.Empty(t, store.digests)
+
+	rec = serve(t, app, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+)
+
+// maxRepeatHours bounds how long an overdue reminder may go without being
+// notified again.
+const maxRepeatHours = 30 * 24
+
+// An escalationPolicyResponse is the representation of a user's escalation
+// policy. Thresholds and the contact are null when unset. The contact is
+// only told of escalations once ContactEmailVerified.
+type escalationPolicyResponse struct {
+	AfterNotifications   *int32    `json:"after_notifications"`
+	AfterDays            *int32    `json:"after_days"`
+	RepeatHours          int32     `json:"repeat_hours"`
+	EscalatedRepeatHours int32     `json:"escalated_repeat_hours"`
+	Channels             []string  `json:"channels"`
+	ContactEmail         *string   `json:"contact_email"`
+	ContactEmailVerified bool      `json:"contact_email_verified"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+func newEscalationPolicyResponse(policy db.EscalationPolicy) escalationPolicyResponse {
+	res := escalationPolicyResponse{
+		RepeatHours:          policy.RepeatHours,
+		EscalatedRepeatHours: policy.EscalatedRepeatHours,
+		Channels:             policy.Channels,
+		ContactEmailVerified: policy.ContactVerifiedAt.Valid,
+		UpdatedAt:            policy.UpdatedAt,
+	}
+	if policy.AfterNotifications.Valid {
+		res.AfterNotifications = &policy.AfterNotifications.Int32
+	}
+	if policy.AfterDays.Valid {
+		res.AfterDays = &policy.AfterDays.Int32
+	}
+	if policy.ContactEmail.Valid {
+		res.ContactEmail = &policy.ContactEmail.String
+	}
+
+	return res
+}
+
+// An escalationResponse describes how far a reminder is overdue. EscalatedAt
+// is null until the reminder is escalated.
+type escalationResponse struct {
+	Notifications  int32      `json:"notifications"`
+	OverdueSince   time.Time  `json:"overdue_since"`
+	LastNotifiedAt time.Time  `json:"last_notified_at"`
+	EscalatedAt    *time.Time `json:"escalated_at"`
+}
+
+func newEscalationResponse(escalation db.ReminderEscalation) escalationResponse {
+	res := escalationResponse{
+		Notifications:  escalation.Notifications,
+		OverdueSince:   escalation.OverdueSince,
+		LastNotifiedAt: escalation.LastNotifiedAt,
+	}
+	if escalation.EscalatedAt.Valid {
+		res.EscalatedAt = &escalation.EscalatedAt.Time
+	}
+
+	return res
+}
+
+func (app *KeyKeeper) getEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	policy, err := app.Store.GetEscalationPolicy(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newEscalationPolicyResponse(policy), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateEscalationPolicy replaces the caller's escalation policy. An overdue
+// reminder is notified again every repeat_hours until it is escalated after
+// after_notifications notifications or after_days days, whichever comes
+// first. From then on it is notified every escalated_repeat_hours over the
+// given channels, and the contact, if any, is emailed once. A new contact is
+// sent a verification token and is only emailed once they have used it.
+func (app *KeyKeeper) updateEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		AfterNotifications   *int32   `json:"after_notifications"`
+		AfterDays            *int32   `json:"after_days"`
+		RepeatHours          int32    `json:"repeat_hours"`
+		EscalatedRepeatHours int32    `json:"escalated_repeat_hours"`
+		Channels             []string `json:"channels"`
+		ContactEmail         *string  `json:"contact_email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.AfterNotifications != nil || input.AfterDays != nil, "after_notifications", "must be provided unless after_days is")
+	if input.AfterNotifications != nil {
+		v.Check(*input.AfterNotifications > 0, "after_notifications", "must be greater than zero")
+	}
+	if input.AfterDays != nil {
+		v.Check(*input.AfterDays > 0, "after_days", "must be greater than zero")
+	}
+
+	v.Check(input.RepeatHours > 0, "repeat_hours", "must be greater than zero")
+	v.Check(input.RepeatHours <= maxRepeatHours, "repeat_hours", "must be a maximum of 720")
+	v.Check(input.EscalatedRepeatHours > 0, "escalated_repeat_hours", "must be greater than zero")
+	v.Check(input.EscalatedRepeatHours <= input.RepeatHours, "escalated_repeat_hours", "must not be greater than repeat_hours")
+
+	v.Check(len(input.Channels) > 0, "channels", "must contain at least one channel")
+	seen := make(map[string]bool, len(input.Channels))
+	for _, channel := range input.Channels {
+		v.Check(validator.PermittedValue(channel, notifier.Channels...), "channels", "must only contain email or push")
+		v.Check(!seen[channel], "channels", "must not contain duplicate values")
+		seen[channel] = true
+	}
+
+	if input.ContactEmail != nil {
+		v.Check(validator.Matches(*input.ContactEmail, validator.EmailRX), "contact_email", "must be a valid email address")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	current, err := app.Store.GetEscalationPolicy(r.Context(), id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	newContact := input.ContactEmail != nil && *input.ContactEmail != current.ContactEmail.String
+	if newContact {
+		exceeded, err := app.contactVerificationsExceeded(r.Context(), id)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if exceeded {
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+	}
+
+	arg := db.UpsertEscalationPolicyParams{
+		UserID:               id,
+		RepeatHours:          input.RepeatHours,
+		EscalatedRepeatHours: input.EscalatedRepeatHours,
+		Channels:             input.Channels,
+	}
+	if input.AfterNotifications != nil {
+		arg.AfterNotifications = sql.NullInt32{Int32: *input.AfterNotifications, Valid: true}
+	}
+	if input.AfterDays != nil {
+		arg.AfterDays = sql.NullInt32{Int32: *input.AfterDays, Valid: true}
+	}
+	if input.ContactEmail != nil {
+		arg.ContactEmail = sql.NullString{String: *input.ContactEmail, Valid: true}
+	}
+
+	policy, err := app.Store.UpsertEscalationPolicy(r.Context(), arg)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if newContact {
+		err = app.sendContactVerificationEmail(r.Context(), policy)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newEscalationPolicyResponse(policy), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteEscalationPolicy removes the caller's escalation policy. Overdue
+// reminders are then notified once, as before escalation was set up.
+func (app *KeyKeeper) deleteEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := app.Store.DeleteEscalationPolicy(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if n == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// contactVerificationsExceeded reports whether the user was sent as many
+// contact verification emails as allowed per verificationEmailWindow.
+func (app *KeyKeeper) contactVerificationsExceeded(ctx context.Context, userID int64) (bool, error) {
+	sent, err := app.Store.CountContactVerificationTokensSince(ctx, db.CountContactVerificationTokensSinceParams{
+		UserID:    userID,
+		CreatedAt: time.Now().Add(-verificationEmailWindow),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return sent >= verificationEmailLimit, nil
+}
+
+// sendContactVerificationEmail creates a verification token for the contact
+// of policy and emails it to the contact in the background.
+func (app *KeyKeeper) sendContactVerificationEmail(ctx context.Context, policy db.EscalationPolicy) error {
+	user, err := app.Store.GetUser(ctx, policy.UserID)
+	if err != nil {
+		return err
+	}
+
+	verificationToken, err := util.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = app.Store.CreateContactVerificationToken(ctx, db.CreateContactVerificationTokenParams{
+		TokenHash:    util.HashToken(verificationToken),
+		UserID:       user.ID,
+		ContactEmail: policy.ContactEmail.String,
+		ExpiresAt:    time.Now().Add(emailVerificationTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"FullName":  user.FullName,
+			"Token":     verificationToken,
+			"ExpiresIn": "24 hours",
+		}
+
+		err := app.Mailer.Send(policy.ContactEmail.String, "contact_verification.tmpl", data)
+		if err != nil {
+			log.Printf("failed to send contact verification email for user %d: %v", user.ID, err)
+		}
+	})
+
+	return nil
+}
+
+// resendContactVerificationEmail sends the contact of the caller's
+// escalation policy a new verification token.
+func (app *KeyKeeper) resendContactVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	policy, err := app.Store.GetEscalationPolicy(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	v.Check(policy.ContactEmail.Valid, "contact_email", "must be set")
+	v.Check(!policy.ContactVerifiedAt.Valid, "contact_email", "is already verified")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	exceeded, err := app.contactVerificationsExceeded(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if exceeded {
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	err = app.sendContactVerificationEmail(r.Context(), policy)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "a verification token has been sent to the contact's email address"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// verifyContact confirms the contact of an escalation policy with the token
+// sent to them. It needs no authentication, as it is the contact who holds
+// the token.
+func (app *KeyKeeper) verifyContact(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Token != "", "token", "must be provided")
+	v.Check(len(input.Token) == 26, "token", "must be 26 bytes long")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	_, err = app.Store.VerifyContactTx(r.Context(), util.HashToken(input.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			v.AddError("token", "invalid or expired contact verification token")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "the email address has been confirmed as a contact"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getReminderEscalation returns the escalation state of a reminder. Only
+// reminders that are overdue and were notified have one.
+func (app *KeyKeeper) getReminderEscalation(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	escalation, err := app.Store.GetReminderEscalation(r.Context(), reminder.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newEscalationResponse(escalation), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalationPolicy(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/escalation-policy", user.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	body := `{
+		"after_notifications": 3,
+		"after_days": null,
+		"repeat_hours": 48,
+		"escalated_repeat_hours": 12,
+		"channels": ["push"],
+		"contact_email": "joe@example.com"
+	}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.NotEmpty(t, got["updated_at"])
+	require.Equal(t, false, got["contact_email_verified"])
+	delete(got, "updated_at")
+	delete(got, "contact_email_verified")
+	res, err := json.Marshal(got)
+	require.NoError(t, err)
+	require.JSONEq(t, body, string(res))
+
+	policy := store.policies[user.ID]
+	require.Equal(t, sql.NullInt32{Int32: 3, Valid: true}, policy.AfterNotifications)
+	require.False(t, policy.AfterDays.Valid)
+	require.Equal(t, sql.NullString{String: "joe@example.com", Valid: true}, policy.ContactEmail)
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"contact_email":"joe@example.com"`)
+
+	// Replacing the policy drops fields that are left out.
+	body = `{"after_days": 14, "repeat_hours": 24, "escalated_repeat_hours": 24, "channels": ["email", "push"]}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.False(t, store.policies[user.ID].AfterNotifications.Valid)
+	require.False(t, store.policies[user.ID].ContactEmail.Valid)
+	require.Equal(t, []string{"email", "push"}, store.policies[user.ID].Channels)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Empty(t, store.policies)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
+
+func TestEscalationContactVerification(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app, server := newMailerTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/escalation-policy", user.ID)
+
+	policyBody := func(contactEmail string) []byte {
+		return []byte(fmt.Sprintf(`{"after_days": 7, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["email"], "contact_email": %q}`, contactEmail))
+	}
+
+	// Naming a contact sends them a token.
+	rec := serveAs(t, app, user.ID, http.MethodPut, path, policyBody("joe@example.com"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"contact_email_verified":false`)
+	first := receivedToken(t, app, server, "joe@example.com")
+	require.NotEmpty(t, first)
+
+	// Saving the policy with the same contact sends nothing.
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, policyBody("joe@example.com"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, receivedToken(t, app, server, "joe@example.com"))
+
+	rec = serveAs(t, app, user.ID, http.MethodPost, path+"/contact-verification", nil)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	second := receivedToken(t, app, server, "joe@example.com")
+	require.NotEmpty(t, second)
+
+	body := fmt.Sprintf(`{"token": %q}`, second)
+	rec = serve(t, app, http.MethodPut, "/v1/auth/contact-verification", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, store.policies[user.ID].ContactVerifiedAt.Valid)
+
+	rec = serve(t, app, http.MethodPut, "/v1/auth/contact-verification", []byte(body))
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+
+	// A verified contact gets no more tokens, and stays verified when the
+	// policy is saved again.
+	rec = serveAs(t, app, user.ID, http.MethodPost, path+"/contact-verification", nil)
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, policyBody("joe@example.com"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"contact_email_verified":true`)
+
+	// A new contact has to verify their address, and the tokens sent to the
+	// previous one no longer count.
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, policyBody("ann@example.com"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"contact_email_verified":false`)
+	require.NotEmpty(t, receivedToken(t, app, server, "ann@example.com"))
+
+	body = fmt.Sprintf(`{"token": %q}`, first)
+	rec = serve(t, app, http.MethodPut, "/v1/auth/contact-verification", []byte(body))
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+	require.False(t, store.policies[user.ID].ContactVerifiedAt.Valid)
+
+	// Verification emails are limited like those of account addresses.
+	rec = serveAs(t, app, user.ID, http.MethodPost, path+"/contact-verification", nil)
+	requireErrorResponse(t, rec, http.StatusTooManyRequests)
+
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, policyBody("jim@example.com"))
+	requireErrorResponse(t, rec, http.StatusTooManyRequests)
+	require.Equal(t, "ann@example.com", store.policies[user.ID].ContactEmail.String)
+	require.Empty(t, receivedToken(t, app, server, "jim@example.com"))
+}
+
+func TestUpdateEscalationPolicyValidation(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/escalation-policy", user.ID)
+
+	for _, body := range []string{
+		`{}`,
+		`{"repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["email"]}`,
+		`{"after_notifications": 0, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["email"]}`,
+		`{"after_days": -1, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["email"]}`,
+		`{"after_days": 7, "repeat_hours": 0, "escalated_repeat_hours": 0, "channels": ["email"]}`,
+		`{"after_days": 7, "repeat_hours": 721, "escalated_repeat_hours": 12, "channels": ["email"]}`,
+		`{"after_days": 7, "repeat_hours": 12, "escalated_repeat_hours": 24, "channels": ["email"]}`,
+		`{"after_days": 7, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": []}`,
+		`{"after_days": 7, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["sms"]}`,
+		`{"after_days": 7, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["push", "push"]}`,
+		`{"after_days": 7, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["email"], "contact_email": "joe@"}`,
+	} {
+		rec := serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	require.Empty(t, store.policies)
+}
+
+func TestEscalationPolicyOtherUser(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/escalation-policy", other.ID)
+
+	store.policies[other.ID] = db.EscalationPolicy{UserID: other.ID}
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	body := `{"after_days": 7, "repeat_hours": 24, "escalated_repeat_hours": 12, "channels": ["email"]}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+	require.Len(t, store.policies, 1)
+
+	rec = serve(t, app, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
+
+func TestReminderEscalation(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	reminder, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "example.com",
+		Interval:   "1 month",
+		UpdatedAt:  time.Now().AddDate(0, -2, 0),
+	})
+	require.NoError(t, err)
+
+	path := reminderPath(reminder, "/escalation")
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	overdueSince := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+	escalatedAt := overdueSince.AddDate(0, 0, 7)
+	store.overdue[reminder.ID] = db.ReminderEscalation{
+		ReminderID:     reminder.ID,
+		UserID:         user.ID,
+		Notifications:  4,
+		OverdueSince:   overdueSince,
+		LastNotifiedAt: escalatedAt,
+		EscalatedAt:    sql.NullTime{Time: escalatedAt, Valid: true},
+	}
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{
+		"notifications": 4,
+		"overdue_since": "2023-06-01T12:00:00Z",
+		"last_notified_at": "2023-06-08T12:00:00Z",
+		"escalated_at": "2023-06-08T12:00:00Z"
+	}`, rec.Body.String())
+
+	rec = serveAs(t, app, other.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	// Marking the reminder rotated resets its escalation.
+	rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/rotated"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+const (
+	// eventBatchSize is how many stored events a stream reads at a time.
+	eventBatchSize = 100
+
+	// eventCatchUpWindow is how far back a stream looks again for events
+	// it has not sent. Event IDs are taken when an event is recorded but
+	// events become visible when their transaction commits, so an event
+	// may show up after events with greater IDs were sent.
+	eventCatchUpWindow = time.Minute
+)
+
+// streamEvents streams the caller's reminder events as Server-Sent Events.
+// Each event carries an ID, so a client that reconnects with the
+// Last-Event-ID header gets the events it missed first, as far back as they
+// are kept; without it, the stream starts with the next event. An event
+// that commits after events with greater IDs were sent is still sent, with
+// the latest ID of the stream. A comment is
+// sent every heartbeat to keep proxies from closing an idle stream, and the
+// stream ends when the access token expires, for the client to reconnect
+// with a fresh one, or when the server shuts down.
+func (app *KeyKeeper) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverErrorResponse(w, r, errors.New("streaming is not supported by the response writer"))
+		return
+	}
+
+	payload := app.contextGetAuthPayload(r)
+
+	var lastID int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			app.badRequestResponse(w, r, errors.New("invalid Last-Event-ID header"))
+			return
+		}
+		lastID = id
+	}
+
+	// Subscribe before looking for events, so that none published in
+	// between are missed.
+	sub := app.Events.Subscribe(payload.UserID)
+	defer sub.Close()
+
+	cursor, err := app.startEvents(r, payload.UserID, lastID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(app.Config.EventsHeartbeat)
+	defer heartbeat.Stop()
+
+	expiry := time.NewTimer(time.Until(payload.ExpiredAt))
+	defer expiry.Stop()
+
+	err = app.writeEvents(w, r, payload.UserID, cursor)
+	if err != nil {
+		app.logStreamError(r, err)
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expiry.C:
+			return
+		case <-sub.Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case <-sub.C():
+			err = app.writeEvents(w, r, payload.UserID, cursor)
+		}
+		if err != nil {
+			app.logStreamError(r, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// logStreamError logs err unless the client has gone away, which is how
+// streams usually end.
+func (app *KeyKeeper) logStreamError(r *http.Request, err error) {
+	if r.Context().Err() == nil {
+		app.logError(r, err)
+	}
+}
+
+// An eventCursor is where a stream is in the events of its user: the
+// greatest ID it sent, and the events it sent within the catch-up window,
+// by ID, with when they were recorded.
+type eventCursor struct {
+	lastID int64
+	sent   map[int64]time.Time
+}
+
+// add records that event was sent.
+func (c *eventCursor) add(event db.UserEvent) {
+	c.sent[event.ID] = event.CreatedAt
+	if event.ID > c.lastID {
+		c.lastID = event.ID
+	}
+}
+
+// prune forgets the events recorded before since.
+func (c *eventCursor) prune(since time.Time) {
+	for id, createdAt := range c.sent {
+		if createdAt.Before(since) {
+			delete(c.sent, id)
+		}
+	}
+}
+
+// startEvents returns the cursor of a new stream of the events of userID,
+// after lastID or, when it is 0, after the latest event. The events of the
+// catch-up window up to there count as sent, as the client has seen or
+// skipped them.
+func (app *KeyKeeper) startEvents(r *http.Request, userID, lastID int64) (*eventCursor, error) {
+	if lastID == 0 {
+		id, err := app.Store.GetLatestUserEventID(r.Context(), userID)
+		if err != nil {
+			return nil, err
+		}
+		lastID = id
+	}
+
+	events, err := app.Store.ListRecentUserEvents(r.Context(), db.ListRecentUserEventsParams{
+		UserID:  userID,
+		UntilID: lastID,
+		Since:   time.Now().Add(-eventCatchUpWindow),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &eventCursor{lastID: lastID, sent: make(map[int64]time.Time)}
+	for _, event := range events {
+		c.add(event)
+	}
+
+	return c, nil
+}
+
+// writeEvents writes the events of userID that c has not sent to w: those
+// of the catch-up window that committed late, then those after c.lastID.
+func (app *KeyKeeper) writeEvents(w http.ResponseWriter, r *http.Request, userID int64, c *eventCursor) error {
+	now := time.Now()
+	c.prune(now.Add(-2 * eventCatchUpWindow))
+
+	late, err := app.Store.ListRecentUserEvents(r.Context(), db.ListRecentUserEventsParams{
+		UserID:  userID,
+		UntilID: c.lastID,
+		Since:   now.Add(-eventCatchUpWindow),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range late {
+		if _, ok := c.sent[event.ID]; ok {
+			continue
+		}
+
+		// The stream's ID stays the greatest one sent, for a client that
+		// resumes from it not to get the events after it again.
+		err = writeEvent(w, c.lastID, event)
+		if err != nil {
+			return err
+		}
+		c.add(event)
+	}
+
+	for {
+		events, err := app.Store.ListUserEvents(r.Context(), db.ListUserEventsParams{
+			UserID: userID,
+			ID:     c.lastID,
+			Limit:  eventBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			err = writeEvent(w, event.ID, event)
+			if err != nil {
+				return err
+			}
+			c.add(event)
+		}
+
+		if len(events) < eventBatchSize {
+			return nil
+		}
+	}
+}
+
+// writeEvent writes event to w with the Server-Sent Events ID id.
+func writeEvent(w http.ResponseWriter, id int64, event db.UserEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event.EventType, event.Payload)
+	return err
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/events"
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+// A sentEvent is an event read from a stream.
+type sentEvent struct {
+	ID   int64
+	Type string
+	Data webhook.Event
+}
+
+// eventStream reads the events of a streamEvents response.
+type eventStream struct {
+	t          *testing.T
+	lines      chan string
+	heartbeats int
+}
+
+// openEventStream connects to the event stream of srv with accessToken,
+// resuming after lastEventID unless it is empty.
+func openEventStream(t *testing.T, srv *httptest.Server, accessToken, lastEventID string) *eventStream {
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	return &eventStream{t: t, lines: lines}
+}
+
+func (s *eventStream) readLine() (string, bool) {
+	select {
+	case line, ok := <-s.lines:
+		return line, ok
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no line received")
+		return "", false
+	}
+}
+
+// next returns the next event, counting the heartbeats before it.
+func (s *eventStream) next() sentEvent {
+	var event sentEvent
+
+	for {
+		line, ok := s.readLine()
+		require.True(s.t, ok, "stream ended")
+
+		switch {
+		case line == "":
+			if event.ID != 0 {
+				return event
+			}
+		case line == ": heartbeat":
+			s.heartbeats++
+		case strings.HasPrefix(line, "id: "):
+			id, err := strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+			require.NoError(s.t, err)
+			event.ID = id
+		case strings.HasPrefix(line, "event: "):
+			event.Type = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(s.t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data))
+		default:
+			s.t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
+
+// requireEnded checks that the server ended the stream.
+func (s *eventStream) requireEnded() {
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			return
+		}
+		require.Contains(s.t, []string{"", ": heartbeat"}, line)
+	}
+}
+
+func newTestEventApp(t *testing.T, store *memStore) (*KeyKeeper, *httptest.Server) {
+	app := newTestApp(t, store)
+	app.Events = events.NewBroker(store, nil, time.Hour)
+	app.Config.EventsHeartbeat = 20 * time.Millisecond
+
+	srv := httptest.NewServer(app.Routes())
+	t.Cleanup(srv.Close)
+
+	return app, srv
+}
+
+// publishTestEvent publishes an event about reminder and wakes the streams
+// of its owner, as the database would.
+func publishTestEvent(t *testing.T, app *KeyKeeper, eventType string, reminder db.Reminder) {
+	err := events.Publish(context.Background(), app.Store, reminder.UserID, webhook.NewEvent(eventType, webhook.ReminderData(reminder)))
+	require.NoError(t, err)
+
+	app.Events.Wake(reminder.UserID)
+}
+
+func TestStreamEvents(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app, srv := newTestEventApp(t, store)
+	tokens := newTestSession(t, app, user.ID)
+
+	reminder := db.Reminder{ID: 1, UserID: user.ID, WebsiteUrl: "example.com", Interval: "1 mon"}
+
+	// Events published before connecting are not sent to new streams.
+	publishTestEvent(t, app, webhook.EventReminderCreated, reminder)
+
+	stream := openEventStream(t, srv, tokens.AccessToken, "")
+
+	publishTestEvent(t, app, webhook.EventReminderRotated, reminder)
+	publishTestEvent(t, app, webhook.EventReminderRotated, db.Reminder{ID: 2, UserID: other.ID})
+	publishTestEvent(t, app, webhook.EventReminderDue, reminder)
+
+	rotated := stream.next()
+	require.Equal(t, webhook.EventReminderRotated, rotated.Type)
+	require.Equal(t, webhook.EventReminderRotated, rotated.Data.Type)
+	require.Equal(t, map[string]interface{}{
+		"id":          float64(1),
+		"user_id":     float64(user.ID),
+		"website_url": "example.com",
+		"interval":    "1 mon",
+		"updated_at":  "0001-01-01T00:00:00Z",
+		"next_due_at": nil,
+	}, rotated.Data.Data.(map[string]interface{})["reminder"])
+
+	due := stream.next()
+	require.Equal(t, webhook.EventReminderDue, due.Type)
+	require.Greater(t, due.ID, rotated.ID)
+
+	// Idle streams get heartbeats.
+	time.Sleep(100 * time.Millisecond)
+	publishTestEvent(t, app, webhook.EventReminderDeleted, reminder)
+	require.Equal(t, webhook.EventReminderDeleted, stream.next().Type)
+	require.NotZero(t, stream.heartbeats)
+
+	// A client that reconnects gets the events it missed.
+	resumed := openEventStream(t, srv, tokens.AccessToken, strconv.FormatInt(rotated.ID, 10))
+	require.Equal(t, due.ID, resumed.next().ID)
+	require.Equal(t, webhook.EventReminderDeleted, resumed.next().Type)
+}
+
+func TestStreamEventsLateCommit(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app, srv := newTestEventApp(t, store)
+	tokens := newTestSession(t, app, user.ID)
+
+	reminder := db.Reminder{ID: 1, UserID: user.ID, WebsiteUrl: "example.com"}
+	stream := openEventStream(t, srv, tokens.AccessToken, "")
+
+	// An event takes its ID, but another one with a greater ID commits and
+	// is sent first.
+	store.mu.Lock()
+	lateID := store.id()
+	store.mu.Unlock()
+
+	publishTestEvent(t, app, webhook.EventReminderRotated, reminder)
+	rotated := stream.next()
+	require.Greater(t, rotated.ID, lateID)
+
+	payload, err := json.Marshal(webhook.NewEvent(webhook.EventReminderSnoozed, webhook.ReminderData(reminder)))
+	require.NoError(t, err)
+
+	store.mu.Lock()
+	store.events = append(store.events, db.UserEvent{
+		ID:        lateID,
+		UserID:    user.ID,
+		EventType: webhook.EventReminderSnoozed,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+	store.mu.Unlock()
+	app.Events.Wake(user.ID)
+
+	// The late event is sent once, with the latest ID of the stream.
+	late := stream.next()
+	require.Equal(t, webhook.EventReminderSnoozed, late.Type)
+	require.Equal(t, rotated.ID, late.ID)
+
+	publishTestEvent(t, app, webhook.EventReminderDeleted, reminder)
+	deleted := stream.next()
+	require.Equal(t, webhook.EventReminderDeleted, deleted.Type)
+	require.Greater(t, deleted.ID, rotated.ID)
+}
+
+func TestStreamEventsEnds(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+
+	// Streams end when the server shuts down.
+	app, srv := newTestEventApp(t, store)
+	tokens := newTestSession(t, app, user.ID)
+	stream := openEventStream(t, srv, tokens.AccessToken, "")
+	app.Events.CloseStreams()
+	stream.requireEnded()
+
+	// Streams end when the access token expires.
+	app, srv = newTestEventApp(t, store)
+	app.Config.AccessTokenDuration = 200 * time.Millisecond
+	tokens = newTestSession(t, app, user.ID)
+	stream = openEventStream(t, srv, tokens.AccessToken, "")
+	stream.requireEnded()
+}
+
+func TestStreamEventsErrors(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app, _ := newTestEventApp(t, store)
+
+	for _, lastEventID := range []string{"abc", "-1", "1.5"} {
+		tokens := newTestSession(t, app, user.ID)
+		req := newRequest(http.MethodGet, "/v1/events", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		req.Header.Set("Last-Event-ID", lastEventID)
+
+		rec := serveHandler(app.Routes(), req)
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	rec := serve(t, app, http.MethodGet, "/v1/events", nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
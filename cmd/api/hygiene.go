@@ -0,0 +1,83 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/hygiene"
+)
+
+// A hygieneResponse is a user's hygiene score, the factors it is made of and
+// the reminders that pull it down, worst first. Points are rounded to one
+// decimal.
+type hygieneResponse struct {
+	Score         int                      `json:"score"`
+	ReminderCount int                      `json:"reminder_count"`
+	Factors       []hygieneFactorResponse  `json:"factors"`
+	Reminders     []hygieneFindingResponse `json:"reminders"`
+}
+
+type hygieneFactorResponse struct {
+	Name    string  `json:"name"`
+	Value   float64 `json:"value"`
+	Penalty float64 `json:"penalty"`
+}
+
+type hygieneFindingResponse struct {
+	Reminder    reminderResponse `json:"reminder"`
+	Factors     []string         `json:"factors"`
+	DaysOverdue int              `json:"days_overdue"`
+	Penalty     float64          `json:"penalty"`
+}
+
+// roundPoints rounds x to one decimal.
+func roundPoints(x float64) float64 {
+	return math.Round(x*10) / 10
+}
+
+func newHygieneResponse(report hygiene.Report) hygieneResponse {
+	res := hygieneResponse{
+		Score:         report.Score,
+		ReminderCount: report.Reminders,
+		Factors:       make([]hygieneFactorResponse, len(report.Factors)),
+		Reminders:     make([]hygieneFindingResponse, len(report.Findings)),
+	}
+	for i, factor := range report.Factors {
+		res.Factors[i] = hygieneFactorResponse{
+			Name:    factor.Name,
+			Value:   math.Round(factor.Value*100) / 100,
+			Penalty: roundPoints(factor.Penalty),
+		}
+	}
+	for i, finding := range report.Findings {
+		res.Reminders[i] = hygieneFindingResponse{
+			Reminder:    newReminderResponse(finding.Reminder),
+			Factors:     finding.Factors,
+			DaysOverdue: finding.DaysOverdue,
+			Penalty:     roundPoints(finding.Penalty),
+		}
+	}
+
+	return res
+}
+
+// getHygiene returns the caller's password hygiene score. See package
+// hygiene for how it is computed.
+func (app *KeyKeeper) getHygiene(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := hygiene.Load(r.Context(), app.Store, id, time.Now())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newHygieneResponse(report), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
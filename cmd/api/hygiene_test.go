@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/hygiene"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHygiene(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/hygiene", user.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{
+		"score": 100,
+		"reminder_count": 0,
+		"factors": [
+			{"name": "overdue", "value": 0, "penalty": 0},
+			{"name": "lateness", "value": 0, "penalty": 0},
+			{"name": "long_interval", "value": 0, "penalty": 0},
+			{"name": "never_rotated", "value": 0, "penalty": 0}
+		],
+		"reminders": []
+	}`, rec.Body.String())
+
+	fresh, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "fresh.com",
+		Interval:   "P3M",
+		UpdatedAt:  time.Now(),
+	})
+	require.NoError(t, err)
+	stale, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "stale.com",
+		Interval:   "P30D",
+		UpdatedAt:  time.Now().AddDate(0, 0, -40),
+	})
+	require.NoError(t, err)
+
+	rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(fresh, "/rotated"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got hygieneResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, 2, got.ReminderCount)
+	require.Len(t, got.Factors, 4)
+	require.Equal(t, hygieneFactorResponse{Name: hygiene.FactorOverdue, Value: 0.5, Penalty: 20}, got.Factors[0])
+	require.Equal(t, 69, got.Score)
+
+	require.Len(t, got.Reminders, 1)
+	require.Equal(t, stale.ID, got.Reminders[0].Reminder.ID)
+	require.Equal(t, []string{hygiene.FactorOverdue, hygiene.FactorNeverRotated}, got.Reminders[0].Factors)
+	require.Equal(t, 10, got.Reminders[0].DaysOverdue)
+
+	other := store.addUser(t)
+	rec = serveAs(t, app, other.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+}
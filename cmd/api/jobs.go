@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+)
+
+// jobStatuses lists the statuses a job can have.
+var jobStatuses = []string{jobs.StatusQueued, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusDead}
+
+// A jobResponse is the representation of a background job in the admin
+// view.
+type jobResponse struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Args        json.RawMessage `json:"args"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until"`
+	LastError   *string         `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+}
+
+func newJobResponse(job db.Job) jobResponse {
+	res := jobResponse{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		Args:        job.Args,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		CreatedAt:   job.CreatedAt,
+	}
+	if job.LockedUntil.Valid {
+		res.LockedUntil = &job.LockedUntil.Time
+	}
+	if job.LastError.Valid {
+		res.LastError = &job.LastError.String
+	}
+	if job.FinishedAt.Valid {
+		res.FinishedAt = &job.FinishedAt.Time
+	}
+
+	return res
+}
+
+// readJob looks up the job identified by the "id" URL parameter. It writes
+// the error response and returns false when there is no such job.
+func (app *KeyKeeper) readJob(w http.ResponseWriter, r *http.Request) (db.Job, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return db.Job{}, false
+	}
+
+	job, err := app.Store.GetJob(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return db.Job{}, false
+	}
+
+	return job, true
+}
+
+// listJobs returns the jobs with an optional status and kind, newest first,
+// along with how many jobs there are of each status.
+func (app *KeyKeeper) listJobs(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	status := qs.Get("status")
+	kind := qs.Get("kind")
+	page, err := app.readInt(qs, "page", 1)
+	if err != nil {
+		v.AddError("page", "must be an integer value")
+	}
+	pageSize, err := app.readInt(qs, "page_size", defaultPageSize)
+	if err != nil {
+		v.AddError("page_size", "must be an integer value")
+	}
+
+	v.Check(status == "" || validator.PermittedValue(status, jobStatuses...), "status", "must be one of queued, running, succeeded or dead")
+	v.Check(page > 0, "page", "must be greater than zero")
+	v.Check(page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(pageSize > 0, "page_size", "must be greater than zero")
+	v.Check(pageSize <= maxPageSize, "page_size", "must be a maximum of 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	list, err := app.Store.ListJobs(r.Context(), db.ListJobsParams{
+		Status:    sql.NullString{String: status, Valid: status != ""},
+		Kind:      sql.NullString{String: kind, Valid: kind != ""},
+		RowLimit:  int32(pageSize),
+		RowOffset: int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	rows, err := app.Store.CountJobsByStatus(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	counts := make(map[string]int64, len(jobStatuses))
+	for _, s := range jobStatuses {
+		counts[s] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	data := make([]jobResponse, len(list))
+	for i, job := range list {
+		data[i] = newJobResponse(job)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data, "counts": counts}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) getJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := app.readJob(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, newJobResponse(job), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// retryJob queues a dead-lettered job again, with a fresh set of attempts.
+func (app *KeyKeeper) retryJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := app.readJob(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := app.Store.RequeueDeadJob(r.Context(), job.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.conflictResponse(w, r, "only dead jobs can be retried")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newJobResponse(job), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminJobsRequireAdmin(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	job := store.addJob("reminder.notify", jobs.StatusDead)
+
+	rec := serve(t, app, http.MethodGet, "/v1/admin/jobs", nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/admin/jobs"},
+		{http.MethodGet, fmt.Sprintf("/v1/admin/jobs/%d", job.ID)},
+		{http.MethodPost, fmt.Sprintf("/v1/admin/jobs/%d/retry", job.ID)},
+	} {
+		rec = serveAs(t, app, user.ID, route.method, route.path, nil)
+		requireErrorResponse(t, rec, http.StatusForbidden)
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	store := newMemStore()
+	admin := store.addAdmin(t)
+	app := newTestApp(t, store)
+
+	queued := store.addJob("reminder.notify", jobs.StatusQueued)
+	dead := store.addJob("reminder.notify", jobs.StatusDead)
+	other := store.addJob("cleanup", jobs.StatusDead)
+
+	list := func(query string) ([]jobResponse, map[string]int64) {
+		rec := serveAs(t, app, admin, http.MethodGet, "/v1/admin/jobs"+query, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Data   []jobResponse    `json:"data"`
+			Counts map[string]int64 `json:"counts"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+		return body.Data, body.Counts
+	}
+
+	data, counts := list("")
+	require.Len(t, data, 3)
+	require.Equal(t, other.ID, data[0].ID)
+	require.Equal(t, queued.ID, data[2].ID)
+	require.Equal(t, map[string]int64{"queued": 1, "running": 0, "succeeded": 0, "dead": 2}, counts)
+
+	data, _ = list("?status=dead&kind=reminder.notify")
+	require.Len(t, data, 1)
+	require.Equal(t, dead.ID, data[0].ID)
+
+	data, _ = list("?page=2&page_size=2")
+	require.Len(t, data, 1)
+	require.Equal(t, queued.ID, data[0].ID)
+
+	rec := serveAs(t, app, admin, http.MethodGet, "/v1/admin/jobs?status=lost", nil)
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+}
+
+func TestGetJob(t *testing.T) {
+	store := newMemStore()
+	admin := store.addAdmin(t)
+	app := newTestApp(t, store)
+	job := store.addJob("reminder.notify", jobs.StatusQueued)
+
+	rec := serveAs(t, app, admin, http.MethodGet, fmt.Sprintf("/v1/admin/jobs/%d", job.ID), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got jobResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, job.ID, got.ID)
+	require.Equal(t, "reminder.notify", got.Kind)
+	require.JSONEq(t, `{}`, string(got.Args))
+	require.Nil(t, got.LastError)
+
+	rec = serveAs(t, app, admin, http.MethodGet, "/v1/admin/jobs/999", nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
+
+func TestRetryJob(t *testing.T) {
+	store := newMemStore()
+	admin := store.addAdmin(t)
+	app := newTestApp(t, store)
+
+	dead := store.addJob("reminder.notify", jobs.StatusDead)
+	queued := store.addJob("reminder.notify", jobs.StatusQueued)
+
+	rec := serveAs(t, app, admin, http.MethodPost, fmt.Sprintf("/v1/admin/jobs/%d/retry", dead.ID), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got jobResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, jobs.StatusQueued, got.Status)
+	require.Zero(t, got.Attempts)
+
+	// Only dead jobs can be retried.
+	rec = serveAs(t, app, admin, http.MethodPost, fmt.Sprintf("/v1/admin/jobs/%d/retry", queued.ID), nil)
+	requireErrorResponse(t, rec, http.StatusConflict)
+
+	rec = serveAs(t, app, admin, http.MethodPost, "/v1/admin/jobs/999/retry", nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
@@ -0,0 +1,1335 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory db.Store for handler tests. Queries it does not
+// implement panic on the nil embedded Store.
+type memStore struct {
+	db.Store
+
+	mu        sync.Mutex
+	users     map[int64]db.User
+	reminders map[int64]db.Reminder
+	sessions  map[uuid.UUID]db.Session
+	resets    map[string]db.PasswordResetToken
+	verifies  map[string]db.EmailVerificationToken
+	contacts  map[string]db.ContactVerificationToken
+	endpoints map[int64]db.WebhookEndpoint
+	delivered []db.WebhookDelivery
+	jobs      map[int64]db.Job
+	pushSubs  map[int64]db.PushSubscription
+	feeds     map[int64]db.CalendarFeed
+	digests   map[int64]db.DigestSubscription
+	prefs     map[int64]db.UserPreference
+	actions   []db.ReminderAction
+	policies  map[int64]db.EscalationPolicy
+	overdue   map[int64]db.ReminderEscalation
+	events    []db.UserEvent
+	rotations []db.ReminderRotation
+	breaches  map[int64][]db.ListReminderBreachesRow
+	nextID    int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		users:     make(map[int64]db.User),
+		reminders: make(map[int64]db.Reminder),
+		sessions:  make(map[uuid.UUID]db.Session),
+		resets:    make(map[string]db.PasswordResetToken),
+		verifies:  make(map[string]db.EmailVerificationToken),
+		contacts:  make(map[string]db.ContactVerificationToken),
+		endpoints: make(map[int64]db.WebhookEndpoint),
+		jobs:      make(map[int64]db.Job),
+		pushSubs:  make(map[int64]db.PushSubscription),
+		feeds:     make(map[int64]db.CalendarFeed),
+		digests:   make(map[int64]db.DigestSubscription),
+		prefs:     make(map[int64]db.UserPreference),
+		policies:  make(map[int64]db.EscalationPolicy),
+		overdue:   make(map[int64]db.ReminderEscalation),
+		breaches:  make(map[int64][]db.ListReminderBreachesRow),
+	}
+}
+
+func (s *memStore) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// addUser inserts a user with a random password, bypassing any handler.
+func (s *memStore) addUser(t *testing.T) db.User {
+	return s.addUserWithPassword(t, util.RandomString(12))
+}
+
+// addUserWithPassword inserts a user with the given password.
+func (s *memStore) addUserWithPassword(t *testing.T, password string) db.User {
+	hashedPassword, err := util.HashedPassword(password)
+	require.NoError(t, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := db.User{
+		ID:              s.id(),
+		FullName:        util.RandomString(6),
+		HashedPassword:  hashedPassword,
+		Email:           util.RandomEmail(),
+		CreatedAt:       time.Now(),
+		IsActivated:     true,
+		IsEmailVerified: true,
+	}
+	s.users[user.ID] = user
+
+	return user
+}
+
+// addAdmin inserts a user with administrator rights.
+func (s *memStore) addAdmin(t *testing.T) int64 {
+	user := s.addUser(t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.IsAdmin = true
+	s.users[user.ID] = user
+
+	return user.ID
+}
+
+func (s *memStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == arg.Email {
+			return db.User{}, &pq.Error{Code: "23505"}
+		}
+	}
+
+	user := db.User{
+		ID:              s.id(),
+		FullName:        arg.FullName,
+		HashedPassword:  arg.HashedPassword,
+		Email:           arg.Email,
+		CreatedAt:       time.Now(),
+		IsActivated:     true,
+		IsEmailVerified: false,
+	}
+	s.users[user.ID] = user
+
+	return user, nil
+}
+
+func (s *memStore) GetUser(ctx context.Context, userID int64) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return db.User{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (s *memStore) DeactivateUser(ctx context.Context, arg db.DeactivateUserParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.ID]
+	if !ok || user.Email != arg.Email {
+		return db.User{}, sql.ErrNoRows
+	}
+
+	user.IsActivated = false
+	s.users[user.ID] = user
+
+	return user, nil
+}
+
+func (s *memStore) DeactivateUserTx(ctx context.Context, arg db.DeactivateUserParams) (db.User, error) {
+	user, err := s.DeactivateUser(ctx, arg)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	return user, s.BlockUserSessions(ctx, user.ID)
+}
+
+func (s *memStore) ChangePassword(ctx context.Context, arg db.ChangePasswordParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, user := range s.users {
+		if user.Email == arg.Email {
+			user.HashedPassword = arg.HashedPassword
+			user.PasswordChangedAt = arg.ChangedAt
+			s.users[id] = user
+			return user, nil
+		}
+	}
+
+	return db.User{}, sql.ErrNoRows
+}
+
+func (s *memStore) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return db.User{}, sql.ErrNoRows
+}
+
+func (s *memStore) CreateSession(ctx context.Context, arg db.CreateSessionParams) (db.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createSession(arg), nil
+}
+
+func (s *memStore) createSession(arg db.CreateSessionParams) db.Session {
+	session := db.Session{
+		ID:               arg.ID,
+		UserID:           arg.UserID,
+		RefreshTokenHash: arg.RefreshTokenHash,
+		UserAgent:        arg.UserAgent,
+		ClientIp:         arg.ClientIp,
+		IsBlocked:        arg.IsBlocked,
+		ExpiresAt:        arg.ExpiresAt,
+		CreatedAt:        time.Now(),
+		FamilyID:         arg.FamilyID,
+	}
+	s.sessions[session.ID] = session
+
+	return session
+}
+
+func (s *memStore) GetSession(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return db.Session{}, sql.ErrNoRows
+	}
+
+	return session, nil
+}
+
+func (s *memStore) BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.FamilyID == familyID {
+			session.IsBlocked = true
+			s.sessions[id] = session
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) BlockUserSessions(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			session.IsBlocked = true
+			s.sessions[id] = session
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) ListActiveSessions(ctx context.Context, userID int64) ([]db.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := []db.Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && !session.IsBlocked && !session.RotatedAt.Valid && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+
+	return sessions, nil
+}
+
+func (s *memStore) RefreshSessionTx(ctx context.Context, arg db.RefreshSessionTxParams) (db.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[arg.SessionID]
+	if !ok || session.RotatedAt.Valid || session.IsBlocked {
+		return db.Session{}, sql.ErrNoRows
+	}
+
+	session.RotatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.sessions[session.ID] = session
+
+	newSession := arg.NewSession
+	newSession.FamilyID = session.FamilyID
+
+	return s.createSession(newSession), nil
+}
+
+func (s *memStore) CreatePasswordResetToken(ctx context.Context, arg db.CreatePasswordResetTokenParams) (db.PasswordResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resetToken := db.PasswordResetToken{
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	s.resets[resetToken.TokenHash] = resetToken
+
+	return resetToken, nil
+}
+
+func (s *memStore) CountPasswordResetTokensSince(ctx context.Context, arg db.CountPasswordResetTokensSinceParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, resetToken := range s.resets {
+		if resetToken.UserID == arg.UserID && resetToken.CreatedAt.After(arg.CreatedAt) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *memStore) ResetPasswordTx(ctx context.Context, arg db.ResetPasswordTxParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resetToken, ok := s.resets[arg.TokenHash]
+	if !ok || resetToken.UsedAt.Valid || !resetToken.ExpiresAt.After(time.Now()) {
+		return db.User{}, sql.ErrNoRows
+	}
+
+	user := s.users[resetToken.UserID]
+	user.HashedPassword = arg.HashedPassword
+	user.PasswordChangedAt = arg.ChangedAt
+	s.users[user.ID] = user
+
+	for hash, rt := range s.resets {
+		if rt.UserID == user.ID && !rt.UsedAt.Valid {
+			rt.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.resets[hash] = rt
+		}
+	}
+
+	for id, session := range s.sessions {
+		if session.UserID == user.ID {
+			session.IsBlocked = true
+			s.sessions[id] = session
+		}
+	}
+
+	return user, nil
+}
+
+func (s *memStore) CreateEmailVerificationToken(ctx context.Context, arg db.CreateEmailVerificationTokenParams) (db.EmailVerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verificationToken := db.EmailVerificationToken{
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	s.verifies[verificationToken.TokenHash] = verificationToken
+
+	return verificationToken, nil
+}
+
+func (s *memStore) CountEmailVerificationTokensSince(ctx context.Context, arg db.CountEmailVerificationTokensSinceParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, verificationToken := range s.verifies {
+		if verificationToken.UserID == arg.UserID && verificationToken.CreatedAt.After(arg.CreatedAt) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *memStore) VerifyEmailTx(ctx context.Context, tokenHash string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verificationToken, ok := s.verifies[tokenHash]
+	if !ok || verificationToken.UsedAt.Valid || !verificationToken.ExpiresAt.After(time.Now()) {
+		return db.User{}, sql.ErrNoRows
+	}
+
+	user := s.users[verificationToken.UserID]
+	user.IsEmailVerified = true
+	s.users[user.ID] = user
+
+	for hash, vt := range s.verifies {
+		if vt.UserID == user.ID && !vt.UsedAt.Valid {
+			vt.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.verifies[hash] = vt
+		}
+	}
+
+	return user, nil
+}
+
+func (s *memStore) CreateReminder(ctx context.Context, arg db.CreateReminderParams) (db.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return db.Reminder{}, &pq.Error{Code: "23503"}
+	}
+
+	for _, reminder := range s.reminders {
+		if reminder.UserID == arg.UserID && arg.WebsiteDomain.Valid && reminder.WebsiteDomain == arg.WebsiteDomain {
+			return db.Reminder{}, &pq.Error{Code: "23505"}
+		}
+	}
+
+	reminder := db.Reminder{
+		ID:            s.id(),
+		UserID:        arg.UserID,
+		WebsiteUrl:    arg.WebsiteUrl,
+		Interval:      arg.Interval,
+		UpdatedAt:     arg.UpdatedAt,
+		Extension:     arg.Extension,
+		NextDueAt:     arg.NextDueAt,
+		WebsiteHost:   arg.WebsiteHost,
+		WebsiteDomain: arg.WebsiteDomain,
+	}
+	s.reminders[reminder.ID] = reminder
+
+	return reminder, nil
+}
+
+func (s *memStore) GetReminder(ctx context.Context, arg db.GetReminderParams) (db.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, ok := s.reminders[arg.ID]
+	if !ok || reminder.WebsiteUrl != arg.WebsiteUrl {
+		return db.Reminder{}, sql.ErrNoRows
+	}
+
+	return reminder, nil
+}
+
+func (s *memStore) ListReminders(ctx context.Context, arg db.ListRemindersParams) ([]db.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders := []db.Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.UserID == arg.UserID {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].ID < reminders[j].ID })
+
+	start := int(arg.Offset)
+	if start > len(reminders) {
+		start = len(reminders)
+	}
+	end := start + int(arg.Limit)
+	if end > len(reminders) {
+		end = len(reminders)
+	}
+
+	return reminders[start:end], nil
+}
+
+func (s *memStore) ListAllReminders(ctx context.Context, userID int64) ([]db.Reminder, error) {
+	return s.ListReminders(ctx, db.ListRemindersParams{UserID: userID, Limit: math.MaxInt32})
+}
+
+func (s *memStore) DeleteReminder(ctx context.Context, arg db.DeleteReminderParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, ok := s.reminders[arg.ID]
+	if ok && reminder.WebsiteUrl == arg.WebsiteUrl {
+		delete(s.reminders, arg.ID)
+	}
+
+	return nil
+}
+
+// updateReminder applies fn to a stored reminder matching id and websiteURL.
+func (s *memStore) updateReminder(id int64, websiteURL string, fn func(*db.Reminder)) (db.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, ok := s.reminders[id]
+	if !ok || reminder.WebsiteUrl != websiteURL {
+		return db.Reminder{}, sql.ErrNoRows
+	}
+
+	fn(&reminder)
+	s.reminders[id] = reminder
+
+	return reminder, nil
+}
+
+func (s *memStore) SetNewInterval(ctx context.Context, arg db.SetNewIntervalParams) (db.Reminder, error) {
+	return s.updateReminder(arg.ID, arg.WebsiteUrl, func(r *db.Reminder) {
+		r.Interval = arg.NewInterval
+		r.NextDueAt = arg.NextDueAt
+	})
+}
+
+func (s *memStore) UpdateReminder(ctx context.Context, arg db.UpdateReminderParams) (db.Reminder, error) {
+	return s.updateReminder(arg.ID, arg.WebsiteUrl, func(r *db.Reminder) {
+		r.UpdatedAt = arg.UpdatedAt
+		r.NextDueAt = arg.NextDueAt
+	})
+}
+
+func (s *memStore) RotateReminderTx(ctx context.Context, arg db.RotateReminderTxParams) (db.Reminder, error) {
+	reminder, err := s.updateReminder(arg.ID, arg.WebsiteUrl, func(r *db.Reminder) {
+		r.UpdatedAt = arg.RotatedAt
+		r.NextDueAt = arg.NextDueAt
+		delete(s.overdue, r.ID)
+		for i, breach := range s.breaches[r.ID] {
+			if !breach.ResolvedAt.Valid {
+				s.breaches[r.ID][i].ResolvedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+		}
+	})
+	if err != nil {
+		return db.Reminder{}, err
+	}
+
+	s.addReminderAction(reminder, db.ReminderActionRotated, sql.NullTime{})
+
+	_, err = s.CreateReminderRotation(ctx, db.CreateReminderRotationParams{
+		ReminderID: reminder.ID,
+		UserID:     reminder.UserID,
+		RotatedAt:  arg.RotatedAt,
+		Source:     arg.Source,
+		Note:       arg.Note,
+	})
+	if err != nil {
+		return db.Reminder{}, err
+	}
+
+	return reminder, nil
+}
+
+func (s *memStore) SnoozeReminderTx(ctx context.Context, arg db.SnoozeReminderTxParams) (db.Reminder, error) {
+	snoozedUntil := sql.NullTime{Time: arg.SnoozedUntil, Valid: true}
+
+	reminder, err := s.updateReminder(arg.ID, arg.WebsiteUrl, func(r *db.Reminder) {
+		r.NextDueAt = snoozedUntil
+	})
+	if err != nil {
+		return db.Reminder{}, err
+	}
+
+	s.addReminderAction(reminder, db.ReminderActionSnoozed, snoozedUntil)
+
+	return reminder, nil
+}
+
+// addReminderAction records an action on reminder.
+func (s *memStore) addReminderAction(reminder db.Reminder, action string, snoozedUntil sql.NullTime) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.actions = append(s.actions, db.ReminderAction{
+		ID:           s.id(),
+		ReminderID:   reminder.ID,
+		UserID:       reminder.UserID,
+		Action:       action,
+		SnoozedUntil: snoozedUntil,
+		CreatedAt:    time.Now(),
+	})
+}
+
+func (s *memStore) ListReminderActions(ctx context.Context, reminderID int64) ([]db.ReminderAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.ReminderAction{}
+	for i := len(s.actions) - 1; i >= 0; i-- {
+		if s.actions[i].ReminderID == reminderID {
+			items = append(items, s.actions[i])
+		}
+	}
+
+	return items, nil
+}
+
+func (s *memStore) SetReminderConfigs(ctx context.Context, arg db.SetReminderConfigsParams) (db.Reminder, error) {
+	return s.updateReminder(arg.ID, arg.WebsiteUrl, func(r *db.Reminder) {
+		r.Extension = arg.UpdatedExtension
+	})
+}
+
+func (s *memStore) CreateWebhookEndpoint(ctx context.Context, arg db.CreateWebhookEndpointParams) (db.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint := db.WebhookEndpoint{
+		ID:        s.id(),
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+	}
+	s.endpoints[endpoint.ID] = endpoint
+
+	return endpoint, nil
+}
+
+func (s *memStore) GetWebhookEndpoint(ctx context.Context, id int64) (db.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[id]
+	if !ok {
+		return db.WebhookEndpoint{}, sql.ErrNoRows
+	}
+
+	return endpoint, nil
+}
+
+func (s *memStore) ListWebhookEndpoints(ctx context.Context, userID int64) ([]db.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoints := []db.WebhookEndpoint{}
+	for _, endpoint := range s.endpoints {
+		if endpoint.UserID == userID {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].ID < endpoints[j].ID })
+
+	return endpoints, nil
+}
+
+func (s *memStore) DeleteWebhookEndpoint(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.endpoints, id)
+
+	deliveries := s.delivered[:0]
+	for _, delivery := range s.delivered {
+		if delivery.EndpointID != id {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	s.delivered = deliveries
+
+	return nil
+}
+
+func (s *memStore) createWebhookDelivery(arg db.CreateWebhookDeliveryParams) db.WebhookDelivery {
+	delivery := db.WebhookDelivery{
+		ID:            s.id(),
+		EndpointID:    arg.EndpointID,
+		EventID:       arg.EventID,
+		EventType:     arg.EventType,
+		Payload:       arg.Payload,
+		Status:        "pending",
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	s.delivered = append(s.delivered, delivery)
+
+	return delivery
+}
+
+func (s *memStore) CreateWebhookDelivery(ctx context.Context, arg db.CreateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createWebhookDelivery(arg), nil
+}
+
+func (s *memStore) EnqueueWebhookEvent(ctx context.Context, arg db.EnqueueWebhookEventParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, endpoint := range s.endpoints {
+		if endpoint.UserID == arg.UserID && endpoint.IsActive {
+			s.createWebhookDelivery(db.CreateWebhookDeliveryParams{
+				EndpointID: endpoint.ID,
+				EventID:    arg.EventID,
+				EventType:  arg.EventType,
+				Payload:    arg.Payload,
+			})
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := []db.WebhookDelivery{}
+	for i := len(s.delivered) - 1; i >= 0; i-- {
+		if s.delivered[i].EndpointID == arg.EndpointID {
+			deliveries = append(deliveries, s.delivered[i])
+		}
+	}
+
+	start := int(arg.Offset)
+	if start > len(deliveries) {
+		start = len(deliveries)
+	}
+	end := start + int(arg.Limit)
+	if end > len(deliveries) {
+		end = len(deliveries)
+	}
+
+	return deliveries[start:end], nil
+}
+
+// addJob inserts a job with the given kind and status.
+func (s *memStore) addJob(kind, status string) db.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := db.Job{
+		ID:          s.id(),
+		Kind:        kind,
+		Args:        []byte(`{}`),
+		Status:      status,
+		MaxAttempts: 8,
+		RunAt:       time.Now(),
+		CreatedAt:   time.Now(),
+	}
+	s.jobs[job.ID] = job
+
+	return job
+}
+
+func (s *memStore) GetJob(ctx context.Context, id int64) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return db.Job{}, sql.ErrNoRows
+	}
+
+	return job, nil
+}
+
+func (s *memStore) ListJobs(ctx context.Context, arg db.ListJobsParams) ([]db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []db.Job{}
+	for _, job := range s.jobs {
+		if arg.Status.Valid && job.Status != arg.Status.String {
+			continue
+		}
+		if arg.Kind.Valid && job.Kind != arg.Kind.String {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+
+	start := int(arg.RowOffset)
+	if start > len(jobs) {
+		start = len(jobs)
+	}
+	end := start + int(arg.RowLimit)
+	if end > len(jobs) {
+		end = len(jobs)
+	}
+
+	return jobs[start:end], nil
+}
+
+func (s *memStore) CountJobsByStatus(ctx context.Context) ([]db.CountJobsByStatusRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int64)
+	for _, job := range s.jobs {
+		counts[job.Status]++
+	}
+
+	rows := []db.CountJobsByStatusRow{}
+	for status, count := range counts {
+		rows = append(rows, db.CountJobsByStatusRow{Status: status, Count: count})
+	}
+
+	return rows, nil
+}
+
+func (s *memStore) RequeueDeadJob(ctx context.Context, id int64) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Status != "dead" {
+		return db.Job{}, sql.ErrNoRows
+	}
+
+	job.Status = "queued"
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	job.FinishedAt = sql.NullTime{}
+	s.jobs[id] = job
+
+	return job, nil
+}
+
+func (s *memStore) CreatePushSubscription(ctx context.Context, arg db.CreatePushSubscriptionParams) (db.PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := db.PushSubscription{
+		ID:        s.id(),
+		CreatedAt: time.Now(),
+	}
+	for _, existing := range s.pushSubs {
+		if existing.Endpoint == arg.Endpoint {
+			sub = existing
+		}
+	}
+
+	sub.UserID = arg.UserID
+	sub.Endpoint = arg.Endpoint
+	sub.P256dh = arg.P256dh
+	sub.Auth = arg.Auth
+	sub.UserAgent = arg.UserAgent
+	s.pushSubs[sub.ID] = sub
+
+	return sub, nil
+}
+
+func (s *memStore) GetPushSubscription(ctx context.Context, id int64) (db.PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.pushSubs[id]
+	if !ok {
+		return db.PushSubscription{}, sql.ErrNoRows
+	}
+
+	return sub, nil
+}
+
+func (s *memStore) ListPushSubscriptions(ctx context.Context, userID int64) ([]db.PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := []db.PushSubscription{}
+	for _, sub := range s.pushSubs {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+
+	return subs, nil
+}
+
+func (s *memStore) DeletePushSubscription(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pushSubs, id)
+
+	return nil
+}
+
+func (s *memStore) CreateCalendarFeed(ctx context.Context, arg db.CreateCalendarFeedParams) (db.CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed := db.CalendarFeed{
+		UserID:    arg.UserID,
+		TokenHash: arg.TokenHash,
+		CreatedAt: time.Now(),
+	}
+	s.feeds[feed.UserID] = feed
+
+	return feed, nil
+}
+
+func (s *memStore) GetCalendarFeed(ctx context.Context, userID int64) (db.CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed, ok := s.feeds[userID]
+	if !ok {
+		return db.CalendarFeed{}, sql.ErrNoRows
+	}
+
+	return feed, nil
+}
+
+func (s *memStore) GetCalendarFeedByTokenHash(ctx context.Context, tokenHash string) (db.CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, feed := range s.feeds {
+		if feed.TokenHash == tokenHash {
+			return feed, nil
+		}
+	}
+
+	return db.CalendarFeed{}, sql.ErrNoRows
+}
+
+func (s *memStore) DeleteCalendarFeed(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.feeds, userID)
+
+	return nil
+}
+
+func (s *memStore) EnqueueJob(ctx context.Context, arg db.EnqueueJobParams) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := db.Job{
+		ID:          s.id(),
+		Kind:        arg.Kind,
+		Args:        arg.Args,
+		Status:      jobs.StatusQueued,
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       arg.RunAt,
+		CreatedAt:   time.Now(),
+	}
+	s.jobs[job.ID] = job
+
+	return job, nil
+}
+
+func (s *memStore) UpsertDigestSubscription(ctx context.Context, arg db.UpsertDigestSubscriptionParams) (db.DigestSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.digests[arg.UserID]
+	if !ok {
+		subscription = db.DigestSubscription{
+			UserID:     arg.UserID,
+			LastSentAt: time.Now(),
+			CreatedAt:  time.Now(),
+		}
+	}
+	subscription.Frequency = arg.Frequency
+	subscription.NextSendAt = arg.NextSendAt
+	s.digests[arg.UserID] = subscription
+
+	return subscription, nil
+}
+
+func (s *memStore) GetDigestSubscription(ctx context.Context, userID int64) (db.DigestSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.digests[userID]
+	if !ok {
+		return db.DigestSubscription{}, sql.ErrNoRows
+	}
+
+	return subscription, nil
+}
+
+func (s *memStore) DeleteDigestSubscription(ctx context.Context, userID int64) (db.DigestSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.digests[userID]
+	if !ok {
+		return db.DigestSubscription{}, sql.ErrNoRows
+	}
+	delete(s.digests, userID)
+
+	return subscription, nil
+}
+
+func (s *memStore) GetUserPreferences(ctx context.Context, userID int64) (db.UserPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, ok := s.prefs[userID]
+	if !ok {
+		return db.UserPreference{}, sql.ErrNoRows
+	}
+
+	return prefs, nil
+}
+
+func (s *memStore) UpsertUserPreferences(ctx context.Context, arg db.UpsertUserPreferencesParams) (db.UserPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs := db.UserPreference{
+		UserID:          arg.UserID,
+		TimeZone:        arg.TimeZone,
+		QuietHoursStart: arg.QuietHoursStart,
+		QuietHoursEnd:   arg.QuietHoursEnd,
+		UpdatedAt:       time.Now(),
+	}
+	s.prefs[arg.UserID] = prefs
+
+	return prefs, nil
+}
+
+func (s *memStore) GetEscalationPolicy(ctx context.Context, userID int64) (db.EscalationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, ok := s.policies[userID]
+	if !ok {
+		return db.EscalationPolicy{}, sql.ErrNoRows
+	}
+
+	return policy, nil
+}
+
+func (s *memStore) UpsertEscalationPolicy(ctx context.Context, arg db.UpsertEscalationPolicyParams) (db.EscalationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy := db.EscalationPolicy{
+		UserID:               arg.UserID,
+		AfterNotifications:   arg.AfterNotifications,
+		AfterDays:            arg.AfterDays,
+		RepeatHours:          arg.RepeatHours,
+		EscalatedRepeatHours: arg.EscalatedRepeatHours,
+		Channels:             arg.Channels,
+		ContactEmail:         arg.ContactEmail,
+		UpdatedAt:            time.Now(),
+	}
+	if current, ok := s.policies[arg.UserID]; ok && current.ContactEmail.Valid && current.ContactEmail == arg.ContactEmail {
+		policy.ContactVerifiedAt = current.ContactVerifiedAt
+	}
+	s.policies[arg.UserID] = policy
+
+	return policy, nil
+}
+
+func (s *memStore) CreateContactVerificationToken(ctx context.Context, arg db.CreateContactVerificationTokenParams) (db.ContactVerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verificationToken := db.ContactVerificationToken{
+		TokenHash:    arg.TokenHash,
+		UserID:       arg.UserID,
+		ContactEmail: arg.ContactEmail,
+		ExpiresAt:    arg.ExpiresAt,
+		CreatedAt:    time.Now(),
+	}
+	s.contacts[verificationToken.TokenHash] = verificationToken
+
+	return verificationToken, nil
+}
+
+func (s *memStore) CountContactVerificationTokensSince(ctx context.Context, arg db.CountContactVerificationTokensSinceParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, verificationToken := range s.contacts {
+		if verificationToken.UserID == arg.UserID && verificationToken.CreatedAt.After(arg.CreatedAt) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *memStore) VerifyContactTx(ctx context.Context, tokenHash string) (db.EscalationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verificationToken, ok := s.contacts[tokenHash]
+	if !ok || verificationToken.UsedAt.Valid || !verificationToken.ExpiresAt.After(time.Now()) {
+		return db.EscalationPolicy{}, sql.ErrNoRows
+	}
+
+	policy, ok := s.policies[verificationToken.UserID]
+	if !ok || policy.ContactEmail.String != verificationToken.ContactEmail {
+		return db.EscalationPolicy{}, sql.ErrNoRows
+	}
+
+	verificationToken.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.contacts[tokenHash] = verificationToken
+
+	policy.ContactVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.policies[policy.UserID] = policy
+
+	return policy, nil
+}
+
+func (s *memStore) DeleteEscalationPolicy(ctx context.Context, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[userID]; !ok {
+		return 0, nil
+	}
+	delete(s.policies, userID)
+
+	return 1, nil
+}
+
+func (s *memStore) GetReminderEscalation(ctx context.Context, reminderID int64) (db.ReminderEscalation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	escalation, ok := s.overdue[reminderID]
+	if !ok {
+		return db.ReminderEscalation{}, sql.ErrNoRows
+	}
+
+	return escalation, nil
+}
+
+func (s *memStore) CreateUserEvent(ctx context.Context, arg db.CreateUserEventParams) (db.UserEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := db.UserEvent{
+		ID:        s.id(),
+		UserID:    arg.UserID,
+		EventType: arg.EventType,
+		Payload:   arg.Payload,
+		CreatedAt: time.Now(),
+	}
+	s.events = append(s.events, event)
+
+	return event, nil
+}
+
+func (s *memStore) GetLatestUserEventID(ctx context.Context, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var id int64
+	for _, event := range s.events {
+		if event.UserID == userID {
+			id = event.ID
+		}
+	}
+
+	return id, nil
+}
+
+func (s *memStore) ListRecentUserEvents(ctx context.Context, arg db.ListRecentUserEventsParams) ([]db.UserEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.UserEvent{}
+	for _, event := range s.events {
+		if event.UserID == arg.UserID && event.ID <= arg.UntilID && !event.CreatedAt.Before(arg.Since) {
+			items = append(items, event)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return items, nil
+}
+
+func (s *memStore) ListUserEvents(ctx context.Context, arg db.ListUserEventsParams) ([]db.UserEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.UserEvent{}
+	for _, event := range s.events {
+		if len(items) == int(arg.Limit) {
+			break
+		}
+		if event.UserID == arg.UserID && event.ID > arg.ID {
+			items = append(items, event)
+		}
+	}
+
+	return items, nil
+}
+
+func (s *memStore) CreateReminderRotation(ctx context.Context, arg db.CreateReminderRotationParams) (db.ReminderRotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotation := db.ReminderRotation{
+		ID:         s.id(),
+		ReminderID: arg.ReminderID,
+		UserID:     arg.UserID,
+		RotatedAt:  arg.RotatedAt,
+		Source:     arg.Source,
+		Note:       arg.Note,
+		CreatedAt:  time.Now(),
+	}
+	s.rotations = append(s.rotations, rotation)
+
+	return rotation, nil
+}
+
+func (s *memStore) ListReminderRotations(ctx context.Context, arg db.ListReminderRotationsParams) ([]db.ReminderRotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.ReminderRotation{}
+	for i := len(s.rotations) - 1; i >= 0; i-- {
+		if s.rotations[i].ReminderID == arg.ReminderID {
+			items = append(items, s.rotations[i])
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].RotatedAt.After(items[j].RotatedAt)
+	})
+
+	if int(arg.Offset) >= len(items) {
+		return []db.ReminderRotation{}, nil
+	}
+	items = items[arg.Offset:]
+	if len(items) > int(arg.Limit) {
+		items = items[:arg.Limit]
+	}
+
+	return items, nil
+}
+
+func (s *memStore) ListRotatedReminderIDs(ctx context.Context, userID int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int64]bool)
+	items := []int64{}
+	for _, rotation := range s.rotations {
+		if rotation.UserID == userID && !seen[rotation.ReminderID] {
+			seen[rotation.ReminderID] = true
+			items = append(items, rotation.ReminderID)
+		}
+	}
+
+	return items, nil
+}
+
+func (s *memStore) ListReminderBreaches(ctx context.Context, reminderID int64) ([]db.ListReminderBreachesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]db.ListReminderBreachesRow{}, s.breaches[reminderID]...), nil
+}
+
+// newTestApp creates a KeyKeeper backed by store.
+func newTestApp(t *testing.T, store db.Store) *KeyKeeper {
+	tokenMaker, err := token.NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	return &KeyKeeper{
+		Config: util.Configs{
+			AccessTokenDuration:  15 * time.Minute,
+			SessionTokenDuration: 24 * time.Hour,
+		},
+		Store:      store,
+		TokenMaker: tokenMaker,
+	}
+}
+
+// newRequest creates a request with an optional JSON body.
+func newRequest(method, target string, body []byte) *http.Request {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req
+}
+
+// serveHandler records the response of handler to req.
+func serveHandler(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// serve sends an unauthenticated request through the app routes and returns
+// the recorded response.
+func serve(t *testing.T, app *KeyKeeper, method, target string, body []byte) *httptest.ResponseRecorder {
+	return serveHandler(app.Routes(), newRequest(method, target, body))
+}
+
+// newTestSession creates a session for userID, as a login would, and
+// returns its tokens.
+func newTestSession(t *testing.T, app *KeyKeeper, userID int64) sessionTokens {
+	tokens, arg, err := app.newSessionTokens(newRequest(http.MethodPost, "/v1/auth/login", nil), userID, uuid.Nil)
+	require.NoError(t, err)
+
+	_, err = app.Store.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+
+	return tokens
+}
+
+// serveWithToken sends a request authenticated with accessToken.
+func serveWithToken(t *testing.T, app *KeyKeeper, accessToken, method, target string, body []byte) *httptest.ResponseRecorder {
+	req := newRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	return serveHandler(app.Routes(), req)
+}
+
+// serveAs sends a request authenticated with the access token of a new
+// session for userID.
+func serveAs(t *testing.T, app *KeyKeeper, userID int64, method, target string, body []byte) *httptest.ResponseRecorder {
+	tokens := newTestSession(t, app, userID)
+	return serveWithToken(t, app, tokens.AccessToken, method, target, body)
+}
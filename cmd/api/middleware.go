@@ -0,0 +1,89 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
+)
+
+// requireAuthentication rejects requests without a valid
+// "Authorization: Bearer <access token>" header, or whose token belongs to a
+// blocked session or a deactivated user or was issued before the user's last
+// password change, and stores the token payload in the request context for
+// next.
+func (app *KeyKeeper) requireAuthentication(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		headerParts := strings.Fields(authorizationHeader)
+		if len(headerParts) != 2 || !strings.EqualFold(headerParts[0], "Bearer") {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		payload, err := app.TokenMaker.VerifyToken(headerParts[1])
+		if err != nil || payload.Type != token.TypeAccess {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		session, err := app.Store.GetSession(r.Context(), payload.SessionID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if session.IsBlocked || session.UserID != payload.UserID {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		revoked, err := app.tokenRevoked(r, payload)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		r = app.contextSetAuthPayload(r, payload)
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// requireAdmin is requireAuthentication for routes that only administrators
+// may use.
+func (app *KeyKeeper) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAuthentication(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.Store.GetUser(r.Context(), app.contextGetAuthPayload(r).UserID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !user.IsAdmin {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAuthentication(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	session := newTestSession(t, app, user.ID)
+	validToken := session.AccessToken
+
+	expiredToken, _, err := app.TokenMaker.CreateToken(token.TypeAccess, -time.Minute, user.ID, session.SessionID)
+	require.NoError(t, err)
+
+	// A token whose session does not exist.
+	orphanToken, _, err := app.TokenMaker.CreateToken(token.TypeAccess, time.Minute, user.ID, uuid.New())
+	require.NoError(t, err)
+
+	// A token whose session was blocked, e.g. by a logout.
+	blocked := newTestSession(t, app, user.ID)
+	require.NoError(t, store.BlockSessionFamily(context.Background(), blocked.SessionID))
+
+	// A token issued before the user's last password change.
+	staleToken, _, err := app.TokenMaker.CreateToken(token.TypeAccess, time.Minute, user.ID, session.SessionID)
+	require.NoError(t, err)
+	user.PasswordChangedAt = time.Now()
+	store.users[user.ID] = user
+
+	fresh := newTestSession(t, app, user.ID)
+	validToken = fresh.AccessToken
+
+	testCases := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{name: "OK", authorization: "Bearer " + validToken, status: http.StatusOK},
+		{name: "NoHeader", authorization: "", status: http.StatusUnauthorized},
+		{name: "UnsupportedScheme", authorization: "Basic " + validToken, status: http.StatusUnauthorized},
+		{name: "MalformedHeader", authorization: validToken, status: http.StatusUnauthorized},
+		{name: "InvalidToken", authorization: "Bearer invalid", status: http.StatusUnauthorized},
+		{name: "ExpiredToken", authorization: "Bearer " + expiredToken, status: http.StatusUnauthorized},
+		{name: "NoSession", authorization: "Bearer " + orphanToken, status: http.StatusUnauthorized},
+		{name: "BlockedSession", authorization: "Bearer " + blocked.AccessToken, status: http.StatusUnauthorized},
+		{name: "IssuedBeforePasswordChange", authorization: "Bearer " + staleToken, status: http.StatusUnauthorized},
+		{name: "RefreshToken", authorization: "Bearer " + fresh.RefreshToken, status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var called bool
+			handler := app.requireAuthentication(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				require.Equal(t, user.ID, app.contextGetAuthPayload(r).UserID)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := newRequest(http.MethodGet, "/v1/reminders", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			rec := serveHandler(handler, req)
+			require.Equal(t, tc.status, rec.Code)
+			require.Equal(t, tc.status == http.StatusOK, called)
+
+			if tc.status == http.StatusUnauthorized {
+				require.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+				requireErrorResponse(t, rec, tc.status)
+			}
+		})
+	}
+}
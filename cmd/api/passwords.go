@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+)
+
+const (
+	// passwordResetTokenDuration is how long a password reset token stays
+	// valid.
+	passwordResetTokenDuration = 30 * time.Minute
+
+	// At most passwordResetEmailLimit password reset emails are sent to a
+	// user per passwordResetEmailWindow. Further requests get the same
+	// response but no email, so that the limit does not tell who has an
+	// account.
+	passwordResetEmailLimit  = 3
+	passwordResetEmailWindow = time.Hour
+)
+
+// sendPasswordResetEmail creates a password reset token for the activated
+// account of email, if there is one, and emails it, unless the account was
+// already sent passwordResetEmailLimit of them in the last
+// passwordResetEmailWindow.
+func (app *KeyKeeper) sendPasswordResetEmail(ctx context.Context, email string) error {
+	user, err := app.Store.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if !user.IsActivated {
+		return nil
+	}
+
+	sent, err := app.Store.CountPasswordResetTokensSince(ctx, db.CountPasswordResetTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-passwordResetEmailWindow),
+	})
+	if err != nil {
+		return err
+	}
+
+	if sent >= passwordResetEmailLimit {
+		return nil
+	}
+
+	resetToken, err := util.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = app.Store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		TokenHash: util.HashToken(resetToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"FullName":  user.FullName,
+		"Token":     resetToken,
+		"ExpiresIn": "30 minutes",
+	}
+
+	err = app.Mailer.Send(user.Email, "password_reset.tmpl", data)
+	if err != nil {
+		return fmt.Errorf("user %d: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+func (app *KeyKeeper) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validateEmail(v, input.Email)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// The response is the same whether or not the email is registered, and
+	// the account is looked up in the background, so that neither what the
+	// response says nor how long it takes tells who has an account.
+	app.background(func() {
+		err := app.sendPasswordResetEmail(context.Background(), input.Email)
+		if err != nil {
+			log.Printf("failed to send password reset email: %v", err)
+		}
+	})
+
+	res := envelope{"message": "if an account with this email address exists, a password reset token has been sent to it"}
+
+	err = app.writeJSON(w, http.StatusAccepted, res, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Token != "", "token", "must be provided")
+	v.Check(len(input.Token) == 26, "token", "must be 26 bytes long")
+	validateNewPassword(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	hashedPassword, err := util.HashedPassword(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.Store.ResetPasswordTx(r.Context(), db.ResetPasswordTxParams{
+		TokenHash:      util.HashToken(input.Token),
+		HashedPassword: hashedPassword,
+		ChangedAt:      time.Now(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer/smtptest"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+var mailedTokenRX = regexp.MustCompile(`\b[A-Z2-7]{26}\b`)
+
+// newMailerTestApp creates a KeyKeeper whose mailer delivers to an
+// in-process SMTP server.
+func newMailerTestApp(t *testing.T, store db.Store) (*KeyKeeper, *smtptest.Server) {
+	server, err := smtptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	app := newTestApp(t, store)
+	app.Mailer = mailer.NewSMTPMailer(server.Host, server.Port, "", "", "KeyKeeper <no-reply@keykeeper.test>")
+
+	return app, server
+}
+
+// receivedToken waits for the app's background tasks and returns the token
+// mailed to email, or "" when nothing was sent.
+func receivedToken(t *testing.T, app *KeyKeeper, server *smtptest.Server, email string) string {
+	app.wg.Wait()
+
+	select {
+	case msg := <-server.Received():
+		require.Equal(t, []string{email}, msg.To)
+
+		plain, err := msg.Body("text/plain")
+		require.NoError(t, err)
+
+		mailedToken := mailedTokenRX.FindString(plain)
+		require.NotEmpty(t, mailedToken)
+		return mailedToken
+	case <-time.After(100 * time.Millisecond):
+		return ""
+	}
+}
+
+// requestResetToken asks for a reset token for email and returns the token
+// that was mailed, if any.
+func requestResetToken(t *testing.T, app *KeyKeeper, server *smtptest.Server, email string) string {
+	body := fmt.Sprintf(`{"email": %q}`, email)
+	rec := serve(t, app, http.MethodPost, "/v1/auth/password-reset", []byte(body))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	return receivedToken(t, app, server, email)
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+
+	inactive := store.addUser(t)
+	inactive.IsActivated = false
+	store.users[inactive.ID] = inactive
+
+	app, server := newMailerTestApp(t, store)
+
+	resetToken := requestResetToken(t, app, server, user.Email)
+	require.NotEmpty(t, resetToken)
+
+	// Only the token's hash is stored.
+	stored, ok := store.resets[util.HashToken(resetToken)]
+	require.True(t, ok)
+	require.Equal(t, user.ID, stored.UserID)
+	require.WithinDuration(t, time.Now().Add(passwordResetTokenDuration), stored.ExpiresAt, time.Minute)
+
+	// Unknown and deactivated accounts get the same response but no email.
+	require.Empty(t, requestResetToken(t, app, server, util.RandomEmail()))
+	require.Empty(t, requestResetToken(t, app, server, inactive.Email))
+	require.Len(t, store.resets, 1)
+
+	rec := serve(t, app, http.MethodPost, "/v1/auth/password-reset", []byte(`{"email": "not-an-email"}`))
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+}
+
+func TestRequestPasswordResetLimit(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app, server := newMailerTestApp(t, store)
+
+	for i := 0; i < passwordResetEmailLimit; i++ {
+		require.NotEmpty(t, requestResetToken(t, app, server, user.Email))
+	}
+
+	// Further requests within the window get the same response without an
+	// email.
+	require.Empty(t, requestResetToken(t, app, server, user.Email))
+	require.Len(t, store.resets, passwordResetEmailLimit)
+
+	// Tokens older than the window no longer count.
+	for hash, resetToken := range store.resets {
+		resetToken.CreatedAt = time.Now().Add(-passwordResetEmailWindow)
+		store.resets[hash] = resetToken
+	}
+
+	require.NotEmpty(t, requestResetToken(t, app, server, user.Email))
+}
+
+// lookupBlockingStore is a memStore whose email lookups wait for release.
+type lookupBlockingStore struct {
+	*memStore
+	release chan struct{}
+}
+
+func (s *lookupBlockingStore) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	<-s.release
+	return s.memStore.GetUserByEmail(ctx, email)
+}
+
+func TestRequestPasswordResetInBackground(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+
+	blocking := &lookupBlockingStore{memStore: store, release: make(chan struct{})}
+	app, server := newMailerTestApp(t, blocking)
+
+	// The response does not wait for the account to be looked up, so it
+	// takes as long for unknown addresses as for registered ones.
+	body := fmt.Sprintf(`{"email": %q}`, user.Email)
+	rec := serve(t, app, http.MethodPost, "/v1/auth/password-reset", []byte(body))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	close(blocking.release)
+	require.NotEmpty(t, receivedToken(t, app, server, user.Email))
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	store := newMemStore()
+	oldPassword := util.RandomString(12)
+	user := store.addUserWithPassword(t, oldPassword)
+
+	app, server := newMailerTestApp(t, store)
+
+	res := login(t, app, user.Email, oldPassword)
+
+	first := requestResetToken(t, app, server, user.Email)
+	second := requestResetToken(t, app, server, user.Email)
+	require.NotEqual(t, first, second)
+
+	newPassword := util.RandomString(12)
+	body := fmt.Sprintf(`{"token": %q, "password": %q}`, second, newPassword)
+	rec := serve(t, app, http.MethodPut, "/v1/auth/password-reset", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The new password works, the old one does not and existing sessions
+	// are signed out.
+	require.NoError(t, util.VerifyPassword(store.users[user.ID].HashedPassword, newPassword))
+	require.Error(t, util.VerifyPassword(store.users[user.ID].HashedPassword, oldPassword))
+	require.True(t, store.sessions[res.SessionID].IsBlocked)
+
+	// The token is single-use and the user's other tokens are expired too.
+	for _, resetToken := range []string{second, first} {
+		body := fmt.Sprintf(`{"token": %q, "password": %q}`, resetToken, util.RandomString(12))
+		rec := serve(t, app, http.MethodPut, "/v1/auth/password-reset", []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+	require.NoError(t, util.VerifyPassword(store.users[user.ID].HashedPassword, newPassword))
+}
+
+func TestConfirmPasswordResetInvalid(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+
+	expired, err := util.GenerateToken()
+	require.NoError(t, err)
+	store.resets[util.HashToken(expired)] = db.PasswordResetToken{
+		TokenHash: util.HashToken(expired),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+
+	unknown, err := util.GenerateToken()
+	require.NoError(t, err)
+
+	app := newTestApp(t, store)
+
+	testCases := []struct {
+		name     string
+		token    string
+		password string
+	}{
+		{name: "Expired", token: expired, password: util.RandomString(12)},
+		{name: "Unknown", token: unknown, password: util.RandomString(12)},
+		{name: "MalformedToken", token: "abc", password: util.RandomString(12)},
+		{name: "ShortPassword", token: unknown, password: "short"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"token": %q, "password": %q}`, tc.token, tc.password)
+			rec := serve(t, app, http.MethodPut, "/v1/auth/password-reset", []byte(body))
+			requireErrorResponse(t, rec, http.StatusBadRequest)
+		})
+	}
+
+	require.Equal(t, user.HashedPassword, store.users[user.ID].HashedPassword)
+}
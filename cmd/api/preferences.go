@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/digest"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+)
+
+// A quietHoursResponse is the representation of quiet hours, as "HH:MM"
+// wall clock times in the user's time zone.
+type quietHoursResponse struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// A preferencesResponse is the representation of a user's preferences.
+type preferencesResponse struct {
+	TimeZone   string              `json:"time_zone"`
+	QuietHours *quietHoursResponse `json:"quiet_hours"`
+}
+
+func newPreferencesResponse(prefs preferences.Preferences) preferencesResponse {
+	res := preferencesResponse{TimeZone: prefs.Location.String()}
+	if prefs.QuietHours != nil {
+		res.QuietHours = &quietHoursResponse{
+			Start: prefs.QuietHours.Start.String(),
+			End:   prefs.QuietHours.End.String(),
+		}
+	}
+
+	return res
+}
+
+func (app *KeyKeeper) getPreferences(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newPreferencesResponse(prefs), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updatePreferences replaces the caller's preferences. Reminders that are
+// scheduled, and the digest, are rescheduled in the new time zone and
+// around the new quiet hours.
+func (app *KeyKeeper) updatePreferences(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		TimeZone   string              `json:"time_zone"`
+		QuietHours *quietHoursResponse `json:"quiet_hours"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.TimeZone != "", "time_zone", "must be provided")
+	loc, err := preferences.LoadLocation(input.TimeZone)
+	v.Check(err == nil, "time_zone", "must be an IANA time zone, such as Europe/Berlin")
+
+	arg := db.UpsertUserPreferencesParams{
+		UserID:   id,
+		TimeZone: input.TimeZone,
+	}
+
+	if input.QuietHours != nil {
+		start, err := preferences.ParseClock(input.QuietHours.Start)
+		v.Check(err == nil, "quiet_hours.start", "must be a time of day as HH:MM")
+		end, err := preferences.ParseClock(input.QuietHours.End)
+		v.Check(err == nil, "quiet_hours.end", "must be a time of day as HH:MM")
+		v.Check(start != end || !v.Valid(), "quiet_hours.end", "must differ from the start")
+
+		arg.QuietHoursStart = sql.NullInt32{Int32: int32(start), Valid: true}
+		arg.QuietHoursEnd = sql.NullInt32{Int32: int32(end), Valid: true}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	old, err := preferences.Load(r.Context(), app.Store, id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	row, err := app.Store.UpsertUserPreferences(r.Context(), arg)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	prefs, err := preferences.New(row)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if old.Location.String() != loc.String() {
+		err = app.rescheduleReminders(r.Context(), id, loc)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.rescheduleDigest(r.Context(), id, prefs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newPreferencesResponse(prefs), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// rescheduleReminders moves the due dates of the scheduled reminders of
+// userID to the same calendar days in loc. Reminders that were already
+// notified stay as they are, and so do snoozes that defer a reminder past
+// its new due date.
+func (app *KeyKeeper) rescheduleReminders(ctx context.Context, userID int64, loc *time.Location) error {
+	reminders, err := app.Store.ListAllReminders(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, reminder := range reminders {
+		if !reminder.NextDueAt.Valid {
+			continue
+		}
+
+		due, err := app.rescheduledDueAt(ctx, reminder, reminder.Interval, loc)
+		if err != nil {
+			return fmt.Errorf("reschedule reminder %d: %w", reminder.ID, err)
+		}
+
+		_, err = app.Store.UpdateReminder(ctx, db.UpdateReminderParams{
+			UpdatedAt:  reminder.UpdatedAt,
+			NextDueAt:  due,
+			ID:         reminder.ID,
+			WebsiteUrl: reminder.WebsiteUrl,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reschedule reminder %d: %w", reminder.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// rescheduleDigest moves the next digest of userID, if any, to the send
+// time under prefs.
+func (app *KeyKeeper) rescheduleDigest(ctx context.Context, userID int64, prefs preferences.Preferences) error {
+	subscription, err := app.Store.GetDigestSubscription(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = app.Store.UpsertDigestSubscription(ctx, db.UpsertDigestSubscriptionParams{
+		UserID:     userID,
+		Frequency:  subscription.Frequency,
+		NextSendAt: digest.Next(subscription.Frequency, time.Now(), prefs),
+	})
+	return err
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferences(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/preferences", user.ID)
+
+	// Users start out in UTC without quiet hours.
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"time_zone": "UTC", "quiet_hours": null}`, rec.Body.String())
+
+	body := `{"time_zone": "Europe/Berlin", "quiet_hours": {"start": "22:00", "end": "07:00"}}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, body, rec.Body.String())
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, body, rec.Body.String())
+
+	body = `{"time_zone": "Asia/Tokyo", "quiet_hours": null}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, body, rec.Body.String())
+	require.False(t, store.prefs[user.ID].QuietHoursStart.Valid)
+}
+
+func TestUpdatePreferencesReschedules(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/preferences", user.ID)
+
+	updatedAt := time.Date(2023, time.May, 10, 23, 30, 0, 0, time.UTC)
+	reminder, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "example.com",
+		Interval:   "1 month",
+		UpdatedAt:  updatedAt,
+		NextDueAt:  sql.NullTime{Time: time.Date(2023, time.June, 10, 23, 30, 0, 0, time.UTC), Valid: true},
+	})
+	require.NoError(t, err)
+
+	// A reminder that was already notified is not scheduled again.
+	notified, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "example.org",
+		Interval:   "1 month",
+		UpdatedAt:  updatedAt,
+	})
+	require.NoError(t, err)
+
+	updateTestDigest(t, app, user.ID, digest.FrequencyDaily)
+
+	body := `{"time_zone": "Asia/Tokyo", "quiet_hours": {"start": "07:00", "end": "09:00"}}`
+	rec := serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// 23:30 UTC on 10 May is 08:30 on 11 May in Tokyo, so the reminder is
+	// due a month later on 11 June, Tokyo time.
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	got := store.reminders[reminder.ID]
+	require.True(t, got.UpdatedAt.Equal(updatedAt))
+	require.True(t, got.NextDueAt.Valid)
+	require.True(t, time.Date(2023, time.June, 11, 8, 30, 0, 0, tokyo).Equal(got.NextDueAt.Time), got.NextDueAt.Time)
+
+	require.False(t, store.reminders[notified.ID].NextDueAt.Valid)
+
+	// The digest moves out of the quiet hours, which cover its send hour.
+	next := store.digests[user.ID].NextSendAt.In(tokyo)
+	require.Equal(t, 9, next.Hour())
+	require.Equal(t, 0, next.Minute())
+}
+
+func TestUpdatePreferencesKeepsSnoozes(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	updatedAt := time.Now().AddDate(0, -1, 1)
+	reminder, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "example.com",
+		Interval:   "P1M",
+		UpdatedAt:  updatedAt,
+		NextDueAt:  sql.NullTime{Time: updatedAt.AddDate(0, 1, 0), Valid: true},
+	})
+	require.NoError(t, err)
+
+	rec := serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/snooze"), []byte(`{"days": 5}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	snoozed := store.reminders[reminder.ID].NextDueAt
+	require.True(t, snoozed.Time.After(updatedAt.AddDate(0, 1, 1)))
+
+	// Changing the time zone moves the due date by hours, which the snooze
+	// still defers.
+	body := `{"time_zone": "Asia/Tokyo"}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, fmt.Sprintf("/v1/users/%d/preferences", user.ID), []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, snoozed, store.reminders[reminder.ID].NextDueAt)
+
+	// Once the reminder is rotated, the snooze no longer applies.
+	rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/rotated"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body = `{"time_zone": "America/New_York"}`
+	rec = serveAs(t, app, user.ID, http.MethodPut, fmt.Sprintf("/v1/users/%d/preferences", user.ID), []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rotated := store.reminders[reminder.ID]
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	require.True(t, rotated.UpdatedAt.In(newYork).AddDate(0, 1, 0).Equal(rotated.NextDueAt.Time))
+}
+
+func TestUpdatePreferencesValidation(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/preferences", user.ID)
+
+	for _, body := range []string{
+		`{}`,
+		`{"time_zone": "Mars/Olympus_Mons"}`,
+		`{"time_zone": "Local"}`,
+		`{"time_zone": "UTC", "quiet_hours": {"start": "22:00"}}`,
+		`{"time_zone": "UTC", "quiet_hours": {"start": "10pm", "end": "7am"}}`,
+		`{"time_zone": "UTC", "quiet_hours": {"start": "22:00", "end": "22:00"}}`,
+		`{"time_zone": "UTC", "locale": "en"}`,
+	} {
+		rec := serveAs(t, app, user.ID, http.MethodPut, path, []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	require.Empty(t, store.prefs)
+}
+
+func TestPreferencesOtherUser(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+	path := fmt.Sprintf("/v1/users/%d/preferences", other.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serveAs(t, app, user.ID, http.MethodPut, path, []byte(`{"time_zone": "UTC"}`))
+	requireErrorResponse(t, rec, http.StatusForbidden)
+	require.Empty(t, store.prefs)
+
+	rec = serve(t, app, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
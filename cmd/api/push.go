@@ -0,0 +1,182 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+)
+
+// A pushSubscriptionResponse is the public representation of a Web Push
+// subscription. Its keys are never returned.
+type pushSubscriptionResponse struct {
+	ID        int64     `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newPushSubscriptionResponse(sub db.PushSubscription) pushSubscriptionResponse {
+	return pushSubscriptionResponse{
+		ID:        sub.ID,
+		Endpoint:  sub.Endpoint,
+		UserAgent: sub.UserAgent,
+		CreatedAt: sub.CreatedAt,
+	}
+}
+
+// validatePushSubscription checks a subscription as sent by a browser.
+// Push services are only reachable over HTTPS.
+func validatePushSubscription(v *validator.Validator, sub webpush.Subscription) {
+	if sub.Endpoint == "" {
+		v.AddError("endpoint", "must be provided")
+	} else if len(sub.Endpoint) > 2048 {
+		v.AddError("endpoint", "must not be more than 2048 bytes long")
+	} else {
+		u, err := url.Parse(sub.Endpoint)
+		v.Check(err == nil && u.Scheme == "https" && u.Host != "", "endpoint", "must be an absolute https URL")
+	}
+
+	if sub.P256dh == "" || sub.Auth == "" {
+		v.AddError("keys", "must contain p256dh and auth")
+		return
+	}
+
+	// Once the endpoint is known to be valid, only the keys can fail.
+	if v.Valid() {
+		v.Check(sub.Validate() == nil, "keys", "must be a valid p256dh key and auth secret")
+	}
+}
+
+// readOwnedPushSubscription looks up the push subscription identified by
+// the "id" URL parameter and checks that it belongs to the authenticated
+// caller. It writes the error response and returns false when the
+// subscription cannot be used.
+func (app *KeyKeeper) readOwnedPushSubscription(w http.ResponseWriter, r *http.Request) (db.PushSubscription, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return db.PushSubscription{}, false
+	}
+
+	sub, err := app.Store.GetPushSubscription(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return db.PushSubscription{}, false
+	}
+
+	if sub.UserID != app.contextGetAuthPayload(r).UserID {
+		app.notFoundResponse(w, r)
+		return db.PushSubscription{}, false
+	}
+
+	return sub, true
+}
+
+// getVAPIDPublicKey returns the key browsers must pass to
+// PushManager.subscribe as the applicationServerKey.
+func (app *KeyKeeper) getVAPIDPublicKey(w http.ResponseWriter, r *http.Request) {
+	if app.Config.VAPIDPublicKey == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"public_key": app.Config.VAPIDPublicKey}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createPushSubscription stores the JSON form of a browser's
+// PushSubscription. Subscribing an endpoint again replaces its keys, and
+// moves it to the caller if another user had subscribed it.
+func (app *KeyKeeper) createPushSubscription(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Endpoint       string `json:"endpoint"`
+		ExpirationTime *int64 `json:"expirationTime"`
+		Keys           struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sub := webpush.Subscription{
+		Endpoint: input.Endpoint,
+		P256dh:   input.Keys.P256dh,
+		Auth:     input.Keys.Auth,
+	}
+
+	v := validator.New()
+	validatePushSubscription(v, sub)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	created, err := app.Store.CreatePushSubscription(r.Context(), db.CreatePushSubscriptionParams{
+		UserID:    app.contextGetAuthPayload(r).UserID,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.P256dh,
+		Auth:      sub.Auth,
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, newPushSubscriptionResponse(created), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) listPushSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := app.Store.ListPushSubscriptions(r.Context(), app.contextGetAuthPayload(r).UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]pushSubscriptionResponse, len(subs))
+	for i, sub := range subs {
+		data[i] = newPushSubscriptionResponse(sub)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) deletePushSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := app.readOwnedPushSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Store.DeletePushSubscription(r.Context(), sub.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testP256dh = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	testAuth   = "BTBZMqHH6r4Tts7J_aSIgg"
+)
+
+// createTestPushSubscription subscribes endpoint for userID through the API.
+func createTestPushSubscription(t *testing.T, app *KeyKeeper, userID int64, endpoint string) pushSubscriptionResponse {
+	body := fmt.Sprintf(`{"endpoint": %q, "expirationTime": null, "keys": {"p256dh": %q, "auth": %q}}`, endpoint, testP256dh, testAuth)
+	rec := serveAs(t, app, userID, http.MethodPost, "/v1/push/subscriptions", []byte(body))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var sub pushSubscriptionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sub))
+
+	return sub
+}
+
+func TestGetVAPIDPublicKey(t *testing.T) {
+	app := newTestApp(t, newMemStore())
+
+	rec := serve(t, app, http.MethodGet, "/v1/push/vapid-public-key", nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	keys, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	app.Config.VAPIDPublicKey = keys.PublicKey()
+
+	rec = serve(t, app, http.MethodGet, "/v1/push/vapid-public-key", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, fmt.Sprintf(`{"public_key": %q}`, keys.PublicKey()), rec.Body.String())
+}
+
+func TestCreatePushSubscription(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	sub := createTestPushSubscription(t, app, user.ID, "https://push.example.com/send/abc")
+	require.NotZero(t, sub.ID)
+	require.Equal(t, "https://push.example.com/send/abc", sub.Endpoint)
+	require.Equal(t, testP256dh, store.pushSubs[sub.ID].P256dh)
+	require.Equal(t, testAuth, store.pushSubs[sub.ID].Auth)
+
+	// Subscribing the same endpoint again updates it, even for another
+	// user signed in on the same browser.
+	other := store.addUser(t)
+	again := createTestPushSubscription(t, app, other.ID, "https://push.example.com/send/abc")
+	require.Equal(t, sub.ID, again.ID)
+	require.Equal(t, other.ID, store.pushSubs[sub.ID].UserID)
+
+	for _, body := range []string{
+		`{}`,
+		fmt.Sprintf(`{"endpoint": "http://push.example.com/send/abc", "keys": {"p256dh": %q, "auth": %q}}`, testP256dh, testAuth),
+		`{"endpoint": "https://push.example.com/send/abc", "keys": {"p256dh": "", "auth": ""}}`,
+		fmt.Sprintf(`{"endpoint": "https://push.example.com/send/abc", "keys": {"p256dh": %q, "auth": %q}}`, testAuth, testAuth),
+		fmt.Sprintf(`{"endpoint": "https://push.example.com/send/abc", "keys": {"p256dh": %q, "auth": %q}}`, testP256dh, testP256dh),
+	} {
+		rec := serveAs(t, app, user.ID, http.MethodPost, "/v1/push/subscriptions", []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	rec := serve(t, app, http.MethodPost, "/v1/push/subscriptions", []byte(`{}`))
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
+
+func TestListPushSubscriptions(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	phone := createTestPushSubscription(t, app, user.ID, "https://push.example.com/send/phone")
+	laptop := createTestPushSubscription(t, app, user.ID, "https://push.example.com/send/laptop")
+	createTestPushSubscription(t, app, other.ID, "https://push.example.com/send/other")
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, "/v1/push/subscriptions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data []pushSubscriptionResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+	require.Equal(t, phone.ID, body.Data[0].ID)
+	require.Equal(t, laptop.ID, body.Data[1].ID)
+	require.NotContains(t, rec.Body.String(), testAuth)
+}
+
+func TestDeletePushSubscription(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	sub := createTestPushSubscription(t, app, user.ID, "https://push.example.com/send/abc")
+	path := fmt.Sprintf("/v1/push/subscriptions/%d", sub.ID)
+
+	rec := serveAs(t, app, other.ID, http.MethodDelete, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Empty(t, store.pushSubs)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned"
+	"github.com/julienschmidt/httprouter"
+)
+
+// getPwnedRange answers a Pwned Passwords range query, from the cache or
+// from the configured upstream. Callers send the first five characters of
+// the SHA-1 hash of a password and look its suffix up in the response
+// themselves, so the server never sees the password nor its full hash.
+// pkg/pwned implements the client side.
+func (app *KeyKeeper) getPwnedRange(w http.ResponseWriter, r *http.Request) {
+	prefix := httprouter.ParamsFromContext(r.Context()).ByName("prefix")
+
+	body, err := app.Pwned.Range(r.Context(), prefix)
+	if err != nil {
+		switch {
+		case errors.Is(err, pwned.ErrPrefix):
+			app.badRequestResponse(w, r, errors.New("prefix must be the first 5 hexadecimal characters of a SHA-1 hash"))
+		default:
+			app.badGatewayResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(app.Pwned.TTL().Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned"
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned/pwnedtest"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPwnedApp(t *testing.T) (*KeyKeeper, *memStore, *pwnedtest.Server) {
+	upstream := pwnedtest.NewServer(map[string]int{"password": 9659365})
+	t.Cleanup(upstream.Close)
+
+	store := newMemStore()
+	app := newTestApp(t, store)
+	app.Pwned = pwned.NewCache(pwned.NewClient(upstream.URL), time.Hour, 10)
+
+	return app, store, upstream
+}
+
+func TestGetPwnedRange(t *testing.T) {
+	app, store, upstream := newTestPwnedApp(t)
+	user := store.addUser(t)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, "/v1/pwned-passwords/range/5baa6", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "private, max-age=3600", rec.Header().Get("Cache-Control"))
+	require.Equal(t, "1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n", rec.Body.String())
+
+	// The range is served from the cache.
+	rec = serveAs(t, app, user.ID, http.MethodGet, "/v1/pwned-passwords/range/5BAA6", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []string{"5BAA6"}, upstream.Requests())
+
+	for _, prefix := range []string{"5BAA", "5BAA61", "ZZZZZ"} {
+		rec = serveAs(t, app, user.ID, http.MethodGet, "/v1/pwned-passwords/range/"+prefix, nil)
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	upstream.Fail(http.StatusTooManyRequests)
+	rec = serveAs(t, app, user.ID, http.MethodGet, "/v1/pwned-passwords/range/00000", nil)
+	requireErrorResponse(t, rec, http.StatusBadGateway)
+
+	rec = serve(t, app, http.MethodGet, "/v1/pwned-passwords/range/5BAA6", nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
+
+func TestPwnedClientThroughProxy(t *testing.T) {
+	app, store, upstream := newTestPwnedApp(t)
+	user := store.addUser(t)
+	tokens := newTestSession(t, app, user.ID)
+
+	srv := httptest.NewServer(app.Routes())
+	defer srv.Close()
+
+	c := pwned.NewClient(srv.URL + "/v1/pwned-passwords")
+	c.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	n, err := c.Count(context.Background(), "password")
+	require.NoError(t, err)
+	require.Equal(t, 9659365, n)
+
+	n, err = c.Count(context.Background(), "a much better passphrase")
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	require.Equal(t, []string{"5BAA6", pwned.Hash("a much better passphrase")[:5]}, upstream.Requests())
+
+	c.Header.Del("Authorization")
+	_, err = c.Count(context.Background(), "password")
+	var statusErr *pwned.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
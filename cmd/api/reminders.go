@@ -0,0 +1,768 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/interval"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+	"github.com/OCD-Labs/KeyKeeper/internal/website"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// maxSnoozeDays bounds how far a single snooze defers a reminder.
+	maxSnoozeDays = 30
+
+	// maxNoteLength bounds the note on a rotation, in characters.
+	maxNoteLength = 500
+)
+
+// A reminderResponse is the public representation of a reminder, as
+// described by the Reminder definition in docs/specs.yaml.
+type reminderResponse struct {
+	ID             int64           `json:"id"`
+	UserID         int64           `json:"user_id"`
+	WebsiteURL     string          `json:"website_url"`
+	WebsiteHost    *string         `json:"website_host"`
+	WebsiteDomain  *string         `json:"website_domain"`
+	Interval       string          `json:"interval"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Extension      json.RawMessage `json:"extension"`
+	NextDueAt      *time.Time      `json:"next_due_at"`
+	LastNotifiedAt *time.Time      `json:"last_notified_at"`
+}
+
+func newReminderResponse(reminder db.Reminder) reminderResponse {
+	res := reminderResponse{
+		ID:         reminder.ID,
+		UserID:     reminder.UserID,
+		WebsiteURL: reminder.WebsiteUrl,
+		Interval:   normalizeInterval(reminder.Interval),
+		UpdatedAt:  reminder.UpdatedAt,
+		Extension:  reminder.Extension,
+	}
+	if reminder.WebsiteDomain.Valid {
+		res.WebsiteHost = &reminder.WebsiteHost.String
+		res.WebsiteDomain = &reminder.WebsiteDomain.String
+	}
+	if reminder.NextDueAt.Valid {
+		res.NextDueAt = &reminder.NextDueAt.Time
+	}
+	if reminder.LastNotifiedAt.Valid {
+		res.LastNotifiedAt = &reminder.LastNotifiedAt.Time
+	}
+
+	return res
+}
+
+func validateWebsiteURL(v *validator.Validator, websiteURL string) {
+	v.Check(websiteURL != "", "website_url", "must be provided")
+	v.Check(len(websiteURL) <= 2048, "website_url", "must not be more than 2048 bytes long")
+}
+
+// validateWebsite checks that websiteURL names a website and returns its
+// canonical form.
+func validateWebsite(v *validator.Validator, websiteURL string) website.Site {
+	validateWebsiteURL(v, websiteURL)
+
+	site, err := website.Parse(websiteURL)
+	if err != nil && websiteURL != "" {
+		v.AddError("website_url", "must be a valid website URL")
+	}
+
+	return site
+}
+
+// validateInterval checks that s is an interval of the grammar described in
+// package interval and returns it parsed.
+func validateInterval(v *validator.Validator, s string) interval.Interval {
+	if s == "" {
+		v.AddError("interval", "must be provided")
+		return interval.Interval{}
+	}
+
+	if len(s) > 64 {
+		v.AddError("interval", "must not be more than 64 bytes long")
+		return interval.Interval{}
+	}
+
+	i, err := interval.Parse(s)
+	if err != nil {
+		v.AddError("interval", err.Error())
+	}
+
+	return i
+}
+
+// normalizeInterval returns the normalized form of a stored interval, or the
+// interval as is if it predates interval validation and does not parse.
+func normalizeInterval(s string) string {
+	i, err := interval.Parse(s)
+	if err != nil {
+		return s
+	}
+
+	return i.String()
+}
+
+// nextDueAt returns when a reminder with the stored interval s that was last
+// updated at updatedAt falls due, counting calendar days in loc. Reminders
+// whose interval predates interval validation and does not parse are never
+// due.
+func nextDueAt(s string, updatedAt time.Time, loc *time.Location) sql.NullTime {
+	i, err := interval.Parse(s)
+	if err != nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: i.AddIn(updatedAt, loc), Valid: true}
+}
+
+// rescheduledDueAt returns when reminder falls due under the stored interval
+// s, counting calendar days in loc. A snooze in force that defers the
+// reminder past that date is kept: a reminder is snoozed when its last
+// action snoozed it to its current due date, and any later action, such as
+// a rotation, ends the snooze.
+func (app *KeyKeeper) rescheduledDueAt(ctx context.Context, reminder db.Reminder, s string, loc *time.Location) (sql.NullTime, error) {
+	due := nextDueAt(s, reminder.UpdatedAt, loc)
+	if !due.Valid || !reminder.NextDueAt.Valid || !reminder.NextDueAt.Time.After(due.Time) {
+		return due, nil
+	}
+
+	actions, err := app.Store.ListReminderActions(ctx, reminder.ID)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+
+	if len(actions) > 0 && actions[0].Action == db.ReminderActionSnoozed && actions[0].SnoozedUntil.Time.Equal(reminder.NextDueAt.Time) {
+		return reminder.NextDueAt, nil
+	}
+
+	return due, nil
+}
+
+// validateExtension checks that an extension, when given, is a JSON object.
+func validateExtension(v *validator.Validator, extension json.RawMessage) {
+	if len(extension) == 0 || bytes.Equal(extension, []byte("null")) {
+		return
+	}
+
+	var obj map[string]json.RawMessage
+	v.Check(json.Unmarshal(extension, &obj) == nil, "extension", "must be a JSON object")
+}
+
+// validateNote checks the note on a rotation, and returns it as stored,
+// trimmed. A blank note is no note.
+func validateNote(v *validator.Validator, note *string) sql.NullString {
+	if note == nil {
+		return sql.NullString{}
+	}
+
+	trimmed := strings.TrimSpace(*note)
+	if trimmed == "" {
+		return sql.NullString{}
+	}
+
+	v.Check(utf8.RuneCountInString(trimmed) <= maxNoteLength, "note", "must not be more than 500 characters long")
+
+	return sql.NullString{String: trimmed, Valid: true}
+}
+
+// readOwnedReminder looks up the reminder identified by the "id" URL
+// parameter and the website_url query parameter, and checks that it belongs
+// to the authenticated caller. It writes the error response and returns
+// false when the reminder cannot be used.
+func (app *KeyKeeper) readOwnedReminder(w http.ResponseWriter, r *http.Request) (db.Reminder, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return db.Reminder{}, false
+	}
+
+	websiteURL := r.URL.Query().Get("website_url")
+
+	v := validator.New()
+	validateWebsiteURL(v, websiteURL)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return db.Reminder{}, false
+	}
+
+	reminder, err := app.Store.GetReminder(r.Context(), db.GetReminderParams{
+		ID:         id,
+		WebsiteUrl: websiteURL,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return db.Reminder{}, false
+	}
+
+	if reminder.UserID != app.contextGetAuthPayload(r).UserID {
+		app.notPermittedResponse(w, r)
+		return db.Reminder{}, false
+	}
+
+	return reminder, true
+}
+
+func (app *KeyKeeper) createReminder(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID     int64           `json:"user_id"`
+		WebsiteURL string          `json:"website_url"`
+		Interval   string          `json:"interval"`
+		Extension  json.RawMessage `json:"extension"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload := app.contextGetAuthPayload(r)
+	if input.UserID == 0 {
+		input.UserID = payload.UserID
+	}
+
+	if input.UserID != payload.UserID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	site := validateWebsite(v, input.WebsiteURL)
+	reminderInterval := validateInterval(v, input.Interval)
+	validateExtension(v, input.Extension)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, input.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	now := time.Now()
+
+	reminder, err := app.Store.CreateReminder(r.Context(), db.CreateReminderParams{
+		UserID:        input.UserID,
+		WebsiteUrl:    input.WebsiteURL,
+		Interval:      reminderInterval.String(),
+		Extension:     input.Extension,
+		UpdatedAt:     now,
+		NextDueAt:     sql.NullTime{Time: reminderInterval.AddIn(now, prefs.Location), Valid: true},
+		WebsiteHost:   sql.NullString{String: site.Host, Valid: true},
+		WebsiteDomain: sql.NullString{String: site.Domain, Valid: true},
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			v.AddError("website_url", "a reminder for this website already exists")
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderCreated, reminder)
+
+	err = app.writeJSON(w, http.StatusCreated, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) listReminders(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	payload := app.contextGetAuthPayload(r)
+
+	userID, err := app.readInt(qs, "user_id", int(payload.UserID))
+	if err != nil {
+		v.AddError("user_id", "must be an integer value")
+	}
+	page, err := app.readInt(qs, "page", 1)
+	if err != nil {
+		v.AddError("page", "must be an integer value")
+	}
+	pageSize, err := app.readInt(qs, "page_size", defaultPageSize)
+	if err != nil {
+		v.AddError("page_size", "must be an integer value")
+	}
+
+	v.Check(page > 0, "page", "must be greater than zero")
+	v.Check(page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(pageSize > 0, "page_size", "must be greater than zero")
+	v.Check(pageSize <= maxPageSize, "page_size", "must be a maximum of 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if int64(userID) != payload.UserID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	reminders, err := app.Store.ListReminders(r.Context(), db.ListRemindersParams{
+		UserID: int64(userID),
+		Limit:  int32(pageSize),
+		Offset: int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]reminderResponse, len(reminders))
+	for i, reminder := range reminders {
+		data[i] = newReminderResponse(reminder)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) getReminder(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) deleteReminder(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Store.DeleteReminder(r.Context(), db.DeleteReminderParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderDeleted, reminder)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *KeyKeeper) updateReminderInterval(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Interval string `json:"interval"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	reminderInterval := validateInterval(v, input.Interval)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, reminder.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	due, err := app.rescheduledDueAt(r.Context(), reminder, reminderInterval.String(), prefs.Location)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reminder, err = app.Store.SetNewInterval(r.Context(), db.SetNewIntervalParams{
+		NewInterval: reminderInterval.String(),
+		NextDueAt:   due,
+		ID:          reminder.ID,
+		WebsiteUrl:  reminder.WebsiteUrl,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderUpdated, reminder)
+
+	err = app.writeJSON(w, http.StatusOK, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateReminderUpdatedAt records that the caller changed the password of a
+// reminder at a given time, with an optional note. The time may be neither
+// in the future nor before the last recorded rotation, so that the history
+// stays in order and a reminder cannot be pushed back past a later rotation.
+func (app *KeyKeeper) updateReminderUpdatedAt(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		UpdatedAt time.Time `json:"updated_at"`
+		Note      *string   `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rotations, err := app.Store.ListReminderRotations(r.Context(), db.ListReminderRotationsParams{
+		ReminderID: reminder.ID,
+		Limit:      1,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(!input.UpdatedAt.IsZero(), "updated_at", "must be provided")
+	v.Check(!input.UpdatedAt.After(time.Now()), "updated_at", "must not be in the future")
+	if len(rotations) > 0 {
+		v.Check(!input.UpdatedAt.Before(rotations[0].RotatedAt), "updated_at", "must not be before the last rotation")
+	}
+	note := validateNote(v, input.Note)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, reminder.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reminder, err = app.Store.RotateReminderTx(r.Context(), db.RotateReminderTxParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+		RotatedAt:  input.UpdatedAt,
+		NextDueAt:  nextDueAt(reminder.Interval, input.UpdatedAt, prefs.Location),
+		Source:     db.RotationSourceAPI,
+		Note:       note,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderRotated, reminder)
+
+	err = app.writeJSON(w, http.StatusOK, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// rotateReminder records that the caller changed the password of a
+// reminder just now, with an optional note, and schedules the reminder from
+// now on.
+func (app *KeyKeeper) rotateReminder(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Note *string `json:"note"`
+	}
+
+	// The body is optional.
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+	note := validateNote(v, input.Note)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, reminder.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	now := time.Now()
+
+	reminder, err = app.Store.RotateReminderTx(r.Context(), db.RotateReminderTxParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+		RotatedAt:  now,
+		NextDueAt:  nextDueAt(reminder.Interval, now, prefs.Location),
+		Source:     db.RotationSourceAPI,
+		Note:       note,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderRotated, reminder)
+
+	err = app.writeJSON(w, http.StatusOK, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// snoozeReminder defers the next notification of a reminder by a number of
+// days, counted from when it is due or from now if it is overdue. The time
+// the password was last changed stays as it is.
+func (app *KeyKeeper) snoozeReminder(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Days int `json:"days"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Days > 0, "days", "must be greater than zero")
+	v.Check(input.Days <= maxSnoozeDays, "days", "must be a maximum of 30")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), app.Store, reminder.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	from := time.Now()
+	if reminder.NextDueAt.Valid && reminder.NextDueAt.Time.After(from) {
+		from = reminder.NextDueAt.Time
+	}
+
+	reminder, err = app.Store.SnoozeReminderTx(r.Context(), db.SnoozeReminderTxParams{
+		ID:           reminder.ID,
+		WebsiteUrl:   reminder.WebsiteUrl,
+		SnoozedUntil: interval.Interval{Days: input.Days}.AddIn(from, prefs.Location),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderSnoozed, reminder)
+
+	err = app.writeJSON(w, http.StatusOK, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) updateReminderExtension(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	var input map[string]json.RawMessage
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input != nil, "extension", "must be a JSON object")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	extension, err := json.Marshal(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reminder, err = app.Store.SetReminderConfigs(r.Context(), db.SetReminderConfigsParams{
+		UpdatedExtension: extension,
+		ID:               reminder.ID,
+		WebsiteUrl:       reminder.WebsiteUrl,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.emitReminderEvent(r, webhook.EventReminderUpdated, reminder)
+
+	err = app.writeJSON(w, http.StatusOK, newReminderResponse(reminder), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// A rotationResponse is the public representation of a rotation in the
+// history of a reminder.
+type rotationResponse struct {
+	ID        int64     `json:"id"`
+	RotatedAt time.Time `json:"rotated_at"`
+	Source    string    `json:"source"`
+	Note      *string   `json:"note"`
+}
+
+func newRotationResponse(rotation db.ReminderRotation) rotationResponse {
+	res := rotationResponse{
+		ID:        rotation.ID,
+		RotatedAt: rotation.RotatedAt,
+		Source:    rotation.Source,
+	}
+	if rotation.Note.Valid {
+		res.Note = &rotation.Note.String
+	}
+
+	return res
+}
+
+// listReminderHistory returns the rotations of a reminder, latest first.
+func (app *KeyKeeper) listReminderHistory(w http.ResponseWriter, r *http.Request) {
+	reminder, ok := app.readOwnedReminder(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	page, err := app.readInt(qs, "page", 1)
+	if err != nil {
+		v.AddError("page", "must be an integer value")
+	}
+	pageSize, err := app.readInt(qs, "page_size", defaultPageSize)
+	if err != nil {
+		v.AddError("page_size", "must be an integer value")
+	}
+
+	v.Check(page > 0, "page", "must be greater than zero")
+	v.Check(page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(pageSize > 0, "page_size", "must be greater than zero")
+	v.Check(pageSize <= maxPageSize, "page_size", "must be a maximum of 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	rotations, err := app.Store.ListReminderRotations(r.Context(), db.ListReminderRotationsParams{
+		ReminderID: reminder.ID,
+		Limit:      int32(pageSize),
+		Offset:     int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]rotationResponse, len(rotations))
+	for i, rotation := range rotations {
+		data[i] = newRotationResponse(rotation)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
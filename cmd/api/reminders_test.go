@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+// reminderPath returns the URL of a reminder sub-resource.
+func reminderPath(reminder db.Reminder, suffix string) string {
+	return fmt.Sprintf("/v1/reminders/%d%s?website_url=%s", reminder.ID, suffix, url.QueryEscape(reminder.WebsiteUrl))
+}
+
+func requireErrorResponse(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	res := rec.Result()
+	require.Equal(t, status, res.StatusCode)
+
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	require.Equal(t, http.StatusText(status), body.Error)
+	require.NotEmpty(t, body.Message)
+}
+
+func TestCreateReminderHandler(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	testCases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{
+			name:   "OK",
+			body:   fmt.Sprintf(`{"user_id": %d, "website_url": "example.com", "interval": "2 weeks", "extension": {"region": "Europe"}}`, user.ID),
+			status: http.StatusCreated,
+		},
+		{
+			name:   "MissingFields",
+			body:   fmt.Sprintf(`{"user_id": %d}`, user.ID),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "InvalidInterval",
+			body:   fmt.Sprintf(`{"user_id": %d, "website_url": "example.com", "interval": "every now and then"}`, user.ID),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "ExtensionNotObject",
+			body:   fmt.Sprintf(`{"user_id": %d, "website_url": "example.com", "interval": "2 weeks", "extension": [1]}`, user.ID),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "InvalidWebsite",
+			body:   fmt.Sprintf(`{"user_id": %d, "website_url": "https://co.uk", "interval": "2 weeks"}`, user.ID),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "DuplicateWebsite",
+			body:   fmt.Sprintf(`{"user_id": %d, "website_url": "https://www.Example.com/login", "interval": "2 weeks"}`, user.ID),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "OtherUser",
+			body:   fmt.Sprintf(`{"user_id": %d, "website_url": "example.com", "interval": "2 weeks"}`, user.ID+1),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "UnknownField",
+			body:   `{"website": "example.com"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "BadJSON",
+			body:   `{"user_id": `,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveAs(t, app, user.ID, http.MethodPost, "/v1/reminders", []byte(tc.body))
+			if tc.status != http.StatusCreated {
+				requireErrorResponse(t, rec, tc.status)
+				return
+			}
+
+			require.Equal(t, tc.status, rec.Code)
+
+			var reminder reminderResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reminder))
+			require.NotZero(t, reminder.ID)
+			require.Equal(t, user.ID, reminder.UserID)
+			require.Equal(t, "example.com", reminder.WebsiteURL)
+			require.Equal(t, "example.com", *reminder.WebsiteHost)
+			require.Equal(t, "example.com", *reminder.WebsiteDomain)
+			require.Equal(t, "P14D", reminder.Interval)
+			require.NotNil(t, reminder.NextDueAt)
+			require.True(t, reminder.UpdatedAt.UTC().AddDate(0, 0, 14).Equal(*reminder.NextDueAt))
+			require.JSONEq(t, `{"region": "Europe"}`, string(reminder.Extension))
+		})
+	}
+}
+
+func TestListRemindersHandler(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	for i := 0; i < 5; i++ {
+		_, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+			UserID:     user.ID,
+			WebsiteUrl: util.RandomWebsiteURL(),
+			Interval:   "2 weeks",
+		})
+		require.NoError(t, err)
+	}
+	_, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     other.ID,
+		WebsiteUrl: util.RandomWebsiteURL(),
+		Interval:   "2 weeks",
+	})
+	require.NoError(t, err)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, fmt.Sprintf("/v1/reminders?user_id=%d&page=2&page_size=3", user.ID), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data []reminderResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+	for _, reminder := range body.Data {
+		require.Equal(t, user.ID, reminder.UserID)
+	}
+
+	rec = serveAs(t, app, user.ID, http.MethodGet, "/v1/reminders?page_size=1000", nil)
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+
+	// The user_id defaults to the caller and must not name anyone else.
+	rec = serveAs(t, app, other.ID, http.MethodGet, "/v1/reminders", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+
+	rec = serveAs(t, app, other.ID, http.MethodGet, fmt.Sprintf("/v1/reminders?user_id=%d", user.ID), nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serve(t, app, http.MethodGet, "/v1/reminders", nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+}
+
+func TestReminderHandlers(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	reminder, err := store.CreateReminder(context.Background(), db.CreateReminderParams{
+		UserID:     user.ID,
+		WebsiteUrl: "https://example.com/login",
+		Interval:   "P14D",
+		UpdatedAt:  time.Now(),
+	})
+	require.NoError(t, err)
+
+	t.Run("Get", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, ""), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got reminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.Equal(t, reminder.ID, got.ID)
+	})
+
+	t.Run("GetWrongWebsite", func(t *testing.T) {
+		wrong := reminder
+		wrong.WebsiteUrl = "other.com"
+		rec := serveAs(t, app, user.ID, http.MethodGet, reminderPath(wrong, ""), nil)
+		requireErrorResponse(t, rec, http.StatusNotFound)
+	})
+
+	t.Run("GetOtherUser", func(t *testing.T) {
+		other := store.addUser(t)
+		rec := serveAs(t, app, other.ID, http.MethodGet, reminderPath(reminder, ""), nil)
+		requireErrorResponse(t, rec, http.StatusForbidden)
+
+		rec = serveAs(t, app, other.ID, http.MethodDelete, reminderPath(reminder, ""), nil)
+		requireErrorResponse(t, rec, http.StatusForbidden)
+	})
+
+	t.Run("GetMissingWebsite", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodGet, fmt.Sprintf("/v1/reminders/%d", reminder.ID), nil)
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/interval"), []byte(`{"interval": "1 month"}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got reminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.Equal(t, "P1M", got.Interval)
+		require.NotNil(t, got.NextDueAt)
+		require.True(t, got.UpdatedAt.UTC().AddDate(0, 1, 0).Equal(*got.NextDueAt))
+
+		for _, invalid := range []string{"soon", "PT1H", "0 days", "11 years"} {
+			body := fmt.Sprintf(`{"interval": %q}`, invalid)
+			rec = serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/interval"), []byte(body))
+			requireErrorResponse(t, rec, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("UpdatedAt", func(t *testing.T) {
+		updatedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		body := fmt.Sprintf(`{"updated_at": %q}`, updatedAt.Format(time.RFC3339))
+		rec := serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/updated-at"), []byte(body))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got reminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.True(t, updatedAt.Equal(got.UpdatedAt))
+		require.True(t, updatedAt.AddDate(0, 1, 0).Equal(*got.NextDueAt))
+		require.Equal(t, db.ReminderActionRotated, store.actions[len(store.actions)-1].Action)
+
+		future := time.Now().Add(time.Hour).Format(time.RFC3339)
+		rec = serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/updated-at"), []byte(fmt.Sprintf(`{"updated_at": %q}`, future)))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+
+		rotations := len(store.rotations)
+		earlier := updatedAt.Add(-time.Minute).Format(time.RFC3339)
+		rec = serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/updated-at"), []byte(fmt.Sprintf(`{"updated_at": %q}`, earlier)))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+		require.Len(t, store.rotations, rotations)
+		require.True(t, updatedAt.Equal(store.reminders[reminder.ID].UpdatedAt))
+	})
+
+	t.Run("Rotated", func(t *testing.T) {
+		before := len(store.actions)
+		start := time.Now()
+
+		rec := serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/rotated"), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got reminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.False(t, got.UpdatedAt.Before(start))
+		require.True(t, got.UpdatedAt.UTC().AddDate(0, 1, 0).Equal(*got.NextDueAt))
+
+		require.Len(t, store.actions, before+1)
+		action := store.actions[before]
+		require.Equal(t, reminder.ID, action.ReminderID)
+		require.Equal(t, db.ReminderActionRotated, action.Action)
+		require.False(t, action.SnoozedUntil.Valid)
+
+		rotation := store.rotations[len(store.rotations)-1]
+		require.Equal(t, reminder.ID, rotation.ReminderID)
+		require.Equal(t, db.RotationSourceAPI, rotation.Source)
+		require.False(t, rotation.Note.Valid)
+
+		rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/rotated"), []byte(`{"note": "  after the breach notice  "}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rotation = store.rotations[len(store.rotations)-1]
+		require.Equal(t, sql.NullString{String: "after the breach notice", Valid: true}, rotation.Note)
+
+		for _, body := range []string{`{"note": 1}`, fmt.Sprintf(`{"note": %q}`, strings.Repeat("a", maxNoteLength+1))} {
+			rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/rotated"), []byte(body))
+			requireErrorResponse(t, rec, http.StatusBadRequest)
+		}
+		require.Len(t, store.actions, before+2)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, "/history"), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got struct {
+			Data []rotationResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.Len(t, got.Data, 3)
+		require.Equal(t, "after the breach notice", *got.Data[0].Note)
+		require.Nil(t, got.Data[1].Note)
+		require.Equal(t, db.RotationSourceAPI, got.Data[2].Source)
+		for i := 1; i < len(got.Data); i++ {
+			require.False(t, got.Data[i].RotatedAt.After(got.Data[i-1].RotatedAt))
+		}
+
+		// The rotation backdated through /updated-at comes last.
+		rec = serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, "/history")+"&page=2&page_size=2", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var page struct {
+			Data []rotationResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		require.Equal(t, got.Data[2:], page.Data)
+
+		for _, query := range []string{"&page=0", "&page_size=101", "&page=x"} {
+			rec = serveAs(t, app, user.ID, http.MethodGet, reminderPath(reminder, "/history")+query, nil)
+			requireErrorResponse(t, rec, http.StatusBadRequest)
+		}
+
+		other := store.addUser(t)
+		rec = serveAs(t, app, other.ID, http.MethodGet, reminderPath(reminder, "/history"), nil)
+		requireErrorResponse(t, rec, http.StatusForbidden)
+	})
+
+	t.Run("Snooze", func(t *testing.T) {
+		before := len(store.actions)
+		rotated := store.reminders[reminder.ID]
+
+		// A reminder that is not due yet is deferred from its due date.
+		rec := serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/snooze"), []byte(`{"days": 3}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got reminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.True(t, rotated.UpdatedAt.Equal(got.UpdatedAt))
+		require.True(t, rotated.NextDueAt.Time.AddDate(0, 0, 3).Equal(*got.NextDueAt))
+
+		// An overdue reminder is deferred from now.
+		overdue := store.reminders[reminder.ID]
+		overdue.NextDueAt.Valid = false
+		store.reminders[reminder.ID] = overdue
+
+		start := time.Now()
+		rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/snooze"), []byte(`{"days": 1}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.True(t, rotated.UpdatedAt.Equal(got.UpdatedAt))
+		require.WithinDuration(t, start.AddDate(0, 0, 1), *got.NextDueAt, time.Second)
+
+		require.Len(t, store.actions, before+2)
+		for _, action := range store.actions[before:] {
+			require.Equal(t, db.ReminderActionSnoozed, action.Action)
+			require.True(t, action.SnoozedUntil.Valid)
+		}
+		require.True(t, got.NextDueAt.Equal(store.actions[before+1].SnoozedUntil.Time))
+
+		for _, body := range []string{`{}`, `{"days": 0}`, `{"days": 31}`, `{"days": "3"}`} {
+			rec = serveAs(t, app, user.ID, http.MethodPost, reminderPath(reminder, "/snooze"), []byte(body))
+			requireErrorResponse(t, rec, http.StatusBadRequest)
+		}
+		require.Len(t, store.actions, before+2)
+
+		// Changing the interval keeps a snooze that defers the reminder
+		// past its new due date, and drops one that no longer does.
+		snoozed := store.reminders[reminder.ID].NextDueAt
+		rec = serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/interval"), []byte(`{"interval": "P1D"}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.True(t, snoozed.Time.Equal(store.reminders[reminder.ID].NextDueAt.Time))
+
+		rec = serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/interval"), []byte(`{"interval": "P1M"}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.True(t, rotated.UpdatedAt.AddDate(0, 1, 0).Equal(store.reminders[reminder.ID].NextDueAt.Time))
+	})
+
+	t.Run("Extension", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/extension"), []byte(`{"region": "Africa"}`))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var got reminderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		require.JSONEq(t, `{"region": "Africa"}`, string(got.Extension))
+
+		rec = serveAs(t, app, user.ID, http.MethodPatch, reminderPath(reminder, "/extension"), []byte(`null`))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodDelete, reminderPath(reminder, ""), nil)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = serveAs(t, app, user.ID, http.MethodDelete, reminderPath(reminder, ""), nil)
+		requireErrorResponse(t, rec, http.StatusNotFound)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		rec := serveAs(t, app, user.ID, http.MethodPut, reminderPath(reminder, ""), nil)
+		requireErrorResponse(t, rec, http.StatusMethodNotAllowed)
+	})
+}
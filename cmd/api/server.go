@@ -2,25 +2,111 @@ package api
 
 import (
 	"net/http"
+	"sync"
 
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/events"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
 	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned"
+	"github.com/julienschmidt/httprouter"
 )
 
-type KeyKeeper struct{
+type KeyKeeper struct {
 	SwaggerSpec []byte
-	Config util.Configs
+	Config      util.Configs
+	Store       db.Store
+	TokenMaker  token.TokenMaker
+	Mailer      mailer.Mailer
+	Events      *events.Broker
+	Pwned       *pwned.Cache
+
+	wg sync.WaitGroup
 }
 
 func (app *KeyKeeper) Routes() http.Handler {
-	mux := http.NewServeMux()
+	router := httprouter.New()
+
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	mux.HandleFunc("/healthcheck", app.ping)
+	router.HandlerFunc(http.MethodGet, "/healthcheck", app.ping)
 	// Register the Swagger documentation handler
-	mux.HandleFunc("/docs", app.serveDocs)
-	mux.HandleFunc("/swagger.json", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(app.SwaggerSpec)
+	router.HandlerFunc(http.MethodGet, "/docs", app.serveDocs)
+	router.HandlerFunc(http.MethodGet, "/swagger.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(app.SwaggerSpec)
 	})
 
-	return mux
+	router.HandlerFunc(http.MethodGet, "/v1/reminders", app.requireAuthentication(app.listReminders))
+	router.HandlerFunc(http.MethodPost, "/v1/reminders", app.requireAuthentication(app.createReminder))
+	router.HandlerFunc(http.MethodGet, "/v1/reminders/:id", app.requireAuthentication(app.getReminder))
+	router.HandlerFunc(http.MethodDelete, "/v1/reminders/:id", app.requireAuthentication(app.deleteReminder))
+	router.HandlerFunc(http.MethodPatch, "/v1/reminders/:id/interval", app.requireAuthentication(app.updateReminderInterval))
+	router.HandlerFunc(http.MethodPatch, "/v1/reminders/:id/updated-at", app.requireAuthentication(app.updateReminderUpdatedAt))
+	router.HandlerFunc(http.MethodPatch, "/v1/reminders/:id/extension", app.requireAuthentication(app.updateReminderExtension))
+	router.HandlerFunc(http.MethodPost, "/v1/reminders/:id/rotated", app.requireAuthentication(app.rotateReminder))
+	router.HandlerFunc(http.MethodPost, "/v1/reminders/:id/snooze", app.requireAuthentication(app.snoozeReminder))
+	router.HandlerFunc(http.MethodGet, "/v1/reminders/:id/history", app.requireAuthentication(app.listReminderHistory))
+	router.HandlerFunc(http.MethodGet, "/v1/reminders/:id/breaches", app.requireAuthentication(app.listReminderBreaches))
+	router.HandlerFunc(http.MethodGet, "/v1/reminders/:id/escalation", app.requireAuthentication(app.getReminderEscalation))
+
+	router.HandlerFunc(http.MethodGet, "/v1/events", app.requireAuthentication(app.streamEvents))
+
+	router.HandlerFunc(http.MethodGet, "/v1/pwned-passwords/range/:prefix", app.requireAuthentication(app.getPwnedRange))
+
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks", app.requireAuthentication(app.listWebhooks))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks", app.requireAuthentication(app.createWebhook))
+	router.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", app.requireAuthentication(app.deleteWebhook))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id/deliveries", app.requireAuthentication(app.listWebhookDeliveries))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks/:id/test", app.requireAuthentication(app.sendTestWebhook))
+
+	router.HandlerFunc(http.MethodGet, "/v1/push/vapid-public-key", app.getVAPIDPublicKey)
+	router.HandlerFunc(http.MethodGet, "/v1/push/subscriptions", app.requireAuthentication(app.listPushSubscriptions))
+	router.HandlerFunc(http.MethodPost, "/v1/push/subscriptions", app.requireAuthentication(app.createPushSubscription))
+	router.HandlerFunc(http.MethodDelete, "/v1/push/subscriptions/:id", app.requireAuthentication(app.deletePushSubscription))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUser)
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id", app.requireAuthentication(app.getUser))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/:id/deactivate", app.requireAuthentication(app.deactivateUser))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/:id/change-password", app.requireAuthentication(app.changeUserPassword))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/verification-email", app.requireAuthentication(app.resendVerificationEmail))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/calendar-feed", app.requireAuthentication(app.getCalendarFeedInfo))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/calendar-feed", app.requireAuthentication(app.createCalendarFeed))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/calendar-feed", app.requireAuthentication(app.deleteCalendarFeed))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/digest", app.requireAuthentication(app.getDigest))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/digest", app.requireAuthentication(app.updateDigest))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/preferences", app.requireAuthentication(app.getPreferences))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/preferences", app.requireAuthentication(app.updatePreferences))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/escalation-policy", app.requireAuthentication(app.getEscalationPolicy))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/escalation-policy", app.requireAuthentication(app.updateEscalationPolicy))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/escalation-policy", app.requireAuthentication(app.deleteEscalationPolicy))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/escalation-policy/contact-verification", app.requireAuthentication(app.resendContactVerificationEmail))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/hygiene", app.requireAuthentication(app.getHygiene))
+
+	router.HandlerFunc(http.MethodGet, "/v1/calendar/:feed", app.getCalendarFeed)
+
+	router.HandlerFunc(http.MethodPost, "/v1/auth/login", app.loginUser)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/refresh", app.refreshSession)
+	router.HandlerFunc(http.MethodPut, "/v1/auth/email-verification", app.verifyEmail)
+	router.HandlerFunc(http.MethodPut, "/v1/auth/contact-verification", app.verifyContact)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/password-reset", app.requestPasswordReset)
+	router.HandlerFunc(http.MethodPut, "/v1/auth/password-reset", app.confirmPasswordReset)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/logout", app.requireAuthentication(app.logoutUser))
+	router.HandlerFunc(http.MethodGet, "/v1/auth/sessions", app.requireAuthentication(app.listSessions))
+	router.HandlerFunc(http.MethodDelete, "/v1/auth/sessions", app.requireAuthentication(app.revokeAllSessions))
+	router.HandlerFunc(http.MethodDelete, "/v1/auth/sessions/:id", app.requireAuthentication(app.revokeSession))
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/jobs", app.requireAdmin(app.listJobs))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/jobs/:id", app.requireAdmin(app.getJob))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/jobs/:id/retry", app.requireAdmin(app.retryJob))
+
+	return router
+}
+
+// Wait blocks until every background task, such as sending an email, has
+// finished.
+func (app *KeyKeeper) Wait() {
+	app.wg.Wait()
 }
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/google/uuid"
+)
+
+// A sessionResponse describes an active login of a user.
+type sessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+func newSessionResponse(session db.Session, current db.Session) sessionResponse {
+	return sessionResponse{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+		Current:   session.FamilyID == current.FamilyID,
+	}
+}
+
+// readCurrentSession returns the session of the caller's access token.
+func (app *KeyKeeper) readCurrentSession(w http.ResponseWriter, r *http.Request) (db.Session, bool) {
+	session, err := app.Store.GetSession(r.Context(), app.contextGetAuthPayload(r).SessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return db.Session{}, false
+	}
+
+	return session, true
+}
+
+// logoutUser ends the caller's login by blocking every session of its
+// token family.
+func (app *KeyKeeper) logoutUser(w http.ResponseWriter, r *http.Request) {
+	session, ok := app.readCurrentSession(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Store.BlockSessionFamily(r.Context(), session.FamilyID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been logged out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) listSessions(w http.ResponseWriter, r *http.Request) {
+	current, ok := app.readCurrentSession(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := app.Store.ListActiveSessions(r.Context(), current.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, newSessionResponse(session, current))
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) revokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readUUIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	session, err := app.Store.GetSession(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if session.UserID != app.contextGetAuthPayload(r).UserID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.Store.BlockSessionFamily(r.Context(), session.FamilyID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessions signs the caller out everywhere, including the session
+// making the request.
+func (app *KeyKeeper) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	err := app.Store.BlockUserSessions(r.Context(), app.contextGetAuthPayload(r).UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
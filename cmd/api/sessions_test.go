@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogoutUser(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	session := newTestSession(t, app, user.ID)
+	other := newTestSession(t, app, user.ID)
+
+	rec := serveWithToken(t, app, session.AccessToken, http.MethodPost, "/v1/auth/logout", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The access token and the refresh token of the login stop working.
+	rec = serveWithToken(t, app, session.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	rec = refresh(t, app, session.RefreshToken)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	// Other logins are unaffected.
+	rec = serveWithToken(t, app, other.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestListSessions(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	stranger := store.addUser(t)
+	app := newTestApp(t, store)
+
+	current := newTestSession(t, app, user.ID)
+	newTestSession(t, app, user.ID)
+	newTestSession(t, app, stranger.ID)
+
+	// A rotated session is listed once, under its newest session.
+	rec := refresh(t, app, current.RefreshToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var rotated sessionTokens
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rotated))
+
+	rec = serveWithToken(t, app, rotated.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "refresh_token")
+
+	var body struct {
+		Data []sessionResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+
+	var currents int
+	for _, session := range body.Data {
+		if session.Current {
+			currents++
+			require.Equal(t, rotated.SessionID, session.ID)
+		}
+	}
+	require.Equal(t, 1, currents)
+}
+
+func TestRevokeSession(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	stranger := store.addUser(t)
+	app := newTestApp(t, store)
+
+	current := newTestSession(t, app, user.ID)
+	phone := newTestSession(t, app, user.ID)
+	strangers := newTestSession(t, app, stranger.ID)
+
+	rec := serveWithToken(t, app, current.AccessToken, http.MethodDelete, "/v1/auth/sessions/"+strangers.SessionID.String(), nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	rec = serveWithToken(t, app, current.AccessToken, http.MethodDelete, "/v1/auth/sessions/not-a-uuid", nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	rec = serveWithToken(t, app, current.AccessToken, http.MethodDelete, "/v1/auth/sessions/"+phone.SessionID.String(), nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = serveWithToken(t, app, phone.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	rec = serveWithToken(t, app, current.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveWithToken(t, app, strangers.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	stranger := store.addUser(t)
+	app := newTestApp(t, store)
+
+	current := newTestSession(t, app, user.ID)
+	phone := newTestSession(t, app, user.ID)
+	strangers := newTestSession(t, app, stranger.ID)
+
+	rec := serveWithToken(t, app, current.AccessToken, http.MethodDelete, "/v1/auth/sessions", nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	for _, tokens := range []sessionTokens{current, phone} {
+		rec = serveWithToken(t, app, tokens.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+		requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+		rec = refresh(t, app, tokens.RefreshToken)
+		requireErrorResponse(t, rec, http.StatusUnauthorized)
+	}
+
+	rec = serveWithToken(t, app, strangers.AccessToken, http.MethodGet, "/v1/auth/sessions", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+	"github.com/lib/pq"
+)
+
+// A userResponse is the public representation of a user, as described by
+// the User definition in docs/specs.yaml.
+type userResponse struct {
+	ID              int64     `json:"id"`
+	FullName        string    `json:"full_name"`
+	Email           string    `json:"email"`
+	CreatedAt       time.Time `json:"created_at"`
+	IsActivated     bool      `json:"is_activated"`
+	IsEmailVerified bool      `json:"is_email_verified"`
+}
+
+func newUserResponse(user db.User) userResponse {
+	return userResponse{
+		ID:              user.ID,
+		FullName:        user.FullName,
+		Email:           user.Email,
+		CreatedAt:       user.CreatedAt,
+		IsActivated:     user.IsActivated,
+		IsEmailVerified: user.IsEmailVerified,
+	}
+}
+
+func validateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+}
+
+func validatePasswordPlaintext(v *validator.Validator, password string) {
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
+func validateName(v *validator.Validator, key, name string) {
+	v.Check(name != "", key, "must be provided")
+	v.Check(len(name) <= 100, key, "must not be more than 100 bytes long")
+}
+
+func validateNewPassword(v *validator.Validator, password string) {
+	validatePasswordPlaintext(v, password)
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
+}
+
+// readOwnUserID returns the "id" URL parameter after checking that it is the
+// authenticated caller's user ID. It writes the error response and returns
+// false otherwise.
+func (app *KeyKeeper) readOwnUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+
+	if id != app.contextGetAuthPayload(r).UserID {
+		app.notPermittedResponse(w, r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *KeyKeeper) registerUser(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Password  string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.FirstName = strings.TrimSpace(input.FirstName)
+	input.LastName = strings.TrimSpace(input.LastName)
+
+	v := validator.New()
+	validateName(v, "first_name", input.FirstName)
+	validateName(v, "last_name", input.LastName)
+	validateEmail(v, input.Email)
+	validateNewPassword(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	hashedPassword, err := util.HashedPassword(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.Store.CreateUser(r.Context(), db.CreateUserParams{
+		FullName:       input.FirstName + " " + input.LastName,
+		HashedPassword: hashedPassword,
+		Email:          input.Email,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The account stays pending until the emailed token is confirmed.
+	err = app.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, newUserResponse(user), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) getUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := app.Store.GetUser(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newUserResponse(user), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) deactivateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validateEmail(v, input.Email)
+	validatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user, err := app.Store.GetUser(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.Email != input.Email || util.VerifyPassword(user.HashedPassword, input.Password) != nil {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	user, err = app.Store.DeactivateUserTx(r.Context(), db.DeactivateUserParams{
+		ID:    user.ID,
+		Email: user.Email,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newUserResponse(user), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) changeUserPassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validateNewPassword(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user, err := app.Store.GetUser(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	hashedPassword, err := util.HashedPassword(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user, err = app.Store.ChangePassword(r.Context(), db.ChangePasswordParams{
+		HashedPassword: hashedPassword,
+		ChangedAt:      time.Now(),
+		Email:          user.Email,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newUserResponse(user), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
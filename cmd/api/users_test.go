@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUser(t *testing.T) {
+	store := newMemStore()
+	app, server := newMailerTestApp(t, store)
+
+	email := util.RandomEmail()
+	body := fmt.Sprintf(`{"first_name": "Jane", "last_name": "Doe", "email": %q, "password": "correct horse"}`, email)
+
+	rec := serve(t, app, http.MethodPost, "/v1/users", []byte(body))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.NotContains(t, rec.Body.String(), "hashed_password")
+
+	var user userResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	require.Equal(t, "Jane Doe", user.FullName)
+	require.Equal(t, email, user.Email)
+	require.False(t, user.IsEmailVerified)
+
+	// A verification token is mailed to the new account.
+	verificationToken := receivedToken(t, app, server, email)
+	require.Contains(t, store.verifies, util.HashToken(verificationToken))
+
+	// The same email cannot be registered twice.
+	rec = serve(t, app, http.MethodPost, "/v1/users", []byte(body))
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+
+	body = fmt.Sprintf(`{"first_name": "Jane", "last_name": "Doe", "email": %q, "password": "short"}`, util.RandomEmail())
+	rec = serve(t, app, http.MethodPost, "/v1/users", []byte(body))
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+}
+
+func TestUserHandlersOwnership(t *testing.T) {
+	store := newMemStore()
+	password := util.RandomString(12)
+	user := store.addUserWithPassword(t, password)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	path := fmt.Sprintf("/v1/users/%d", user.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, other.ID, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serve(t, app, http.MethodGet, path, nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	newPassword := []byte(`{"password": "a much better password"}`)
+	rec = serveAs(t, app, other.ID, http.MethodPatch, path+"/change-password", newPassword)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serveAs(t, app, user.ID, http.MethodPatch, path+"/change-password", newPassword)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, util.VerifyPassword(store.users[user.ID].HashedPassword, "a much better password"))
+
+	credentials := []byte(fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, password))
+	rec = serveAs(t, app, user.ID, http.MethodPatch, path+"/deactivate", credentials)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	credentials = []byte(fmt.Sprintf(`{"email": %q, "password": "a much better password"}`, user.Email))
+	rec = serveAs(t, app, other.ID, http.MethodPatch, path+"/deactivate", credentials)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serveAs(t, app, user.ID, http.MethodPatch, path+"/deactivate", credentials)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.False(t, store.users[user.ID].IsActivated)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+)
+
+const (
+	// emailVerificationTokenDuration is how long an email verification token
+	// stays valid.
+	emailVerificationTokenDuration = 24 * time.Hour
+
+	// At most verificationEmailLimit verification emails are sent to a user
+	// per verificationEmailWindow, including the one sent on registration.
+	verificationEmailLimit  = 3
+	verificationEmailWindow = time.Hour
+)
+
+// sendVerificationEmail creates an email verification token for user and
+// emails it in the background.
+func (app *KeyKeeper) sendVerificationEmail(ctx context.Context, user db.User) error {
+	verificationToken, err := util.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = app.Store.CreateEmailVerificationToken(ctx, db.CreateEmailVerificationTokenParams{
+		TokenHash: util.HashToken(verificationToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(emailVerificationTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"FullName":  user.FullName,
+			"Token":     verificationToken,
+			"ExpiresIn": "24 hours",
+		}
+
+		err := app.Mailer.Send(user.Email, "email_verification.tmpl", data)
+		if err != nil {
+			log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+		}
+	})
+
+	return nil
+}
+
+func (app *KeyKeeper) resendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readOwnUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := app.Store.GetUser(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	v.Check(!user.IsEmailVerified, "email", "is already verified")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	sent, err := app.Store.CountEmailVerificationTokensSince(r.Context(), db.CountEmailVerificationTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-verificationEmailWindow),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if sent >= verificationEmailLimit {
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	err = app.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "a verification token has been sent to your email address"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Token != "", "token", "must be provided")
+	v.Check(len(input.Token) == 26, "token", "must be 26 bytes long")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user, err := app.Store.VerifyEmailTx(r.Context(), util.HashToken(input.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			v.AddError("token", "invalid or expired email verification token")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newUserResponse(user), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+// addPendingUser inserts a user whose email address is not yet verified.
+func addPendingUser(t *testing.T, store *memStore) db.User {
+	user := store.addUser(t)
+	user.IsEmailVerified = false
+	store.users[user.ID] = user
+
+	return user
+}
+
+func TestVerifyEmail(t *testing.T) {
+	store := newMemStore()
+	user := addPendingUser(t, store)
+	app, server := newMailerTestApp(t, store)
+
+	path := fmt.Sprintf("/v1/users/%d/verification-email", user.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodPost, path, nil)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	first := receivedToken(t, app, server, user.Email)
+
+	rec = serveAs(t, app, user.ID, http.MethodPost, path, nil)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	second := receivedToken(t, app, server, user.Email)
+	require.NotEqual(t, first, second)
+
+	body := fmt.Sprintf(`{"token": %q}`, second)
+	rec = serve(t, app, http.MethodPut, "/v1/auth/email-verification", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res userResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(t, user.ID, res.ID)
+	require.True(t, res.IsEmailVerified)
+	require.True(t, store.users[user.ID].IsEmailVerified)
+
+	// The token is single-use and the user's other tokens are expired too.
+	for _, verificationToken := range []string{second, first} {
+		body := fmt.Sprintf(`{"token": %q}`, verificationToken)
+		rec := serve(t, app, http.MethodPut, "/v1/auth/email-verification", []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+
+	// A verified address gets no more tokens.
+	rec = serveAs(t, app, user.ID, http.MethodPost, path, nil)
+	requireErrorResponse(t, rec, http.StatusBadRequest)
+}
+
+func TestVerifyEmailInvalid(t *testing.T) {
+	store := newMemStore()
+	user := addPendingUser(t, store)
+
+	expired, err := util.GenerateToken()
+	require.NoError(t, err)
+	store.verifies[util.HashToken(expired)] = db.EmailVerificationToken{
+		TokenHash: util.HashToken(expired),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+
+	unknown, err := util.GenerateToken()
+	require.NoError(t, err)
+
+	app := newTestApp(t, store)
+
+	for name, verificationToken := range map[string]string{"Expired": expired, "Unknown": unknown, "Malformed": "abc", "Empty": ""} {
+		t.Run(name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"token": %q}`, verificationToken)
+			rec := serve(t, app, http.MethodPut, "/v1/auth/email-verification", []byte(body))
+			requireErrorResponse(t, rec, http.StatusBadRequest)
+		})
+	}
+
+	require.False(t, store.users[user.ID].IsEmailVerified)
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	store := newMemStore()
+	user := addPendingUser(t, store)
+	other := store.addUser(t)
+	app, server := newMailerTestApp(t, store)
+
+	path := fmt.Sprintf("/v1/users/%d/verification-email", user.ID)
+
+	rec := serveAs(t, app, other.ID, http.MethodPost, path, nil)
+	requireErrorResponse(t, rec, http.StatusForbidden)
+
+	rec = serve(t, app, http.MethodPost, path, nil)
+	requireErrorResponse(t, rec, http.StatusUnauthorized)
+
+	for i := 0; i < verificationEmailLimit; i++ {
+		rec = serveAs(t, app, user.ID, http.MethodPost, path, nil)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		require.NotEmpty(t, receivedToken(t, app, server, user.Email))
+	}
+
+	// Further requests within the window are rejected without an email.
+	rec = serveAs(t, app, user.ID, http.MethodPost, path, nil)
+	requireErrorResponse(t, rec, http.StatusTooManyRequests)
+	require.Empty(t, receivedToken(t, app, server, user.Email))
+	require.Len(t, store.verifies, verificationEmailLimit)
+
+	// Tokens older than the window no longer count.
+	for hash, verificationToken := range store.verifies {
+		verificationToken.CreatedAt = time.Now().Add(-verificationEmailWindow)
+		store.verifies[hash] = verificationToken
+	}
+
+	rec = serveAs(t, app, user.ID, http.MethodPost, path, nil)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+}
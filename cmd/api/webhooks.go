@@ -0,0 +1,305 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/events"
+	"github.com/OCD-Labs/KeyKeeper/internal/validator"
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// A webhookResponse is the public representation of a webhook endpoint. Its
+// secret is only included when the endpoint is created.
+type webhookResponse struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(endpoint db.WebhookEndpoint) webhookResponse {
+	return webhookResponse{
+		ID:        endpoint.ID,
+		URL:       endpoint.Url,
+		IsActive:  endpoint.IsActive,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+// A webhookDeliveryResponse is an entry of a webhook endpoint's delivery
+// log.
+type webhookDeliveryResponse struct {
+	ID             int64      `json:"id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int32     `json:"response_status"`
+	LastError      *string    `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newWebhookDeliveryResponse(delivery db.WebhookDelivery) webhookDeliveryResponse {
+	res := webhookDeliveryResponse{
+		ID:        delivery.ID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		CreatedAt: delivery.CreatedAt,
+	}
+	if delivery.Status == webhook.StatusPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		res.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	if delivery.ResponseStatus.Valid {
+		res.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+	if delivery.LastError.Valid {
+		res.LastError = &delivery.LastError.String
+	}
+
+	return res
+}
+
+// validateWebhookURL checks that rawURL is an absolute HTTP or HTTPS URL
+// that does not name a local or private address.
+func validateWebhookURL(v *validator.Validator, rawURL string) {
+	if rawURL == "" {
+		v.AddError("url", "must be provided")
+		return
+	}
+
+	if len(rawURL) > 2048 {
+		v.AddError("url", "must not be more than 2048 bytes long")
+		return
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.AddError("url", "must be an absolute http or https URL")
+		return
+	}
+
+	v.Check(webhook.IsPublicHost(u.Hostname()), "url", "must not point to a local or private address")
+}
+
+// emitReminderEvent queues a webhook event about reminder for its owner's
+// endpoints and publishes it to the owner's event streams. Failures are only
+// logged, as the change being reported has already been made.
+func (app *KeyKeeper) emitReminderEvent(r *http.Request, eventType string, reminder db.Reminder) {
+	event := webhook.NewEvent(eventType, webhook.ReminderData(reminder))
+
+	err := webhook.Enqueue(r.Context(), app.Store, reminder.UserID, event)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	err = events.Publish(r.Context(), app.Store, reminder.UserID, event)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// readOwnedWebhook looks up the webhook endpoint identified by the "id" URL
+// parameter and checks that it belongs to the authenticated caller. It
+// writes the error response and returns false when the endpoint cannot be
+// used.
+func (app *KeyKeeper) readOwnedWebhook(w http.ResponseWriter, r *http.Request) (db.WebhookEndpoint, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return db.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := app.Store.GetWebhookEndpoint(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return db.WebhookEndpoint{}, false
+	}
+
+	if endpoint.UserID != app.contextGetAuthPayload(r).UserID {
+		app.notFoundResponse(w, r)
+		return db.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+}
+
+func (app *KeyKeeper) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL string `json:"url"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validateWebhookURL(v, input.URL)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	endpoint, err := app.Store.CreateWebhookEndpoint(r.Context(), db.CreateWebhookEndpointParams{
+		UserID: app.contextGetAuthPayload(r).UserID,
+		Url:    input.URL,
+		Secret: secret,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	res := newWebhookResponse(endpoint)
+	res.Secret = endpoint.Secret
+
+	err = app.writeJSON(w, http.StatusCreated, res, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := app.Store.ListWebhookEndpoints(r.Context(), app.contextGetAuthPayload(r).UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]webhookResponse, len(endpoints))
+	for i, endpoint := range endpoints {
+		data[i] = newWebhookResponse(endpoint)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *KeyKeeper) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Store.DeleteWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries returns the delivery log of an endpoint, newest
+// first.
+func (app *KeyKeeper) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	page, err := app.readInt(qs, "page", 1)
+	if err != nil {
+		v.AddError("page", "must be an integer value")
+	}
+	pageSize, err := app.readInt(qs, "page_size", defaultPageSize)
+	if err != nil {
+		v.AddError("page_size", "must be an integer value")
+	}
+
+	v.Check(page > 0, "page", "must be greater than zero")
+	v.Check(page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(pageSize > 0, "page_size", "must be greater than zero")
+	v.Check(pageSize <= maxPageSize, "page_size", "must be a maximum of 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	deliveries, err := app.Store.ListWebhookDeliveries(r.Context(), db.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(pageSize),
+		Offset:     int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := make([]webhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		data[i] = newWebhookDeliveryResponse(delivery)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": data}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// sendTestWebhook queues a webhook.test event for a single endpoint, whose
+// outcome shows up in the endpoint's delivery log.
+func (app *KeyKeeper) sendTestWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	event := webhook.NewEvent(webhook.EventTest, map[string]int64{"webhook_id": endpoint.ID})
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	delivery, err := app.Store.CreateWebhookDelivery(r.Context(), db.CreateWebhookDeliveryParams{
+		EndpointID: endpoint.ID,
+		EventID:    event.ID,
+		EventType:  event.Type,
+		Payload:    payload,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, newWebhookDeliveryResponse(delivery), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
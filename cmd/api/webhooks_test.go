@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestWebhook registers a webhook endpoint for userID through the API.
+func createTestWebhook(t *testing.T, app *KeyKeeper, userID int64) webhookResponse {
+	rec := serveAs(t, app, userID, http.MethodPost, "/v1/webhooks", []byte(`{"url": "https://hooks.example.com/keykeeper"}`))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var endpoint webhookResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &endpoint))
+
+	return endpoint
+}
+
+// listTestDeliveries returns the delivery log of a webhook endpoint.
+func listTestDeliveries(t *testing.T, app *KeyKeeper, userID, endpointID int64) []webhookDeliveryResponse {
+	rec := serveAs(t, app, userID, http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", endpointID), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data []webhookDeliveryResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+	return body.Data
+}
+
+func TestCreateWebhook(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	app := newTestApp(t, store)
+
+	endpoint := createTestWebhook(t, app, user.ID)
+	require.NotZero(t, endpoint.ID)
+	require.Equal(t, "https://hooks.example.com/keykeeper", endpoint.URL)
+	require.True(t, endpoint.IsActive)
+	require.True(t, strings.HasPrefix(endpoint.Secret, "whsec_"))
+	require.Equal(t, endpoint.Secret, store.endpoints[endpoint.ID].Secret)
+
+	for _, body := range []string{
+		`{}`,
+		`{"url": "hooks.example.com"}`,
+		`{"url": "ftp://hooks.example.com"}`,
+		`{"url": "https://"}`,
+		`{"url": "http://localhost:8080/hook"}`,
+		`{"url": "http://127.0.0.1/hook"}`,
+		`{"url": "http://169.254.169.254/latest/meta-data"}`,
+		`{"url": "https://[::1]/hook"}`,
+	} {
+		rec := serveAs(t, app, user.ID, http.MethodPost, "/v1/webhooks", []byte(body))
+		requireErrorResponse(t, rec, http.StatusBadRequest)
+	}
+}
+
+func TestListWebhooks(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	endpoint := createTestWebhook(t, app, user.ID)
+	createTestWebhook(t, app, other.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodGet, "/v1/webhooks", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data []webhookResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	require.Equal(t, endpoint.ID, body.Data[0].ID)
+
+	// The secret is only shown on creation.
+	require.Empty(t, body.Data[0].Secret)
+	require.NotContains(t, rec.Body.String(), "secret")
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	endpoint := createTestWebhook(t, app, user.ID)
+	path := fmt.Sprintf("/v1/webhooks/%d", endpoint.ID)
+
+	rec := serveAs(t, app, other.ID, http.MethodDelete, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Empty(t, store.endpoints)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
+
+func TestSendTestWebhook(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	endpoint := createTestWebhook(t, app, user.ID)
+	createTestWebhook(t, app, user.ID)
+	path := fmt.Sprintf("/v1/webhooks/%d/test", endpoint.ID)
+
+	rec := serveAs(t, app, other.ID, http.MethodPost, path, nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+
+	rec = serveAs(t, app, user.ID, http.MethodPost, path, nil)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var delivery webhookDeliveryResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &delivery))
+	require.Equal(t, webhook.EventTest, delivery.EventType)
+	require.Equal(t, webhook.StatusPending, delivery.Status)
+	require.NotNil(t, delivery.NextAttemptAt)
+
+	// Only the endpoint being tested gets the event.
+	require.Len(t, store.delivered, 1)
+	require.Equal(t, endpoint.ID, store.delivered[0].EndpointID)
+
+	var event webhook.Event
+	require.NoError(t, json.Unmarshal(store.delivered[0].Payload, &event))
+	require.Equal(t, delivery.EventID, event.ID)
+	require.Equal(t, map[string]interface{}{"webhook_id": float64(endpoint.ID)}, event.Data)
+
+	deliveries := listTestDeliveries(t, app, user.ID, endpoint.ID)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, delivery.ID, deliveries[0].ID)
+
+	rec = serveAs(t, app, other.ID, http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", endpoint.ID), nil)
+	requireErrorResponse(t, rec, http.StatusNotFound)
+}
+
+func TestReminderWebhookEvents(t *testing.T) {
+	store := newMemStore()
+	user := store.addUser(t)
+	other := store.addUser(t)
+	app := newTestApp(t, store)
+
+	endpoint := createTestWebhook(t, app, user.ID)
+	othersEndpoint := createTestWebhook(t, app, other.ID)
+
+	rec := serveAs(t, app, user.ID, http.MethodPost, "/v1/reminders", []byte(`{"website_url": "example.com", "interval": "90 days"}`))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var reminder reminderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reminder))
+	path := fmt.Sprintf("/v1/reminders/%d?website_url=example.com", reminder.ID)
+
+	rec = serveAs(t, app, user.ID, http.MethodPatch, fmt.Sprintf("/v1/reminders/%d/updated-at?website_url=example.com", reminder.ID), []byte(`{"updated_at": "2024-01-01T00:00:00Z"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, user.ID, http.MethodPatch, fmt.Sprintf("/v1/reminders/%d/interval?website_url=example.com", reminder.ID), []byte(`{"interval": "30 days"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, user.ID, http.MethodPatch, fmt.Sprintf("/v1/reminders/%d/extension?website_url=example.com", reminder.ID), []byte(`{"color": "red"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serveAs(t, app, user.ID, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	deliveries := listTestDeliveries(t, app, user.ID, endpoint.ID)
+	require.Len(t, deliveries, 5)
+	require.Equal(t, webhook.EventReminderDeleted, deliveries[0].EventType)
+	require.Equal(t, webhook.EventReminderUpdated, deliveries[1].EventType)
+	require.Equal(t, webhook.EventReminderUpdated, deliveries[2].EventType)
+	require.Equal(t, webhook.EventReminderRotated, deliveries[3].EventType)
+	require.Equal(t, webhook.EventReminderCreated, deliveries[4].EventType)
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Reminder webhook.Reminder `json:"reminder"`
+		} `json:"data"`
+	}
+	for _, delivery := range store.delivered {
+		require.NoError(t, json.Unmarshal(delivery.Payload, &event))
+		require.Equal(t, delivery.EventType, event.Type)
+		require.Equal(t, reminder.ID, event.Data.Reminder.ID)
+
+		// Events carry the reminder as it was when they were sent.
+		interval := "P30D"
+		if event.Type == webhook.EventReminderCreated || event.Type == webhook.EventReminderRotated {
+			interval = "P90D"
+		}
+		require.Equal(t, interval, event.Data.Reminder.Interval)
+	}
+
+	require.Empty(t, listTestDeliveries(t, app, other.ID, othersEndpoint.ID))
+}
@@ -0,0 +1,84 @@
+// Command merge-reminders canonicalizes the website URLs of the reminders
+// created before migration 000018 and merges the duplicates each user has
+// for a website, keeping the reminder whose password was changed last. The
+// rotations, actions and breaches of the duplicates are moved to it.
+//
+// It reads the same configuration as the server. With -dry-run it only
+// prints the merges it would make. It is safe to run again: users whose
+// reminders are all canonicalized are skipped.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/website"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "print the merges without making them")
+	flag.Parse()
+
+	config, err := util.ParseConfigs("./")
+	if err != nil {
+		log.Fatalf("failed to parse configurations: %v", err)
+	}
+
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		log.Fatalf("failed to open db connection: %v", err)
+	}
+	defer conn.Close()
+
+	store := db.NewStore(conn)
+	ctx := context.Background()
+
+	userIDs, err := store.ListUncanonicalReminderUserIDs(ctx)
+	if err != nil {
+		log.Fatalf("failed to list users: %v", err)
+	}
+
+	var merged, deleted, invalid int
+	for _, userID := range userIDs {
+		reminders, err := store.ListAllReminders(ctx, userID)
+		if err != nil {
+			log.Fatalf("failed to list reminders of user %d: %v", userID, err)
+		}
+
+		merges, skipped := website.Plan(reminders)
+		for _, reminder := range skipped {
+			fmt.Printf("user %d: reminder %d: invalid website URL %q, left alone\n", userID, reminder.ID, reminder.WebsiteUrl)
+		}
+		invalid += len(skipped)
+
+		for _, m := range merges {
+			fmt.Printf("user %d: %s: keep reminder %d (%s)", userID, m.Site.Domain, m.Into.ID, m.Into.WebsiteUrl)
+			for _, reminder := range m.From {
+				fmt.Printf(", merge reminder %d (%s)", reminder.ID, reminder.WebsiteUrl)
+			}
+			fmt.Println()
+
+			if !*dryRun {
+				_, err = m.Apply(ctx, store)
+				if err != nil {
+					log.Fatalf("failed to merge reminders of user %d: %v", userID, err)
+				}
+			}
+
+			merged++
+			deleted += len(m.From)
+		}
+	}
+
+	verb := "canonicalized"
+	if *dryRun {
+		verb = "would canonicalize"
+	}
+	fmt.Printf("%d users: %s %d reminders, merging %d duplicates; %d invalid URLs left alone\n", len(userIDs), verb, merged, deleted, invalid)
+}
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS "sessions_family_id_idx";
+
+DROP INDEX IF EXISTS "sessions_refresh_token_hash_idx";
+
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "rotated_at";
+
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "family_id";
+
+ALTER TABLE "sessions" RENAME COLUMN "refresh_token_hash" TO "refresh_token";
@@ -0,0 +1,17 @@
+ALTER TABLE "sessions" RENAME COLUMN "refresh_token" TO "refresh_token_hash";
+
+-- Existing sessions hold plaintext refresh tokens; keep only their hashes.
+UPDATE "sessions"
+SET "refresh_token_hash" = encode(sha256(convert_to("refresh_token_hash", 'UTF8')), 'hex');
+
+ALTER TABLE "sessions" ADD COLUMN "family_id" uuid;
+
+UPDATE "sessions" SET "family_id" = "id";
+
+ALTER TABLE "sessions" ALTER COLUMN "family_id" SET NOT NULL;
+
+ALTER TABLE "sessions" ADD COLUMN "rotated_at" timestamptz;
+
+CREATE UNIQUE INDEX ON "sessions" ("refresh_token_hash");
+
+CREATE INDEX ON "sessions" ("family_id");
@@ -0,0 +1,16 @@
+ALTER TABLE "users" ADD COLUMN "is_email_verified" boolean NOT NULL DEFAULT false;
+
+-- Accounts created before email verification existed keep working.
+UPDATE "users" SET "is_email_verified" = true;
+
+CREATE TABLE "email_verification_tokens" (
+  "token_hash" varchar PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "email_verification_tokens" ("user_id", "created_at");
+
+ALTER TABLE "email_verification_tokens" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
@@ -0,0 +1,2 @@
+ALTER TABLE "reminders" DROP COLUMN IF EXISTS "last_notified_at";
+ALTER TABLE "reminders" DROP COLUMN IF EXISTS "next_due_at";
@@ -0,0 +1,22 @@
+ALTER TABLE "reminders" ADD COLUMN "next_due_at" timestamptz;
+ALTER TABLE "reminders" ADD COLUMN "last_notified_at" timestamptz;
+
+-- Reminders whose interval Postgres cannot parse are left unscheduled until
+-- their interval is fixed.
+DO $$
+DECLARE
+  r record;
+BEGIN
+  FOR r IN SELECT "id", "updated_at", "interval" FROM "reminders" LOOP
+    BEGIN
+      UPDATE "reminders"
+      SET "next_due_at" = r."updated_at" + r."interval"::interval
+      WHERE "id" = r."id";
+    EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow OR interval_field_overflow THEN
+      NULL;
+    END;
+  END LOOP;
+END
+$$;
+
+CREATE INDEX ON "reminders" ("next_due_at");
@@ -0,0 +1 @@
+ALTER TABLE "reminders" DROP CONSTRAINT IF EXISTS "reminders_interval_format";
@@ -0,0 +1,49 @@
+-- Reminders store normalized ISO 8601 intervals. Legacy intervals are
+-- rewritten before the constraint is validated, so that updating a legacy
+-- reminder cannot fail: Postgres parses them, time components round up to a
+-- whole day and the result is clamped to between one day and ten years.
+-- Intervals Postgres cannot parse were never scheduled; they become P90D and
+-- stay unscheduled until the reminder is updated.
+DO $$
+DECLARE
+  r record;
+  i interval;
+BEGIN
+  FOR r IN SELECT "id", "interval" FROM "reminders"
+    WHERE NOT ("interval" ~ '^P([0-9]+Y)?([0-9]+M)?([0-9]+D)?$' AND "interval" <> 'P')
+  LOOP
+    BEGIN
+      i := upper(regexp_replace(r."interval", '\s*(,|\mand\M)\s*', ' ', 'gi'))::interval;
+    EXCEPTION WHEN OTHERS THEN
+      i := interval '90 days';
+    END;
+
+    IF i - date_trunc('day', i) > interval '0' THEN
+      i := date_trunc('day', i)
+        + ceil(extract(epoch FROM i - date_trunc('day', i)) / 86400) * interval '1 day';
+    END IF;
+
+    IF i < interval '1 day' THEN
+      i := interval '1 day';
+    ELSIF i > interval '10 years' THEN
+      i := interval '10 years';
+    END IF;
+
+    IF extract(month FROM i) < 0 OR extract(day FROM i) < 0 THEN
+      i := justify_interval(i);
+    END IF;
+
+    UPDATE "reminders"
+    SET "interval" = 'P'
+      || CASE WHEN extract(year FROM i) > 0 THEN extract(year FROM i)::int || 'Y' ELSE '' END
+      || CASE WHEN extract(month FROM i) > 0 THEN extract(month FROM i)::int || 'M' ELSE '' END
+      || CASE WHEN extract(day FROM i) > 0 THEN extract(day FROM i)::int || 'D' ELSE '' END
+    WHERE "id" = r."id";
+  END LOOP;
+END
+$$;
+
+ALTER TABLE "reminders" ADD CONSTRAINT "reminders_interval_format"
+  CHECK ("interval" ~ '^P([0-9]+Y)?([0-9]+M)?([0-9]+D)?$' AND "interval" <> 'P') NOT VALID;
+
+ALTER TABLE "reminders" VALIDATE CONSTRAINT "reminders_interval_format";
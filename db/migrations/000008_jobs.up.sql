@@ -0,0 +1,21 @@
+CREATE TABLE "jobs" (
+  "id" bigserial PRIMARY KEY,
+  "kind" varchar NOT NULL,
+  "args" jsonb NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'queued',
+  "attempts" int NOT NULL DEFAULT 0,
+  "max_attempts" int NOT NULL,
+  "run_at" timestamptz NOT NULL DEFAULT (now()),
+  "locked_until" timestamptz,
+  "last_error" varchar,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "finished_at" timestamptz
+);
+
+CREATE INDEX ON "jobs" ("run_at") WHERE "status" = 'queued';
+
+CREATE INDEX ON "jobs" ("locked_until") WHERE "status" = 'running';
+
+CREATE INDEX ON "jobs" ("status", "kind");
+
+ALTER TABLE "users" ADD COLUMN "is_admin" boolean NOT NULL DEFAULT false;
@@ -0,0 +1,13 @@
+CREATE TABLE "push_subscriptions" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "endpoint" varchar UNIQUE NOT NULL,
+  "p256dh" varchar NOT NULL,
+  "auth" varchar NOT NULL,
+  "user_agent" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "push_subscriptions" ("user_id");
+
+ALTER TABLE "push_subscriptions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
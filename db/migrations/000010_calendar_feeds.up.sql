@@ -0,0 +1,7 @@
+CREATE TABLE "calendar_feeds" (
+  "user_id" bigint PRIMARY KEY,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "calendar_feeds" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
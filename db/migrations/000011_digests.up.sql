@@ -0,0 +1,23 @@
+CREATE TABLE "digest_subscriptions" (
+  "user_id" bigint PRIMARY KEY,
+  "frequency" varchar NOT NULL,
+  "next_send_at" timestamptz NOT NULL,
+  "last_sent_at" timestamptz NOT NULL DEFAULT (now()),
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "digest_subscriptions" ("next_send_at");
+
+ALTER TABLE "digest_subscriptions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+-- Reminder alerts held back for the next digest of their user. reminder_id
+-- has no foreign key so that deleting a reminder does not have to wait for
+-- the digest; the digest skips reminders that no longer exist.
+CREATE TABLE "digest_items" (
+  "user_id" bigint NOT NULL,
+  "reminder_id" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("user_id", "reminder_id")
+);
+
+ALTER TABLE "digest_items" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
@@ -0,0 +1,12 @@
+-- Quiet hours are minutes after local midnight in time_zone. Both are NULL
+-- when the user has no quiet hours, and they wrap past midnight when start
+-- is after end.
+CREATE TABLE "user_preferences" (
+  "user_id" bigint PRIMARY KEY,
+  "time_zone" varchar NOT NULL DEFAULT 'UTC',
+  "quiet_hours_start" integer,
+  "quiet_hours_end" integer,
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "user_preferences" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
@@ -0,0 +1,17 @@
+-- Rotations and snoozes of reminders, so that reports can tell them apart.
+-- snoozed_until is only set for snoozes. reminder_id has no foreign key so
+-- that the actions of deleted reminders still count.
+CREATE TABLE "reminder_actions" (
+  "id" bigserial PRIMARY KEY,
+  "reminder_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "action" varchar NOT NULL,
+  "snoozed_until" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "reminder_actions" ("reminder_id", "created_at");
+
+CREATE INDEX ON "reminder_actions" ("user_id", "created_at");
+
+ALTER TABLE "reminder_actions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
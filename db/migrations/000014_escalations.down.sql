@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reminder_escalations;
+DROP TABLE IF EXISTS escalation_policies;
@@ -0,0 +1,34 @@
+-- How reminders that stay overdue are escalated. Overdue reminders are
+-- notified again every repeat_hours until after_notifications notifications
+-- went unanswered or the reminder is after_days overdue, whichever comes
+-- first; from then on they are notified every escalated_repeat_hours over
+-- channels, and contact_email, if set, is told once.
+CREATE TABLE "escalation_policies" (
+  "user_id" bigint PRIMARY KEY,
+  "after_notifications" integer,
+  "after_days" integer,
+  "repeat_hours" integer NOT NULL,
+  "escalated_repeat_hours" integer NOT NULL,
+  "channels" varchar[] NOT NULL,
+  "contact_email" varchar,
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "escalation_policies" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+-- Notifications of an overdue reminder since it fell due. The row is removed
+-- when the reminder is rotated.
+CREATE TABLE "reminder_escalations" (
+  "reminder_id" bigint PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "notifications" integer NOT NULL,
+  "overdue_since" timestamptz NOT NULL,
+  "last_notified_at" timestamptz NOT NULL,
+  "escalated_at" timestamptz
+);
+
+CREATE INDEX ON "reminder_escalations" ("user_id");
+
+ALTER TABLE "reminder_escalations" ADD FOREIGN KEY ("reminder_id") REFERENCES "reminders" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "reminder_escalations" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
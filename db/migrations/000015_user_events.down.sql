@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_events;
+DROP FUNCTION IF EXISTS notify_user_event;
@@ -0,0 +1,27 @@
+-- Events about a user's reminders, kept for a while so that clients of the
+-- event stream can resume after reconnecting. Every insert notifies the
+-- user_events channel with the user's ID, so that each API instance can wake
+-- the streams it serves.
+CREATE TABLE "user_events" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "event_type" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "user_events" ("user_id", "id");
+
+CREATE INDEX ON "user_events" ("created_at");
+
+ALTER TABLE "user_events" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+CREATE FUNCTION notify_user_event() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('user_events', NEW.user_id::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "user_events_notify" AFTER INSERT ON "user_events"
+FOR EACH ROW EXECUTE FUNCTION notify_user_event();
@@ -0,0 +1,28 @@
+-- Every rotation of a reminder's password, as reminders.updated_at only keeps
+-- the last one. source is where the rotation was reported from, and note is
+-- the user's own.
+CREATE TABLE "reminder_rotations" (
+  "id" bigserial PRIMARY KEY,
+  "reminder_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "rotated_at" timestamptz NOT NULL,
+  "source" varchar NOT NULL CHECK ("source" IN ('api', 'email_link', 'import')),
+  "note" varchar,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "reminder_rotations" ("reminder_id", "rotated_at");
+
+CREATE INDEX ON "reminder_rotations" ("user_id", "rotated_at");
+
+ALTER TABLE "reminder_rotations" ADD FOREIGN KEY ("reminder_id") REFERENCES "reminders" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "reminder_rotations" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+-- Rotations recorded as actions so far start the history, timed when they
+-- were reported.
+INSERT INTO "reminder_rotations" ("reminder_id", "user_id", "rotated_at", "source", "created_at")
+SELECT a."reminder_id", a."user_id", a."created_at", 'api', a."created_at"
+FROM "reminder_actions" a
+JOIN "reminders" r ON r."id" = a."reminder_id"
+WHERE a."action" = 'rotated';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reminder_breaches;
+DROP TABLE IF EXISTS breaches;
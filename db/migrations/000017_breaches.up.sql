@@ -0,0 +1,39 @@
+-- Data breaches of websites, imported from feeds in the format of the Have I
+-- Been Pwned breaches API. name is the feed's unique identifier of the
+-- breach, and domain the website it happened at.
+CREATE TABLE "breaches" (
+  "id" bigserial PRIMARY KEY,
+  "name" varchar UNIQUE NOT NULL,
+  "title" varchar NOT NULL,
+  "domain" varchar NOT NULL,
+  "breach_date" date NOT NULL,
+  "added_at" timestamptz NOT NULL,
+  "pwn_count" bigint NOT NULL,
+  "data_classes" varchar[] NOT NULL,
+  "is_verified" boolean NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "breaches" ("domain");
+
+-- Reminders whose website had a breach since their password was last
+-- changed. resolved_at is set when the password is changed.
+CREATE TABLE "reminder_breaches" (
+  "reminder_id" bigint NOT NULL,
+  "breach_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "flagged_at" timestamptz NOT NULL DEFAULT (now()),
+  "resolved_at" timestamptz,
+  PRIMARY KEY ("reminder_id", "breach_id")
+);
+
+CREATE INDEX ON "reminder_breaches" ("user_id");
+
+CREATE INDEX ON "reminder_breaches" ("breach_id");
+
+ALTER TABLE "reminder_breaches" ADD FOREIGN KEY ("reminder_id") REFERENCES "reminders" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "reminder_breaches" ADD FOREIGN KEY ("breach_id") REFERENCES "breaches" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "reminder_breaches" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "reminders_user_id_website_domain_idx";
+
+ALTER TABLE "reminders" DROP COLUMN IF EXISTS "website_domain";
+
+ALTER TABLE "reminders" DROP COLUMN IF EXISTS "website_host";
@@ -0,0 +1,10 @@
+-- The canonical form of website_url, see package website. Canonicalizing
+-- needs the public suffix list, so existing reminders are left NULL for the
+-- merge-reminders tool, which merges the duplicates each user already has
+-- and fills both columns in. NULLs are distinct, so the unique index only
+-- binds reminders that have been canonicalized.
+ALTER TABLE "reminders" ADD COLUMN "website_host" varchar;
+
+ALTER TABLE "reminders" ADD COLUMN "website_domain" varchar;
+
+CREATE UNIQUE INDEX ON "reminders" ("user_id", "website_domain");
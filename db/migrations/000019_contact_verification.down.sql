@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS contact_verification_tokens;
+
+ALTER TABLE "escalation_policies" DROP COLUMN IF EXISTS "contact_verified_at";
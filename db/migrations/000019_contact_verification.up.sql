@@ -0,0 +1,18 @@
+-- The contact of an escalation policy is only emailed about escalations once
+-- they have confirmed their address with a token sent to it. Contacts named
+-- before are unconfirmed, and the contact is unconfirmed again whenever it
+-- is changed.
+ALTER TABLE "escalation_policies" ADD COLUMN "contact_verified_at" timestamptz;
+
+CREATE TABLE "contact_verification_tokens" (
+  "token_hash" varchar PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "contact_email" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "contact_verification_tokens" ("user_id", "created_at");
+
+ALTER TABLE "contact_verification_tokens" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
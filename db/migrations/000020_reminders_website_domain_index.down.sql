@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "reminders_website_domain_idx";
@@ -0,0 +1,2 @@
+-- Breaches flag the reminders of their domain, across users.
+CREATE INDEX ON "reminders" ("website_domain");
@@ -0,0 +1,3 @@
+-- The number of times in a row a due reminder failed to be dispatched, which
+-- sets how far its next attempt is deferred.
+ALTER TABLE "reminders" ADD COLUMN "dispatch_failures" integer NOT NULL DEFAULT 0;
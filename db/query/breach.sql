@@ -0,0 +1,75 @@
+-- name: UpsertBreach :one
+INSERT INTO breaches (
+  name,
+  title,
+  domain,
+  breach_date,
+  added_at,
+  pwn_count,
+  data_classes,
+  is_verified
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+ON CONFLICT (name) DO UPDATE
+SET title = EXCLUDED.title,
+  domain = EXCLUDED.domain,
+  breach_date = EXCLUDED.breach_date,
+  added_at = EXCLUDED.added_at,
+  pwn_count = EXCLUDED.pwn_count,
+  data_classes = EXCLUDED.data_classes,
+  is_verified = EXCLUDED.is_verified,
+  updated_at = now()
+RETURNING *;
+
+-- name: GetBreach :one
+SELECT * FROM breaches
+WHERE id = $1 LIMIT 1;
+
+-- name: FlagBreachedReminders :many
+INSERT INTO reminder_breaches (
+  reminder_id,
+  breach_id,
+  user_id
+)
+SELECT id, sqlc.arg(breach_id), user_id
+FROM reminders
+WHERE updated_at < sqlc.arg(changed_before)
+  AND website_domain = sqlc.arg(website_domain)
+  AND (website_host = sqlc.arg(website_host) OR right(website_host, length(sqlc.arg(website_host)) + 1) = '.' || sqlc.arg(website_host))
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: GetFlaggedReminder :one
+SELECT r.* FROM reminders r
+JOIN reminder_breaches rb ON rb.reminder_id = r.id
+WHERE rb.reminder_id = $1
+  AND rb.breach_id = $2
+  AND rb.resolved_at IS NULL
+LIMIT 1;
+
+-- name: ListReminderBreaches :many
+SELECT b.*, rb.flagged_at, rb.resolved_at
+FROM reminder_breaches rb
+JOIN breaches b ON b.id = rb.breach_id
+WHERE rb.reminder_id = $1
+ORDER BY b.breach_date DESC, b.id DESC;
+
+-- name: ResolveReminderBreaches :exec
+UPDATE reminder_breaches
+SET resolved_at = now()
+WHERE reminder_id = $1 AND resolved_at IS NULL;
+
+-- name: MoveReminderBreaches :exec
+INSERT INTO reminder_breaches (
+  reminder_id,
+  breach_id,
+  user_id,
+  flagged_at,
+  resolved_at
+)
+SELECT DISTINCT ON (breach_id) sqlc.arg(into_id)::bigint, breach_id, user_id, flagged_at, resolved_at
+FROM reminder_breaches
+WHERE reminder_id = ANY(sqlc.arg(from_ids)::bigint[])
+ORDER BY breach_id, resolved_at DESC NULLS FIRST
+ON CONFLICT DO NOTHING;
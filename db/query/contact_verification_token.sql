@@ -0,0 +1,21 @@
+-- name: CreateContactVerificationToken :one
+INSERT INTO contact_verification_tokens (
+  token_hash,
+  user_id,
+  contact_email,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: UseContactVerificationToken :one
+UPDATE contact_verification_tokens
+SET used_at = now()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: CountContactVerificationTokensSince :one
+SELECT count(*) FROM contact_verification_tokens
+WHERE user_id = $1 AND created_at > $2;
@@ -0,0 +1,64 @@
+-- name: UpsertDigestSubscription :one
+INSERT INTO digest_subscriptions (
+  user_id,
+  frequency,
+  next_send_at
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET frequency = EXCLUDED.frequency,
+  next_send_at = EXCLUDED.next_send_at
+RETURNING *;
+
+-- name: GetDigestSubscription :one
+SELECT * FROM digest_subscriptions
+WHERE user_id = $1 LIMIT 1;
+
+-- name: DeleteDigestSubscription :one
+DELETE FROM digest_subscriptions
+WHERE user_id = $1
+RETURNING *;
+
+-- name: ClaimDueDigestSubscriptions :many
+SELECT * FROM digest_subscriptions
+WHERE next_send_at <= now()
+ORDER BY next_send_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: ScheduleDigestSubscription :exec
+UPDATE digest_subscriptions
+SET last_sent_at = $2,
+  next_send_at = $3
+WHERE user_id = $1;
+
+-- name: AddDigestItem :execrows
+INSERT INTO digest_items (
+  user_id,
+  reminder_id
+)
+SELECT sqlc.arg(user_id), sqlc.arg(reminder_id)
+WHERE EXISTS (
+  SELECT 1 FROM digest_subscriptions
+  WHERE user_id = sqlc.arg(user_id)
+)
+ON CONFLICT (user_id, reminder_id) DO UPDATE
+SET created_at = digest_items.created_at;
+
+-- name: TakeDigestItems :many
+DELETE FROM digest_items
+WHERE user_id = $1
+RETURNING reminder_id;
+
+-- name: MoveDigestItems :exec
+INSERT INTO digest_items (
+  user_id,
+  reminder_id,
+  created_at
+)
+SELECT user_id, sqlc.arg(into_id)::bigint, min(created_at)
+FROM digest_items
+WHERE reminder_id = ANY(sqlc.arg(from_ids)::bigint[])
+GROUP BY user_id
+ON CONFLICT (user_id, reminder_id) DO NOTHING;
@@ -0,0 +1,25 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (
+  token_hash,
+  user_id,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = now()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: ExpireUserEmailVerificationTokens :exec
+UPDATE email_verification_tokens
+SET used_at = now()
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: CountEmailVerificationTokensSince :one
+SELECT count(*) FROM email_verification_tokens
+WHERE user_id = $1 AND created_at > $2;
@@ -0,0 +1,76 @@
+-- name: UpsertEscalationPolicy :one
+INSERT INTO escalation_policies (
+  user_id,
+  after_notifications,
+  after_days,
+  repeat_hours,
+  escalated_repeat_hours,
+  channels,
+  contact_email
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (user_id) DO UPDATE
+SET after_notifications = EXCLUDED.after_notifications,
+  after_days = EXCLUDED.after_days,
+  repeat_hours = EXCLUDED.repeat_hours,
+  escalated_repeat_hours = EXCLUDED.escalated_repeat_hours,
+  channels = EXCLUDED.channels,
+  contact_email = EXCLUDED.contact_email,
+  contact_verified_at = CASE
+    WHEN escalation_policies.contact_email = EXCLUDED.contact_email THEN escalation_policies.contact_verified_at
+  END,
+  updated_at = now()
+RETURNING *;
+
+-- name: GetEscalationPolicy :one
+SELECT * FROM escalation_policies
+WHERE user_id = $1 LIMIT 1;
+
+-- name: VerifyEscalationContact :one
+UPDATE escalation_policies
+SET contact_verified_at = now()
+WHERE user_id = $1 AND contact_email = $2
+RETURNING *;
+
+-- name: DeleteEscalationPolicy :execrows
+DELETE FROM escalation_policies
+WHERE user_id = $1;
+
+-- name: GetReminderEscalation :one
+SELECT * FROM reminder_escalations
+WHERE reminder_id = $1 LIMIT 1;
+
+-- name: UpsertReminderEscalation :one
+INSERT INTO reminder_escalations (
+  reminder_id,
+  user_id,
+  notifications,
+  overdue_since,
+  last_notified_at,
+  escalated_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (reminder_id) DO UPDATE
+SET notifications = EXCLUDED.notifications,
+  last_notified_at = EXCLUDED.last_notified_at,
+  escalated_at = EXCLUDED.escalated_at
+RETURNING *;
+
+-- name: DeleteReminderEscalation :exec
+DELETE FROM reminder_escalations
+WHERE reminder_id = $1;
+
+-- name: ClaimDueEscalations :many
+SELECT e.reminder_id, e.user_id, e.notifications, e.overdue_since, e.last_notified_at, e.escalated_at, r.website_url FROM reminder_escalations e
+JOIN escalation_policies p ON p.user_id = e.user_id
+JOIN reminders r ON r.id = e.reminder_id
+JOIN users u ON u.id = e.user_id
+WHERE r.next_due_at IS NULL
+  AND u.is_activated
+  AND u.is_email_verified
+  AND e.last_notified_at + make_interval(hours => CASE WHEN e.escalated_at IS NULL THEN p.repeat_hours ELSE p.escalated_repeat_hours END) <= now()
+ORDER BY e.last_notified_at
+LIMIT $1
+FOR UPDATE OF e SKIP LOCKED;
@@ -0,0 +1,77 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (
+  kind,
+  args,
+  run_at,
+  max_attempts
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = $1 LIMIT 1;
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE (sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(kind)::varchar IS NULL OR kind = sqlc.narg(kind))
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit)
+OFFSET sqlc.arg(row_offset);
+
+-- name: CountJobsByStatus :many
+SELECT status, count(*) FROM jobs
+GROUP BY status
+ORDER BY status;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running',
+  attempts = attempts + 1,
+  locked_until = sqlc.arg(locked_until)
+WHERE id IN (
+  SELECT id FROM jobs
+  WHERE (status = 'queued' AND run_at <= now())
+    OR (status = 'running' AND locked_until <= now())
+  ORDER BY run_at
+  LIMIT sqlc.arg(batch_size)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded',
+  locked_until = NULL,
+  finished_at = now()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'queued',
+  locked_until = NULL,
+  run_at = $2,
+  last_error = $3
+WHERE id = $1;
+
+-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead',
+  locked_until = NULL,
+  last_error = $2,
+  finished_at = now()
+WHERE id = $1;
+
+-- name: DeleteSucceededJobsBefore :execrows
+DELETE FROM jobs
+WHERE status = 'succeeded' AND finished_at < $1;
+
+-- name: RequeueDeadJob :one
+UPDATE jobs
+SET status = 'queued',
+  attempts = 0,
+  run_at = now(),
+  finished_at = NULL
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
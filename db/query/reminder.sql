@@ -3,9 +3,13 @@ INSERT INTO reminders (
   user_id,
   website_url,
   interval,
-  extension
+  extension,
+  updated_at,
+  next_due_at,
+  website_host,
+  website_domain
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: DeleteReminder :exec
@@ -14,14 +18,16 @@ WHERE id = $1 AND website_url = $2;
 
 -- name: SetNewInterval :one
 UPDATE reminders
-SET interval = sqlc.arg(new_interval)
+SET interval = sqlc.arg(new_interval),
+  next_due_at = sqlc.arg(next_due_at)
 WHERE id = sqlc.arg(id) AND website_url = sqlc.arg(website_url)
 RETURNING *;
 
 -- name: UpdateReminder :one
 UPDATE reminders
-SET updated_at = $1
-WHERE id = $2 AND website_url = $3
+SET updated_at = $1,
+  next_due_at = $2
+WHERE id = $3 AND website_url = $4
 RETURNING *;
 
 -- name: GetReminderConfigs :one
@@ -45,4 +51,51 @@ SELECT * FROM reminders
 WHERE user_id = $1
 ORDER BY id
 LIMIT $2
-OFFSET $3;
\ No newline at end of file
+OFFSET $3;
+
+-- name: ClaimDueReminders :many
+SELECT r.* FROM reminders r
+JOIN users u ON u.id = r.user_id
+WHERE r.next_due_at <= now()
+  AND u.is_activated
+  AND u.is_email_verified
+ORDER BY r.next_due_at
+LIMIT $1
+FOR UPDATE OF r SKIP LOCKED;
+
+-- name: MarkReminderNotified :exec
+UPDATE reminders
+SET next_due_at = NULL, last_notified_at = now(), dispatch_failures = 0
+WHERE id = $1;
+
+-- name: DeferReminderDispatch :exec
+UPDATE reminders
+SET next_due_at = $1, dispatch_failures = dispatch_failures + 1
+WHERE id = $2;
+
+-- name: ListAllReminders :many
+SELECT * FROM reminders
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: SnoozeReminder :one
+UPDATE reminders
+SET next_due_at = $1
+WHERE id = $2 AND website_url = $3
+RETURNING *;
+
+-- name: SetReminderWebsite :one
+UPDATE reminders
+SET website_host = $1,
+  website_domain = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteReminders :exec
+DELETE FROM reminders
+WHERE id = ANY(sqlc.arg(ids)::bigint[]);
+
+-- name: ListUncanonicalReminderUserIDs :many
+SELECT DISTINCT user_id FROM reminders
+WHERE website_domain IS NULL
+ORDER BY user_id;
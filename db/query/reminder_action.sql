@@ -0,0 +1,19 @@
+-- name: CreateReminderAction :one
+INSERT INTO reminder_actions (
+  reminder_id,
+  user_id,
+  action,
+  snoozed_until
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListReminderActions :many
+SELECT * FROM reminder_actions
+WHERE reminder_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: MoveReminderActions :exec
+UPDATE reminder_actions
+SET reminder_id = sqlc.arg(into_id)
+WHERE reminder_id = ANY(sqlc.arg(from_ids)::bigint[]);
@@ -0,0 +1,26 @@
+-- name: CreateReminderRotation :one
+INSERT INTO reminder_rotations (
+  reminder_id,
+  user_id,
+  rotated_at,
+  source,
+  note
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: ListReminderRotations :many
+SELECT * FROM reminder_rotations
+WHERE reminder_id = $1
+ORDER BY rotated_at DESC, id DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: ListRotatedReminderIDs :many
+SELECT DISTINCT reminder_id FROM reminder_rotations
+WHERE user_id = $1;
+
+-- name: MoveReminderRotations :exec
+UPDATE reminder_rotations
+SET reminder_id = sqlc.arg(into_id)
+WHERE reminder_id = ANY(sqlc.arg(from_ids)::bigint[]);
@@ -11,6 +11,10 @@ INSERT INTO users (
 SELECT * FROM users
 WHERE id = sqlc.arg(user_id) LIMIT 1;
 
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 LIMIT 1;
+
 -- name: DeactivateUser :one
 UPDATE users
 SET is_activated = false
@@ -19,8 +23,8 @@ RETURNING *;
 
 -- name: ChangePassword :one
 UPDATE users
-SET hashed_password = $1
-WHERE email = $2
+SET hashed_password = sqlc.arg(hashed_password), password_changed_at = sqlc.arg(changed_at)
+WHERE email = sqlc.arg(email)
 RETURNING *;
 
 -- name: ChangeEmail :one
@@ -28,3 +32,9 @@ UPDATE users
 SET email = $1
 WHERE id = $2
 RETURNING *;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true
+WHERE id = $1
+RETURNING *;
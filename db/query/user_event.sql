@@ -0,0 +1,29 @@
+-- name: CreateUserEvent :one
+INSERT INTO user_events (
+  user_id,
+  event_type,
+  payload
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: DeleteUserEventsBefore :execrows
+DELETE FROM user_events
+WHERE created_at < $1;
+
+-- name: GetLatestUserEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint FROM user_events
+WHERE user_id = $1;
+
+-- name: ListRecentUserEvents :many
+SELECT * FROM user_events
+WHERE user_id = sqlc.arg(user_id)
+  AND id <= sqlc.arg(until_id)
+  AND created_at >= sqlc.arg(since)
+ORDER BY id;
+
+-- name: ListUserEvents :many
+SELECT * FROM user_events
+WHERE user_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3;
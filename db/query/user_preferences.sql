@@ -0,0 +1,19 @@
+-- name: UpsertUserPreferences :one
+INSERT INTO user_preferences (
+  user_id,
+  time_zone,
+  quiet_hours_start,
+  quiet_hours_end
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET time_zone = EXCLUDED.time_zone,
+  quiet_hours_start = EXCLUDED.quiet_hours_start,
+  quiet_hours_end = EXCLUDED.quiet_hours_end,
+  updated_at = now()
+RETURNING *;
+
+-- name: GetUserPreferences :one
+SELECT * FROM user_preferences
+WHERE user_id = $1 LIMIT 1;
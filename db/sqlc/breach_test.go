@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomBreachParams() UpsertBreachParams {
+	return UpsertBreachParams{
+		Name:        util.RandomString(10),
+		Title:       util.RandomString(10),
+		Domain:      fmt.Sprintf("%s.com", util.RandomString(10)),
+		BreachDate:  time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC),
+		AddedAt:     time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC),
+		PwnCount:    util.RandomNumber(1, 1_000_000),
+		DataClasses: []string{"Email addresses", "Passwords"},
+		IsVerified:  true,
+	}
+}
+
+func createTestBreach(t *testing.T) Breach {
+	arg := randomBreachParams()
+
+	breach, err := testQuerier.UpsertBreach(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, breach.ID)
+	require.Equal(t, arg.Name, breach.Name)
+	require.Equal(t, arg.Title, breach.Title)
+	require.Equal(t, arg.Domain, breach.Domain)
+	require.True(t, arg.BreachDate.Equal(breach.BreachDate))
+	require.WithinDuration(t, arg.AddedAt, breach.AddedAt, time.Second)
+	require.Equal(t, arg.PwnCount, breach.PwnCount)
+	require.Equal(t, arg.DataClasses, breach.DataClasses)
+	require.Equal(t, arg.IsVerified, breach.IsVerified)
+
+	return breach
+}
+
+func createTestReminderAt(t *testing.T, userID int64, websiteURL string, updatedAt time.Time) Reminder {
+	reminder, err := testQuerier.CreateReminder(context.Background(), CreateReminderParams{
+		UserID:     userID,
+		WebsiteUrl: websiteURL,
+		Interval:   "P1Y",
+		UpdatedAt:  updatedAt,
+	})
+	require.NoError(t, err)
+
+	return reminder
+}
+
+// createTestSiteReminder creates a reminder of the canonical website host,
+// of the registrable domain domain, for a new user, as the canonical domain
+// is unique per user.
+func createTestSiteReminder(t *testing.T, host, domain string, updatedAt time.Time) Reminder {
+	user := createTestUser(t)
+	reminder, err := testQuerier.CreateReminder(context.Background(), CreateReminderParams{
+		UserID:        user.ID,
+		WebsiteUrl:    "https://" + host + "/login",
+		Interval:      "P1Y",
+		UpdatedAt:     updatedAt,
+		WebsiteHost:   sql.NullString{String: host, Valid: true},
+		WebsiteDomain: sql.NullString{String: domain, Valid: true},
+	})
+	require.NoError(t, err)
+
+	return reminder
+}
+
+func TestUpsertBreach(t *testing.T) {
+	breach := createTestBreach(t)
+
+	// Importing a breach again updates it in place.
+	arg := randomBreachParams()
+	arg.Name = breach.Name
+	updated, err := testQuerier.UpsertBreach(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, breach.ID, updated.ID)
+	require.Equal(t, arg.Domain, updated.Domain)
+	require.Equal(t, arg.PwnCount, updated.PwnCount)
+	require.False(t, updated.UpdatedAt.Before(breach.UpdatedAt))
+
+	got, err := testQuerier.GetBreach(context.Background(), breach.ID)
+	require.NoError(t, err)
+	require.Equal(t, updated, got)
+}
+
+func TestFlagBreachedReminders(t *testing.T) {
+	breach := createTestBreach(t)
+	before := breach.BreachDate.AddDate(0, -1, 0)
+
+	site := createTestSiteReminder(t, breach.Domain, breach.Domain, before)
+	sub := createTestSiteReminder(t, "accounts."+breach.Domain, breach.Domain, before)
+	createTestSiteReminder(t, breach.Domain, breach.Domain, breach.BreachDate.AddDate(0, 1, 0))
+	createTestSiteReminder(t, "not"+breach.Domain, "not"+breach.Domain, before)
+	createTestSiteReminder(t, breach.Domain+".evil.com", "evil.com", before)
+
+	// Reminders not canonicalized yet are left for the merge-reminders tool.
+	createTestReminderAt(t, site.UserID, "https://"+breach.Domain+"/", before)
+
+	arg := FlagBreachedRemindersParams{
+		BreachID:      breach.ID,
+		ChangedBefore: breach.BreachDate.AddDate(0, 0, 1),
+		WebsiteDomain: breach.Domain,
+		WebsiteHost:   breach.Domain,
+	}
+	flagged, err := testQuerier.FlagBreachedReminders(context.Background(), arg)
+	require.NoError(t, err)
+
+	owners := make(map[int64]int64)
+	for _, flag := range flagged {
+		require.Equal(t, breach.ID, flag.BreachID)
+		require.NotZero(t, flag.FlaggedAt)
+		require.False(t, flag.ResolvedAt.Valid)
+		owners[flag.ReminderID] = flag.UserID
+	}
+	require.Equal(t, map[int64]int64{site.ID: site.UserID, sub.ID: sub.UserID}, owners)
+
+	// A breach of a subdomain leaves its siblings and parent alone.
+	flagged, err = testQuerier.FlagBreachedReminders(context.Background(), FlagBreachedRemindersParams{
+		BreachID:      createTestBreach(t).ID,
+		ChangedBefore: arg.ChangedBefore,
+		WebsiteDomain: breach.Domain,
+		WebsiteHost:   "mail." + breach.Domain,
+	})
+	require.NoError(t, err)
+	require.Empty(t, flagged)
+
+	// Reminders are flagged by a breach only once.
+	flagged, err = testQuerier.FlagBreachedReminders(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, flagged)
+
+	reminder, err := testQuerier.GetFlaggedReminder(context.Background(), GetFlaggedReminderParams{
+		ReminderID: site.ID,
+		BreachID:   breach.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, site.ID, reminder.ID)
+
+	breaches, err := testQuerier.ListReminderBreaches(context.Background(), site.ID)
+	require.NoError(t, err)
+	require.Len(t, breaches, 1)
+	require.Equal(t, breach.ID, breaches[0].ID)
+	require.Equal(t, breach.DataClasses, breaches[0].DataClasses)
+	require.False(t, breaches[0].ResolvedAt.Valid)
+
+	// Resolved flags no longer count.
+	require.NoError(t, testQuerier.ResolveReminderBreaches(context.Background(), site.ID))
+
+	_, err = testQuerier.GetFlaggedReminder(context.Background(), GetFlaggedReminderParams{
+		ReminderID: site.ID,
+		BreachID:   breach.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	breaches, err = testQuerier.ListReminderBreaches(context.Background(), site.ID)
+	require.NoError(t, err)
+	require.Len(t, breaches, 1)
+	require.True(t, breaches[0].ResolvedAt.Valid)
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestCalendarFeed(t *testing.T, userID int64) CalendarFeed {
+	arg := CreateCalendarFeedParams{
+		UserID:    userID,
+		TokenHash: util.HashToken(util.RandomString(26)),
+	}
+
+	feed, err := testQuerier.CreateCalendarFeed(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, feed.UserID)
+	require.Equal(t, arg.TokenHash, feed.TokenHash)
+	require.NotZero(t, feed.CreatedAt)
+
+	return feed
+}
+
+func TestCreateCalendarFeed(t *testing.T) {
+	user := createTestUser(t)
+	feed1 := createTestCalendarFeed(t, user.ID)
+
+	feed2, err := testQuerier.GetCalendarFeed(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, feed1, feed2)
+
+	feed2, err = testQuerier.GetCalendarFeedByTokenHash(context.Background(), feed1.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, feed1, feed2)
+
+	// Creating the feed again replaces its token.
+	feed3 := createTestCalendarFeed(t, user.ID)
+	require.NotEqual(t, feed1.TokenHash, feed3.TokenHash)
+
+	_, err = testQuerier.GetCalendarFeedByTokenHash(context.Background(), feed1.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestDeleteCalendarFeed(t *testing.T) {
+	user := createTestUser(t)
+	createTestCalendarFeed(t, user.ID)
+
+	err := testQuerier.DeleteCalendarFeed(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	_, err = testQuerier.GetCalendarFeed(context.Background(), user.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
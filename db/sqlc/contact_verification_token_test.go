@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestContactVerificationToken creates a verification token for the
+// contact of the user's escalation policy that expires after duration.
+func createTestContactVerificationToken(t *testing.T, userID int64, contactEmail string, duration time.Duration) ContactVerificationToken {
+	plaintext, err := util.GenerateToken()
+	require.NoError(t, err)
+
+	arg := CreateContactVerificationTokenParams{
+		TokenHash:    util.HashToken(plaintext),
+		UserID:       userID,
+		ContactEmail: contactEmail,
+		ExpiresAt:    time.Now().Add(duration),
+	}
+
+	verificationToken, err := testQuerier.CreateContactVerificationToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.TokenHash, verificationToken.TokenHash)
+	require.Equal(t, arg.UserID, verificationToken.UserID)
+	require.Equal(t, arg.ContactEmail, verificationToken.ContactEmail)
+	require.WithinDuration(t, arg.ExpiresAt, verificationToken.ExpiresAt, time.Second)
+	require.False(t, verificationToken.UsedAt.Valid)
+	require.NotZero(t, verificationToken.CreatedAt)
+
+	return verificationToken
+}
+
+func TestUseContactVerificationToken(t *testing.T) {
+	user := createTestUser(t)
+	verificationToken := createTestContactVerificationToken(t, user.ID, "joe@example.com", time.Minute)
+
+	// The first use marks the token as used.
+	verificationToken1, err := testQuerier.UseContactVerificationToken(context.Background(), verificationToken.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, "joe@example.com", verificationToken1.ContactEmail)
+	require.True(t, verificationToken1.UsedAt.Valid)
+
+	// A used token cannot be used again.
+	_, err = testQuerier.UseContactVerificationToken(context.Background(), verificationToken.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// Neither can an expired one.
+	expired := createTestContactVerificationToken(t, user.ID, "joe@example.com", -time.Minute)
+	_, err = testQuerier.UseContactVerificationToken(context.Background(), expired.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestCountContactVerificationTokensSince(t *testing.T) {
+	user := createTestUser(t)
+	since := time.Now().Add(-time.Minute)
+
+	createTestContactVerificationToken(t, user.ID, "joe@example.com", time.Minute)
+	createTestContactVerificationToken(t, user.ID, "ann@example.com", time.Minute)
+
+	count, err := testQuerier.CountContactVerificationTokensSince(context.Background(), CountContactVerificationTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: since,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
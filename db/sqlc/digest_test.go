@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestDigestSubscription(t *testing.T, userID int64, nextSendAt time.Time) DigestSubscription {
+	arg := UpsertDigestSubscriptionParams{
+		UserID:     userID,
+		Frequency:  "daily",
+		NextSendAt: nextSendAt,
+	}
+
+	subscription, err := testQuerier.UpsertDigestSubscription(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, subscription.UserID)
+	require.Equal(t, arg.Frequency, subscription.Frequency)
+	require.WithinDuration(t, arg.NextSendAt, subscription.NextSendAt, time.Second)
+	require.NotZero(t, subscription.LastSentAt)
+	require.NotZero(t, subscription.CreatedAt)
+
+	return subscription
+}
+
+func TestUpsertDigestSubscription(t *testing.T) {
+	user := createTestUser(t)
+	subscription1 := createTestDigestSubscription(t, user.ID, time.Now().Add(time.Hour))
+
+	// Upserting changes the frequency and schedule but keeps when the last
+	// digest was sent.
+	subscription2, err := testQuerier.UpsertDigestSubscription(context.Background(), UpsertDigestSubscriptionParams{
+		UserID:     user.ID,
+		Frequency:  "weekly",
+		NextSendAt: time.Now().AddDate(0, 0, 7),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "weekly", subscription2.Frequency)
+	require.True(t, subscription2.NextSendAt.After(subscription1.NextSendAt))
+	require.Equal(t, subscription1.LastSentAt, subscription2.LastSentAt)
+
+	subscription3, err := testQuerier.GetDigestSubscription(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, subscription2, subscription3)
+}
+
+func TestDeleteDigestSubscription(t *testing.T) {
+	user := createTestUser(t)
+	subscription1 := createTestDigestSubscription(t, user.ID, time.Now().Add(time.Hour))
+
+	subscription2, err := testQuerier.DeleteDigestSubscription(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, subscription1, subscription2)
+
+	_, err = testQuerier.GetDigestSubscription(context.Background(), user.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQuerier.DeleteDigestSubscription(context.Background(), user.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestAddDigestItem(t *testing.T) {
+	user := createTestUser(t)
+	reminder := createTestReminder(t, user.ID)
+	arg := AddDigestItemParams{UserID: user.ID, ReminderID: reminder.ID}
+
+	// Nothing is held back for users without a digest.
+	n, err := testQuerier.AddDigestItem(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	createTestDigestSubscription(t, user.ID, time.Now().Add(time.Hour))
+
+	// Holding back the same alert twice keeps one item.
+	for i := 0; i < 2; i++ {
+		n, err = testQuerier.AddDigestItem(context.Background(), arg)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+	}
+
+	reminderIDs, err := testQuerier.TakeDigestItems(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []int64{reminder.ID}, reminderIDs)
+
+	reminderIDs, err = testQuerier.TakeDigestItems(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Empty(t, reminderIDs)
+}
+
+func TestScheduleDigestSubscription(t *testing.T) {
+	user := createTestUser(t)
+	createTestDigestSubscription(t, user.ID, time.Now().Add(time.Hour))
+
+	now := time.Now()
+	err := testQuerier.ScheduleDigestSubscription(context.Background(), ScheduleDigestSubscriptionParams{
+		UserID:     user.ID,
+		LastSentAt: now,
+		NextSendAt: now.AddDate(0, 0, 1),
+	})
+	require.NoError(t, err)
+
+	subscription, err := testQuerier.GetDigestSubscription(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, now, subscription.LastSentAt, time.Second)
+	require.WithinDuration(t, now.AddDate(0, 0, 1), subscription.NextSendAt, time.Second)
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestEmailVerificationToken creates a verification token for the user
+// that expires after duration, and returns the plaintext token with the row.
+func createTestEmailVerificationToken(t *testing.T, userID int64, duration time.Duration) (string, EmailVerificationToken) {
+	plaintext, err := util.GenerateToken()
+	require.NoError(t, err)
+
+	arg := CreateEmailVerificationTokenParams{
+		TokenHash: util.HashToken(plaintext),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(duration),
+	}
+
+	verificationToken, err := testQuerier.CreateEmailVerificationToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.TokenHash, verificationToken.TokenHash)
+	require.Equal(t, arg.UserID, verificationToken.UserID)
+	require.WithinDuration(t, arg.ExpiresAt, verificationToken.ExpiresAt, time.Second)
+	require.False(t, verificationToken.UsedAt.Valid)
+	require.NotZero(t, verificationToken.CreatedAt)
+
+	return plaintext, verificationToken
+}
+
+func TestCreateEmailVerificationToken(t *testing.T) {
+	user := createTestUser(t)
+	createTestEmailVerificationToken(t, user.ID, time.Minute)
+}
+
+func TestUseEmailVerificationToken(t *testing.T) {
+	user := createTestUser(t)
+	_, verificationToken := createTestEmailVerificationToken(t, user.ID, time.Minute)
+
+	// The first use marks the token as used.
+	verificationToken1, err := testQuerier.UseEmailVerificationToken(context.Background(), verificationToken.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, verificationToken1.UserID)
+	require.True(t, verificationToken1.UsedAt.Valid)
+
+	// A used token cannot be used again.
+	_, err = testQuerier.UseEmailVerificationToken(context.Background(), verificationToken.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// Neither can an expired one.
+	_, expired := createTestEmailVerificationToken(t, user.ID, -time.Minute)
+	_, err = testQuerier.UseEmailVerificationToken(context.Background(), expired.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestExpireUserEmailVerificationTokens(t *testing.T) {
+	user := createTestUser(t)
+	_, verificationToken1 := createTestEmailVerificationToken(t, user.ID, time.Minute)
+	_, verificationToken2 := createTestEmailVerificationToken(t, user.ID, time.Minute)
+
+	err := testQuerier.ExpireUserEmailVerificationTokens(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	for _, verificationToken := range []EmailVerificationToken{verificationToken1, verificationToken2} {
+		_, err = testQuerier.UseEmailVerificationToken(context.Background(), verificationToken.TokenHash)
+		require.EqualError(t, err, sql.ErrNoRows.Error())
+	}
+}
+
+func TestCountEmailVerificationTokensSince(t *testing.T) {
+	user := createTestUser(t)
+	since := time.Now().Add(-time.Minute)
+
+	createTestEmailVerificationToken(t, user.ID, time.Minute)
+	createTestEmailVerificationToken(t, user.ID, time.Minute)
+
+	count, err := testQuerier.CountEmailVerificationTokensSince(context.Background(), CountEmailVerificationTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: since,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	// Tokens created before the given time are not counted.
+	count, err = testQuerier.CountEmailVerificationTokensSince(context.Background(), CountEmailVerificationTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
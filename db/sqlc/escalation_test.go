@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestEscalationPolicy(t *testing.T, userID int64) EscalationPolicy {
+	arg := UpsertEscalationPolicyParams{
+		UserID:               userID,
+		AfterNotifications:   sql.NullInt32{Int32: 3, Valid: true},
+		RepeatHours:          24,
+		EscalatedRepeatHours: 6,
+		Channels:             []string{"email", "push"},
+		ContactEmail:         sql.NullString{String: "joe@example.com", Valid: true},
+	}
+
+	policy, err := testQuerier.UpsertEscalationPolicy(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, policy.UserID)
+	require.Equal(t, arg.AfterNotifications, policy.AfterNotifications)
+	require.False(t, policy.AfterDays.Valid)
+	require.Equal(t, arg.RepeatHours, policy.RepeatHours)
+	require.Equal(t, arg.EscalatedRepeatHours, policy.EscalatedRepeatHours)
+	require.Equal(t, arg.Channels, policy.Channels)
+	require.Equal(t, arg.ContactEmail, policy.ContactEmail)
+	require.NotZero(t, policy.UpdatedAt)
+	require.False(t, policy.ContactVerifiedAt.Valid)
+
+	return policy
+}
+
+// createNotifiedReminder creates a reminder that was notified and not
+// rotated since.
+func createNotifiedReminder(t *testing.T, userID int64) Reminder {
+	reminder := createTestReminder(t, userID)
+
+	reminder, err := testQuerier.UpdateReminder(context.Background(), UpdateReminderParams{
+		ID:         reminder.ID,
+		UpdatedAt:  reminder.UpdatedAt,
+		WebsiteUrl: reminder.WebsiteUrl,
+	})
+	require.NoError(t, err)
+
+	return reminder
+}
+
+func createTestReminderEscalation(t *testing.T, reminder Reminder, lastNotifiedAt time.Time) ReminderEscalation {
+	arg := UpsertReminderEscalationParams{
+		ReminderID:     reminder.ID,
+		UserID:         reminder.UserID,
+		Notifications:  1,
+		OverdueSince:   lastNotifiedAt,
+		LastNotifiedAt: lastNotifiedAt,
+	}
+
+	escalation, err := testQuerier.UpsertReminderEscalation(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ReminderID, escalation.ReminderID)
+	require.Equal(t, arg.UserID, escalation.UserID)
+	require.Equal(t, arg.Notifications, escalation.Notifications)
+	require.WithinDuration(t, arg.OverdueSince, escalation.OverdueSince, time.Second)
+	require.WithinDuration(t, arg.LastNotifiedAt, escalation.LastNotifiedAt, time.Second)
+	require.False(t, escalation.EscalatedAt.Valid)
+
+	return escalation
+}
+
+func TestUpsertEscalationPolicy(t *testing.T) {
+	user := createTestUser(t)
+
+	_, err := testQuerier.GetEscalationPolicy(context.Background(), user.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	policy1 := createTestEscalationPolicy(t, user.ID)
+
+	// Upserting again replaces the policy.
+	policy2, err := testQuerier.UpsertEscalationPolicy(context.Background(), UpsertEscalationPolicyParams{
+		UserID:               user.ID,
+		AfterDays:            sql.NullInt32{Int32: 7, Valid: true},
+		RepeatHours:          48,
+		EscalatedRepeatHours: 48,
+		Channels:             []string{"push"},
+	})
+	require.NoError(t, err)
+	require.False(t, policy2.AfterNotifications.Valid)
+	require.Equal(t, sql.NullInt32{Int32: 7, Valid: true}, policy2.AfterDays)
+	require.Equal(t, []string{"push"}, policy2.Channels)
+	require.False(t, policy2.ContactEmail.Valid)
+	require.False(t, policy2.UpdatedAt.Before(policy1.UpdatedAt))
+
+	policy3, err := testQuerier.GetEscalationPolicy(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, policy2, policy3)
+}
+
+func TestVerifyEscalationContact(t *testing.T) {
+	user := createTestUser(t)
+	policy := createTestEscalationPolicy(t, user.ID)
+
+	// Only the contact the policy names can be verified.
+	_, err := testQuerier.VerifyEscalationContact(context.Background(), VerifyEscalationContactParams{
+		UserID:       user.ID,
+		ContactEmail: sql.NullString{String: "ann@example.com", Valid: true},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	policy1, err := testQuerier.VerifyEscalationContact(context.Background(), VerifyEscalationContactParams{
+		UserID:       user.ID,
+		ContactEmail: policy.ContactEmail,
+	})
+	require.NoError(t, err)
+	require.True(t, policy1.ContactVerifiedAt.Valid)
+
+	// Upserting the same contact keeps it verified; another one is not.
+	arg := UpsertEscalationPolicyParams{
+		UserID:               user.ID,
+		AfterDays:            sql.NullInt32{Int32: 7, Valid: true},
+		RepeatHours:          48,
+		EscalatedRepeatHours: 48,
+		Channels:             []string{"push"},
+		ContactEmail:         policy.ContactEmail,
+	}
+	policy2, err := testQuerier.UpsertEscalationPolicy(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, policy1.ContactVerifiedAt, policy2.ContactVerifiedAt)
+
+	arg.ContactEmail = sql.NullString{String: "ann@example.com", Valid: true}
+	policy3, err := testQuerier.UpsertEscalationPolicy(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, policy3.ContactVerifiedAt.Valid)
+}
+
+func TestDeleteEscalationPolicy(t *testing.T) {
+	user := createTestUser(t)
+	createTestEscalationPolicy(t, user.ID)
+
+	n, err := testQuerier.DeleteEscalationPolicy(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	_, err = testQuerier.GetEscalationPolicy(context.Background(), user.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	n, err = testQuerier.DeleteEscalationPolicy(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestUpsertReminderEscalation(t *testing.T) {
+	reminder := createNotifiedReminder(t, createTestUser(t).ID)
+
+	_, err := testQuerier.GetReminderEscalation(context.Background(), reminder.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	escalation1 := createTestReminderEscalation(t, reminder, time.Now().AddDate(0, 0, -1))
+
+	// Counting another notification keeps the time it became overdue.
+	escalatedAt := time.Now()
+	escalation2, err := testQuerier.UpsertReminderEscalation(context.Background(), UpsertReminderEscalationParams{
+		ReminderID:     reminder.ID,
+		UserID:         reminder.UserID,
+		Notifications:  2,
+		OverdueSince:   escalatedAt,
+		LastNotifiedAt: escalatedAt,
+		EscalatedAt:    sql.NullTime{Time: escalatedAt, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), escalation2.Notifications)
+	require.Equal(t, escalation1.OverdueSince, escalation2.OverdueSince)
+	require.WithinDuration(t, escalatedAt, escalation2.LastNotifiedAt, time.Second)
+	require.WithinDuration(t, escalatedAt, escalation2.EscalatedAt.Time, time.Second)
+
+	escalation3, err := testQuerier.GetReminderEscalation(context.Background(), reminder.ID)
+	require.NoError(t, err)
+	require.Equal(t, escalation2, escalation3)
+
+	err = testQuerier.DeleteReminderEscalation(context.Background(), reminder.ID)
+	require.NoError(t, err)
+
+	_, err = testQuerier.GetReminderEscalation(context.Background(), reminder.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestClaimDueEscalations(t *testing.T) {
+	user := createVerifiedUser(t)
+	createTestEscalationPolicy(t, user.ID)
+
+	due := createNotifiedReminder(t, user.ID)
+	createTestReminderEscalation(t, due, time.Now().AddDate(0, 0, -2))
+
+	notDue := createNotifiedReminder(t, user.ID)
+	createTestReminderEscalation(t, notDue, time.Now().Add(-time.Hour))
+
+	// Rotated reminders are no longer overdue.
+	rotated := createTestReminder(t, user.ID)
+	createTestReminderEscalation(t, rotated, time.Now().AddDate(0, 0, -2))
+
+	// Reminders of users without a policy are notified once only.
+	other := createVerifiedUser(t)
+	noPolicy := createNotifiedReminder(t, other.ID)
+	createTestReminderEscalation(t, noPolicy, time.Now().AddDate(0, 0, -2))
+
+	rows, err := testQuerier.ClaimDueEscalations(context.Background(), 10_000)
+	require.NoError(t, err)
+
+	claimed := make(map[int64]ClaimDueEscalationsRow)
+	for _, row := range rows {
+		claimed[row.ReminderID] = row
+	}
+	require.Contains(t, claimed, due.ID)
+	require.Equal(t, due.WebsiteUrl, claimed[due.ID].WebsiteUrl)
+	require.NotContains(t, claimed, notDue.ID)
+	require.NotContains(t, claimed, rotated.ID)
+	require.NotContains(t, claimed, noPolicy.ID)
+}
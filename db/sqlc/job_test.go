@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestJob(t *testing.T, kind string, runAt time.Time) Job {
+	arg := EnqueueJobParams{
+		Kind:        kind,
+		Args:        json.RawMessage(`{"reminder_id": 1}`),
+		RunAt:       runAt,
+		MaxAttempts: 3,
+	}
+
+	job, err := testQuerier.EnqueueJob(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, job.ID)
+	require.Equal(t, arg.Kind, job.Kind)
+	require.JSONEq(t, string(arg.Args), string(job.Args))
+	require.Equal(t, "queued", job.Status)
+	require.Zero(t, job.Attempts)
+	require.Equal(t, arg.MaxAttempts, job.MaxAttempts)
+	require.WithinDuration(t, arg.RunAt, job.RunAt, time.Second)
+	require.False(t, job.LockedUntil.Valid)
+	require.False(t, job.FinishedAt.Valid)
+
+	return job
+}
+
+// claimTestJobs claims every due job and returns those of kind.
+func claimTestJobs(t *testing.T, q Querier, kind string, lockedUntil time.Time) []Job {
+	claimed, err := q.ClaimJobs(context.Background(), ClaimJobsParams{
+		LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+		BatchSize:   1000,
+	})
+	require.NoError(t, err)
+
+	var jobs []Job
+	for _, job := range claimed {
+		if job.Kind == kind {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+func TestEnqueueJob(t *testing.T) {
+	job := createTestJob(t, "test."+util.RandomString(8), time.Now())
+
+	job1, err := testQuerier.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	require.Equal(t, job.ID, job1.ID)
+	require.Equal(t, job.Kind, job1.Kind)
+}
+
+func TestClaimJobs(t *testing.T) {
+	kind := "test." + util.RandomString(8)
+	due := createTestJob(t, kind, time.Now().Add(-time.Second))
+	later := createTestJob(t, kind, time.Now().Add(time.Hour))
+
+	// Claim due jobs in a transaction that stays open.
+	tx1, err := testDB.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	defer tx1.Rollback()
+
+	claimed := claimTestJobs(t, New(tx1), kind, time.Now().Add(time.Minute))
+	require.Len(t, claimed, 1)
+	require.Equal(t, due.ID, claimed[0].ID)
+	require.Equal(t, "running", claimed[0].Status)
+	require.EqualValues(t, 1, claimed[0].Attempts)
+	require.True(t, claimed[0].LockedUntil.Valid)
+
+	// A concurrent claim skips the locked job instead of waiting for it.
+	tx2, err := testDB.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	defer tx2.Rollback()
+
+	require.Empty(t, claimTestJobs(t, New(tx2), kind, time.Now().Add(time.Minute)))
+	require.NoError(t, tx2.Rollback())
+	require.NoError(t, tx1.Commit())
+
+	// A running job is not claimed again until its lease runs out.
+	require.Empty(t, claimTestJobs(t, testQuerier, kind, time.Now().Add(time.Minute)))
+
+	_, err = testDB.Exec("UPDATE jobs SET locked_until = now() - interval '1 second' WHERE id = $1", due.ID)
+	require.NoError(t, err)
+
+	claimed = claimTestJobs(t, testQuerier, kind, time.Now().Add(time.Minute))
+	require.Len(t, claimed, 1)
+	require.Equal(t, due.ID, claimed[0].ID)
+	require.EqualValues(t, 2, claimed[0].Attempts)
+
+	job, err := testQuerier.GetJob(context.Background(), later.ID)
+	require.NoError(t, err)
+	require.Equal(t, "queued", job.Status)
+}
+
+func TestFinishJobs(t *testing.T) {
+	kind := "test." + util.RandomString(8)
+	succeeded := createTestJob(t, kind, time.Now())
+	retried := createTestJob(t, kind, time.Now())
+	dead := createTestJob(t, kind, time.Now())
+	require.Len(t, claimTestJobs(t, testQuerier, kind, time.Now().Add(time.Minute)), 3)
+
+	err := testQuerier.CompleteJob(context.Background(), succeeded.ID)
+	require.NoError(t, err)
+
+	job, err := testQuerier.GetJob(context.Background(), succeeded.ID)
+	require.NoError(t, err)
+	require.Equal(t, "succeeded", job.Status)
+	require.False(t, job.LockedUntil.Valid)
+	require.True(t, job.FinishedAt.Valid)
+
+	runAt := time.Now().Add(time.Minute)
+	err = testQuerier.RetryJob(context.Background(), RetryJobParams{
+		ID:        retried.ID,
+		RunAt:     runAt,
+		LastError: sql.NullString{String: "smtp unavailable", Valid: true},
+	})
+	require.NoError(t, err)
+
+	job, err = testQuerier.GetJob(context.Background(), retried.ID)
+	require.NoError(t, err)
+	require.Equal(t, "queued", job.Status)
+	require.WithinDuration(t, runAt, job.RunAt, time.Second)
+	require.Equal(t, "smtp unavailable", job.LastError.String)
+	require.False(t, job.LockedUntil.Valid)
+
+	err = testQuerier.DeadLetterJob(context.Background(), DeadLetterJobParams{
+		ID:        dead.ID,
+		LastError: sql.NullString{String: "smtp unavailable", Valid: true},
+	})
+	require.NoError(t, err)
+
+	job, err = testQuerier.GetJob(context.Background(), dead.ID)
+	require.NoError(t, err)
+	require.Equal(t, "dead", job.Status)
+	require.True(t, job.FinishedAt.Valid)
+
+	// Only dead jobs can be requeued, with a fresh set of attempts.
+	_, err = testQuerier.RequeueDeadJob(context.Background(), retried.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	job, err = testQuerier.RequeueDeadJob(context.Background(), dead.ID)
+	require.NoError(t, err)
+	require.Equal(t, "queued", job.Status)
+	require.Zero(t, job.Attempts)
+	require.False(t, job.FinishedAt.Valid)
+}
+
+func TestDeleteSucceededJobsBefore(t *testing.T) {
+	kind := "test." + util.RandomString(8)
+	old := createTestJob(t, kind, time.Now())
+	recent := createTestJob(t, kind, time.Now())
+	dead := createTestJob(t, kind, time.Now())
+	require.Len(t, claimTestJobs(t, testQuerier, kind, time.Now().Add(time.Minute)), 3)
+
+	for _, id := range []int64{old.ID, recent.ID} {
+		require.NoError(t, testQuerier.CompleteJob(context.Background(), id))
+	}
+	require.NoError(t, testQuerier.DeadLetterJob(context.Background(), DeadLetterJobParams{ID: dead.ID}))
+
+	_, err := testDB.Exec("UPDATE jobs SET finished_at = now() - interval '8 days' WHERE id = ANY($1)", pq.Array([]int64{old.ID, dead.ID}))
+	require.NoError(t, err)
+
+	n, err := testQuerier.DeleteSucceededJobsBefore(context.Background(), sql.NullTime{Time: time.Now().AddDate(0, 0, -7), Valid: true})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, int64(1))
+
+	// Only the old succeeded job is deleted; dead jobs are kept.
+	_, err = testQuerier.GetJob(context.Background(), old.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	for _, id := range []int64{recent.ID, dead.ID} {
+		_, err = testQuerier.GetJob(context.Background(), id)
+		require.NoError(t, err)
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	kind := "test." + util.RandomString(8)
+	job1 := createTestJob(t, kind, time.Now())
+	job2 := createTestJob(t, kind, time.Now())
+	createTestJob(t, "test."+util.RandomString(8), time.Now())
+
+	err := testQuerier.DeadLetterJob(context.Background(), DeadLetterJobParams{ID: job1.ID})
+	require.NoError(t, err)
+
+	jobs, err := testQuerier.ListJobs(context.Background(), ListJobsParams{
+		Kind:     sql.NullString{String: kind, Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	require.Equal(t, job2.ID, jobs[0].ID)
+	require.Equal(t, job1.ID, jobs[1].ID)
+
+	jobs, err = testQuerier.ListJobs(context.Background(), ListJobsParams{
+		Status:   sql.NullString{String: "dead", Valid: true},
+		Kind:     sql.NullString{String: kind, Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, job1.ID, jobs[0].ID)
+
+	counts, err := testQuerier.CountJobsByStatus(context.Background())
+	require.NoError(t, err)
+
+	var dead int64
+	for _, row := range counts {
+		if row.Status == "dead" {
+			dead = row.Count
+		}
+	}
+	require.GreaterOrEqual(t, dead, int64(1))
+}
@@ -10,7 +10,9 @@ import (
 	_ "github.com/lib/pq"
 )
 
+var testDB *sql.DB
 var testQuerier Querier
+var testStore Store
 
 func TestMain(m *testing.M) {
 	config, err := util.ParseConfigs("../..")
@@ -18,12 +20,13 @@ func TestMain(m *testing.M) {
 		log.Fatalf("cannot parse configs: %v", err)
 	}
 
-	testdb, err := sql.Open(config.DBDriver, config.DBSource)
+	testDB, err = sql.Open(config.DBDriver, config.DBSource)
 	if err != nil {
 		log.Fatalf("cannot open db connection: %v", err)
 	}
 
-	testQuerier = New(testdb)
+	testQuerier = New(testDB)
+	testStore = NewStore(testDB)
 
 	os.Exit(m.Run())
 }
@@ -3,30 +3,169 @@
 package db
 
 import (
+	"database/sql"
 	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Breach struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Domain      string    `json:"domain"`
+	BreachDate  time.Time `json:"breach_date"`
+	AddedAt     time.Time `json:"added_at"`
+	PwnCount    int64     `json:"pwn_count"`
+	DataClasses []string  `json:"data_classes"`
+	IsVerified  bool      `json:"is_verified"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CalendarFeed struct {
+	UserID    int64     `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ContactVerificationToken struct {
+	TokenHash    string       `json:"token_hash"`
+	UserID       int64        `json:"user_id"`
+	ContactEmail string       `json:"contact_email"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+	UsedAt       sql.NullTime `json:"used_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+type DigestItem struct {
+	UserID     int64     `json:"user_id"`
+	ReminderID int64     `json:"reminder_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type DigestSubscription struct {
+	UserID     int64     `json:"user_id"`
+	Frequency  string    `json:"frequency"`
+	NextSendAt time.Time `json:"next_send_at"`
+	LastSentAt time.Time `json:"last_sent_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type EmailVerificationToken struct {
+	TokenHash string       `json:"token_hash"`
+	UserID    int64        `json:"user_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type EscalationPolicy struct {
+	UserID               int64          `json:"user_id"`
+	AfterNotifications   sql.NullInt32  `json:"after_notifications"`
+	AfterDays            sql.NullInt32  `json:"after_days"`
+	RepeatHours          int32          `json:"repeat_hours"`
+	EscalatedRepeatHours int32          `json:"escalated_repeat_hours"`
+	Channels             []string       `json:"channels"`
+	ContactEmail         sql.NullString `json:"contact_email"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+	ContactVerifiedAt    sql.NullTime   `json:"contact_verified_at"`
+}
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Args        json.RawMessage `json:"args"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil sql.NullTime    `json:"locked_until"`
+	LastError   sql.NullString  `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  sql.NullTime    `json:"finished_at"`
+}
+
+type PasswordResetToken struct {
+	TokenHash string       `json:"token_hash"`
+	UserID    int64        `json:"user_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type PushSubscription struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Endpoint  string    `json:"endpoint"`
+	P256dh    string    `json:"p256dh"`
+	Auth      string    `json:"auth"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Reminder struct {
-	ID         int64           `json:"id"`
-	UserID     int64           `json:"user_id"`
-	WebsiteUrl string          `json:"website_url"`
-	Interval   string          `json:"interval"`
-	UpdatedAt  time.Time       `json:"updated_at"`
-	Extension  json.RawMessage `json:"extension"`
+	ID               int64           `json:"id"`
+	UserID           int64           `json:"user_id"`
+	WebsiteUrl       string          `json:"website_url"`
+	Interval         string          `json:"interval"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	Extension        json.RawMessage `json:"extension"`
+	NextDueAt        sql.NullTime    `json:"next_due_at"`
+	LastNotifiedAt   sql.NullTime    `json:"last_notified_at"`
+	WebsiteHost      sql.NullString  `json:"website_host"`
+	WebsiteDomain    sql.NullString  `json:"website_domain"`
+	DispatchFailures int32           `json:"dispatch_failures"`
+}
+
+type ReminderAction struct {
+	ID           int64        `json:"id"`
+	ReminderID   int64        `json:"reminder_id"`
+	UserID       int64        `json:"user_id"`
+	Action       string       `json:"action"`
+	SnoozedUntil sql.NullTime `json:"snoozed_until"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+type ReminderBreach struct {
+	ReminderID int64        `json:"reminder_id"`
+	BreachID   int64        `json:"breach_id"`
+	UserID     int64        `json:"user_id"`
+	FlaggedAt  time.Time    `json:"flagged_at"`
+	ResolvedAt sql.NullTime `json:"resolved_at"`
+}
+
+type ReminderEscalation struct {
+	ReminderID     int64        `json:"reminder_id"`
+	UserID         int64        `json:"user_id"`
+	Notifications  int32        `json:"notifications"`
+	OverdueSince   time.Time    `json:"overdue_since"`
+	LastNotifiedAt time.Time    `json:"last_notified_at"`
+	EscalatedAt    sql.NullTime `json:"escalated_at"`
+}
+
+type ReminderRotation struct {
+	ID         int64          `json:"id"`
+	ReminderID int64          `json:"reminder_id"`
+	UserID     int64          `json:"user_id"`
+	RotatedAt  time.Time      `json:"rotated_at"`
+	Source     string         `json:"source"`
+	Note       sql.NullString `json:"note"`
+	CreatedAt  time.Time      `json:"created_at"`
 }
 
 type Session struct {
-	ID           uuid.UUID `json:"id"`
-	UserID       int64     `json:"user_id"`
-	RefreshToken string    `json:"refresh_token"`
-	UserAgent    string    `json:"user_agent"`
-	ClientIp     string    `json:"client_ip"`
-	IsBlocked    bool      `json:"is_blocked"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID               uuid.UUID    `json:"id"`
+	UserID           int64        `json:"user_id"`
+	RefreshTokenHash string       `json:"refresh_token_hash"`
+	UserAgent        string       `json:"user_agent"`
+	ClientIp         string       `json:"client_ip"`
+	IsBlocked        bool         `json:"is_blocked"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+	FamilyID         uuid.UUID    `json:"family_id"`
+	RotatedAt        sql.NullTime `json:"rotated_at"`
 }
 
 type User struct {
@@ -37,4 +176,46 @@ type User struct {
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
 	IsActivated       bool      `json:"is_activated"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	IsAdmin           bool      `json:"is_admin"`
+}
+
+type UserEvent struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type UserPreference struct {
+	UserID          int64         `json:"user_id"`
+	TimeZone        string        `json:"time_zone"`
+	QuietHoursStart sql.NullInt32 `json:"quiet_hours_start"`
+	QuietHoursEnd   sql.NullInt32 `json:"quiet_hours_end"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int64           `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  sql.NullTime    `json:"last_attempt_at"`
+	ResponseStatus sql.NullInt32   `json:"response_status"`
+	LastError      sql.NullString  `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type WebhookEndpoint struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestPasswordResetToken creates a reset token for the user that
+// expires after duration, and returns the plaintext token with the row.
+func createTestPasswordResetToken(t *testing.T, userID int64, duration time.Duration) (string, PasswordResetToken) {
+	plaintext, err := util.GenerateToken()
+	require.NoError(t, err)
+
+	arg := CreatePasswordResetTokenParams{
+		TokenHash: util.HashToken(plaintext),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(duration),
+	}
+
+	resetToken, err := testQuerier.CreatePasswordResetToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.TokenHash, resetToken.TokenHash)
+	require.Equal(t, arg.UserID, resetToken.UserID)
+	require.WithinDuration(t, arg.ExpiresAt, resetToken.ExpiresAt, time.Second)
+	require.False(t, resetToken.UsedAt.Valid)
+	require.NotZero(t, resetToken.CreatedAt)
+
+	return plaintext, resetToken
+}
+
+func TestCreatePasswordResetToken(t *testing.T) {
+	user := createTestUser(t)
+	createTestPasswordResetToken(t, user.ID, time.Minute)
+}
+
+func TestUsePasswordResetToken(t *testing.T) {
+	user := createTestUser(t)
+	_, resetToken := createTestPasswordResetToken(t, user.ID, time.Minute)
+
+	// The first use marks the token as used.
+	resetToken1, err := testQuerier.UsePasswordResetToken(context.Background(), resetToken.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, resetToken1.UserID)
+	require.True(t, resetToken1.UsedAt.Valid)
+
+	// A used token cannot be used again.
+	_, err = testQuerier.UsePasswordResetToken(context.Background(), resetToken.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// Neither can an expired one.
+	_, expired := createTestPasswordResetToken(t, user.ID, -time.Minute)
+	_, err = testQuerier.UsePasswordResetToken(context.Background(), expired.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestCountPasswordResetTokensSince(t *testing.T) {
+	user := createTestUser(t)
+	since := time.Now().Add(-time.Minute)
+
+	createTestPasswordResetToken(t, user.ID, time.Minute)
+	createTestPasswordResetToken(t, user.ID, time.Minute)
+
+	count, err := testQuerier.CountPasswordResetTokensSince(context.Background(), CountPasswordResetTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: since,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	// Tokens created before the given time are not counted.
+	count, err = testQuerier.CountPasswordResetTokensSince(context.Background(), CountPasswordResetTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestExpireUserPasswordResetTokens(t *testing.T) {
+	user := createTestUser(t)
+	_, resetToken1 := createTestPasswordResetToken(t, user.ID, time.Minute)
+	_, resetToken2 := createTestPasswordResetToken(t, user.ID, time.Minute)
+
+	err := testQuerier.ExpireUserPasswordResetTokens(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	for _, resetToken := range []PasswordResetToken{resetToken1, resetToken2} {
+		_, err = testQuerier.UsePasswordResetToken(context.Background(), resetToken.TokenHash)
+		require.EqualError(t, err, sql.ErrNoRows.Error())
+	}
+}
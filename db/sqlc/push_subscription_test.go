@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestPushSubscription(t *testing.T, userID int64, endpoint string) PushSubscription {
+	arg := CreatePushSubscriptionParams{
+		UserID:    userID,
+		Endpoint:  endpoint,
+		P256dh:    util.RandomString(87),
+		Auth:      util.RandomString(22),
+		UserAgent: "Mozilla/5.0",
+	}
+
+	sub, err := testQuerier.CreatePushSubscription(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, sub.ID)
+	require.Equal(t, arg.UserID, sub.UserID)
+	require.Equal(t, arg.Endpoint, sub.Endpoint)
+	require.Equal(t, arg.P256dh, sub.P256dh)
+	require.Equal(t, arg.Auth, sub.Auth)
+	require.Equal(t, arg.UserAgent, sub.UserAgent)
+	require.NotZero(t, sub.CreatedAt)
+
+	return sub
+}
+
+func randomPushEndpoint() string {
+	return "https://push.example.com/send/" + util.RandomString(16)
+}
+
+func TestCreatePushSubscription(t *testing.T) {
+	user := createTestUser(t)
+	endpoint := randomPushEndpoint()
+	sub1 := createTestPushSubscription(t, user.ID, endpoint)
+
+	sub2, err := testQuerier.GetPushSubscription(context.Background(), sub1.ID)
+	require.NoError(t, err)
+	require.Equal(t, sub1, sub2)
+
+	// Subscribing an endpoint again replaces its keys and owner.
+	other := createTestUser(t)
+	sub3 := createTestPushSubscription(t, other.ID, endpoint)
+	require.Equal(t, sub1.ID, sub3.ID)
+	require.NotEqual(t, sub1.P256dh, sub3.P256dh)
+}
+
+func TestListPushSubscriptions(t *testing.T) {
+	user := createTestUser(t)
+	sub1 := createTestPushSubscription(t, user.ID, randomPushEndpoint())
+	sub2 := createTestPushSubscription(t, user.ID, randomPushEndpoint())
+	createTestPushSubscription(t, createTestUser(t).ID, randomPushEndpoint())
+
+	subs, err := testQuerier.ListPushSubscriptions(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	require.Equal(t, sub1.ID, subs[0].ID)
+	require.Equal(t, sub2.ID, subs[1].ID)
+}
+
+func TestDeletePushSubscription(t *testing.T) {
+	user := createTestUser(t)
+	sub := createTestPushSubscription(t, user.ID, randomPushEndpoint())
+
+	err := testQuerier.DeletePushSubscription(context.Background(), sub.ID)
+	require.NoError(t, err)
+
+	_, err = testQuerier.GetPushSubscription(context.Background(), sub.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
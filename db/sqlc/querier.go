@@ -4,27 +4,118 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
+	AddDigestItem(ctx context.Context, arg AddDigestItemParams) (int64, error)
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	BlockUserSessions(ctx context.Context, userID int64) error
 	ChangeEmail(ctx context.Context, arg ChangeEmailParams) (User, error)
 	ChangePassword(ctx context.Context, arg ChangePasswordParams) (User, error)
+	ClaimDueDigestSubscriptions(ctx context.Context, limit int32) ([]DigestSubscription, error)
+	ClaimDueEscalations(ctx context.Context, limit int32) ([]ClaimDueEscalationsRow, error)
+	ClaimDueReminders(ctx context.Context, limit int32) ([]Reminder, error)
+	ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]Job, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	CompleteJob(ctx context.Context, id int64) error
+	CountContactVerificationTokensSince(ctx context.Context, arg CountContactVerificationTokensSinceParams) (int64, error)
+	CountEmailVerificationTokensSince(ctx context.Context, arg CountEmailVerificationTokensSinceParams) (int64, error)
+	CountJobsByStatus(ctx context.Context) ([]CountJobsByStatusRow, error)
+	CountPasswordResetTokensSince(ctx context.Context, arg CountPasswordResetTokensSinceParams) (int64, error)
+	CreateCalendarFeed(ctx context.Context, arg CreateCalendarFeedParams) (CalendarFeed, error)
+	CreateContactVerificationToken(ctx context.Context, arg CreateContactVerificationTokenParams) (ContactVerificationToken, error)
+	CreateEmailVerificationToken(ctx context.Context, arg CreateEmailVerificationTokenParams) (EmailVerificationToken, error)
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error)
+	CreatePushSubscription(ctx context.Context, arg CreatePushSubscriptionParams) (PushSubscription, error)
 	CreateReminder(ctx context.Context, arg CreateReminderParams) (Reminder, error)
+	CreateReminderAction(ctx context.Context, arg CreateReminderActionParams) (ReminderAction, error)
+	CreateReminderRotation(ctx context.Context, arg CreateReminderRotationParams) (ReminderRotation, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserEvent(ctx context.Context, arg CreateUserEventParams) (UserEvent, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
 	DeactivateUser(ctx context.Context, arg DeactivateUserParams) (User, error)
+	DeadLetterJob(ctx context.Context, arg DeadLetterJobParams) error
+	DeleteCalendarFeed(ctx context.Context, userID int64) error
+	DeleteDigestSubscription(ctx context.Context, userID int64) (DigestSubscription, error)
+	DeleteEscalationPolicy(ctx context.Context, userID int64) (int64, error)
+	DeletePushSubscription(ctx context.Context, id int64) error
+	DeferReminderDispatch(ctx context.Context, arg DeferReminderDispatchParams) error
 	DeleteReminder(ctx context.Context, arg DeleteReminderParams) error
+	DeleteReminderEscalation(ctx context.Context, reminderID int64) error
+	DeleteReminders(ctx context.Context, ids []int64) error
+	DeleteSucceededJobsBefore(ctx context.Context, finishedAt sql.NullTime) (int64, error)
+	DeleteUserEventsBefore(ctx context.Context, createdAt time.Time) (int64, error)
+	DeleteWebhookEndpoint(ctx context.Context, id int64) error
+	EnqueueJob(ctx context.Context, arg EnqueueJobParams) (Job, error)
+	EnqueueWebhookEvent(ctx context.Context, arg EnqueueWebhookEventParams) error
+	ExpireUserEmailVerificationTokens(ctx context.Context, userID int64) error
+	ExpireUserPasswordResetTokens(ctx context.Context, userID int64) error
+	FlagBreachedReminders(ctx context.Context, arg FlagBreachedRemindersParams) ([]ReminderBreach, error)
+	GetBreach(ctx context.Context, id int64) (Breach, error)
+	GetCalendarFeed(ctx context.Context, userID int64) (CalendarFeed, error)
+	GetCalendarFeedByTokenHash(ctx context.Context, tokenHash string) (CalendarFeed, error)
+	GetDigestSubscription(ctx context.Context, userID int64) (DigestSubscription, error)
+	GetEscalationPolicy(ctx context.Context, userID int64) (EscalationPolicy, error)
+	GetFlaggedReminder(ctx context.Context, arg GetFlaggedReminderParams) (Reminder, error)
+	GetJob(ctx context.Context, id int64) (Job, error)
+	GetLatestUserEventID(ctx context.Context, userID int64) (int64, error)
+	GetPushSubscription(ctx context.Context, id int64) (PushSubscription, error)
 	GetReminder(ctx context.Context, arg GetReminderParams) (Reminder, error)
 	GetReminderConfigs(ctx context.Context, arg GetReminderConfigsParams) (json.RawMessage, error)
+	GetReminderEscalation(ctx context.Context, reminderID int64) (ReminderEscalation, error)
 	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 	GetUser(ctx context.Context, userID int64) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserPreferences(ctx context.Context, userID int64) (UserPreference, error)
+	GetWebhookEndpoint(ctx context.Context, id int64) (WebhookEndpoint, error)
+	ListActiveSessions(ctx context.Context, userID int64) ([]Session, error)
+	ListAllReminders(ctx context.Context, userID int64) ([]Reminder, error)
+	ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error)
+	ListPushSubscriptions(ctx context.Context, userID int64) ([]PushSubscription, error)
+	ListRecentUserEvents(ctx context.Context, arg ListRecentUserEventsParams) ([]UserEvent, error)
+	ListReminderActions(ctx context.Context, reminderID int64) ([]ReminderAction, error)
+	ListReminderBreaches(ctx context.Context, reminderID int64) ([]ListReminderBreachesRow, error)
+	ListReminderRotations(ctx context.Context, arg ListReminderRotationsParams) ([]ReminderRotation, error)
 	ListReminders(ctx context.Context, arg ListRemindersParams) ([]Reminder, error)
+	ListRotatedReminderIDs(ctx context.Context, userID int64) ([]int64, error)
+	ListUncanonicalReminderUserIDs(ctx context.Context) ([]int64, error)
+	ListUserEvents(ctx context.Context, arg ListUserEventsParams) ([]UserEvent, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookEndpoints(ctx context.Context, userID int64) ([]WebhookEndpoint, error)
+	MarkReminderNotified(ctx context.Context, id int64) error
+	MoveDigestItems(ctx context.Context, arg MoveDigestItemsParams) error
+	MoveReminderActions(ctx context.Context, arg MoveReminderActionsParams) error
+	MoveReminderBreaches(ctx context.Context, arg MoveReminderBreachesParams) error
+	MoveReminderRotations(ctx context.Context, arg MoveReminderRotationsParams) error
+	RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) error
+	RequeueDeadJob(ctx context.Context, id int64) (Job, error)
+	ResolveReminderBreaches(ctx context.Context, reminderID int64) error
+	RetryJob(ctx context.Context, arg RetryJobParams) error
+	RotateSession(ctx context.Context, id uuid.UUID) (Session, error)
+	ScheduleDigestSubscription(ctx context.Context, arg ScheduleDigestSubscriptionParams) error
 	SetNewInterval(ctx context.Context, arg SetNewIntervalParams) (Reminder, error)
 	SetReminderConfigs(ctx context.Context, arg SetReminderConfigsParams) (Reminder, error)
+	SetReminderWebsite(ctx context.Context, arg SetReminderWebsiteParams) (Reminder, error)
+	SnoozeReminder(ctx context.Context, arg SnoozeReminderParams) (Reminder, error)
+	TakeDigestItems(ctx context.Context, userID int64) ([]int64, error)
 	UpdateReminder(ctx context.Context, arg UpdateReminderParams) (Reminder, error)
+	UpsertBreach(ctx context.Context, arg UpsertBreachParams) (Breach, error)
+	UpsertDigestSubscription(ctx context.Context, arg UpsertDigestSubscriptionParams) (DigestSubscription, error)
+	UpsertEscalationPolicy(ctx context.Context, arg UpsertEscalationPolicyParams) (EscalationPolicy, error)
+	UpsertReminderEscalation(ctx context.Context, arg UpsertReminderEscalationParams) (ReminderEscalation, error)
+	UpsertUserPreferences(ctx context.Context, arg UpsertUserPreferencesParams) (UserPreference, error)
+	UseContactVerificationToken(ctx context.Context, tokenHash string) (ContactVerificationToken, error)
+	UseEmailVerificationToken(ctx context.Context, tokenHash string) (EmailVerificationToken, error)
+	UsePasswordResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error)
+	VerifyEscalationContact(ctx context.Context, arg VerifyEscalationContactParams) (EscalationPolicy, error)
+	VerifyUserEmail(ctx context.Context, id int64) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
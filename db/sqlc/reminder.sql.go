@@ -5,26 +5,83 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+const claimDueReminders = `-- name: ClaimDueReminders :many
+SELECT r.id, r.user_id, r.website_url, r.interval, r.updated_at, r.extension, r.next_due_at, r.last_notified_at, r.website_host, r.website_domain, r.dispatch_failures FROM reminders r
+JOIN users u ON u.id = r.user_id
+WHERE r.next_due_at <= now()
+  AND u.is_activated
+  AND u.is_email_verified
+ORDER BY r.next_due_at
+LIMIT $1
+FOR UPDATE OF r SKIP LOCKED
+`
+
+func (q *Queries) ClaimDueReminders(ctx context.Context, limit int32) ([]Reminder, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueReminders, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Reminder{}
+	for rows.Next() {
+		var i Reminder
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.WebsiteUrl,
+			&i.Interval,
+			&i.UpdatedAt,
+			&i.Extension,
+			&i.NextDueAt,
+			&i.LastNotifiedAt,
+			&i.WebsiteHost,
+			&i.WebsiteDomain,
+			&i.DispatchFailures,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createReminder = `-- name: CreateReminder :one
 INSERT INTO reminders (
   user_id,
   website_url,
   interval,
-  extension
+  extension,
+  updated_at,
+  next_due_at,
+  website_host,
+  website_domain
 ) VALUES (
-  $1, $2, $3, $4
-) RETURNING id, user_id, website_url, interval, updated_at, extension
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures
 `
 
 type CreateReminderParams struct {
-	UserID     int64           `json:"user_id"`
-	WebsiteUrl string          `json:"website_url"`
-	Interval   string          `json:"interval"`
-	Extension  json.RawMessage `json:"extension"`
+	UserID        int64           `json:"user_id"`
+	WebsiteUrl    string          `json:"website_url"`
+	Interval      string          `json:"interval"`
+	Extension     json.RawMessage `json:"extension"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	NextDueAt     sql.NullTime    `json:"next_due_at"`
+	WebsiteHost   sql.NullString  `json:"website_host"`
+	WebsiteDomain sql.NullString  `json:"website_domain"`
 }
 
 func (q *Queries) CreateReminder(ctx context.Context, arg CreateReminderParams) (Reminder, error) {
@@ -33,6 +90,10 @@ func (q *Queries) CreateReminder(ctx context.Context, arg CreateReminderParams)
 		arg.WebsiteUrl,
 		arg.Interval,
 		arg.Extension,
+		arg.UpdatedAt,
+		arg.NextDueAt,
+		arg.WebsiteHost,
+		arg.WebsiteDomain,
 	)
 	var i Reminder
 	err := row.Scan(
@@ -42,10 +103,31 @@ func (q *Queries) CreateReminder(ctx context.Context, arg CreateReminderParams)
 		&i.Interval,
 		&i.UpdatedAt,
 		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
 	)
 	return i, err
 }
 
+const deferReminderDispatch = `-- name: DeferReminderDispatch :exec
+UPDATE reminders
+SET next_due_at = $1, dispatch_failures = dispatch_failures + 1
+WHERE id = $2
+`
+
+type DeferReminderDispatchParams struct {
+	NextDueAt sql.NullTime `json:"next_due_at"`
+	ID        int64        `json:"id"`
+}
+
+func (q *Queries) DeferReminderDispatch(ctx context.Context, arg DeferReminderDispatchParams) error {
+	_, err := q.db.ExecContext(ctx, deferReminderDispatch, arg.NextDueAt, arg.ID)
+	return err
+}
+
 const deleteReminder = `-- name: DeleteReminder :exec
 DELETE FROM reminders
 WHERE id = $1 AND website_url = $2
@@ -61,8 +143,18 @@ func (q *Queries) DeleteReminder(ctx context.Context, arg DeleteReminderParams)
 	return err
 }
 
+const deleteReminders = `-- name: DeleteReminders :exec
+DELETE FROM reminders
+WHERE id = ANY($1::bigint[])
+`
+
+func (q *Queries) DeleteReminders(ctx context.Context, ids []int64) error {
+	_, err := q.db.ExecContext(ctx, deleteReminders, pq.Array(ids))
+	return err
+}
+
 const getReminder = `-- name: GetReminder :one
-SELECT id, user_id, website_url, interval, updated_at, extension FROM reminders
+SELECT id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures FROM reminders
 WHERE id = $1 AND website_url = $2
 LIMIT 1
 `
@@ -82,6 +174,11 @@ func (q *Queries) GetReminder(ctx context.Context, arg GetReminderParams) (Remin
 		&i.Interval,
 		&i.UpdatedAt,
 		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
 	)
 	return i, err
 }
@@ -104,8 +201,49 @@ func (q *Queries) GetReminderConfigs(ctx context.Context, arg GetReminderConfigs
 	return extension, err
 }
 
+const listAllReminders = `-- name: ListAllReminders :many
+SELECT id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures FROM reminders
+WHERE user_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListAllReminders(ctx context.Context, userID int64) ([]Reminder, error) {
+	rows, err := q.db.QueryContext(ctx, listAllReminders, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Reminder{}
+	for rows.Next() {
+		var i Reminder
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.WebsiteUrl,
+			&i.Interval,
+			&i.UpdatedAt,
+			&i.Extension,
+			&i.NextDueAt,
+			&i.LastNotifiedAt,
+			&i.WebsiteHost,
+			&i.WebsiteDomain,
+			&i.DispatchFailures,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listReminders = `-- name: ListReminders :many
-SELECT id, user_id, website_url, interval, updated_at, extension FROM reminders
+SELECT id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures FROM reminders
 WHERE user_id = $1
 ORDER BY id
 LIMIT $2
@@ -134,6 +272,11 @@ func (q *Queries) ListReminders(ctx context.Context, arg ListRemindersParams) ([
 			&i.Interval,
 			&i.UpdatedAt,
 			&i.Extension,
+			&i.NextDueAt,
+			&i.LastNotifiedAt,
+			&i.WebsiteHost,
+			&i.WebsiteDomain,
+			&i.DispatchFailures,
 		); err != nil {
 			return nil, err
 		}
@@ -148,21 +291,68 @@ func (q *Queries) ListReminders(ctx context.Context, arg ListRemindersParams) ([
 	return items, nil
 }
 
+const listUncanonicalReminderUserIDs = `-- name: ListUncanonicalReminderUserIDs :many
+SELECT DISTINCT user_id FROM reminders
+WHERE website_domain IS NULL
+ORDER BY user_id
+`
+
+func (q *Queries) ListUncanonicalReminderUserIDs(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listUncanonicalReminderUserIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var user_id int64
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markReminderNotified = `-- name: MarkReminderNotified :exec
+UPDATE reminders
+SET next_due_at = NULL, last_notified_at = now(), dispatch_failures = 0
+WHERE id = $1
+`
+
+func (q *Queries) MarkReminderNotified(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markReminderNotified, id)
+	return err
+}
+
 const setNewInterval = `-- name: SetNewInterval :one
 UPDATE reminders
-SET interval = $1
-WHERE id = $2 AND website_url = $3
-RETURNING id, user_id, website_url, interval, updated_at, extension
+SET interval = $1,
+  next_due_at = $2
+WHERE id = $3 AND website_url = $4
+RETURNING id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures
 `
 
 type SetNewIntervalParams struct {
-	NewInterval string `json:"new_interval"`
-	ID          int64  `json:"id"`
-	WebsiteUrl  string `json:"website_url"`
+	NewInterval string       `json:"new_interval"`
+	NextDueAt   sql.NullTime `json:"next_due_at"`
+	ID          int64        `json:"id"`
+	WebsiteUrl  string       `json:"website_url"`
 }
 
 func (q *Queries) SetNewInterval(ctx context.Context, arg SetNewIntervalParams) (Reminder, error) {
-	row := q.db.QueryRowContext(ctx, setNewInterval, arg.NewInterval, arg.ID, arg.WebsiteUrl)
+	row := q.db.QueryRowContext(ctx, setNewInterval,
+		arg.NewInterval,
+		arg.NextDueAt,
+		arg.ID,
+		arg.WebsiteUrl,
+	)
 	var i Reminder
 	err := row.Scan(
 		&i.ID,
@@ -171,6 +361,11 @@ func (q *Queries) SetNewInterval(ctx context.Context, arg SetNewIntervalParams)
 		&i.Interval,
 		&i.UpdatedAt,
 		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
 	)
 	return i, err
 }
@@ -179,7 +374,7 @@ const setReminderConfigs = `-- name: SetReminderConfigs :one
 UPDATE reminders
 SET extension = $1
 WHERE id = $2 AND website_url = $3
-RETURNING id, user_id, website_url, interval, updated_at, extension
+RETURNING id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures
 `
 
 type SetReminderConfigsParams struct {
@@ -198,25 +393,102 @@ func (q *Queries) SetReminderConfigs(ctx context.Context, arg SetReminderConfigs
 		&i.Interval,
 		&i.UpdatedAt,
 		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
 	)
 	return i, err
 }
 
-const updateReminder = `-- name: UpdateReminder :one
+const setReminderWebsite = `-- name: SetReminderWebsite :one
 UPDATE reminders
-SET updated_at = $1
+SET website_host = $1,
+  website_domain = $2
+WHERE id = $3
+RETURNING id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures
+`
+
+type SetReminderWebsiteParams struct {
+	WebsiteHost   sql.NullString `json:"website_host"`
+	WebsiteDomain sql.NullString `json:"website_domain"`
+	ID            int64          `json:"id"`
+}
+
+func (q *Queries) SetReminderWebsite(ctx context.Context, arg SetReminderWebsiteParams) (Reminder, error) {
+	row := q.db.QueryRowContext(ctx, setReminderWebsite, arg.WebsiteHost, arg.WebsiteDomain, arg.ID)
+	var i Reminder
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.WebsiteUrl,
+		&i.Interval,
+		&i.UpdatedAt,
+		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
+	)
+	return i, err
+}
+
+const snoozeReminder = `-- name: SnoozeReminder :one
+UPDATE reminders
+SET next_due_at = $1
 WHERE id = $2 AND website_url = $3
-RETURNING id, user_id, website_url, interval, updated_at, extension
+RETURNING id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures
+`
+
+type SnoozeReminderParams struct {
+	NextDueAt  sql.NullTime `json:"next_due_at"`
+	ID         int64        `json:"id"`
+	WebsiteUrl string       `json:"website_url"`
+}
+
+func (q *Queries) SnoozeReminder(ctx context.Context, arg SnoozeReminderParams) (Reminder, error) {
+	row := q.db.QueryRowContext(ctx, snoozeReminder, arg.NextDueAt, arg.ID, arg.WebsiteUrl)
+	var i Reminder
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.WebsiteUrl,
+		&i.Interval,
+		&i.UpdatedAt,
+		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
+	)
+	return i, err
+}
+
+const updateReminder = `-- name: UpdateReminder :one
+UPDATE reminders
+SET updated_at = $1,
+  next_due_at = $2
+WHERE id = $3 AND website_url = $4
+RETURNING id, user_id, website_url, interval, updated_at, extension, next_due_at, last_notified_at, website_host, website_domain, dispatch_failures
 `
 
 type UpdateReminderParams struct {
-	UpdatedAt  time.Time `json:"updated_at"`
-	ID         int64     `json:"id"`
-	WebsiteUrl string    `json:"website_url"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	NextDueAt  sql.NullTime `json:"next_due_at"`
+	ID         int64        `json:"id"`
+	WebsiteUrl string       `json:"website_url"`
 }
 
 func (q *Queries) UpdateReminder(ctx context.Context, arg UpdateReminderParams) (Reminder, error) {
-	row := q.db.QueryRowContext(ctx, updateReminder, arg.UpdatedAt, arg.ID, arg.WebsiteUrl)
+	row := q.db.QueryRowContext(ctx, updateReminder,
+		arg.UpdatedAt,
+		arg.NextDueAt,
+		arg.ID,
+		arg.WebsiteUrl,
+	)
 	var i Reminder
 	err := row.Scan(
 		&i.ID,
@@ -225,6 +497,11 @@ func (q *Queries) UpdateReminder(ctx context.Context, arg UpdateReminderParams)
 		&i.Interval,
 		&i.UpdatedAt,
 		&i.Extension,
+		&i.NextDueAt,
+		&i.LastNotifiedAt,
+		&i.WebsiteHost,
+		&i.WebsiteDomain,
+		&i.DispatchFailures,
 	)
 	return i, err
 }
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestReminderRotation(t *testing.T, reminder Reminder, rotatedAt time.Time) ReminderRotation {
+	arg := CreateReminderRotationParams{
+		ReminderID: reminder.ID,
+		UserID:     reminder.UserID,
+		RotatedAt:  rotatedAt,
+		Source:     RotationSourceImport,
+		Note:       sql.NullString{String: "imported", Valid: true},
+	}
+
+	rotation, err := testQuerier.CreateReminderRotation(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, rotation.ID)
+	require.Equal(t, arg.ReminderID, rotation.ReminderID)
+	require.Equal(t, arg.UserID, rotation.UserID)
+	require.WithinDuration(t, arg.RotatedAt, rotation.RotatedAt, time.Second)
+	require.Equal(t, arg.Source, rotation.Source)
+	require.Equal(t, arg.Note, rotation.Note)
+	require.NotZero(t, rotation.CreatedAt)
+
+	return rotation
+}
+
+func TestListReminderRotations(t *testing.T) {
+	user := createTestUser(t)
+	reminder := createTestReminder(t, user.ID)
+
+	now := time.Now()
+	oldest := createTestReminderRotation(t, reminder, now.AddDate(0, -2, 0))
+	latest := createTestReminderRotation(t, reminder, now)
+	middle := createTestReminderRotation(t, reminder, now.AddDate(0, -1, 0))
+	createTestReminderRotation(t, createTestReminder(t, user.ID), now)
+
+	rotations, err := testQuerier.ListReminderRotations(context.Background(), ListReminderRotationsParams{
+		ReminderID: reminder.ID,
+		Limit:      2,
+	})
+	require.NoError(t, err)
+	require.Len(t, rotations, 2)
+	require.Equal(t, latest.ID, rotations[0].ID)
+	require.Equal(t, middle.ID, rotations[1].ID)
+
+	rotations, err = testQuerier.ListReminderRotations(context.Background(), ListReminderRotationsParams{
+		ReminderID: reminder.ID,
+		Limit:      2,
+		Offset:     2,
+	})
+	require.NoError(t, err)
+	require.Len(t, rotations, 1)
+	require.Equal(t, oldest.ID, rotations[0].ID)
+}
+
+func TestCreateReminderRotationSource(t *testing.T) {
+	reminder := createTestReminder(t, createTestUser(t).ID)
+
+	_, err := testQuerier.CreateReminderRotation(context.Background(), CreateReminderRotationParams{
+		ReminderID: reminder.ID,
+		UserID:     reminder.UserID,
+		RotatedAt:  time.Now(),
+		Source:     "fax",
+	})
+	require.Error(t, err)
+}
+
+func TestListRotatedReminderIDs(t *testing.T) {
+	user := createTestUser(t)
+	rotated := createTestReminder(t, user.ID)
+	createTestReminder(t, user.ID)
+
+	createTestReminderRotation(t, rotated, time.Now().AddDate(0, -1, 0))
+	createTestReminderRotation(t, rotated, time.Now())
+	createTestReminderRotation(t, createTestReminder(t, createTestUser(t).ID), time.Now())
+
+	ids, err := testQuerier.ListRotatedReminderIDs(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []int64{rotated.ID}, ids)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"os"
 	"testing"
 	"time"
 
@@ -28,12 +29,15 @@ func createTestReminder(t *testing.T, userID int64) Reminder {
 	buf, err := json.Marshal(ext)
 	require.NoError(t, err)
 
-	// Define arguments for creating a reminder
+	// Define arguments for creating a reminder due in two weeks
+	now := time.Now()
 	arg := CreateReminderParams{
 		UserID:     userID,
 		WebsiteUrl: util.RandomWebsiteURL(),
-		Interval:   "2 weeks",
+		Interval:   "P14D",
 		Extension:  buf,
+		UpdatedAt:  now,
+		NextDueAt:  sql.NullTime{Time: now.AddDate(0, 0, 14), Valid: true},
 	}
 
 	// Call the CreateReminder function with the arguments
@@ -46,7 +50,10 @@ func createTestReminder(t *testing.T, userID int64) Reminder {
 	require.Equal(t, userID, reminder.UserID)
 	require.Equal(t, arg.WebsiteUrl, reminder.WebsiteUrl)
 	require.Equal(t, arg.Interval, reminder.Interval)
-	require.NotZero(t, reminder.UpdatedAt)
+	require.WithinDuration(t, arg.UpdatedAt, reminder.UpdatedAt, time.Second)
+	require.True(t, reminder.NextDueAt.Valid)
+	require.WithinDuration(t, arg.NextDueAt.Time, reminder.NextDueAt.Time, time.Second)
+	require.False(t, reminder.LastNotifiedAt.Valid)
 
 	// Unmarshal the reminder's extension into an extension
 	// struct and check that it matches the original extension
@@ -145,6 +152,19 @@ func TestListReminders(t *testing.T) {
 	}
 }
 
+func TestListAllReminders(t *testing.T) {
+	user := createTestUser(t)
+	reminder1 := createTestReminder(t, user.ID)
+	reminder2 := createTestReminder(t, user.ID)
+	createTestReminder(t, createTestUser(t).ID)
+
+	reminders, err := testQuerier.ListAllReminders(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+	require.Equal(t, reminder1.ID, reminders[0].ID)
+	require.Equal(t, reminder2.ID, reminders[1].ID)
+}
+
 func TestSetNewInterval(t *testing.T) {
 	// Create a test user and reminder.
 	user := createTestUser(t)
@@ -152,7 +172,8 @@ func TestSetNewInterval(t *testing.T) {
 
 	// Set the arguments for the SetNewInterval function.
 	arg := SetNewIntervalParams{
-		NewInterval: "1 month",
+		NewInterval: "P1M",
+		NextDueAt:   sql.NullTime{Time: reminder.UpdatedAt.AddDate(0, 1, 0), Valid: true},
 		ID:          reminder.ID,
 		WebsiteUrl:  reminder.WebsiteUrl,
 	}
@@ -167,6 +188,14 @@ func TestSetNewInterval(t *testing.T) {
 	require.Equal(t, user.ID, reminder.UserID)
 
 	require.Equal(t, arg.NewInterval, reminder1.Interval)
+
+	require.True(t, reminder1.NextDueAt.Valid)
+	require.WithinDuration(t, arg.NextDueAt.Time, reminder1.NextDueAt.Time, time.Second)
+
+	// Intervals that are not normalized ISO 8601 durations are rejected.
+	arg.NewInterval = "1 month"
+	_, err = testQuerier.SetNewInterval(context.Background(), arg)
+	require.Error(t, err)
 }
 
 func TestUpdateReminderConfigs(t *testing.T) {
@@ -215,9 +244,11 @@ func TestUpdateReminder(t *testing.T) {
 	reminder := createTestReminder(t, user.ID)
 
 	// Set the arguments for the UpdateReminder function.
+	now := time.Now()
 	arg := UpdateReminderParams{
 		ID:         reminder.ID,
-		UpdatedAt:  time.Now(),
+		UpdatedAt:  now,
+		NextDueAt:  sql.NullTime{Time: now.AddDate(0, 0, 14), Valid: true},
 		WebsiteUrl: reminder.WebsiteUrl,
 	}
 
@@ -230,4 +261,154 @@ func TestUpdateReminder(t *testing.T) {
 	require.Equal(t, reminder.ID, reminder1.ID)
 	require.Equal(t, reminder.WebsiteUrl, reminder1.WebsiteUrl)
 	require.WithinDuration(t, reminder.UpdatedAt, reminder1.UpdatedAt, time.Second)
+
+	require.True(t, reminder1.NextDueAt.Valid)
+	require.WithinDuration(t, arg.NextDueAt.Time, reminder1.NextDueAt.Time, time.Second)
+}
+
+// createDueReminder creates a reminder that fell due a day ago.
+func createDueReminder(t *testing.T, userID int64) Reminder {
+	reminder := createTestReminder(t, userID)
+
+	updatedAt := time.Now().AddDate(0, 0, -15)
+	reminder, err := testQuerier.UpdateReminder(context.Background(), UpdateReminderParams{
+		ID:         reminder.ID,
+		UpdatedAt:  updatedAt,
+		NextDueAt:  sql.NullTime{Time: updatedAt.AddDate(0, 0, 14), Valid: true},
+		WebsiteUrl: reminder.WebsiteUrl,
+	})
+	require.NoError(t, err)
+	require.True(t, reminder.NextDueAt.Time.Before(time.Now()))
+
+	return reminder
+}
+
+// createVerifiedUser creates a user whose email address is verified.
+func createVerifiedUser(t *testing.T) User {
+	user := createTestUser(t)
+
+	user, err := testQuerier.VerifyUserEmail(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	return user
+}
+
+func reminderIDs(reminders []Reminder) []int64 {
+	ids := make([]int64, len(reminders))
+	for i, reminder := range reminders {
+		ids[i] = reminder.ID
+	}
+
+	return ids
+}
+
+func TestClaimDueReminders(t *testing.T) {
+	due := createDueReminder(t, createVerifiedUser(t).ID)
+	notDue := createTestReminder(t, createVerifiedUser(t).ID)
+	unverified := createDueReminder(t, createTestUser(t).ID)
+
+	// Claim due reminders in a transaction that stays open.
+	tx1, err := testDB.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	defer tx1.Rollback()
+
+	claimed, err := New(tx1).ClaimDueReminders(context.Background(), 10_000)
+	require.NoError(t, err)
+	require.Contains(t, reminderIDs(claimed), due.ID)
+	require.NotContains(t, reminderIDs(claimed), notDue.ID)
+	require.NotContains(t, reminderIDs(claimed), unverified.ID)
+
+	// A concurrent claim skips the locked reminder instead of waiting for it.
+	tx2, err := testDB.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	defer tx2.Rollback()
+
+	claimed, err = New(tx2).ClaimDueReminders(context.Background(), 10_000)
+	require.NoError(t, err)
+	require.NotContains(t, reminderIDs(claimed), due.ID)
+}
+
+func TestMarkReminderNotified(t *testing.T) {
+	reminder := createDueReminder(t, createVerifiedUser(t).ID)
+
+	err := testQuerier.MarkReminderNotified(context.Background(), reminder.ID)
+	require.NoError(t, err)
+
+	reminder1, err := testQuerier.GetReminder(context.Background(), GetReminderParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+	})
+	require.NoError(t, err)
+	require.False(t, reminder1.NextDueAt.Valid)
+	require.True(t, reminder1.LastNotifiedAt.Valid)
+	require.WithinDuration(t, time.Now(), reminder1.LastNotifiedAt.Time, time.Second)
+}
+
+func TestListUncanonicalReminderUserIDs(t *testing.T) {
+	pending := createTestUser(t)
+	createTestReminder(t, pending.ID)
+
+	done := createTestUser(t)
+	reminder := createTestReminder(t, done.ID)
+	_, err := testQuerier.SetReminderWebsite(context.Background(), SetReminderWebsiteParams{
+		WebsiteHost:   sql.NullString{String: reminder.WebsiteUrl, Valid: true},
+		WebsiteDomain: sql.NullString{String: reminder.WebsiteUrl, Valid: true},
+		ID:            reminder.ID,
+	})
+	require.NoError(t, err)
+
+	userIDs, err := testQuerier.ListUncanonicalReminderUserIDs(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, userIDs, pending.ID)
+	require.NotContains(t, userIDs, done.ID)
+}
+
+func TestMigrateLegacyIntervals(t *testing.T) {
+	up, err := os.ReadFile("../migrations/000006_reminder_interval_format.up.sql")
+	require.NoError(t, err)
+
+	tx, err := testDB.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	// Run the migration again over legacy intervals, in a transaction that
+	// is rolled back.
+	_, err = tx.Exec(`ALTER TABLE "reminders" DROP CONSTRAINT "reminders_interval_format"`)
+	require.NoError(t, err)
+
+	user := createTestUser(t)
+	want := map[string]string{
+		"90 days":        "P90D",
+		"36 hours":       "P2D",
+		"1 day 12 hours": "P2D",
+		"49 hours":       "P3D",
+		"2 weeks":        "P14D",
+		"1 year":         "P1Y",
+		"5 minutes":      "P1D",
+		"someday":        "P90D",
+		"P1M":            "P1M",
+	}
+	reminders := make(map[string]Reminder, len(want))
+	for legacy := range want {
+		reminder, err := New(tx).CreateReminder(context.Background(), CreateReminderParams{
+			UserID:     user.ID,
+			WebsiteUrl: util.RandomString(10) + ".com",
+			Interval:   legacy,
+			UpdatedAt:  time.Now(),
+		})
+		require.NoError(t, err)
+		reminders[legacy] = reminder
+	}
+
+	_, err = tx.Exec(string(up))
+	require.NoError(t, err)
+
+	for legacy, reminder := range reminders {
+		reminder1, err := New(tx).GetReminder(context.Background(), GetReminderParams{
+			ID:         reminder.ID,
+			WebsiteUrl: reminder.WebsiteUrl,
+		})
+		require.NoError(t, err)
+		require.Equal(t, want[legacy], reminder1.Interval, legacy)
+	}
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
@@ -16,19 +17,29 @@ func createTestSession(t *testing.T) Session {
 	// Create a test user
 	user := createTestUser(t)
 
+	return createTestSessionInFamily(t, user.ID, uuid.Nil)
+}
+
+// createTestSessionInFamily creates a session for the user. A nil familyID
+// starts a new token family.
+func createTestSessionInFamily(t *testing.T, userID int64, familyID uuid.UUID) Session {
 	// Create a new Paseto token maker
 	maker, err := token.NewPasetoMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	// Generate a new token for the user that expires in one minute
-	token, payload, err := maker.CreateToken(time.Minute, user.ID)
+	// Generate a random UUID for the session ID
+	id, err := uuid.NewRandom()
+	require.NoError(t, err)
+
+	// Generate a new token for the session that expires in one minute
+	token, payload, err := maker.CreateToken(token.TypeRefresh, time.Minute, userID, id)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
 
-	// Generate a random UUID for the session ID
-	id, err := uuid.NewRandom()
-	require.NoError(t, err)
+	if familyID == uuid.Nil {
+		familyID = id
+	}
 
 	// Generate a random IP address for the client
 	ip := fmt.Sprintf(
@@ -41,13 +52,14 @@ func createTestSession(t *testing.T) Session {
 
 	// Set the session parameters
 	arg := CreateSessionParams{
-		ID:           id,
-		UserID:       user.ID,
-		RefreshToken: token,
-		UserAgent:    util.RandomString(9),
-		ClientIp:     ip,
-		IsBlocked:    false,
-		ExpiresAt:    payload.ExpiredAt,
+		ID:               id,
+		UserID:           userID,
+		RefreshTokenHash: util.HashToken(token),
+		UserAgent:        util.RandomString(9),
+		ClientIp:         ip,
+		IsBlocked:        false,
+		ExpiresAt:        payload.ExpiredAt,
+		FamilyID:         familyID,
 	}
 
 	// Call CreateSession and check for errors.
@@ -57,10 +69,12 @@ func createTestSession(t *testing.T) Session {
 
 	// Check that the returned session matches the expected values.
 	require.Equal(t, arg.ID, session.ID)
-	require.Equal(t, user.ID, session.UserID)
-	require.Equal(t, arg.RefreshToken, session.RefreshToken)
+	require.Equal(t, userID, session.UserID)
+	require.Equal(t, arg.RefreshTokenHash, session.RefreshTokenHash)
 	require.Equal(t, ip, session.ClientIp)
+	require.Equal(t, familyID, session.FamilyID)
 	require.False(t, session.IsBlocked)
+	require.False(t, session.RotatedAt.Valid)
 	require.WithinDuration(t, payload.ExpiredAt, session.ExpiresAt, time.Second)
 
 	return session
@@ -84,8 +98,101 @@ func TestGetSession(t *testing.T) {
 	require.Equal(t, session.UserID, session1.UserID)
 	require.Equal(t, session.ClientIp, session1.ClientIp)
 	require.Equal(t, session.UserAgent, session1.UserAgent)
-	require.Equal(t, session.RefreshToken, session1.RefreshToken)
+	require.Equal(t, session.RefreshTokenHash, session1.RefreshTokenHash)
+	require.Equal(t, session.FamilyID, session1.FamilyID)
 	require.Equal(t, session.IsBlocked, session1.IsBlocked)
 	require.WithinDuration(t, session.CreatedAt, session1.CreatedAt, time.Second)
 	require.WithinDuration(t, session.ExpiresAt, session1.ExpiresAt, time.Second)
 }
+
+func TestRotateSession(t *testing.T) {
+	// Create a test session for testing.
+	session := createTestSession(t)
+
+	// Rotate the session and check that it is marked as rotated.
+	session1, err := testQuerier.RotateSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.Equal(t, session.ID, session1.ID)
+	require.True(t, session1.RotatedAt.Valid)
+	require.WithinDuration(t, time.Now(), session1.RotatedAt.Time, time.Second)
+
+	// A session can only be rotated once.
+	session2, err := testQuerier.RotateSession(context.Background(), session.ID)
+	require.Error(t, err)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.Empty(t, session2)
+}
+
+func TestBlockSessionFamily(t *testing.T) {
+	// Create two sessions in one family and one in another.
+	session1 := createTestSession(t)
+	session2 := createTestSessionInFamily(t, session1.UserID, session1.FamilyID)
+	session3 := createTestSessionInFamily(t, session1.UserID, uuid.Nil)
+
+	// Block the first family.
+	err := testQuerier.BlockSessionFamily(context.Background(), session1.FamilyID)
+	require.NoError(t, err)
+
+	// Assert that only the sessions of the first family are blocked.
+	for _, s := range []Session{session1, session2} {
+		blocked, err := testQuerier.GetSession(context.Background(), s.ID)
+		require.NoError(t, err)
+		require.True(t, blocked.IsBlocked)
+	}
+
+	session4, err := testQuerier.GetSession(context.Background(), session3.ID)
+	require.NoError(t, err)
+	require.False(t, session4.IsBlocked)
+
+	// A blocked session cannot be rotated.
+	_, err = testQuerier.RotateSession(context.Background(), session2.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestListActiveSessions(t *testing.T) {
+	// Create a session, rotate it and create its successor.
+	session1 := createTestSession(t)
+	_, err := testQuerier.RotateSession(context.Background(), session1.ID)
+	require.NoError(t, err)
+	session2 := createTestSessionInFamily(t, session1.UserID, session1.FamilyID)
+
+	// Create a second login and block it.
+	session3 := createTestSessionInFamily(t, session1.UserID, uuid.Nil)
+	require.NoError(t, testQuerier.BlockSessionFamily(context.Background(), session3.FamilyID))
+
+	// Create a third login that stays active.
+	session4 := createTestSessionInFamily(t, session1.UserID, uuid.Nil)
+
+	// Only the newest session of each unblocked login is listed.
+	sessions, err := testQuerier.ListActiveSessions(context.Background(), session1.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, session4.ID, sessions[0].ID)
+	require.Equal(t, session2.ID, sessions[1].ID)
+}
+
+func TestBlockUserSessions(t *testing.T) {
+	// Create two logins for one user and one for another user.
+	session1 := createTestSession(t)
+	session2 := createTestSessionInFamily(t, session1.UserID, uuid.Nil)
+	session3 := createTestSession(t)
+
+	// Block every session of the first user.
+	err := testQuerier.BlockUserSessions(context.Background(), session1.UserID)
+	require.NoError(t, err)
+
+	sessions, err := testQuerier.ListActiveSessions(context.Background(), session1.UserID)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	for _, s := range []Session{session1, session2} {
+		blocked, err := testQuerier.GetSession(context.Background(), s.ID)
+		require.NoError(t, err)
+		require.True(t, blocked.IsBlocked)
+	}
+
+	// Assert that the other user's session is untouched.
+	session4, err := testQuerier.GetSession(context.Background(), session3.ID)
+	require.NoError(t, err)
+	require.False(t, session4.IsBlocked)
+}
@@ -0,0 +1,676 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A Store provides all functions to run queries individually
+// and within transactions.
+type Store interface {
+	Querier
+
+	// RefreshSessionTx marks a session as rotated and creates its successor.
+	RefreshSessionTx(ctx context.Context, arg RefreshSessionTxParams) (Session, error)
+
+	// ResetPasswordTx consumes a password reset token and sets a new password.
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error)
+
+	// VerifyEmailTx consumes an email verification token and marks the
+	// email address of its user as verified.
+	VerifyEmailTx(ctx context.Context, tokenHash string) (User, error)
+
+	// VerifyContactTx consumes a contact verification token and marks the
+	// contact of its user's escalation policy as verified.
+	VerifyContactTx(ctx context.Context, tokenHash string) (EscalationPolicy, error)
+
+	// DeactivateUserTx deactivates a user and ends all of their sessions.
+	DeactivateUserTx(ctx context.Context, arg DeactivateUserParams) (User, error)
+
+	// DispatchDueRemindersTx claims a batch of due reminders and dispatches
+	// each of them, deferring the ones whose dispatch fails.
+	DispatchDueRemindersTx(ctx context.Context, arg DispatchDueRemindersTxParams) (DispatchDueRemindersTxResult, error)
+
+	// DispatchDueDigestsTx claims a batch of digest subscriptions that are
+	// due to be sent and dispatches each of them.
+	DispatchDueDigestsTx(ctx context.Context, arg DispatchDueDigestsTxParams) (int, error)
+
+	// TakeDigestItemsTx removes the reminders held back for a user's digest
+	// and hands them to a function that sends it.
+	TakeDigestItemsTx(ctx context.Context, arg TakeDigestItemsTxParams) error
+
+	// DispatchDueEscalationsTx claims a batch of overdue reminders that are
+	// due to be notified again and dispatches each of them.
+	DispatchDueEscalationsTx(ctx context.Context, arg DispatchDueEscalationsTxParams) (int, error)
+
+	// RotateReminderTx records that the password of a reminder was changed,
+	// adds it to the reminder's rotation history, resets its escalation,
+	// resolves its breaches and reschedules the reminder.
+	RotateReminderTx(ctx context.Context, arg RotateReminderTxParams) (Reminder, error)
+
+	// SnoozeReminderTx defers the next notification of a reminder and
+	// records the snooze.
+	SnoozeReminderTx(ctx context.Context, arg SnoozeReminderTxParams) (Reminder, error)
+
+	// ImportBreachTx stores a breach and flags the reminders of its website
+	// whose password has not been changed since.
+	ImportBreachTx(ctx context.Context, arg ImportBreachTxParams) (ImportBreachTxResult, error)
+
+	// MergeRemindersTx merges duplicate reminders of a website into one and
+	// stores the canonical form of its URL.
+	MergeRemindersTx(ctx context.Context, arg MergeRemindersTxParams) (Reminder, error)
+}
+
+// SQLStore is a Store backed by a SQL database.
+type SQLStore struct {
+	*Queries
+	db *sql.DB
+}
+
+// NewStore creates a new SQLStore.
+func NewStore(db *sql.DB) Store {
+	return &SQLStore{
+		Queries: New(db),
+		db:      db,
+	}
+}
+
+// execTx runs fn within a database transaction.
+func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(New(tx))
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshSessionTxParams contains the input parameters of RefreshSessionTx.
+type RefreshSessionTxParams struct {
+	SessionID  uuid.UUID
+	NewSession CreateSessionParams
+}
+
+// RefreshSessionTx marks the session as rotated and creates the new session
+// in the same token family. It returns sql.ErrNoRows when the session was
+// already rotated or blocked, which means its refresh token was reused.
+func (store *SQLStore) RefreshSessionTx(ctx context.Context, arg RefreshSessionTxParams) (Session, error) {
+	var session Session
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		rotated, err := q.RotateSession(ctx, arg.SessionID)
+		if err != nil {
+			return err
+		}
+
+		newSession := arg.NewSession
+		newSession.FamilyID = rotated.FamilyID
+
+		session, err = q.CreateSession(ctx, newSession)
+		return err
+	})
+
+	return session, err
+}
+
+// ResetPasswordTxParams contains the input parameters of ResetPasswordTx.
+type ResetPasswordTxParams struct {
+	TokenHash      string
+	HashedPassword string
+
+	// ChangedAt is recorded as the time the password changed. Tokens
+	// issued before it are revoked, so it must come from the clock that
+	// issues them.
+	ChangedAt time.Time
+}
+
+// ResetPasswordTx marks the reset token as used, changes the password of its
+// user, expires the user's other reset tokens and blocks all of the user's
+// sessions. It returns sql.ErrNoRows when the token is unknown, used or
+// expired.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		resetToken, err := q.UsePasswordResetToken(ctx, arg.TokenHash)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.GetUser(ctx, resetToken.UserID)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.ChangePassword(ctx, ChangePasswordParams{
+			HashedPassword: arg.HashedPassword,
+			ChangedAt:      arg.ChangedAt,
+			Email:          user.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.ExpireUserPasswordResetTokens(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		return q.BlockUserSessions(ctx, user.ID)
+	})
+
+	return user, err
+}
+
+// VerifyEmailTx marks the verification token as used, marks the email address
+// of its user as verified and expires the user's other verification tokens.
+// It returns sql.ErrNoRows when the token is unknown, used or expired.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, tokenHash string) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		verificationToken, err := q.UseEmailVerificationToken(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.VerifyUserEmail(ctx, verificationToken.UserID)
+		if err != nil {
+			return err
+		}
+
+		return q.ExpireUserEmailVerificationTokens(ctx, user.ID)
+	})
+
+	return user, err
+}
+
+// VerifyContactTx marks the contact verification token as used and the
+// contact of its user's escalation policy as verified. It returns
+// sql.ErrNoRows when the token is unknown, used or expired, or when the
+// policy no longer names the address the token was sent to.
+func (store *SQLStore) VerifyContactTx(ctx context.Context, tokenHash string) (EscalationPolicy, error) {
+	var policy EscalationPolicy
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		verificationToken, err := q.UseContactVerificationToken(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		policy, err = q.VerifyEscalationContact(ctx, VerifyEscalationContactParams{
+			UserID:       verificationToken.UserID,
+			ContactEmail: sql.NullString{String: verificationToken.ContactEmail, Valid: true},
+		})
+		return err
+	})
+
+	return policy, err
+}
+
+// DeactivateUserTx deactivates a user and blocks all of their sessions.
+func (store *SQLStore) DeactivateUserTx(ctx context.Context, arg DeactivateUserParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.DeactivateUser(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return q.BlockUserSessions(ctx, user.ID)
+	})
+
+	return user, err
+}
+
+// DispatchDueRemindersTxParams contains the input parameters of
+// DispatchDueRemindersTx.
+type DispatchDueRemindersTxParams struct {
+	Limit int32
+
+	// Dispatch is called with the transaction's Querier for every claimed
+	// reminder. An error rolls back what was done for that reminder only,
+	// which stays due.
+	Dispatch func(ctx context.Context, q Querier, reminder Reminder) error
+
+	// Backoff returns how long to defer a reminder whose dispatch has failed
+	// failures times in a row.
+	Backoff func(failures int) time.Duration
+}
+
+// DispatchDueRemindersTxResult contains the result of
+// DispatchDueRemindersTx.
+type DispatchDueRemindersTxResult struct {
+	// Dispatched is the number of reminders dispatched.
+	Dispatched int
+
+	// Failed is the number of reminders whose dispatch failed.
+	Failed int
+}
+
+// DispatchDueRemindersTx locks up to arg.Limit due reminders of verified,
+// active users, skipping rows locked by other instances, dispatches them and
+// marks them as notified. Each reminder is dispatched in its own savepoint,
+// so that one failing reminder does not hold back the rest of the batch, and
+// a reminder whose dispatch fails is deferred by arg.Backoff, so that it
+// does not stay at the head of the queue either. Along with the result, it
+// returns an error for the first failed reminder, if any.
+func (store *SQLStore) DispatchDueRemindersTx(ctx context.Context, arg DispatchDueRemindersTxParams) (DispatchDueRemindersTxResult, error) {
+	var (
+		result  DispatchDueRemindersTxResult
+		failure error
+	)
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		reminders, err := q.ClaimDueReminders(ctx, arg.Limit)
+		if err != nil {
+			return err
+		}
+
+		for _, reminder := range reminders {
+			_, err = q.db.ExecContext(ctx, "SAVEPOINT dispatch_reminder")
+			if err != nil {
+				return err
+			}
+
+			err = arg.Dispatch(ctx, q, reminder)
+			if err == nil {
+				err = q.MarkReminderNotified(ctx, reminder.ID)
+			}
+			if err != nil {
+				if _, rbErr := q.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT dispatch_reminder"); rbErr != nil {
+					return fmt.Errorf("dispatch reminder %d: %v, rb err: %v", reminder.ID, err, rbErr)
+				}
+
+				deferErr := q.DeferReminderDispatch(ctx, DeferReminderDispatchParams{
+					NextDueAt: sql.NullTime{Time: time.Now().Add(arg.Backoff(int(reminder.DispatchFailures) + 1)), Valid: true},
+					ID:        reminder.ID,
+				})
+				if deferErr != nil {
+					return fmt.Errorf("dispatch reminder %d: %v, defer err: %v", reminder.ID, err, deferErr)
+				}
+
+				if failure == nil {
+					failure = fmt.Errorf("dispatch reminder %d: %w", reminder.ID, err)
+				}
+				result.Failed++
+				continue
+			}
+
+			_, err = q.db.ExecContext(ctx, "RELEASE SAVEPOINT dispatch_reminder")
+			if err != nil {
+				return err
+			}
+			result.Dispatched++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return DispatchDueRemindersTxResult{}, err
+	}
+
+	if result.Failed > 1 {
+		failure = fmt.Errorf("%w (and %d more)", failure, result.Failed-1)
+	}
+
+	return result, failure
+}
+
+// DispatchDueDigestsTxParams contains the input parameters of
+// DispatchDueDigestsTx.
+type DispatchDueDigestsTxParams struct {
+	Limit int32
+
+	// Dispatch is called with the transaction's Querier for every claimed
+	// subscription, and must reschedule it. An error rolls the whole batch
+	// back.
+	Dispatch func(ctx context.Context, q Querier, subscription DigestSubscription) error
+}
+
+// DispatchDueDigestsTx locks up to arg.Limit digest subscriptions that are
+// due, skipping rows locked by other instances, and dispatches them. It
+// returns the number of subscriptions dispatched.
+func (store *SQLStore) DispatchDueDigestsTx(ctx context.Context, arg DispatchDueDigestsTxParams) (int, error) {
+	var n int
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		subscriptions, err := q.ClaimDueDigestSubscriptions(ctx, arg.Limit)
+		if err != nil {
+			return err
+		}
+
+		for _, subscription := range subscriptions {
+			err = arg.Dispatch(ctx, q, subscription)
+			if err != nil {
+				return fmt.Errorf("dispatch digest of user %d: %w", subscription.UserID, err)
+			}
+		}
+
+		n = len(subscriptions)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// TakeDigestItemsTxParams contains the input parameters of
+// TakeDigestItemsTx.
+type TakeDigestItemsTxParams struct {
+	UserID int64
+
+	// Send is called with the transaction's Querier and the IDs of the
+	// reminders held back for the digest. An error puts them back.
+	Send func(ctx context.Context, q Querier, reminderIDs []int64) error
+}
+
+// TakeDigestItemsTx removes the reminders held back for the digest of
+// arg.UserID and calls arg.Send with them. The reminders are only removed
+// if arg.Send succeeds, so that a failed digest carries them again when it
+// is retried.
+func (store *SQLStore) TakeDigestItemsTx(ctx context.Context, arg TakeDigestItemsTxParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		reminderIDs, err := q.TakeDigestItems(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+
+		return arg.Send(ctx, q, reminderIDs)
+	})
+}
+
+// DispatchDueEscalationsTxParams contains the input parameters of
+// DispatchDueEscalationsTx.
+type DispatchDueEscalationsTxParams struct {
+	Limit int32
+
+	// Dispatch is called with the transaction's Querier for every claimed
+	// escalation, and must record the notification. An error rolls the
+	// whole batch back.
+	Dispatch func(ctx context.Context, q Querier, escalation ClaimDueEscalationsRow) error
+}
+
+// DispatchDueEscalationsTx locks up to arg.Limit escalations of overdue
+// reminders whose policy says they are due to be notified again, skipping
+// rows locked by other instances, and dispatches them. It returns the
+// number of escalations dispatched.
+func (store *SQLStore) DispatchDueEscalationsTx(ctx context.Context, arg DispatchDueEscalationsTxParams) (int, error) {
+	var n int
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		escalations, err := q.ClaimDueEscalations(ctx, arg.Limit)
+		if err != nil {
+			return err
+		}
+
+		for _, escalation := range escalations {
+			err = arg.Dispatch(ctx, q, escalation)
+			if err != nil {
+				return fmt.Errorf("dispatch escalation of reminder %d: %w", escalation.ReminderID, err)
+			}
+		}
+
+		n = len(escalations)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Actions recorded in reminder_actions.
+const (
+	ReminderActionRotated = "rotated"
+	ReminderActionSnoozed = "snoozed"
+)
+
+// Sources of rotations recorded in reminder_rotations.
+const (
+	RotationSourceAPI       = "api"
+	RotationSourceEmailLink = "email_link"
+	RotationSourceImport    = "import"
+)
+
+// RotateReminderTxParams contains the input parameters of RotateReminderTx.
+type RotateReminderTxParams struct {
+	ID         int64
+	WebsiteUrl string
+	RotatedAt  time.Time
+	NextDueAt  sql.NullTime
+	Source     string
+	Note       sql.NullString
+}
+
+// RotateReminderTx sets the time the password of a reminder was last changed
+// and when the reminder is next due, records the rotation in the action log
+// and the rotation history, and resets the escalation of the reminder. It
+// returns sql.ErrNoRows when the reminder does not exist.
+func (store *SQLStore) RotateReminderTx(ctx context.Context, arg RotateReminderTxParams) (Reminder, error) {
+	var reminder Reminder
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		reminder, err = q.UpdateReminder(ctx, UpdateReminderParams{
+			UpdatedAt:  arg.RotatedAt,
+			NextDueAt:  arg.NextDueAt,
+			ID:         arg.ID,
+			WebsiteUrl: arg.WebsiteUrl,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateReminderAction(ctx, CreateReminderActionParams{
+			ReminderID: reminder.ID,
+			UserID:     reminder.UserID,
+			Action:     ReminderActionRotated,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateReminderRotation(ctx, CreateReminderRotationParams{
+			ReminderID: reminder.ID,
+			UserID:     reminder.UserID,
+			RotatedAt:  arg.RotatedAt,
+			Source:     arg.Source,
+			Note:       arg.Note,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteReminderEscalation(ctx, reminder.ID)
+		if err != nil {
+			return err
+		}
+
+		return q.ResolveReminderBreaches(ctx, reminder.ID)
+	})
+
+	return reminder, err
+}
+
+// SnoozeReminderTxParams contains the input parameters of SnoozeReminderTx.
+type SnoozeReminderTxParams struct {
+	ID           int64
+	WebsiteUrl   string
+	SnoozedUntil time.Time
+}
+
+// SnoozeReminderTx makes a reminder due at arg.SnoozedUntil without
+// changing when its password was last changed, and records the snooze. It
+// returns sql.ErrNoRows when the reminder does not exist.
+func (store *SQLStore) SnoozeReminderTx(ctx context.Context, arg SnoozeReminderTxParams) (Reminder, error) {
+	var reminder Reminder
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		reminder, err = q.SnoozeReminder(ctx, SnoozeReminderParams{
+			NextDueAt:  sql.NullTime{Time: arg.SnoozedUntil, Valid: true},
+			ID:         arg.ID,
+			WebsiteUrl: arg.WebsiteUrl,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateReminderAction(ctx, CreateReminderActionParams{
+			ReminderID:   reminder.ID,
+			UserID:       reminder.UserID,
+			Action:       ReminderActionSnoozed,
+			SnoozedUntil: sql.NullTime{Time: arg.SnoozedUntil, Valid: true},
+		})
+		return err
+	})
+
+	return reminder, err
+}
+
+// ImportBreachTxParams contains the input parameters of ImportBreachTx.
+type ImportBreachTxParams struct {
+	Breach UpsertBreachParams
+
+	// WebsiteHost and WebsiteDomain are the canonical host of the breached
+	// website and its registrable domain, see package website.
+	WebsiteHost   string
+	WebsiteDomain string
+
+	// Flagged is called with the transaction's Querier for every reminder
+	// the breach flags. An error rolls the import back.
+	Flagged func(ctx context.Context, q Querier, breach Breach, flag ReminderBreach) error
+}
+
+// ImportBreachTxResult contains the result of ImportBreachTx.
+type ImportBreachTxResult struct {
+	Breach  Breach
+	Flagged []ReminderBreach
+}
+
+// ImportBreachTx creates or updates arg.Breach and flags the reminders of
+// arg.WebsiteHost, or of its subdomains, whose password was last changed
+// before the day after the breach. Reminders are matched on their canonical
+// website, so those not canonicalized yet are not flagged. Reminders are
+// flagged once per breach, so importing a breach again only flags reminders
+// created since.
+func (store *SQLStore) ImportBreachTx(ctx context.Context, arg ImportBreachTxParams) (ImportBreachTxResult, error) {
+	var result ImportBreachTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result.Breach, err = q.UpsertBreach(ctx, arg.Breach)
+		if err != nil {
+			return err
+		}
+
+		result.Flagged, err = q.FlagBreachedReminders(ctx, FlagBreachedRemindersParams{
+			BreachID:      result.Breach.ID,
+			ChangedBefore: result.Breach.BreachDate.AddDate(0, 0, 1),
+			WebsiteDomain: arg.WebsiteDomain,
+			WebsiteHost:   arg.WebsiteHost,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, flag := range result.Flagged {
+			err = arg.Flagged(ctx, q, result.Breach, flag)
+			if err != nil {
+				return fmt.Errorf("flag reminder %d: %w", flag.ReminderID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ImportBreachTxResult{}, err
+	}
+
+	return result, nil
+}
+
+// MergeRemindersTxParams contains the input parameters of MergeRemindersTx.
+type MergeRemindersTxParams struct {
+	IntoID        int64
+	FromIDs       []int64
+	WebsiteHost   string
+	WebsiteDomain string
+}
+
+// MergeRemindersTx moves the rotations, actions and breaches of the
+// reminders arg.FromIDs to the reminder arg.IntoID, holds it back for the
+// digests they were held back for, deletes them and sets the canonical host
+// and domain of the remaining reminder. Escalations of the deleted reminders
+// are dropped. It returns sql.ErrNoRows when the remaining reminder does not
+// exist.
+func (store *SQLStore) MergeRemindersTx(ctx context.Context, arg MergeRemindersTxParams) (Reminder, error) {
+	var reminder Reminder
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		err := q.MoveReminderRotations(ctx, MoveReminderRotationsParams{
+			IntoID:  arg.IntoID,
+			FromIds: arg.FromIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.MoveReminderActions(ctx, MoveReminderActionsParams{
+			IntoID:  arg.IntoID,
+			FromIds: arg.FromIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.MoveReminderBreaches(ctx, MoveReminderBreachesParams{
+			IntoID:  arg.IntoID,
+			FromIds: arg.FromIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.MoveDigestItems(ctx, MoveDigestItemsParams{
+			IntoID:  arg.IntoID,
+			FromIds: arg.FromIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The duplicates go first, as they may hold the canonical domain.
+		err = q.DeleteReminders(ctx, arg.FromIDs)
+		if err != nil {
+			return err
+		}
+
+		reminder, err = q.SetReminderWebsite(ctx, SetReminderWebsiteParams{
+			WebsiteHost:   sql.NullString{String: arg.WebsiteHost, Valid: true},
+			WebsiteDomain: sql.NullString{String: arg.WebsiteDomain, Valid: true},
+			ID:            arg.IntoID,
+		})
+		return err
+	})
+
+	return reminder, err
+}
@@ -0,0 +1,633 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshSessionTx(t *testing.T) {
+	// Create a test session to rotate.
+	session := createTestSession(t)
+
+	// Set up the successor session.
+	newID, err := uuid.NewRandom()
+	require.NoError(t, err)
+
+	arg := RefreshSessionTxParams{
+		SessionID: session.ID,
+		NewSession: CreateSessionParams{
+			ID:               newID,
+			UserID:           session.UserID,
+			RefreshTokenHash: util.HashToken(util.RandomString(32)),
+			UserAgent:        session.UserAgent,
+			ClientIp:         session.ClientIp,
+			ExpiresAt:        time.Now().Add(time.Hour),
+		},
+	}
+
+	// Rotate the session and check the successor.
+	session1, err := testStore.RefreshSessionTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, newID, session1.ID)
+	require.Equal(t, session.FamilyID, session1.FamilyID)
+	require.False(t, session1.RotatedAt.Valid)
+
+	// Assert that the old session is marked as rotated.
+	session2, err := testQuerier.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, session2.RotatedAt.Valid)
+
+	// Rotating the old session again fails and creates nothing.
+	arg.NewSession.ID, err = uuid.NewRandom()
+	require.NoError(t, err)
+	arg.NewSession.RefreshTokenHash = util.HashToken(util.RandomString(32))
+
+	_, err = testStore.RefreshSessionTx(context.Background(), arg)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQuerier.GetSession(context.Background(), arg.NewSession.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	// Create a user with an open session and two reset tokens.
+	session := createTestSession(t)
+	_, resetToken := createTestPasswordResetToken(t, session.UserID, time.Minute)
+	_, otherToken := createTestPasswordResetToken(t, session.UserID, time.Minute)
+
+	hashedPassword, err := util.HashedPassword(util.RandomString(12))
+	require.NoError(t, err)
+
+	arg := ResetPasswordTxParams{
+		TokenHash:      resetToken.TokenHash,
+		HashedPassword: hashedPassword,
+		ChangedAt:      time.Now(),
+	}
+
+	// Reset the password and check the user.
+	user, err := testStore.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, session.UserID, user.ID)
+	require.Equal(t, hashedPassword, user.HashedPassword)
+	require.WithinDuration(t, arg.ChangedAt, user.PasswordChangedAt, time.Millisecond)
+
+	// Assert that the user's sessions are blocked.
+	session1, err := testQuerier.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, session1.IsBlocked)
+
+	// Neither reset token can be used anymore.
+	for _, tokenHash := range []string{resetToken.TokenHash, otherToken.TokenHash} {
+		arg.TokenHash = tokenHash
+		_, err = testStore.ResetPasswordTx(context.Background(), arg)
+		require.EqualError(t, err, sql.ErrNoRows.Error())
+	}
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	// Create a user with two verification tokens.
+	user := createTestUser(t)
+	_, verificationToken := createTestEmailVerificationToken(t, user.ID, time.Minute)
+	_, otherToken := createTestEmailVerificationToken(t, user.ID, time.Minute)
+
+	// Verify the email address and check the user.
+	user1, err := testStore.VerifyEmailTx(context.Background(), verificationToken.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, user1.ID)
+	require.True(t, user1.IsEmailVerified)
+
+	// Neither verification token can be used anymore.
+	for _, tokenHash := range []string{verificationToken.TokenHash, otherToken.TokenHash} {
+		_, err = testStore.VerifyEmailTx(context.Background(), tokenHash)
+		require.EqualError(t, err, sql.ErrNoRows.Error())
+	}
+}
+
+func TestVerifyContactTx(t *testing.T) {
+	user := createTestUser(t)
+	policy := createTestEscalationPolicy(t, user.ID)
+	verificationToken := createTestContactVerificationToken(t, user.ID, policy.ContactEmail.String, time.Minute)
+	staleToken := createTestContactVerificationToken(t, user.ID, "ann@example.com", time.Minute)
+
+	policy1, err := testStore.VerifyContactTx(context.Background(), verificationToken.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, policy1.UserID)
+	require.True(t, policy1.ContactVerifiedAt.Valid)
+
+	// The token cannot be used again, and a token sent to an address the
+	// policy no longer names is rejected without being used up.
+	for _, tokenHash := range []string{verificationToken.TokenHash, staleToken.TokenHash} {
+		_, err = testStore.VerifyContactTx(context.Background(), tokenHash)
+		require.EqualError(t, err, sql.ErrNoRows.Error())
+	}
+
+	staleToken1, err := testQuerier.UseContactVerificationToken(context.Background(), staleToken.TokenHash)
+	require.NoError(t, err)
+	require.True(t, staleToken1.UsedAt.Valid)
+}
+
+func TestDeactivateUserTx(t *testing.T) {
+	// Create a user with two open sessions.
+	session := createTestSession(t)
+	other := createTestSessionInFamily(t, session.UserID, uuid.Nil)
+
+	user, err := testQuerier.GetUser(context.Background(), session.UserID)
+	require.NoError(t, err)
+
+	user1, err := testStore.DeactivateUserTx(context.Background(), DeactivateUserParams{
+		ID:    user.ID,
+		Email: user.Email,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.ID, user1.ID)
+	require.False(t, user1.IsActivated)
+
+	// Assert that the user's sessions are blocked.
+	for _, id := range []uuid.UUID{session.ID, other.ID} {
+		session1, err := testQuerier.GetSession(context.Background(), id)
+		require.NoError(t, err)
+		require.True(t, session1.IsBlocked)
+	}
+
+	// Unknown users are not deactivated.
+	_, err = testStore.DeactivateUserTx(context.Background(), DeactivateUserParams{
+		ID:    user.ID,
+		Email: util.RandomEmail(),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestDispatchDueRemindersTx(t *testing.T) {
+	user := createVerifiedUser(t)
+	reminder := createDueReminder(t, user.ID)
+	other := createDueReminder(t, user.ID)
+
+	getReminder := func(reminder Reminder) Reminder {
+		reminder1, err := testQuerier.GetReminder(context.Background(), GetReminderParams{
+			ID:         reminder.ID,
+			WebsiteUrl: reminder.WebsiteUrl,
+		})
+		require.NoError(t, err)
+		return reminder1
+	}
+
+	backoff := func(failures int) time.Duration {
+		return time.Duration(failures) * time.Hour
+	}
+
+	// A failed dispatch is rolled back and the reminder is deferred, while
+	// the rest of the batch is dispatched.
+	errDispatch := errors.New("dispatch failed")
+	result, err := testStore.DispatchDueRemindersTx(context.Background(), DispatchDueRemindersTxParams{
+		Limit: 10_000,
+		Dispatch: func(ctx context.Context, q Querier, r Reminder) error {
+			if r.ID == reminder.ID {
+				err := q.MarkReminderNotified(ctx, r.ID)
+				require.NoError(t, err)
+				return errDispatch
+			}
+			return nil
+		},
+		Backoff: backoff,
+	})
+	require.ErrorIs(t, err, errDispatch)
+	require.Equal(t, 1, result.Failed)
+	require.Positive(t, result.Dispatched)
+
+	reminder1 := getReminder(reminder)
+	require.True(t, reminder1.NextDueAt.Valid)
+	require.WithinDuration(t, time.Now().Add(time.Hour), reminder1.NextDueAt.Time, time.Minute)
+	require.False(t, reminder1.LastNotifiedAt.Valid)
+	require.EqualValues(t, 1, reminder1.DispatchFailures)
+	require.False(t, getReminder(other).NextDueAt.Valid)
+
+	// A deferred reminder is not claimed until its backoff is over.
+	var dispatched []int64
+	dispatch := func(ctx context.Context, q Querier, r Reminder) error {
+		dispatched = append(dispatched, r.ID)
+		return nil
+	}
+	_, err = testStore.DispatchDueRemindersTx(context.Background(), DispatchDueRemindersTxParams{
+		Limit:    10_000,
+		Dispatch: dispatch,
+		Backoff:  backoff,
+	})
+	require.NoError(t, err)
+	require.NotContains(t, dispatched, reminder.ID)
+
+	// A successful dispatch marks the reminder as notified.
+	_, err = testQuerier.SnoozeReminder(context.Background(), SnoozeReminderParams{
+		NextDueAt:  sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+	})
+	require.NoError(t, err)
+
+	dispatched = nil
+	result, err = testStore.DispatchDueRemindersTx(context.Background(), DispatchDueRemindersTxParams{
+		Limit:    10_000,
+		Dispatch: dispatch,
+		Backoff:  backoff,
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(dispatched), result.Dispatched)
+	require.Zero(t, result.Failed)
+	require.Contains(t, dispatched, reminder.ID)
+
+	reminder1 = getReminder(reminder)
+	require.False(t, reminder1.NextDueAt.Valid)
+	require.True(t, reminder1.LastNotifiedAt.Valid)
+	require.Zero(t, reminder1.DispatchFailures)
+}
+
+func TestDispatchDueEscalationsTx(t *testing.T) {
+	user := createVerifiedUser(t)
+	createTestEscalationPolicy(t, user.ID)
+
+	reminder := createNotifiedReminder(t, user.ID)
+	lastNotifiedAt := time.Now().AddDate(0, 0, -2)
+	createTestReminderEscalation(t, reminder, lastNotifiedAt)
+
+	// A failed dispatch rolls the batch back.
+	errDispatch := errors.New("dispatch failed")
+	_, err := testStore.DispatchDueEscalationsTx(context.Background(), DispatchDueEscalationsTxParams{
+		Limit: 10_000,
+		Dispatch: func(ctx context.Context, q Querier, row ClaimDueEscalationsRow) error {
+			if row.ReminderID == reminder.ID {
+				_, err := q.UpsertReminderEscalation(ctx, UpsertReminderEscalationParams{
+					ReminderID:     row.ReminderID,
+					UserID:         row.UserID,
+					Notifications:  row.Notifications + 1,
+					OverdueSince:   row.OverdueSince,
+					LastNotifiedAt: time.Now(),
+				})
+				require.NoError(t, err)
+				return errDispatch
+			}
+			return nil
+		},
+	})
+	require.ErrorIs(t, err, errDispatch)
+
+	escalation, err := testQuerier.GetReminderEscalation(context.Background(), reminder.ID)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), escalation.Notifications)
+
+	var dispatched []int64
+	n, err := testStore.DispatchDueEscalationsTx(context.Background(), DispatchDueEscalationsTxParams{
+		Limit: 10_000,
+		Dispatch: func(ctx context.Context, q Querier, row ClaimDueEscalationsRow) error {
+			dispatched = append(dispatched, row.ReminderID)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(dispatched), n)
+	require.Contains(t, dispatched, reminder.ID)
+}
+
+func TestDispatchDueDigestsTx(t *testing.T) {
+	due := createTestDigestSubscription(t, createTestUser(t).ID, time.Now().Add(-time.Minute))
+	later := createTestDigestSubscription(t, createTestUser(t).ID, time.Now().Add(time.Hour))
+
+	// A failed dispatch rolls the batch back.
+	errDispatch := errors.New("dispatch failed")
+	_, err := testStore.DispatchDueDigestsTx(context.Background(), DispatchDueDigestsTxParams{
+		Limit: 10_000,
+		Dispatch: func(ctx context.Context, q Querier, s DigestSubscription) error {
+			if s.UserID == due.UserID {
+				err := q.ScheduleDigestSubscription(ctx, ScheduleDigestSubscriptionParams{
+					UserID:     s.UserID,
+					LastSentAt: time.Now(),
+					NextSendAt: time.Now().AddDate(0, 0, 1),
+				})
+				require.NoError(t, err)
+				return errDispatch
+			}
+			return nil
+		},
+	})
+	require.ErrorIs(t, err, errDispatch)
+
+	subscription, err := testQuerier.GetDigestSubscription(context.Background(), due.UserID)
+	require.NoError(t, err)
+	require.Equal(t, due, subscription)
+
+	// Only due subscriptions are dispatched.
+	var dispatched []int64
+	n, err := testStore.DispatchDueDigestsTx(context.Background(), DispatchDueDigestsTxParams{
+		Limit: 10_000,
+		Dispatch: func(ctx context.Context, q Querier, s DigestSubscription) error {
+			dispatched = append(dispatched, s.UserID)
+			return q.ScheduleDigestSubscription(ctx, ScheduleDigestSubscriptionParams{
+				UserID:     s.UserID,
+				LastSentAt: time.Now(),
+				NextSendAt: time.Now().AddDate(0, 0, 1),
+			})
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(dispatched), n)
+	require.Contains(t, dispatched, due.UserID)
+	require.NotContains(t, dispatched, later.UserID)
+}
+
+func TestTakeDigestItemsTx(t *testing.T) {
+	user := createTestUser(t)
+	reminder := createTestReminder(t, user.ID)
+	createTestDigestSubscription(t, user.ID, time.Now().Add(time.Hour))
+
+	_, err := testQuerier.AddDigestItem(context.Background(), AddDigestItemParams{UserID: user.ID, ReminderID: reminder.ID})
+	require.NoError(t, err)
+
+	// A failed send puts the items back.
+	errSend := errors.New("send failed")
+	err = testStore.TakeDigestItemsTx(context.Background(), TakeDigestItemsTxParams{
+		UserID: user.ID,
+		Send: func(ctx context.Context, q Querier, reminderIDs []int64) error {
+			require.Equal(t, []int64{reminder.ID}, reminderIDs)
+			return errSend
+		},
+	})
+	require.ErrorIs(t, err, errSend)
+
+	var sent []int64
+	err = testStore.TakeDigestItemsTx(context.Background(), TakeDigestItemsTxParams{
+		UserID: user.ID,
+		Send: func(ctx context.Context, q Querier, reminderIDs []int64) error {
+			sent = reminderIDs
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int64{reminder.ID}, sent)
+
+	reminderIDs, err := testQuerier.TakeDigestItems(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Empty(t, reminderIDs)
+}
+
+func TestRotateReminderTx(t *testing.T) {
+	user := createTestUser(t)
+	reminder := createTestReminder(t, user.ID)
+
+	rotatedAt := time.Now().Add(time.Minute)
+	rotated, err := testStore.RotateReminderTx(context.Background(), RotateReminderTxParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+		RotatedAt:  rotatedAt,
+		NextDueAt:  sql.NullTime{Time: rotatedAt.AddDate(0, 0, 14), Valid: true},
+		Source:     RotationSourceAPI,
+		Note:       sql.NullString{String: "scheduled", Valid: true},
+	})
+	require.NoError(t, err)
+	require.WithinDuration(t, rotatedAt, rotated.UpdatedAt, time.Second)
+	require.WithinDuration(t, rotatedAt.AddDate(0, 0, 14), rotated.NextDueAt.Time, time.Second)
+
+	actions, err := testQuerier.ListReminderActions(context.Background(), reminder.ID)
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	require.Equal(t, ReminderActionRotated, actions[0].Action)
+	require.Equal(t, user.ID, actions[0].UserID)
+	require.False(t, actions[0].SnoozedUntil.Valid)
+
+	rotations, err := testQuerier.ListReminderRotations(context.Background(), ListReminderRotationsParams{
+		ReminderID: reminder.ID,
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	require.Len(t, rotations, 1)
+	require.Equal(t, user.ID, rotations[0].UserID)
+	require.WithinDuration(t, rotatedAt, rotations[0].RotatedAt, time.Second)
+	require.Equal(t, RotationSourceAPI, rotations[0].Source)
+	require.Equal(t, "scheduled", rotations[0].Note.String)
+
+	// Rotating resets the escalation of the reminder.
+	createTestReminderEscalation(t, rotated, time.Now())
+	_, err = testStore.RotateReminderTx(context.Background(), RotateReminderTxParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+		RotatedAt:  rotatedAt,
+		Source:     RotationSourceAPI,
+	})
+	require.NoError(t, err)
+
+	_, err = testQuerier.GetReminderEscalation(context.Background(), reminder.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Nothing is recorded for reminders that do not exist.
+	_, err = testStore.RotateReminderTx(context.Background(), RotateReminderTxParams{
+		ID:         reminder.ID,
+		WebsiteUrl: "other.com",
+		RotatedAt:  rotatedAt,
+		Source:     RotationSourceAPI,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	actions, err = testQuerier.ListReminderActions(context.Background(), reminder.ID)
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+
+	rotations, err = testQuerier.ListReminderRotations(context.Background(), ListReminderRotationsParams{
+		ReminderID: reminder.ID,
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	require.Len(t, rotations, 2)
+}
+
+func TestSnoozeReminderTx(t *testing.T) {
+	user := createTestUser(t)
+	reminder := createTestReminder(t, user.ID)
+
+	snoozedUntil := reminder.NextDueAt.Time.AddDate(0, 0, 3)
+	snoozed, err := testStore.SnoozeReminderTx(context.Background(), SnoozeReminderTxParams{
+		ID:           reminder.ID,
+		WebsiteUrl:   reminder.WebsiteUrl,
+		SnoozedUntil: snoozedUntil,
+	})
+	require.NoError(t, err)
+	require.WithinDuration(t, snoozedUntil, snoozed.NextDueAt.Time, time.Second)
+	require.Equal(t, reminder.UpdatedAt, snoozed.UpdatedAt)
+
+	actions, err := testQuerier.ListReminderActions(context.Background(), reminder.ID)
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	require.Equal(t, ReminderActionSnoozed, actions[0].Action)
+	require.WithinDuration(t, snoozedUntil, actions[0].SnoozedUntil.Time, time.Second)
+}
+
+func TestImportBreachTx(t *testing.T) {
+	arg := randomBreachParams()
+	before := arg.BreachDate.AddDate(0, -1, 0)
+	reminder := createTestSiteReminder(t, arg.Domain, arg.Domain, before)
+	createTestSiteReminder(t, arg.Domain, arg.Domain, arg.BreachDate.AddDate(0, 1, 0))
+
+	// A failing callback rolls the whole import back.
+	errFlagged := errors.New("queue unavailable")
+	_, err := testStore.ImportBreachTx(context.Background(), ImportBreachTxParams{
+		Breach:        arg,
+		WebsiteHost:   arg.Domain,
+		WebsiteDomain: arg.Domain,
+		Flagged: func(ctx context.Context, q Querier, breach Breach, flag ReminderBreach) error {
+			return errFlagged
+		},
+	})
+	require.ErrorIs(t, err, errFlagged)
+
+	breaches, err := testQuerier.ListReminderBreaches(context.Background(), reminder.ID)
+	require.NoError(t, err)
+	require.Empty(t, breaches)
+
+	var called []int64
+	result, err := testStore.ImportBreachTx(context.Background(), ImportBreachTxParams{
+		Breach:        arg,
+		WebsiteHost:   arg.Domain,
+		WebsiteDomain: arg.Domain,
+		Flagged: func(ctx context.Context, q Querier, breach Breach, flag ReminderBreach) error {
+			require.NotNil(t, q)
+			require.Equal(t, arg.Name, breach.Name)
+			called = append(called, flag.ReminderID)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, arg.Name, result.Breach.Name)
+	require.Len(t, result.Flagged, 1)
+	require.Equal(t, reminder.ID, result.Flagged[0].ReminderID)
+	require.Equal(t, []int64{reminder.ID}, called)
+
+	// Importing the breach again flags nothing new, and rotating the
+	// reminder resolves it.
+	called = nil
+	result, err = testStore.ImportBreachTx(context.Background(), ImportBreachTxParams{
+		Breach:        arg,
+		WebsiteHost:   arg.Domain,
+		WebsiteDomain: arg.Domain,
+		Flagged: func(ctx context.Context, q Querier, breach Breach, flag ReminderBreach) error {
+			called = append(called, flag.ReminderID)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Flagged)
+	require.Empty(t, called)
+
+	_, err = testStore.RotateReminderTx(context.Background(), RotateReminderTxParams{
+		ID:         reminder.ID,
+		WebsiteUrl: reminder.WebsiteUrl,
+		RotatedAt:  time.Now(),
+		Source:     RotationSourceAPI,
+	})
+	require.NoError(t, err)
+
+	_, err = testQuerier.GetFlaggedReminder(context.Background(), GetFlaggedReminderParams{
+		ReminderID: reminder.ID,
+		BreachID:   result.Breach.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestMergeRemindersTx(t *testing.T) {
+	user := createTestUser(t)
+	domain := util.RandomString(10) + ".com"
+	now := time.Now()
+
+	into := createTestReminderAt(t, user.ID, domain, now.AddDate(0, -2, 0))
+
+	// The duplicate was created since canonicalizing, the kept reminder
+	// before.
+	dup, err := testQuerier.CreateReminder(context.Background(), CreateReminderParams{
+		UserID:        user.ID,
+		WebsiteUrl:    "https://www." + domain + "/login",
+		Interval:      "P1Y",
+		UpdatedAt:     now.AddDate(0, -3, 0),
+		WebsiteHost:   sql.NullString{String: domain, Valid: true},
+		WebsiteDomain: sql.NullString{String: domain, Valid: true},
+	})
+	require.NoError(t, err)
+	other := createTestReminder(t, user.ID)
+
+	rotation := createTestReminderRotation(t, dup, now.AddDate(0, -3, 0))
+	createTestReminderEscalation(t, dup, now)
+
+	arg := randomBreachParams()
+	arg.Domain = domain
+	arg.BreachDate = now.AddDate(0, -1, 0)
+	breach, err := testQuerier.UpsertBreach(context.Background(), arg)
+	require.NoError(t, err)
+
+	flagged, err := testQuerier.FlagBreachedReminders(context.Background(), FlagBreachedRemindersParams{
+		BreachID:      breach.ID,
+		ChangedBefore: now,
+		WebsiteDomain: domain,
+		WebsiteHost:   domain,
+	})
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	require.Equal(t, dup.ID, flagged[0].ReminderID)
+
+	merged, err := testStore.MergeRemindersTx(context.Background(), MergeRemindersTxParams{
+		IntoID:        into.ID,
+		FromIDs:       []int64{dup.ID},
+		WebsiteHost:   domain,
+		WebsiteDomain: domain,
+	})
+	require.NoError(t, err)
+	require.Equal(t, into.ID, merged.ID)
+	require.Equal(t, into.WebsiteUrl, merged.WebsiteUrl)
+	require.Equal(t, domain, merged.WebsiteHost.String)
+	require.Equal(t, domain, merged.WebsiteDomain.String)
+
+	// The duplicate is gone, and its history moved to the kept reminder.
+	_, err = testQuerier.GetReminder(context.Background(), GetReminderParams{ID: dup.ID, WebsiteUrl: dup.WebsiteUrl})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQuerier.GetReminderEscalation(context.Background(), dup.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	rotations, err := testQuerier.ListReminderRotations(context.Background(), ListReminderRotationsParams{
+		ReminderID: into.ID,
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	require.Len(t, rotations, 1)
+	require.Equal(t, rotation.ID, rotations[0].ID)
+
+	breaches, err := testQuerier.ListReminderBreaches(context.Background(), into.ID)
+	require.NoError(t, err)
+	require.Len(t, breaches, 1)
+
+	// The canonical domain is unique per user.
+	_, err = testQuerier.CreateReminder(context.Background(), CreateReminderParams{
+		UserID:        user.ID,
+		WebsiteUrl:    "m." + domain,
+		Interval:      "P1Y",
+		UpdatedAt:     now,
+		WebsiteHost:   sql.NullString{String: "m." + domain, Valid: true},
+		WebsiteDomain: sql.NullString{String: domain, Valid: true},
+	})
+	var pqErr *pq.Error
+	require.ErrorAs(t, err, &pqErr)
+	require.Equal(t, "unique_violation", pqErr.Code.Name())
+
+	// Nothing is merged into a reminder that does not exist.
+	_, err = testStore.MergeRemindersTx(context.Background(), MergeRemindersTxParams{
+		IntoID:        into.ID + 1_000_000,
+		FromIDs:       []int64{other.ID},
+		WebsiteHost:   other.WebsiteUrl,
+		WebsiteDomain: other.WebsiteUrl,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQuerier.GetReminder(context.Background(), GetReminderParams{ID: other.ID, WebsiteUrl: other.WebsiteUrl})
+	require.NoError(t, err)
+}
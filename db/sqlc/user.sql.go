@@ -5,13 +5,14 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 const changeEmail = `-- name: ChangeEmail :one
 UPDATE users
 SET email = $1
 WHERE id = $2
-RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated
+RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin
 `
 
 type ChangeEmailParams struct {
@@ -30,24 +31,27 @@ func (q *Queries) ChangeEmail(ctx context.Context, arg ChangeEmailParams) (User,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const changePassword = `-- name: ChangePassword :one
 UPDATE users
-SET hashed_password = $1
-WHERE email = $2
-RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated
+SET hashed_password = $1, password_changed_at = $2
+WHERE email = $3
+RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin
 `
 
 type ChangePasswordParams struct {
-	HashedPassword string `json:"hashed_password"`
-	Email          string `json:"email"`
+	HashedPassword string    `json:"hashed_password"`
+	ChangedAt      time.Time `json:"changed_at"`
+	Email          string    `json:"email"`
 }
 
 func (q *Queries) ChangePassword(ctx context.Context, arg ChangePasswordParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, changePassword, arg.HashedPassword, arg.Email)
+	row := q.db.QueryRowContext(ctx, changePassword, arg.HashedPassword, arg.ChangedAt, arg.Email)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -57,6 +61,8 @@ func (q *Queries) ChangePassword(ctx context.Context, arg ChangePasswordParams)
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -68,7 +74,7 @@ INSERT INTO users (
   email
 ) VALUES (
   $1, $2, $3
-) RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated
+) RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin
 `
 
 type CreateUserParams struct {
@@ -88,6 +94,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -96,7 +104,7 @@ const deactivateUser = `-- name: DeactivateUser :one
 UPDATE users
 SET is_activated = false
 WHERE id = $1 AND email = $2
-RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated
+RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin
 `
 
 type DeactivateUserParams struct {
@@ -115,12 +123,14 @@ func (q *Queries) DeactivateUser(ctx context.Context, arg DeactivateUserParams)
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, full_name, hashed_password, email, password_changed_at, created_at, is_activated FROM users
+SELECT id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin FROM users
 WHERE id = $1 LIMIT 1
 `
 
@@ -135,6 +145,54 @@ func (q *Queries) GetUser(ctx context.Context, userID int64) (User, error) {
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin FROM users
+WHERE email = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true
+WHERE id = $1
+RETURNING id, full_name, hashed_password, email, password_changed_at, created_at, is_activated, is_email_verified, is_admin
+`
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsActivated,
+		&i.IsEmailVerified,
+		&i.IsAdmin,
 	)
 	return i, err
 }
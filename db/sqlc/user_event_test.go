@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestUserEvent(t *testing.T, userID int64) UserEvent {
+	arg := CreateUserEventParams{
+		UserID:    userID,
+		EventType: "reminder.due",
+		Payload:   json.RawMessage(`{"type": "reminder.due"}`),
+	}
+
+	event, err := testQuerier.CreateUserEvent(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, event.ID)
+	require.Equal(t, arg.UserID, event.UserID)
+	require.Equal(t, arg.EventType, event.EventType)
+	require.JSONEq(t, string(arg.Payload), string(event.Payload))
+	require.NotZero(t, event.CreatedAt)
+
+	return event
+}
+
+func TestListUserEvents(t *testing.T) {
+	user := createTestUser(t)
+
+	latest, err := testQuerier.GetLatestUserEventID(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Zero(t, latest)
+
+	event1 := createTestUserEvent(t, user.ID)
+	event2 := createTestUserEvent(t, user.ID)
+	event3 := createTestUserEvent(t, user.ID)
+	createTestUserEvent(t, createTestUser(t).ID)
+
+	latest, err = testQuerier.GetLatestUserEventID(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, event3.ID, latest)
+
+	events, err := testQuerier.ListUserEvents(context.Background(), ListUserEventsParams{
+		UserID: user.ID,
+		ID:     event1.ID,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, event2.ID, events[0].ID)
+	require.Equal(t, event3.ID, events[1].ID)
+
+	events, err = testQuerier.ListUserEvents(context.Background(), ListUserEventsParams{
+		UserID: user.ID,
+		Limit:  1,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, event1.ID, events[0].ID)
+}
+
+func TestListRecentUserEvents(t *testing.T) {
+	user := createTestUser(t)
+
+	event1 := createTestUserEvent(t, user.ID)
+	event2 := createTestUserEvent(t, user.ID)
+	createTestUserEvent(t, user.ID)
+	createTestUserEvent(t, createTestUser(t).ID)
+
+	events, err := testQuerier.ListRecentUserEvents(context.Background(), ListRecentUserEventsParams{
+		UserID:  user.ID,
+		UntilID: event2.ID,
+		Since:   event1.CreatedAt.Add(-time.Second),
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, event1.ID, events[0].ID)
+	require.Equal(t, event2.ID, events[1].ID)
+
+	events, err = testQuerier.ListRecentUserEvents(context.Background(), ListRecentUserEventsParams{
+		UserID:  user.ID,
+		UntilID: event2.ID,
+		Since:   time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestDeleteUserEventsBefore(t *testing.T) {
+	user := createTestUser(t)
+	event := createTestUserEvent(t, user.ID)
+
+	_, err := testQuerier.DeleteUserEventsBefore(context.Background(), event.CreatedAt.Add(-time.Hour))
+	require.NoError(t, err)
+
+	events, err := testQuerier.ListUserEvents(context.Background(), ListUserEventsParams{
+		UserID: user.ID,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	n, err := testQuerier.DeleteUserEventsBefore(context.Background(), event.CreatedAt.Add(time.Second))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, int64(1))
+
+	events, err = testQuerier.ListUserEvents(context.Background(), ListUserEventsParams{
+		UserID: user.ID,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertUserPreferences(t *testing.T) {
+	user := createTestUser(t)
+
+	_, err := testQuerier.GetUserPreferences(context.Background(), user.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	arg := UpsertUserPreferencesParams{
+		UserID:          user.ID,
+		TimeZone:        "Europe/Berlin",
+		QuietHoursStart: sql.NullInt32{Int32: 22 * 60, Valid: true},
+		QuietHoursEnd:   sql.NullInt32{Int32: 7 * 60, Valid: true},
+	}
+
+	prefs1, err := testQuerier.UpsertUserPreferences(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, prefs1.UserID)
+	require.Equal(t, arg.TimeZone, prefs1.TimeZone)
+	require.Equal(t, arg.QuietHoursStart, prefs1.QuietHoursStart)
+	require.Equal(t, arg.QuietHoursEnd, prefs1.QuietHoursEnd)
+	require.NotZero(t, prefs1.UpdatedAt)
+
+	// Upserting again replaces the preferences.
+	prefs2, err := testQuerier.UpsertUserPreferences(context.Background(), UpsertUserPreferencesParams{
+		UserID:   user.ID,
+		TimeZone: "Asia/Tokyo",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Asia/Tokyo", prefs2.TimeZone)
+	require.False(t, prefs2.QuietHoursStart.Valid)
+	require.False(t, prefs2.QuietHoursEnd.Valid)
+	require.False(t, prefs2.UpdatedAt.Before(prefs1.UpdatedAt))
+
+	prefs3, err := testQuerier.GetUserPreferences(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, prefs2, prefs3)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
@@ -28,6 +29,8 @@ func createTestUser(t *testing.T) User {
 	require.Equal(t, arg.Email, user.Email)
 	require.Equal(t, arg.HashedPassword, user.HashedPassword)
 	require.True(t, user.IsActivated)
+	require.False(t, user.IsEmailVerified)
+	require.False(t, user.IsAdmin)
 	require.Zero(t, user.PasswordChangedAt)
 	require.NotZero(t, user.CreatedAt)
 
@@ -78,6 +81,27 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, user.PasswordChangedAt, user1.PasswordChangedAt, time.Second)
 }
 
+func TestGetUserByEmail(t *testing.T) {
+	// Create a test user.
+	user := createTestUser(t)
+
+	// Retrieve the test user from the database by email.
+	user1, err := testQuerier.GetUserByEmail(context.Background(), user.Email)
+	require.NoError(t, err)
+	require.NotEmpty(t, user1)
+
+	// Assert that the retrieved user is the test user.
+	require.Equal(t, user.ID, user1.ID)
+	require.Equal(t, user.Email, user1.Email)
+	require.Equal(t, user.HashedPassword, user1.HashedPassword)
+
+	// Assert that an unknown email returns no rows.
+	user2, err := testQuerier.GetUserByEmail(context.Background(), util.RandomEmail())
+	require.Error(t, err)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.Empty(t, user2)
+}
+
 func TestChangePassword(t *testing.T) {
 	// Create a test user.
 	user := createTestUser(t)
@@ -85,6 +109,7 @@ func TestChangePassword(t *testing.T) {
 	// Set up parameters to change the test user password.
 	arg := ChangePasswordParams{
 		HashedPassword: util.RandomPasswordHash(16),
+		ChangedAt:      time.Now().Add(-time.Hour),
 		Email:          user.Email,
 	}
 
@@ -101,6 +126,11 @@ func TestChangePassword(t *testing.T) {
 	// Assert that the retrieved user's password changed.
 	require.NotEqual(t, user.HashedPassword, user1.HashedPassword)
 	require.Equal(t, arg.HashedPassword, user1.HashedPassword)
+
+	// Assert that the given password change time was recorded, rather
+	// than the database's clock.
+	require.True(t, user1.PasswordChangedAt.After(user.PasswordChangedAt))
+	require.WithinDuration(t, arg.ChangedAt, user1.PasswordChangedAt, time.Millisecond)
 }
 
 func TestChangeEmail(t *testing.T) {
@@ -123,3 +153,17 @@ func TestChangeEmail(t *testing.T) {
 	require.NotEqual(t, user.Email, user1.Email)
 	require.Equal(t, arg.Email, user1.Email)
 }
+
+func TestVerifyUserEmail(t *testing.T) {
+	// Create a test user.
+	user := createTestUser(t)
+
+	// Verify the user's email address and check for errors.
+	user1, err := testQuerier.VerifyUserEmail(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	// Assert that only the verification status changed.
+	require.Equal(t, user.ID, user1.ID)
+	require.Equal(t, user.Email, user1.Email)
+	require.True(t, user1.IsEmailVerified)
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestWebhookEndpoint(t *testing.T, userID int64) WebhookEndpoint {
+	arg := CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    "https://" + util.RandomString(8) + ".example.com/hook",
+		Secret: "whsec_" + util.RandomString(32),
+	}
+
+	endpoint, err := testQuerier.CreateWebhookEndpoint(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, endpoint.ID)
+	require.Equal(t, arg.UserID, endpoint.UserID)
+	require.Equal(t, arg.Url, endpoint.Url)
+	require.Equal(t, arg.Secret, endpoint.Secret)
+	require.True(t, endpoint.IsActive)
+	require.NotZero(t, endpoint.CreatedAt)
+
+	return endpoint
+}
+
+func createTestWebhookDelivery(t *testing.T, endpointID int64) WebhookDelivery {
+	arg := CreateWebhookDeliveryParams{
+		EndpointID: endpointID,
+		EventID:    uuid.New(),
+		EventType:  "webhook.test",
+		Payload:    json.RawMessage(`{"type": "webhook.test"}`),
+	}
+
+	delivery, err := testQuerier.CreateWebhookDelivery(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.EndpointID, delivery.EndpointID)
+	require.Equal(t, arg.EventID, delivery.EventID)
+	require.Equal(t, arg.EventType, delivery.EventType)
+	require.JSONEq(t, string(arg.Payload), string(delivery.Payload))
+	require.Equal(t, "pending", delivery.Status)
+	require.Zero(t, delivery.Attempts)
+	require.False(t, delivery.LastAttemptAt.Valid)
+
+	return delivery
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	user := createTestUser(t)
+	endpoint1 := createTestWebhookEndpoint(t, user.ID)
+	endpoint2 := createTestWebhookEndpoint(t, user.ID)
+	createTestWebhookEndpoint(t, createTestUser(t).ID)
+
+	endpoint, err := testQuerier.GetWebhookEndpoint(context.Background(), endpoint1.ID)
+	require.NoError(t, err)
+	require.Equal(t, endpoint1, endpoint)
+
+	endpoints, err := testQuerier.ListWebhookEndpoints(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []WebhookEndpoint{endpoint1, endpoint2}, endpoints)
+
+	// Deleting an endpoint deletes its delivery log.
+	delivery := createTestWebhookDelivery(t, endpoint1.ID)
+
+	err = testQuerier.DeleteWebhookEndpoint(context.Background(), endpoint1.ID)
+	require.NoError(t, err)
+
+	_, err = testQuerier.GetWebhookEndpoint(context.Background(), endpoint1.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	var count int
+	err = testDB.QueryRow("SELECT count(*) FROM webhook_deliveries WHERE id = $1", delivery.ID).Scan(&count)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestEnqueueWebhookEvent(t *testing.T) {
+	user := createTestUser(t)
+	endpoint1 := createTestWebhookEndpoint(t, user.ID)
+	endpoint2 := createTestWebhookEndpoint(t, user.ID)
+	other := createTestWebhookEndpoint(t, createTestUser(t).ID)
+
+	arg := EnqueueWebhookEventParams{
+		EventID:   uuid.New(),
+		EventType: "reminder.created",
+		Payload:   json.RawMessage(`{"type": "reminder.created"}`),
+		UserID:    user.ID,
+	}
+	err := testQuerier.EnqueueWebhookEvent(context.Background(), arg)
+	require.NoError(t, err)
+
+	// Every endpoint of the user gets its own delivery of the event.
+	for _, endpoint := range []WebhookEndpoint{endpoint1, endpoint2} {
+		deliveries, err := testQuerier.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+			EndpointID: endpoint.ID,
+			Limit:      10,
+		})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, arg.EventID, deliveries[0].EventID)
+		require.Equal(t, arg.EventType, deliveries[0].EventType)
+		require.JSONEq(t, string(arg.Payload), string(deliveries[0].Payload))
+	}
+
+	deliveries, err := testQuerier.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		EndpointID: other.ID,
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	endpoint := createTestWebhookEndpoint(t, createTestUser(t).ID)
+
+	var created []WebhookDelivery
+	for i := 0; i < 3; i++ {
+		created = append(created, createTestWebhookDelivery(t, endpoint.ID))
+	}
+
+	// The log lists the newest deliveries first.
+	deliveries, err := testQuerier.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      2,
+		Offset:     1,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	require.Equal(t, created[1].ID, deliveries[0].ID)
+	require.Equal(t, created[0].ID, deliveries[1].ID)
+}
+
+func TestClaimWebhookDeliveries(t *testing.T) {
+	endpoint := createTestWebhookEndpoint(t, createTestUser(t).ID)
+	delivery := createTestWebhookDelivery(t, endpoint.ID)
+
+	claim := func() []ClaimWebhookDeliveriesRow {
+		rows, err := testQuerier.ClaimWebhookDeliveries(context.Background(), ClaimWebhookDeliveriesParams{
+			LeaseUntil: time.Now().Add(time.Minute),
+			BatchSize:  1000,
+		})
+		require.NoError(t, err)
+
+		var claimed []ClaimWebhookDeliveriesRow
+		for _, row := range rows {
+			if row.EndpointID == endpoint.ID {
+				claimed = append(claimed, row)
+			}
+		}
+
+		return claimed
+	}
+
+	claimed := claim()
+	require.Len(t, claimed, 1)
+	require.Equal(t, delivery.ID, claimed[0].ID)
+	require.EqualValues(t, 1, claimed[0].Attempts)
+	require.Equal(t, endpoint.Url, claimed[0].Url)
+	require.Equal(t, endpoint.Secret, claimed[0].Secret)
+
+	// A leased delivery is not claimed again.
+	require.Empty(t, claim())
+
+	// A failed attempt is retried once its backoff has passed.
+	err := testQuerier.RecordWebhookDeliveryAttempt(context.Background(), RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         "pending",
+		ResponseStatus: sql.NullInt32{Int32: 500, Valid: true},
+		LastError:      sql.NullString{String: "endpoint responded with 500", Valid: true},
+		NextAttemptAt:  time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+
+	claimed = claim()
+	require.Len(t, claimed, 1)
+	require.EqualValues(t, 2, claimed[0].Attempts)
+	require.True(t, claimed[0].LastAttemptAt.Valid)
+	require.EqualValues(t, 500, claimed[0].ResponseStatus.Int32)
+	require.Equal(t, "endpoint responded with 500", claimed[0].LastError.String)
+
+	// Delivered ones are not.
+	err = testQuerier.RecordWebhookDeliveryAttempt(context.Background(), RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         "succeeded",
+		ResponseStatus: sql.NullInt32{Int32: 200, Valid: true},
+		NextAttemptAt:  time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+	require.Empty(t, claim())
+}
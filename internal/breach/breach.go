@@ -0,0 +1,273 @@
+// Package breach imports feeds of website data breaches and urgently tells
+// the users of breached websites to change their password. Feeds are in the
+// format of the Have I Been Pwned breaches API: a JSON array of breaches,
+// read from a file or fetched from a URL.
+package breach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+	"github.com/OCD-Labs/KeyKeeper/internal/website"
+)
+
+const (
+	// requestTimeout bounds the download of a feed.
+	requestTimeout = time.Minute
+
+	// maxFeedLength bounds the size of a feed. The whole HIBP feed is a few
+	// megabytes.
+	maxFeedLength = 64 << 20
+
+	// dateLayout is the layout of breach dates.
+	dateLayout = "2006-01-02"
+)
+
+// A Breach is an entry of a breaches feed.
+type Breach struct {
+	Name         string    `json:"Name"`
+	Title        string    `json:"Title"`
+	Domain       string    `json:"Domain"`
+	BreachDate   string    `json:"BreachDate"`
+	AddedDate    time.Time `json:"AddedDate"`
+	PwnCount     int64     `json:"PwnCount"`
+	DataClasses  []string  `json:"DataClasses"`
+	IsVerified   bool      `json:"IsVerified"`
+	IsFabricated bool      `json:"IsFabricated"`
+	IsRetired    bool      `json:"IsRetired"`
+	IsSpamList   bool      `json:"IsSpamList"`
+}
+
+// Parse reads a breaches feed from r.
+func Parse(r io.Reader) ([]Breach, error) {
+	var breaches []Breach
+	err := json.NewDecoder(io.LimitReader(r, maxFeedLength)).Decode(&breaches)
+	if err != nil {
+		return nil, fmt.Errorf("breach: invalid feed: %w", err)
+	}
+
+	return breaches, nil
+}
+
+// Fetch reads the breaches feed at source, an http or https URL or the path
+// of a file. client fetches URLs; if nil, a client with a one minute timeout
+// is used.
+func Fetch(ctx context.Context, source string, client *http.Client) ([]Breach, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("breach: %w", err)
+		}
+		defer f.Close()
+
+		return Parse(f)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("breach: %w", err)
+	}
+	req.Header.Set("User-Agent", "KeyKeeper")
+	req.Header.Set("Accept", "application/json")
+
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("breach: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("breach: unexpected status %d fetching the feed", res.StatusCode)
+	}
+
+	return Parse(res.Body)
+}
+
+// params returns the breach to import for b, and false if b is not a breach
+// of a website that users must act on: fabricated breaches, spam lists,
+// retired breaches and breaches without a valid domain are left out. The
+// domain is stored in its canonical form, see package website, as the
+// reminders it flags are matched on theirs.
+func (b Breach) params() (db.ImportBreachTxParams, bool) {
+	if b.Name == "" || b.IsFabricated || b.IsSpamList || b.IsRetired {
+		return db.ImportBreachTxParams{}, false
+	}
+
+	site, err := website.Parse(b.Domain)
+	if err != nil {
+		return db.ImportBreachTxParams{}, false
+	}
+
+	date, err := time.Parse(dateLayout, b.BreachDate)
+	if err != nil {
+		return db.ImportBreachTxParams{}, false
+	}
+
+	title := b.Title
+	if title == "" {
+		title = b.Name
+	}
+
+	dataClasses := b.DataClasses
+	if dataClasses == nil {
+		dataClasses = []string{}
+	}
+
+	return db.ImportBreachTxParams{
+		Breach: db.UpsertBreachParams{
+			Name:        b.Name,
+			Title:       title,
+			Domain:      site.Host,
+			BreachDate:  date,
+			AddedAt:     b.AddedDate,
+			PwnCount:    b.PwnCount,
+			DataClasses: dataClasses,
+			IsVerified:  b.IsVerified,
+		},
+		WebsiteHost:   site.Host,
+		WebsiteDomain: site.Domain,
+	}, true
+}
+
+// A Result sums up an import.
+type Result struct {
+	// Imported is the number of breaches stored.
+	Imported int
+
+	// Skipped is the number of entries left out, see Breach.params.
+	Skipped int
+
+	// Flagged is the number of reminders flagged by the import, each of
+	// which is notified.
+	Flagged int
+}
+
+// Import stores breaches and queues a NotifyArgs job for every reminder
+// they flag, in the transaction that flags it.
+func Import(ctx context.Context, store db.Store, breaches []Breach) (Result, error) {
+	var result Result
+
+	for _, b := range breaches {
+		params, ok := b.params()
+		if !ok {
+			result.Skipped++
+			continue
+		}
+
+		params.Flagged = enqueueNotify
+		res, err := store.ImportBreachTx(ctx, params)
+		if err != nil {
+			return result, fmt.Errorf("breach: import %s: %w", b.Name, err)
+		}
+
+		result.Imported++
+		result.Flagged += len(res.Flagged)
+	}
+
+	return result, nil
+}
+
+// enqueueNotify queues a NotifyArgs job per channel for a flagged reminder,
+// so that a failing channel is retried on its own.
+func enqueueNotify(ctx context.Context, q db.Querier, breach db.Breach, flag db.ReminderBreach) error {
+	for _, channel := range notifier.Channels {
+		_, err := jobs.Enqueue(ctx, q, NotifyArgs{
+			ReminderID: flag.ReminderID,
+			BreachID:   breach.ID,
+			Channel:    channel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NotifyArgs are the arguments of the job that urgently tells the owner of
+// a reminder flagged by a breach to change their password, over one
+// channel.
+type NotifyArgs struct {
+	ReminderID int64 `json:"reminder_id"`
+	BreachID   int64 `json:"breach_id"`
+
+	// Channel is the channel the job delivers over. Empty means every
+	// channel.
+	Channel string `json:"channel,omitempty"`
+}
+
+// Kind implements jobs.Args.
+func (NotifyArgs) Kind() string { return "breach.notify" }
+
+// HandleNotify returns the handler of NotifyArgs jobs, which tells the owner
+// of the reminder about the breach through n, whatever the reminder's
+// interval. Reminders deleted or rotated since they were flagged, and those
+// of deactivated users, are skipped. During the owner's quiet hours the job is queued again for when
+// they end.
+func HandleNotify(q db.Querier, n notifier.Notifier) func(ctx context.Context, args NotifyArgs) error {
+	return func(ctx context.Context, args NotifyArgs) error {
+		reminder, err := q.GetFlaggedReminder(ctx, db.GetFlaggedReminderParams{
+			ReminderID: args.ReminderID,
+			BreachID:   args.BreachID,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		breach, err := q.GetBreach(ctx, args.BreachID)
+		if err != nil {
+			return err
+		}
+
+		user, err := q.GetUser(ctx, reminder.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !user.IsActivated {
+			return nil
+		}
+
+		prefs, err := preferences.Load(ctx, q, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if now := time.Now(); prefs.Quiet(now) {
+			_, err = jobs.EnqueueAt(ctx, q, args, prefs.Deliverable(now))
+			return err
+		}
+
+		notification := notifier.Notification{
+			Kind:     notifier.KindReminderBreached,
+			User:     user,
+			Reminder: reminder,
+			Breach:   &breach,
+			Location: prefs.Location,
+		}
+		if args.Channel != "" {
+			notification.Channels = []string{args.Channel}
+		}
+
+		return n.Notify(ctx, notification)
+	}
+}
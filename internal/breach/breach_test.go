@@ -0,0 +1,467 @@
+package breach
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+const feed = `[
+  {
+    "Name": "Adobe",
+    "Title": "Adobe",
+    "Domain": "adobe.com",
+    "BreachDate": "2013-10-04",
+    "AddedDate": "2013-12-04T00:00:00Z",
+    "PwnCount": 152445165,
+    "DataClasses": ["Email addresses", "Passwords"],
+    "IsVerified": true,
+    "IsFabricated": false,
+    "IsSpamList": false,
+    "IsRetired": false
+  },
+  {
+    "Name": "LinkedIn",
+    "Title": "",
+    "Domain": "WWW.LinkedIn.com.",
+    "BreachDate": "2012-05-05",
+    "AddedDate": "2016-05-21T21:35:40Z",
+    "PwnCount": 164611595,
+    "DataClasses": null,
+    "IsVerified": true
+  },
+  {
+    "Name": "Collection1",
+    "Title": "Collection #1",
+    "Domain": "",
+    "BreachDate": "2019-01-07",
+    "AddedDate": "2019-01-16T21:46:07Z",
+    "PwnCount": 772904991
+  },
+  {
+    "Name": "Fake",
+    "Title": "Fake",
+    "Domain": "fake.com",
+    "BreachDate": "2020-01-01",
+    "AddedDate": "2020-01-02T00:00:00Z",
+    "IsFabricated": true
+  }
+]`
+
+// fakeStore imports breaches the way ImportBreachTx does, flagging the
+// reminders listed for their domain that were not flagged by them yet.
+type fakeStore struct {
+	db.Store
+
+	mu       sync.Mutex
+	q        *fakeQuerier
+	breaches map[string]db.Breach
+	domains  map[string][]int64
+	flagged  map[db.GetFlaggedReminderParams]bool
+}
+
+func newFakeStore(domains map[string][]int64) *fakeStore {
+	return &fakeStore{
+		q:        &fakeQuerier{},
+		breaches: make(map[string]db.Breach),
+		domains:  domains,
+		flagged:  make(map[db.GetFlaggedReminderParams]bool),
+	}
+}
+
+func (s *fakeStore) ImportBreachTx(ctx context.Context, arg db.ImportBreachTxParams) (db.ImportBreachTxResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	breach, ok := s.breaches[arg.Breach.Name]
+	if !ok {
+		breach.ID = int64(len(s.breaches) + 1)
+	}
+	breach.Name = arg.Breach.Name
+	breach.Title = arg.Breach.Title
+	breach.Domain = arg.Breach.Domain
+	breach.BreachDate = arg.Breach.BreachDate
+	breach.DataClasses = arg.Breach.DataClasses
+	s.breaches[breach.Name] = breach
+
+	result := db.ImportBreachTxResult{Breach: breach}
+	for _, id := range s.domains[breach.Domain] {
+		key := db.GetFlaggedReminderParams{ReminderID: id, BreachID: breach.ID}
+		if s.flagged[key] {
+			continue
+		}
+		s.flagged[key] = true
+
+		flag := db.ReminderBreach{ReminderID: id, BreachID: breach.ID}
+		if err := arg.Flagged(ctx, s.q, breach, flag); err != nil {
+			return db.ImportBreachTxResult{}, err
+		}
+		result.Flagged = append(result.Flagged, flag)
+	}
+
+	return result, nil
+}
+
+func TestParse(t *testing.T) {
+	breaches, err := Parse(strings.NewReader(feed))
+	require.NoError(t, err)
+	require.Len(t, breaches, 4)
+	require.Equal(t, "Adobe", breaches[0].Name)
+	require.Equal(t, "2013-10-04", breaches[0].BreachDate)
+	require.Equal(t, time.Date(2013, 12, 4, 0, 0, 0, 0, time.UTC), breaches[0].AddedDate)
+	require.Equal(t, []string{"Email addresses", "Passwords"}, breaches[0].DataClasses)
+	require.True(t, breaches[3].IsFabricated)
+
+	_, err = Parse(strings.NewReader(`{"Name": "Adobe"}`))
+	require.Error(t, err)
+}
+
+func TestParams(t *testing.T) {
+	breaches, err := Parse(strings.NewReader(feed))
+	require.NoError(t, err)
+
+	params, ok := breaches[0].params()
+	require.True(t, ok)
+	require.Equal(t, db.UpsertBreachParams{
+		Name:        "Adobe",
+		Title:       "Adobe",
+		Domain:      "adobe.com",
+		BreachDate:  time.Date(2013, 10, 4, 0, 0, 0, 0, time.UTC),
+		AddedAt:     time.Date(2013, 12, 4, 0, 0, 0, 0, time.UTC),
+		PwnCount:    152445165,
+		DataClasses: []string{"Email addresses", "Passwords"},
+		IsVerified:  true,
+	}, params.Breach)
+	require.Equal(t, "adobe.com", params.WebsiteHost)
+	require.Equal(t, "adobe.com", params.WebsiteDomain)
+
+	// Domains are canonicalized, and a missing title falls back to the name.
+	params, ok = breaches[1].params()
+	require.True(t, ok)
+	require.Equal(t, "linkedin.com", params.Breach.Domain)
+	require.Equal(t, "LinkedIn", params.Breach.Title)
+	require.Equal(t, []string{}, params.Breach.DataClasses)
+
+	params, ok = Breach{Name: "Mail", Domain: "Mail.Example.co.uk", BreachDate: "2020-01-02"}.params()
+	require.True(t, ok)
+	require.Equal(t, "mail.example.co.uk", params.WebsiteHost)
+	require.Equal(t, "example.co.uk", params.WebsiteDomain)
+
+	// Breaches without a domain, and fabricated ones, are left out.
+	_, ok = breaches[2].params()
+	require.False(t, ok)
+	_, ok = breaches[3].params()
+	require.False(t, ok)
+
+	// So are breaches with an invalid date.
+	_, ok = Breach{Name: "Bad", Domain: "bad.com", BreachDate: "last week"}.params()
+	require.False(t, ok)
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/breaches" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(feed))
+	}))
+	defer srv.Close()
+
+	breaches, err := Fetch(context.Background(), srv.URL+"/breaches", nil)
+	require.NoError(t, err)
+	require.Len(t, breaches, 4)
+
+	_, err = Fetch(context.Background(), srv.URL+"/missing", nil)
+	require.ErrorContains(t, err, "unexpected status 404")
+
+	path := filepath.Join(t.TempDir(), "breaches.json")
+	require.NoError(t, os.WriteFile(path, []byte(feed), 0o600))
+
+	breaches, err = Fetch(context.Background(), path, nil)
+	require.NoError(t, err)
+	require.Len(t, breaches, 4)
+
+	_, err = Fetch(context.Background(), filepath.Join(t.TempDir(), "missing.json"), nil)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestImport(t *testing.T) {
+	breaches, err := Parse(strings.NewReader(feed))
+	require.NoError(t, err)
+
+	store := newFakeStore(map[string][]int64{
+		"adobe.com":    {1, 2},
+		"linkedin.com": {3},
+		"fake.com":     {4},
+	})
+
+	result, err := Import(context.Background(), store, breaches)
+	require.NoError(t, err)
+	require.Equal(t, Result{Imported: 2, Skipped: 2, Flagged: 3}, result)
+	require.Len(t, store.breaches, 2)
+
+	// Every flagged reminder gets an urgent notification job per channel.
+	require.Len(t, store.q.jobs, 6)
+	require.Equal(t, "breach.notify", store.q.jobs[0].Kind)
+	require.JSONEq(t, `{"reminder_id": 1, "breach_id": 1, "channel": "email"}`, string(store.q.jobs[0].Args))
+	require.JSONEq(t, `{"reminder_id": 1, "breach_id": 1, "channel": "push"}`, string(store.q.jobs[1].Args))
+	require.JSONEq(t, `{"reminder_id": 3, "breach_id": 2, "channel": "push"}`, string(store.q.jobs[5].Args))
+
+	// Importing the feed again flags nothing new.
+	result, err = Import(context.Background(), store, breaches)
+	require.NoError(t, err)
+	require.Equal(t, Result{Imported: 2, Skipped: 2}, result)
+	require.Len(t, store.q.jobs, 6)
+}
+
+func TestImportError(t *testing.T) {
+	breaches, err := Parse(strings.NewReader(feed))
+	require.NoError(t, err)
+
+	store := newFakeStore(map[string][]int64{"linkedin.com": {3}})
+	errEnqueue := errors.New("queue unavailable")
+	store.q.err = errEnqueue
+
+	result, err := Import(context.Background(), store, breaches)
+	require.ErrorIs(t, err, errEnqueue)
+	require.ErrorContains(t, err, "LinkedIn")
+	require.Equal(t, Result{Imported: 1}, result)
+}
+
+func TestImporterRunOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breaches.json")
+	require.NoError(t, os.WriteFile(path, []byte(feed), 0o600))
+
+	store := newFakeStore(map[string][]int64{"adobe.com": {1}})
+	importer := NewImporter(store, path, time.Hour)
+
+	result, err := importer.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Result{Imported: 2, Skipped: 2, Flagged: 1}, result)
+
+	importer = NewImporter(store, filepath.Join(t.TempDir(), "missing.json"), time.Hour)
+	_, err = importer.RunOnce(context.Background())
+	require.Error(t, err)
+}
+
+func TestImporterStartStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breaches.json")
+	require.NoError(t, os.WriteFile(path, []byte(feed), 0o600))
+
+	store := newFakeStore(map[string][]int64{"adobe.com": {1}})
+	importer := NewImporter(store, path, 10*time.Millisecond)
+	importer.Start()
+	importer.Start()
+
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.breaches) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, importer.Stop(ctx))
+	require.NoError(t, importer.Stop(ctx))
+}
+
+// fakeQuerier is the Querier that jobs get, or that the Flagged callback
+// gets from the importing transaction.
+type fakeQuerier struct {
+	db.Querier
+
+	users     map[int64]db.User
+	reminders map[int64]db.Reminder
+	breaches  map[int64]db.Breach
+	flagged   map[db.GetFlaggedReminderParams]bool
+	prefs     map[int64]db.UserPreference
+	jobs      []db.EnqueueJobParams
+	err       error
+}
+
+func (q *fakeQuerier) GetUser(ctx context.Context, userID int64) (db.User, error) {
+	user, ok := q.users[userID]
+	if !ok {
+		return db.User{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (q *fakeQuerier) GetFlaggedReminder(ctx context.Context, arg db.GetFlaggedReminderParams) (db.Reminder, error) {
+	reminder, ok := q.reminders[arg.ReminderID]
+	if !ok || !q.flagged[arg] {
+		return db.Reminder{}, sql.ErrNoRows
+	}
+
+	return reminder, nil
+}
+
+func (q *fakeQuerier) GetBreach(ctx context.Context, id int64) (db.Breach, error) {
+	breach, ok := q.breaches[id]
+	if !ok {
+		return db.Breach{}, sql.ErrNoRows
+	}
+
+	return breach, nil
+}
+
+func (q *fakeQuerier) GetUserPreferences(ctx context.Context, userID int64) (db.UserPreference, error) {
+	prefs, ok := q.prefs[userID]
+	if !ok {
+		return db.UserPreference{}, sql.ErrNoRows
+	}
+
+	return prefs, nil
+}
+
+func (q *fakeQuerier) EnqueueJob(ctx context.Context, arg db.EnqueueJobParams) (db.Job, error) {
+	if q.err != nil {
+		return db.Job{}, q.err
+	}
+
+	q.jobs = append(q.jobs, arg)
+	return db.Job{ID: int64(len(q.jobs)), Kind: arg.Kind, Args: arg.Args}, nil
+}
+
+type notifierFunc func(ctx context.Context, n notifier.Notification) error
+
+func (f notifierFunc) Notify(ctx context.Context, n notifier.Notification) error {
+	return f(ctx, n)
+}
+
+func TestHandleNotify(t *testing.T) {
+	user := db.User{ID: 1, Email: "jane@example.com", IsActivated: true, IsEmailVerified: true}
+	flagged := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "https://adobe.com"}
+	rotated := db.Reminder{ID: 3, UserID: user.ID, WebsiteUrl: "https://account.adobe.com"}
+	orphan := db.Reminder{ID: 4, UserID: 42, WebsiteUrl: "adobe.com"}
+	breach := db.Breach{ID: 5, Name: "Adobe", Title: "Adobe", Domain: "adobe.com"}
+
+	q := &fakeQuerier{
+		users: map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{
+			flagged.ID: flagged,
+			rotated.ID: rotated,
+			orphan.ID:  orphan,
+		},
+		breaches: map[int64]db.Breach{breach.ID: breach},
+		flagged: map[db.GetFlaggedReminderParams]bool{
+			{ReminderID: flagged.ID, BreachID: breach.ID}: true,
+			{ReminderID: orphan.ID, BreachID: breach.ID}:  true,
+		},
+	}
+
+	var got []notifier.Notification
+	handle := HandleNotify(q, notifierFunc(func(ctx context.Context, n notifier.Notification) error {
+		got = append(got, n)
+		return nil
+	}))
+
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: flagged.ID, BreachID: breach.ID}))
+	require.Equal(t, []notifier.Notification{{
+		Kind:     notifier.KindReminderBreached,
+		User:     user,
+		Reminder: flagged,
+		Breach:   &breach,
+		Location: time.UTC,
+	}}, got)
+
+	// Reminders rotated or deleted since they were flagged are skipped.
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: rotated.ID, BreachID: breach.ID}))
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: 99, BreachID: breach.ID}))
+	require.Len(t, got, 1)
+
+	// A reminder of an unknown user fails the job, so that it is retried.
+	err := handle(context.Background(), NotifyArgs{ReminderID: orphan.ID, BreachID: breach.ID})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Len(t, got, 1)
+
+	// The job of a channel only delivers over it.
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: flagged.ID, BreachID: breach.ID, Channel: notifier.ChannelEmail}))
+	require.Len(t, got, 2)
+	require.Equal(t, []string{notifier.ChannelEmail}, got[1].Channels)
+}
+
+func TestHandleNotifyDeactivatedUser(t *testing.T) {
+	user := db.User{ID: 1, Email: "jane@example.com", IsEmailVerified: true}
+	reminder := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "adobe.com"}
+	breach := db.Breach{ID: 5, Name: "Adobe", Title: "Adobe", Domain: "adobe.com"}
+
+	q := &fakeQuerier{
+		users:     map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{reminder.ID: reminder},
+		breaches:  map[int64]db.Breach{breach.ID: breach},
+		flagged: map[db.GetFlaggedReminderParams]bool{
+			{ReminderID: reminder.ID, BreachID: breach.ID}: true,
+		},
+	}
+
+	handle := HandleNotify(q, notifierFunc(func(ctx context.Context, n notifier.Notification) error {
+		t.Fatalf("notified deactivated user %d", n.User.ID)
+		return nil
+	}))
+
+	// The job is dropped rather than failed, so that it is not retried.
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: reminder.ID, BreachID: breach.ID}))
+	require.Empty(t, q.jobs)
+}
+
+func TestHandleNotifyQuietHours(t *testing.T) {
+	user := db.User{ID: 1, Email: "jane@example.com", IsActivated: true, IsEmailVerified: true}
+	reminder := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "adobe.com"}
+	breach := db.Breach{ID: 5, Name: "Adobe", Title: "Adobe", Domain: "adobe.com"}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Quiet hours from an hour ago to an hour from now, in Tokyo.
+	now := time.Now().In(tokyo)
+	clock := now.Hour()*60 + now.Minute()
+	start := int32((clock - 60 + 24*60) % (24 * 60))
+	end := int32((clock + 60) % (24 * 60))
+
+	q := &fakeQuerier{
+		users:     map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{reminder.ID: reminder},
+		breaches:  map[int64]db.Breach{breach.ID: breach},
+		flagged: map[db.GetFlaggedReminderParams]bool{
+			{ReminderID: reminder.ID, BreachID: breach.ID}: true,
+		},
+		prefs: map[int64]db.UserPreference{user.ID: {
+			UserID:          user.ID,
+			TimeZone:        "Asia/Tokyo",
+			QuietHoursStart: sql.NullInt32{Int32: start, Valid: true},
+			QuietHoursEnd:   sql.NullInt32{Int32: end, Valid: true},
+		}},
+	}
+
+	var got []notifier.Notification
+	handle := HandleNotify(q, notifierFunc(func(ctx context.Context, n notifier.Notification) error {
+		got = append(got, n)
+		return nil
+	}))
+
+	// Even urgent notifications wait for the quiet hours to end.
+	args := NotifyArgs{ReminderID: reminder.ID, BreachID: breach.ID}
+	require.NoError(t, handle(context.Background(), args))
+	require.Empty(t, got)
+	require.Len(t, q.jobs, 1)
+	require.Equal(t, "breach.notify", q.jobs[0].Kind)
+	require.JSONEq(t, `{"reminder_id": 2, "breach_id": 5}`, string(q.jobs[0].Args))
+	require.WithinDuration(t, now.Add(time.Hour), q.jobs[0].RunAt, time.Minute)
+}
@@ -0,0 +1,106 @@
+package breach
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// An Importer periodically imports the breaches feed at a source. Imports
+// are idempotent: known breaches are updated in place and reminders are
+// flagged by a breach only once, so any number of instances can run against
+// the same database.
+type Importer struct {
+	store    db.Store
+	source   string
+	client   *http.Client
+	interval time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewImporter creates an Importer that imports the feed at source, a URL or
+// the path of a file, into store every interval.
+func NewImporter(store db.Store, source string, interval time.Duration) *Importer {
+	return &Importer{
+		store:    store,
+		source:   source,
+		client:   &http.Client{Timeout: requestTimeout},
+		interval: interval,
+	}
+}
+
+// Start runs the importer in a new goroutine until Stop is called. Calling
+// Start on a running importer does nothing.
+func (i *Importer) Start() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i.cancel = cancel
+	i.done = make(chan struct{})
+
+	go i.run(ctx, i.done)
+}
+
+// Stop stops the importer and waits for the import in progress to finish, or
+// for ctx to be done.
+func (i *Importer) Stop(ctx context.Context) error {
+	i.mu.Lock()
+	cancel, done := i.cancel, i.done
+	i.cancel, i.done = nil, nil
+	i.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Importer) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := i.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("breach: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce fetches the feed and imports it.
+func (i *Importer) RunOnce(ctx context.Context) (Result, error) {
+	breaches, err := Fetch(ctx, i.source, i.client)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Import(ctx, i.store, breaches)
+}
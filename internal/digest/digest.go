@@ -0,0 +1,211 @@
+// Package digest sends users who opt in a daily or weekly email that
+// summarizes their reminders. Reminder alerts of subscribed users are held
+// back from individual emails and carried by the next digest instead, so
+// every alert is emailed exactly once.
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/interval"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+)
+
+// Digest frequencies.
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+)
+
+// Frequencies lists the frequencies a digest can be sent at.
+var Frequencies = []string{FrequencyDaily, FrequencyWeekly}
+
+const (
+	// SendHour is the hour of the day, in the user's time zone, at which
+	// digests are sent. Weekly digests are sent on Mondays.
+	SendHour = 8
+
+	// upcomingWindow is how far ahead a digest looks for reminders that are
+	// about to become due.
+	upcomingWindow = 7 * 24 * time.Hour
+
+	// pageSize is how many reminders are read at a time.
+	pageSize = 100
+)
+
+// Next returns when a digest sent at frequency is next due after t: at
+// SendHour in the user's time zone, or when the user's quiet hours end if
+// SendHour falls within them.
+func Next(frequency string, t time.Time, prefs preferences.Preferences) time.Time {
+	local := t.In(prefs.Location)
+	year, month, day := local.Date()
+
+	days := 1
+	if frequency == FrequencyWeekly {
+		days = 7
+		day += (int(time.Monday) - int(local.Weekday()) + 7) % 7
+	}
+
+	for ; ; day += days {
+		next := prefs.Deliverable(time.Date(year, month, day, SendHour, 0, 0, 0, prefs.Location))
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// An Item is a reminder as listed in a digest.
+type Item struct {
+	ID         int64
+	WebsiteURL string
+	Interval   string
+	UpdatedAt  time.Time
+	DueAt      time.Time
+}
+
+// A Digest groups a user's reminders for one digest email.
+type Digest struct {
+	// Overdue are reminders that became due since the last digest and are
+	// still waiting for their password to be changed.
+	Overdue []Item
+
+	// DueThisWeek are reminders that become due within the next seven days.
+	DueThisWeek []Item
+
+	// RecentlyRotated are reminders whose password was changed since the
+	// last digest.
+	RecentlyRotated []Item
+}
+
+// Empty reports whether d has nothing to tell.
+func (d Digest) Empty() bool {
+	return len(d.Overdue) == 0 && len(d.DueThisWeek) == 0 && len(d.RecentlyRotated) == 0
+}
+
+// Build groups the reminders of userID as of until, with their dates in
+// loc. overdue are the IDs of the reminders held back for the digest; since
+// is when the previous digest was sent.
+func Build(ctx context.Context, q db.Querier, userID int64, overdue []int64, since, until time.Time, loc *time.Location) (Digest, error) {
+	held := make(map[int64]bool, len(overdue))
+	for _, id := range overdue {
+		held[id] = true
+	}
+
+	var d Digest
+	for offset := 0; ; offset += pageSize {
+		reminders, err := q.ListReminders(ctx, db.ListRemindersParams{
+			UserID: userID,
+			Limit:  pageSize,
+			Offset: int32(offset),
+		})
+		if err != nil {
+			return Digest{}, err
+		}
+
+		for _, reminder := range reminders {
+			item := newItem(reminder, loc)
+
+			switch {
+			case held[reminder.ID] && !reminder.NextDueAt.Valid:
+				d.Overdue = append(d.Overdue, item)
+			case reminder.NextDueAt.Valid && reminder.NextDueAt.Time.Before(until.Add(upcomingWindow)):
+				d.DueThisWeek = append(d.DueThisWeek, item)
+			}
+
+			if reminder.UpdatedAt.After(since) && !reminder.UpdatedAt.After(until) {
+				d.RecentlyRotated = append(d.RecentlyRotated, item)
+			}
+		}
+
+		if len(reminders) < pageSize {
+			break
+		}
+	}
+
+	return d, nil
+}
+
+// newItem returns the digest item of reminder, with its dates in loc. Its
+// due date falls back to the end of its interval when the reminder is not
+// scheduled.
+func newItem(reminder db.Reminder, loc *time.Location) Item {
+	item := Item{
+		ID:         reminder.ID,
+		WebsiteURL: reminder.WebsiteUrl,
+		Interval:   reminder.Interval,
+		UpdatedAt:  reminder.UpdatedAt.In(loc),
+		DueAt:      reminder.NextDueAt.Time.In(loc),
+	}
+
+	if i, err := interval.Parse(reminder.Interval); err == nil {
+		item.Interval = i.Describe()
+		if !reminder.NextDueAt.Valid {
+			item.DueAt = i.AddIn(reminder.UpdatedAt, loc)
+		}
+	}
+
+	return item
+}
+
+// Args are the arguments of the job that sends a user's digest covering
+// Since to Until.
+type Args struct {
+	UserID    int64     `json:"user_id"`
+	Frequency string    `json:"frequency"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+}
+
+// Kind implements jobs.Args.
+func (Args) Kind() string { return "digest.send" }
+
+// Handle returns the handler of Args jobs, which emails the digest through
+// m. Nothing is sent to inactive users, unverified addresses or when the
+// digest is empty.
+func Handle(store db.Store, m mailer.Mailer) func(ctx context.Context, args Args) error {
+	return func(ctx context.Context, args Args) error {
+		user, err := store.GetUser(ctx, args.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if !user.IsActivated || !user.IsEmailVerified {
+			return nil
+		}
+
+		prefs, err := preferences.Load(ctx, store, user.ID)
+		if err != nil {
+			return err
+		}
+
+		return store.TakeDigestItemsTx(ctx, db.TakeDigestItemsTxParams{
+			UserID: user.ID,
+			Send: func(ctx context.Context, q db.Querier, reminderIDs []int64) error {
+				d, err := Build(ctx, q, user.ID, reminderIDs, args.Since, args.Until, prefs.Location)
+				if err != nil {
+					return err
+				}
+
+				if d.Empty() {
+					return nil
+				}
+
+				return m.Send(user.Email, "digest.tmpl", map[string]interface{}{
+					"FullName":        user.FullName,
+					"Frequency":       args.Frequency,
+					"Overdue":         d.Overdue,
+					"DueThisWeek":     d.DueThisWeek,
+					"RecentlyRotated": d.RecentlyRotated,
+				})
+			},
+		})
+	}
+}
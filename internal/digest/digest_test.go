@@ -0,0 +1,388 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer/smtptest"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is an in-memory store of users, reminders, held back digest
+// items and digest subscriptions.
+type fakeStore struct {
+	db.Store
+
+	mu            sync.Mutex
+	users         map[int64]db.User
+	reminders     []db.Reminder
+	items         map[int64][]int64
+	subscriptions []db.DigestSubscription
+	prefs         map[int64]db.UserPreference
+	jobs          []db.EnqueueJobParams
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users: make(map[int64]db.User),
+		items: make(map[int64][]int64),
+		prefs: make(map[int64]db.UserPreference),
+	}
+}
+
+func (s *fakeStore) GetUserPreferences(ctx context.Context, userID int64) (db.UserPreference, error) {
+	prefs, ok := s.prefs[userID]
+	if !ok {
+		return db.UserPreference{}, sql.ErrNoRows
+	}
+	return prefs, nil
+}
+
+func (s *fakeStore) GetUser(ctx context.Context, userID int64) (db.User, error) {
+	user, ok := s.users[userID]
+	if !ok {
+		return db.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *fakeStore) ListReminders(ctx context.Context, arg db.ListRemindersParams) ([]db.Reminder, error) {
+	reminders := []db.Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.UserID == arg.UserID {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	if int(arg.Offset) >= len(reminders) {
+		return []db.Reminder{}, nil
+	}
+	reminders = reminders[arg.Offset:]
+	if len(reminders) > int(arg.Limit) {
+		reminders = reminders[:arg.Limit]
+	}
+	return reminders, nil
+}
+
+func (s *fakeStore) TakeDigestItemsTx(ctx context.Context, arg db.TakeDigestItemsTxParams) error {
+	err := arg.Send(ctx, s, s.items[arg.UserID])
+	if err != nil {
+		return err
+	}
+
+	delete(s.items, arg.UserID)
+	return nil
+}
+
+func (s *fakeStore) EnqueueJob(ctx context.Context, arg db.EnqueueJobParams) (db.Job, error) {
+	s.jobs = append(s.jobs, arg)
+	return db.Job{ID: int64(len(s.jobs)), Kind: arg.Kind, Args: arg.Args}, nil
+}
+
+func (s *fakeStore) ScheduleDigestSubscription(ctx context.Context, arg db.ScheduleDigestSubscriptionParams) error {
+	for i := range s.subscriptions {
+		if s.subscriptions[i].UserID == arg.UserID {
+			s.subscriptions[i].LastSentAt = arg.LastSentAt
+			s.subscriptions[i].NextSendAt = arg.NextSendAt
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) DispatchDueDigestsTx(ctx context.Context, arg db.DispatchDueDigestsTxParams) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, subscription := range s.subscriptions {
+		if n == int(arg.Limit) {
+			break
+		}
+		if subscription.NextSendAt.After(time.Now()) {
+			continue
+		}
+
+		if err := arg.Dispatch(ctx, s, subscription); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func TestNext(t *testing.T) {
+	// Thursday 16 March 2023.
+	thursday := time.Date(2023, time.March, 16, 7, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		frequency string
+		t         time.Time
+		want      time.Time
+	}{
+		{"daily before send hour", FrequencyDaily, thursday, time.Date(2023, time.March, 16, SendHour, 0, 0, 0, time.UTC)},
+		{"daily at send hour", FrequencyDaily, time.Date(2023, time.March, 16, SendHour, 0, 0, 0, time.UTC), time.Date(2023, time.March, 17, SendHour, 0, 0, 0, time.UTC)},
+		{"daily across months", FrequencyDaily, time.Date(2023, time.March, 31, 20, 0, 0, 0, time.UTC), time.Date(2023, time.April, 1, SendHour, 0, 0, 0, time.UTC)},
+		{"weekly", FrequencyWeekly, thursday, time.Date(2023, time.March, 20, SendHour, 0, 0, 0, time.UTC)},
+		{"weekly on a monday before send hour", FrequencyWeekly, time.Date(2023, time.March, 20, 6, 0, 0, 0, time.UTC), time.Date(2023, time.March, 20, SendHour, 0, 0, 0, time.UTC)},
+		{"weekly on a monday after send hour", FrequencyWeekly, time.Date(2023, time.March, 20, 9, 0, 0, 0, time.UTC), time.Date(2023, time.March, 27, SendHour, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, Next(tc.frequency, tc.t, preferences.Default))
+		})
+	}
+}
+
+func TestNextTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	prefs := preferences.Preferences{Location: newYork}
+
+	// Sunday 12 March 2023 is when daylight saving time starts in New York,
+	// and still Sunday there at 03:00 UTC on Monday.
+	sunday := time.Date(2023, time.March, 13, 3, 0, 0, 0, time.UTC)
+
+	got := Next(FrequencyWeekly, sunday, prefs)
+	require.Equal(t, time.Date(2023, time.March, 13, SendHour, 0, 0, 0, newYork), got)
+	require.Equal(t, time.Date(2023, time.March, 13, SendHour+4, 0, 0, 0, time.UTC), got.UTC())
+
+	// A week earlier, New York was five hours behind UTC.
+	got = Next(FrequencyWeekly, sunday.AddDate(0, 0, -7), prefs)
+	require.Equal(t, time.Date(2023, time.March, 6, SendHour+5, 0, 0, 0, time.UTC), got.UTC())
+
+	got = Next(FrequencyDaily, time.Date(2023, time.March, 12, 0, 0, 0, 0, newYork), prefs)
+	require.Equal(t, time.Date(2023, time.March, 12, SendHour, 0, 0, 0, newYork), got)
+}
+
+func TestNextQuietHours(t *testing.T) {
+	prefs := preferences.Preferences{
+		Location:   time.UTC,
+		QuietHours: &preferences.QuietHours{Start: 22 * 60, End: 9*60 + 30},
+	}
+
+	// Digests wait for the quiet hours to end.
+	got := Next(FrequencyDaily, time.Date(2023, time.March, 16, 7, 0, 0, 0, time.UTC), prefs)
+	require.Equal(t, time.Date(2023, time.March, 16, 9, 30, 0, 0, time.UTC), got)
+
+	got = Next(FrequencyDaily, time.Date(2023, time.March, 16, 9, 30, 0, 0, time.UTC), prefs)
+	require.Equal(t, time.Date(2023, time.March, 17, 9, 30, 0, 0, time.UTC), got)
+
+	got = Next(FrequencyWeekly, time.Date(2023, time.March, 16, 7, 0, 0, 0, time.UTC), prefs)
+	require.Equal(t, time.Date(2023, time.March, 20, 9, 30, 0, 0, time.UTC), got)
+}
+
+func TestBuild(t *testing.T) {
+	since := time.Date(2023, time.March, 13, SendHour, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 0, 7)
+	scheduled := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+
+	store := newFakeStore()
+
+	// Enough unrelated reminders to need several pages.
+	for i := 0; i < 2*pageSize; i++ {
+		store.reminders = append(store.reminders, db.Reminder{
+			ID:        int64(i + 100),
+			UserID:    1,
+			Interval:  "P1Y",
+			UpdatedAt: since.AddDate(0, -1, 0),
+			NextDueAt: scheduled(since.AddDate(0, 11, 0)),
+		})
+	}
+
+	overdue := db.Reminder{ID: 1, UserID: 1, WebsiteUrl: "overdue.com", Interval: "P90D", UpdatedAt: since.AddDate(0, 0, -92)}
+	alertedBefore := db.Reminder{ID: 2, UserID: 1, WebsiteUrl: "alerted.com", Interval: "P90D", UpdatedAt: since.AddDate(0, 0, -120)}
+	rotatedSinceAlert := db.Reminder{ID: 3, UserID: 1, WebsiteUrl: "rotated.com", Interval: "P90D", UpdatedAt: until.AddDate(0, 0, -1), NextDueAt: scheduled(until.AddDate(0, 0, 89))}
+	dueSoon := db.Reminder{ID: 4, UserID: 1, WebsiteUrl: "soon.com", Interval: "P30D", UpdatedAt: since.AddDate(0, 0, -25), NextDueAt: scheduled(until.AddDate(0, 0, 3))}
+	otherUser := db.Reminder{ID: 5, UserID: 2, WebsiteUrl: "other.com", Interval: "P30D", UpdatedAt: since.AddDate(0, 0, 1), NextDueAt: scheduled(until.AddDate(0, 0, 1))}
+	store.reminders = append(store.reminders, overdue, alertedBefore, rotatedSinceAlert, dueSoon, otherUser)
+
+	// Reminder 6 was deleted after being held back.
+	d, err := Build(context.Background(), store, 1, []int64{overdue.ID, rotatedSinceAlert.ID, 6}, since, until, time.UTC)
+	require.NoError(t, err)
+
+	require.Len(t, d.Overdue, 1)
+	require.Equal(t, Item{
+		ID:         overdue.ID,
+		WebsiteURL: "overdue.com",
+		Interval:   "90 days",
+		UpdatedAt:  overdue.UpdatedAt,
+		DueAt:      overdue.UpdatedAt.AddDate(0, 0, 90),
+	}, d.Overdue[0])
+
+	// alerted.com was alerted before and is not repeated.
+	require.Len(t, d.DueThisWeek, 1)
+	require.Equal(t, "soon.com", d.DueThisWeek[0].WebsiteURL)
+	require.Equal(t, dueSoon.NextDueAt.Time, d.DueThisWeek[0].DueAt)
+
+	require.Len(t, d.RecentlyRotated, 1)
+	require.Equal(t, "rotated.com", d.RecentlyRotated[0].WebsiteURL)
+
+	require.False(t, d.Empty())
+	require.True(t, Digest{}.Empty())
+}
+
+func newTestMailer(t *testing.T) (mailer.Mailer, *smtptest.Server) {
+	server, err := smtptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	return mailer.NewSMTPMailer(server.Host, server.Port, "", "", "KeyKeeper <no-reply@keykeeper.test>"), server
+}
+
+func TestHandle(t *testing.T) {
+	m, server := newTestMailer(t)
+	until := time.Now()
+	since := until.AddDate(0, 0, -1)
+
+	store := newFakeStore()
+	store.users[1] = db.User{ID: 1, FullName: "Jane <Doe>", Email: "jane@example.com", IsActivated: true, IsEmailVerified: true}
+	store.reminders = []db.Reminder{
+		{ID: 1, UserID: 1, WebsiteUrl: "https://example.com/?a=1&b=2", Interval: "P90D", UpdatedAt: since.AddDate(0, 0, -90)},
+	}
+	store.items[1] = []int64{1}
+
+	handle := Handle(store, m)
+	err := handle(context.Background(), Args{UserID: 1, Frequency: FrequencyDaily, Since: since, Until: until})
+	require.NoError(t, err)
+
+	var msg smtptest.Message
+	select {
+	case msg = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	require.Equal(t, []string{"jane@example.com"}, msg.To)
+	require.Equal(t, "Your daily KeyKeeper digest", msg.Header("Subject"))
+
+	plain, err := msg.Body("text/plain")
+	require.NoError(t, err)
+	require.Contains(t, plain, "Hi Jane <Doe>,")
+	require.Contains(t, plain, "Overdue:")
+	require.Contains(t, plain, "- https://example.com/?a=1&b=2: due since")
+	require.NotContains(t, plain, "Due this week:")
+
+	html, err := msg.Body("text/html")
+	require.NoError(t, err)
+	require.Contains(t, html, "https://example.com/?a=1&amp;b=2")
+
+	// The held back alert went out and is not repeated, so the next digest
+	// has nothing to tell and is not sent.
+	require.Empty(t, store.items)
+	err = handle(context.Background(), Args{UserID: 1, Frequency: FrequencyDaily, Since: until, Until: until.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+
+	select {
+	case <-server.Received():
+		t.Fatal("empty digest was sent")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHandleSkipsUnverifiedUsers(t *testing.T) {
+	m, server := newTestMailer(t)
+	now := time.Now()
+
+	store := newFakeStore()
+	store.users[1] = db.User{ID: 1, Email: "jane@example.com", IsActivated: true}
+	store.reminders = []db.Reminder{{ID: 1, UserID: 1, WebsiteUrl: "example.com", Interval: "P90D", UpdatedAt: now.AddDate(0, 0, -90)}}
+	store.items[1] = []int64{1}
+
+	handle := Handle(store, m)
+	require.NoError(t, handle(context.Background(), Args{UserID: 1, Frequency: FrequencyDaily, Since: now.AddDate(0, 0, -1), Until: now}))
+	require.NoError(t, handle(context.Background(), Args{UserID: 42, Frequency: FrequencyDaily, Since: now.AddDate(0, 0, -1), Until: now}))
+
+	select {
+	case <-server.Received():
+		t.Fatal("digest was sent to an unverified address")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The alert is kept for when the address is verified.
+	require.Equal(t, []int64{1}, store.items[1])
+}
+
+func TestHandleSendError(t *testing.T) {
+	now := time.Now()
+
+	store := newFakeStore()
+	store.users[1] = db.User{ID: 1, Email: "jane@example.com", IsActivated: true, IsEmailVerified: true}
+	store.reminders = []db.Reminder{{ID: 1, UserID: 1, WebsiteUrl: "example.com", Interval: "P90D", UpdatedAt: now.AddDate(0, 0, -90)}}
+	store.items[1] = []int64{1}
+
+	errSend := errors.New("smtp unavailable")
+	handle := Handle(store, mailerFunc(func(recipient, templateFile string, data interface{}) error {
+		return errSend
+	}))
+
+	// A failed digest keeps its alerts for the retry.
+	err := handle(context.Background(), Args{UserID: 1, Frequency: FrequencyDaily, Since: now.AddDate(0, 0, -1), Until: now})
+	require.ErrorIs(t, err, errSend)
+	require.Equal(t, []int64{1}, store.items[1])
+}
+
+type mailerFunc func(recipient, templateFile string, data interface{}) error
+
+func (f mailerFunc) Send(recipient, templateFile string, data interface{}) error {
+	return f(recipient, templateFile, data)
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Now()
+
+	store := newFakeStore()
+	for i := 1; i <= 5; i++ {
+		store.subscriptions = append(store.subscriptions, db.DigestSubscription{
+			UserID:     int64(i),
+			Frequency:  FrequencyWeekly,
+			NextSendAt: now.Add(-time.Minute),
+			LastSentAt: now.AddDate(0, 0, -7),
+		})
+	}
+	// Not due yet.
+	store.subscriptions = append(store.subscriptions, db.DigestSubscription{
+		UserID:     6,
+		Frequency:  FrequencyDaily,
+		NextSendAt: now.Add(time.Hour),
+		LastSentAt: now.AddDate(0, 0, -1),
+	})
+
+	s := NewScheduler(store, time.Minute, 2)
+
+	n, err := s.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Len(t, store.jobs, 5)
+
+	for i, job := range store.jobs {
+		require.Equal(t, "digest.send", job.Kind)
+
+		var args Args
+		require.NoError(t, json.Unmarshal(job.Args, &args))
+		require.Equal(t, int64(i+1), args.UserID)
+		require.Equal(t, FrequencyWeekly, args.Frequency)
+		require.WithinDuration(t, now.AddDate(0, 0, -7), args.Since, time.Second)
+		require.True(t, args.Until.After(args.Since), fmt.Sprintf("job %d covers no time", i))
+
+		// The next digest picks up where this one ends.
+		subscription := store.subscriptions[i]
+		require.True(t, subscription.LastSentAt.Equal(args.Until))
+		require.Equal(t, Next(FrequencyWeekly, args.Until, preferences.Default), subscription.NextSendAt)
+	}
+
+	n, err = s.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
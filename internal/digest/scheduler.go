@@ -0,0 +1,145 @@
+package digest
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+)
+
+// Enqueue queues the digest of subscription covering the time since it was
+// last sent up to now, and schedules the next one. q should run within the
+// transaction that claimed the subscription.
+func Enqueue(ctx context.Context, q db.Querier, subscription db.DigestSubscription, now time.Time) error {
+	prefs, err := preferences.Load(ctx, q, subscription.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = jobs.Enqueue(ctx, q, Args{
+		UserID:    subscription.UserID,
+		Frequency: subscription.Frequency,
+		Since:     subscription.LastSentAt,
+		Until:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.ScheduleDigestSubscription(ctx, db.ScheduleDigestSubscriptionParams{
+		UserID:     subscription.UserID,
+		LastSentAt: now,
+		NextSendAt: Next(subscription.Frequency, now, prefs),
+	})
+}
+
+// A Scheduler periodically claims digest subscriptions that are due and
+// queues their digests. Claims use FOR UPDATE SKIP LOCKED, so any number of
+// instances can run against the same database.
+type Scheduler struct {
+	store     db.Store
+	interval  time.Duration
+	batchSize int32
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler creates a Scheduler that polls store every interval and
+// claims up to batchSize subscriptions per transaction.
+func NewScheduler(store db.Store, interval time.Duration, batchSize int) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		interval:  interval,
+		batchSize: int32(batchSize),
+	}
+}
+
+// Start runs the scheduler in a new goroutine until Stop is called. Calling
+// Start on a running scheduler does nothing.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.run(ctx, s.done)
+}
+
+// Stop stops the scheduler and waits for the batch in progress to finish, or
+// for ctx to be done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("digest: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce queues the digests that are due batch by batch until none are
+// left, and returns how many were queued.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	var total int
+
+	for ctx.Err() == nil {
+		n, err := s.store.DispatchDueDigestsTx(ctx, db.DispatchDueDigestsTxParams{
+			Limit: s.batchSize,
+			Dispatch: func(ctx context.Context, q db.Querier, subscription db.DigestSubscription) error {
+				return Enqueue(ctx, q, subscription, time.Now())
+			},
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if n < int(s.batchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
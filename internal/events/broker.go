@@ -0,0 +1,224 @@
+package events
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/lib/pq"
+)
+
+// pruneInterval is how often events older than the retention are deleted.
+const pruneInterval = time.Hour
+
+// A Subscription is woken whenever its user may have new events. Wake-ups
+// carry no events; the stream reads them from the database, from the last
+// one it sent. Wake-ups that arrive while the stream is busy are coalesced.
+type Subscription struct {
+	UserID int64
+
+	c      chan struct{}
+	broker *Broker
+}
+
+// C returns the channel that receives a value when the user may have new
+// events.
+func (s *Subscription) C() <-chan struct{} {
+	return s.c
+}
+
+// Done returns a channel that is closed when the stream must end, as the
+// server is shutting down.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.broker.closing
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+func (s *Subscription) wake() {
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+}
+
+// A Broker wakes the subscriptions of a user whenever the database notifies
+// Channel of a new event for them, and deletes events older than its
+// retention. Every API instance runs one, so streams are woken wherever they
+// are served.
+type Broker struct {
+	store         db.Querier
+	notifications <-chan *pq.Notification
+	retention     time.Duration
+
+	subsMu sync.Mutex
+	subs   map[int64]map[*Subscription]struct{}
+
+	closeOnce sync.Once
+	closing   chan struct{}
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewBroker creates a Broker that reads notifications, usually those of a
+// pq.Listener on Channel, and keeps events in store for retention.
+func NewBroker(store db.Querier, notifications <-chan *pq.Notification, retention time.Duration) *Broker {
+	return &Broker{
+		store:         store,
+		notifications: notifications,
+		retention:     retention,
+		subs:          make(map[int64]map[*Subscription]struct{}),
+		closing:       make(chan struct{}),
+	}
+}
+
+// Subscribe returns a new subscription to the events of userID. It must be
+// closed when the stream ends.
+func (b *Broker) Subscribe(userID int64) *Subscription {
+	s := &Subscription{
+		UserID: userID,
+		c:      make(chan struct{}, 1),
+		broker: b,
+	}
+
+	b.subsMu.Lock()
+	defer b.subsMu.Unlock()
+
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*Subscription]struct{})
+	}
+	b.subs[userID][s] = struct{}{}
+
+	return s
+}
+
+func (b *Broker) unsubscribe(s *Subscription) {
+	b.subsMu.Lock()
+	defer b.subsMu.Unlock()
+
+	delete(b.subs[s.UserID], s)
+	if len(b.subs[s.UserID]) == 0 {
+		delete(b.subs, s.UserID)
+	}
+}
+
+// Wake wakes the subscriptions of userID.
+func (b *Broker) Wake(userID int64) {
+	b.subsMu.Lock()
+	defer b.subsMu.Unlock()
+
+	for s := range b.subs[userID] {
+		s.wake()
+	}
+}
+
+// wakeAll wakes every subscription, for when notifications may have been
+// missed.
+func (b *Broker) wakeAll() {
+	b.subsMu.Lock()
+	defer b.subsMu.Unlock()
+
+	for _, subs := range b.subs {
+		for s := range subs {
+			s.wake()
+		}
+	}
+}
+
+// CloseStreams tells every stream to end, for the server to be able to shut
+// down. Streams are long-lived requests that would otherwise hold it up.
+func (b *Broker) CloseStreams() {
+	b.closeOnce.Do(func() { close(b.closing) })
+}
+
+// Start runs the broker in a new goroutine until Stop is called. Calling
+// Start on a running broker does nothing.
+func (b *Broker) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.done = make(chan struct{})
+
+	go b.run(ctx, b.done)
+}
+
+// Stop stops the broker and waits for the pruning in progress to finish, or
+// for ctx to be done.
+func (b *Broker) Stop(ctx context.Context) error {
+	b.mu.Lock()
+	cancel, done := b.cancel, b.done
+	b.cancel, b.done = nil, nil
+	b.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Broker) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-b.notifications:
+			b.notify(n)
+		case <-ticker.C:
+			_, err := b.Prune(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("events: %v", err)
+			}
+		}
+	}
+}
+
+// notify wakes the subscriptions of the user n is about. A nil n means that
+// the listener reconnected and may have missed notifications, so every
+// subscription is woken.
+func (b *Broker) notify(n *pq.Notification) {
+	if n == nil {
+		b.wakeAll()
+		return
+	}
+
+	userID, err := strconv.ParseInt(n.Extra, 10, 64)
+	if err != nil {
+		log.Printf("events: bad notification payload %q", n.Extra)
+		return
+	}
+
+	b.Wake(userID)
+}
+
+// Prune deletes the events older than the retention of the broker, and
+// returns how many were deleted.
+func (b *Broker) Prune(ctx context.Context) (int64, error) {
+	return b.store.DeleteUserEventsBefore(ctx, time.Now().Add(-b.retention))
+}
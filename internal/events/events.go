@@ -0,0 +1,40 @@
+// Package events streams events about a user's reminders to the clients the
+// user has connected. Events are kept in the database, which notifies every
+// API instance of new ones, so a client can be served by any instance and
+// resume from the last event it saw after reconnecting.
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+)
+
+// Channel is the Postgres notification channel that carries the ID of the
+// user of every new event.
+const Channel = "user_events"
+
+// Publish records event for the streams of a user. Streams are woken once
+// the transaction of q commits.
+func Publish(ctx context.Context, q db.Querier, userID int64, event webhook.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateUserEvent(ctx, db.CreateUserEventParams{
+		UserID:    userID,
+		EventType: event.Type,
+		Payload:   payload,
+	})
+	return err
+}
+
+// PublishReminderDue records a reminder.due event for the owner of reminder.
+// Its signature lets it serve as a scheduler dispatcher, so the event is
+// recorded in the transaction that claims the reminder.
+func PublishReminderDue(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+	return Publish(ctx, q, reminder.UserID, webhook.NewEvent(webhook.EventReminderDue, webhook.ReminderData(reminder)))
+}
@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuerier keeps the events created through it.
+type fakeQuerier struct {
+	db.Querier
+
+	events []db.UserEvent
+	before time.Time
+}
+
+func (q *fakeQuerier) CreateUserEvent(ctx context.Context, arg db.CreateUserEventParams) (db.UserEvent, error) {
+	event := db.UserEvent{
+		ID:        int64(len(q.events) + 1),
+		UserID:    arg.UserID,
+		EventType: arg.EventType,
+		Payload:   arg.Payload,
+		CreatedAt: time.Now(),
+	}
+	q.events = append(q.events, event)
+
+	return event, nil
+}
+
+func (q *fakeQuerier) DeleteUserEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	q.before = createdAt
+	return 0, nil
+}
+
+func requireWoken(t *testing.T, s *Subscription) {
+	select {
+	case <-s.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not woken")
+	}
+}
+
+func requireNotWoken(t *testing.T, s *Subscription) {
+	select {
+	case <-s.C():
+		t.Fatal("subscription was woken")
+	default:
+	}
+}
+
+func TestPublishReminderDue(t *testing.T) {
+	q := &fakeQuerier{}
+	reminder := db.Reminder{ID: 2, UserID: 1, WebsiteUrl: "example.com", Interval: "1 mon"}
+
+	require.NoError(t, PublishReminderDue(context.Background(), q, reminder))
+	require.Len(t, q.events, 1)
+	require.Equal(t, int64(1), q.events[0].UserID)
+	require.Equal(t, webhook.EventReminderDue, q.events[0].EventType)
+
+	var event webhook.Event
+	require.NoError(t, json.Unmarshal(q.events[0].Payload, &event))
+	require.Equal(t, webhook.EventReminderDue, event.Type)
+	require.NotZero(t, event.ID)
+	require.Equal(t, map[string]interface{}{
+		"reminder": map[string]interface{}{
+			"id":          float64(2),
+			"user_id":     float64(1),
+			"website_url": "example.com",
+			"interval":    "1 mon",
+			"updated_at":  "0001-01-01T00:00:00Z",
+			"next_due_at": nil,
+		},
+	}, event.Data)
+}
+
+func TestBrokerWake(t *testing.T) {
+	b := NewBroker(&fakeQuerier{}, nil, time.Hour)
+
+	s1 := b.Subscribe(1)
+	s2 := b.Subscribe(1)
+	other := b.Subscribe(2)
+
+	b.Wake(1)
+	requireWoken(t, s1)
+	requireWoken(t, s2)
+	requireNotWoken(t, other)
+
+	// Wake-ups coalesce until the subscription is read.
+	b.Wake(1)
+	b.Wake(1)
+	requireWoken(t, s1)
+	requireNotWoken(t, s1)
+
+	s1.Close()
+	s2.Close()
+	b.Wake(1)
+	requireNotWoken(t, s1)
+	require.NotContains(t, b.subs, int64(1))
+
+	other.Close()
+	require.Empty(t, b.subs)
+}
+
+func TestBrokerNotifications(t *testing.T) {
+	notifications := make(chan *pq.Notification)
+	b := NewBroker(&fakeQuerier{}, notifications, time.Hour)
+	b.Start()
+	defer b.Stop(context.Background())
+
+	s1 := b.Subscribe(1)
+	defer s1.Close()
+	s2 := b.Subscribe(2)
+	defer s2.Close()
+
+	notifications <- &pq.Notification{Channel: Channel, Extra: "1"}
+	requireWoken(t, s1)
+
+	notifications <- &pq.Notification{Channel: Channel, Extra: "bad"}
+
+	// The listener reconnecting wakes everyone, as notifications may have
+	// been lost.
+	notifications <- nil
+	requireWoken(t, s1)
+	requireWoken(t, s2)
+}
+
+func TestBrokerPrune(t *testing.T) {
+	q := &fakeQuerier{}
+	b := NewBroker(q, nil, 24*time.Hour)
+
+	_, err := b.Prune(context.Background())
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(-24*time.Hour), q.before, time.Second)
+}
+
+func TestBrokerStop(t *testing.T) {
+	b := NewBroker(&fakeQuerier{}, nil, time.Hour)
+	require.NoError(t, b.Stop(context.Background()))
+
+	b.Start()
+	b.Start()
+	require.NoError(t, b.Stop(context.Background()))
+}
+
+func TestBrokerCloseStreams(t *testing.T) {
+	b := NewBroker(&fakeQuerier{}, nil, time.Hour)
+	s := b.Subscribe(1)
+	defer s.Close()
+
+	select {
+	case <-s.Done():
+		t.Fatal("stream ended before the broker closed it")
+	default:
+	}
+
+	b.CloseStreams()
+	b.CloseStreams()
+
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("stream did not end")
+	}
+}
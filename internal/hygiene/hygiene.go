@@ -0,0 +1,206 @@
+// Package hygiene scores how well users keep their passwords fresh.
+//
+// A user's score runs from 0 to 100. It starts at 100 and every reminder can
+// take points off it: for being overdue, for how late it is, for an interval
+// longer than MaxInterval and for having no rotation on record. The points a
+// reminder can take are divided by the number of reminders, so the score
+// reflects the share of reminders in each state rather than their number.
+package hygiene
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/interval"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+)
+
+// Factors of the score.
+const (
+	FactorOverdue      = "overdue"
+	FactorLateness     = "lateness"
+	FactorLongInterval = "long_interval"
+	FactorNeverRotated = "never_rotated"
+)
+
+// Points each factor takes off the score when every reminder is affected.
+const (
+	overdueWeight      = 40
+	latenessWeight     = 20
+	longIntervalWeight = 20
+	neverRotatedWeight = 20
+)
+
+// maxLateness is how late a reminder must be to take every lateness point.
+const maxLateness = 90 * 24 * time.Hour
+
+// MaxInterval is the longest interval that is not counted as too long.
+var MaxInterval = interval.Interval{Years: 1}
+
+// A Factor is one of the measures the score is made of.
+type Factor struct {
+	Name string
+
+	// Value is the share of reminders affected, between 0 and 1, except for
+	// FactorLateness, whose value is the average number of days overdue
+	// reminders are late.
+	Value float64
+
+	// Penalty is the number of points the factor takes off the score.
+	Penalty float64
+}
+
+// A Finding is a reminder that takes points off the score.
+type Finding struct {
+	Reminder db.Reminder
+
+	// Factors are the factors the reminder counts towards. FactorLateness is
+	// left out, as it goes with FactorOverdue.
+	Factors []string
+
+	// DaysOverdue is how many whole days the reminder is late; zero unless it
+	// is overdue.
+	DaysOverdue int
+
+	// Penalty is the number of points the reminder takes off the score.
+	Penalty float64
+}
+
+// A Report is a user's score and what it is made of.
+type Report struct {
+	Score     int
+	Reminders int
+	Factors   []Factor
+
+	// Findings are ordered by decreasing penalty.
+	Findings []Finding
+}
+
+// Compute returns the report of reminders as of now. rotated holds the IDs
+// of the reminders that have a rotation on record, and loc is the time zone
+// of their user.
+//
+// A reminder is overdue from the end of its interval, counted in loc from its
+// last rotation like its notifications are, so snoozing it does not improve
+// the score. Reminders whose
+// interval does not parse are overdue from their next due date, or from when
+// they were last notified if they are not scheduled.
+func Compute(reminders []db.Reminder, rotated map[int64]bool, loc *time.Location, now time.Time) Report {
+	report := Report{Score: 100, Reminders: len(reminders)}
+
+	var overdue, longInterval, neverRotated int
+	var lateness, latenessPenalty float64
+
+	n := float64(len(reminders))
+	for _, reminder := range reminders {
+		finding := Finding{Reminder: reminder}
+
+		i, err := interval.Parse(reminder.Interval)
+		valid := err == nil
+
+		if due, ok := dueAt(reminder, i, valid, loc); ok && due.Before(now) {
+			late := now.Sub(due)
+			overdue++
+			lateness += late.Hours() / 24
+
+			penalty := latenessWeight * math.Min(float64(late)/float64(maxLateness), 1) / n
+			latenessPenalty += penalty
+
+			finding.Factors = append(finding.Factors, FactorOverdue)
+			finding.DaysOverdue = int(late / (24 * time.Hour))
+			finding.Penalty += overdueWeight/n + penalty
+		}
+
+		if valid && i.Longer(MaxInterval) {
+			longInterval++
+			finding.Factors = append(finding.Factors, FactorLongInterval)
+			finding.Penalty += longIntervalWeight / n
+		}
+
+		if !rotated[reminder.ID] {
+			neverRotated++
+			finding.Factors = append(finding.Factors, FactorNeverRotated)
+			finding.Penalty += neverRotatedWeight / n
+		}
+
+		if len(finding.Factors) > 0 {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	report.Factors = []Factor{
+		share(FactorOverdue, overdue, len(reminders), overdueWeight),
+		{Name: FactorLateness, Penalty: latenessPenalty},
+		share(FactorLongInterval, longInterval, len(reminders), longIntervalWeight),
+		share(FactorNeverRotated, neverRotated, len(reminders), neverRotatedWeight),
+	}
+	if overdue > 0 {
+		report.Factors[1].Value = lateness / float64(overdue)
+	}
+
+	var penalty float64
+	for _, factor := range report.Factors {
+		penalty += factor.Penalty
+	}
+	report.Score = int(math.Max(math.Round(100-penalty), 0))
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Penalty > report.Findings[j].Penalty
+	})
+
+	return report
+}
+
+// share returns the factor name affecting count of total reminders.
+func share(name string, count, total int, weight float64) Factor {
+	if total == 0 {
+		return Factor{Name: name}
+	}
+
+	value := float64(count) / float64(total)
+	return Factor{Name: name, Value: value, Penalty: weight * value}
+}
+
+// dueAt returns when reminder became or becomes due, and false if that is
+// unknown. i is its interval, if valid, counted in loc.
+func dueAt(reminder db.Reminder, i interval.Interval, valid bool, loc *time.Location) (time.Time, bool) {
+	switch {
+	case valid:
+		return i.AddIn(reminder.UpdatedAt, loc), true
+	case reminder.NextDueAt.Valid:
+		return reminder.NextDueAt.Time, true
+	case reminder.LastNotifiedAt.Valid:
+		return reminder.LastNotifiedAt.Time, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Load returns the report of userID as of now, in the time zone of their
+// preferences.
+func Load(ctx context.Context, q db.Querier, userID int64, now time.Time) (Report, error) {
+	prefs, err := preferences.Load(ctx, q, userID)
+	if err != nil {
+		return Report{}, err
+	}
+
+	reminders, err := q.ListAllReminders(ctx, userID)
+	if err != nil {
+		return Report{}, err
+	}
+
+	ids, err := q.ListRotatedReminderIDs(ctx, userID)
+	if err != nil {
+		return Report{}, err
+	}
+
+	rotated := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		rotated[id] = true
+	}
+
+	return Compute(reminders, rotated, prefs.Location, now), nil
+}
@@ -0,0 +1,182 @@
+package hygiene
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+func factor(t *testing.T, report Report, name string) Factor {
+	for _, f := range report.Factors {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("no factor %q", name)
+	return Factor{}
+}
+
+func TestComputeEmpty(t *testing.T) {
+	report := Compute(nil, nil, time.UTC, now)
+	require.Equal(t, 100, report.Score)
+	require.Zero(t, report.Reminders)
+	require.Len(t, report.Factors, 4)
+	for _, f := range report.Factors {
+		require.Zero(t, f.Value)
+		require.Zero(t, f.Penalty)
+	}
+	require.Empty(t, report.Findings)
+}
+
+func TestCompute(t *testing.T) {
+	reminders := []db.Reminder{
+		// Rotated and on time.
+		{ID: 1, Interval: "P3M", UpdatedAt: now.AddDate(0, -1, 0)},
+		// 30 days overdue, even though it is snoozed.
+		{
+			ID:        2,
+			Interval:  "P30D",
+			UpdatedAt: now.AddDate(0, 0, -60),
+			NextDueAt: sql.NullTime{Time: now.AddDate(0, 0, 3), Valid: true},
+		},
+		// Too long an interval and never rotated.
+		{ID: 3, Interval: "P2Y", UpdatedAt: now.AddDate(0, -1, 0)},
+		// An interval that does not parse, notified 180 days ago.
+		{
+			ID:             4,
+			Interval:       "1 mon",
+			UpdatedAt:      now.AddDate(-1, 0, 0),
+			LastNotifiedAt: sql.NullTime{Time: now.AddDate(0, 0, -180), Valid: true},
+		},
+	}
+	rotated := map[int64]bool{1: true, 2: true, 4: true}
+
+	report := Compute(reminders, rotated, time.UTC, now)
+	require.Equal(t, 4, report.Reminders)
+
+	overdue := factor(t, report, FactorOverdue)
+	require.Equal(t, 0.5, overdue.Value)
+	require.Equal(t, 20.0, overdue.Penalty)
+
+	// Reminder 2 takes a third of its lateness points, reminder 4 all of
+	// them.
+	lateness := factor(t, report, FactorLateness)
+	require.InDelta(t, 105, lateness.Value, 0.001)
+	require.InDelta(t, 20.0/3/4+20.0/4, lateness.Penalty, 0.001)
+
+	longInterval := factor(t, report, FactorLongInterval)
+	require.Equal(t, 0.25, longInterval.Value)
+	require.Equal(t, 5.0, longInterval.Penalty)
+
+	neverRotated := factor(t, report, FactorNeverRotated)
+	require.Equal(t, 0.25, neverRotated.Value)
+	require.Equal(t, 5.0, neverRotated.Penalty)
+
+	// 100 - 20 - 6.67 - 5 - 5
+	require.Equal(t, 63, report.Score)
+
+	require.Len(t, report.Findings, 3)
+	require.Equal(t, int64(4), report.Findings[0].Reminder.ID)
+	require.Equal(t, []string{FactorOverdue}, report.Findings[0].Factors)
+	require.Equal(t, 180, report.Findings[0].DaysOverdue)
+	require.InDelta(t, 15, report.Findings[0].Penalty, 0.001)
+
+	require.Equal(t, int64(2), report.Findings[1].Reminder.ID)
+	require.Equal(t, 30, report.Findings[1].DaysOverdue)
+
+	require.Equal(t, int64(3), report.Findings[2].Reminder.ID)
+	require.Equal(t, []string{FactorLongInterval, FactorNeverRotated}, report.Findings[2].Factors)
+	require.Zero(t, report.Findings[2].DaysOverdue)
+	require.Equal(t, 10.0, report.Findings[2].Penalty)
+}
+
+func TestComputeWorst(t *testing.T) {
+	reminders := []db.Reminder{
+		{ID: 1, Interval: "P2Y", UpdatedAt: now.AddDate(-3, 0, 0)},
+	}
+
+	report := Compute(reminders, nil, time.UTC, now)
+	require.Zero(t, report.Score)
+}
+
+func TestComputeLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Rotated on January 31 in Tokyo, but still on January 30 in UTC, so
+	// the reminder is due on February 28 in Tokyo, a day earlier than in
+	// UTC.
+	reminders := []db.Reminder{
+		{ID: 1, Interval: "P1M", UpdatedAt: time.Date(2023, time.January, 30, 20, 0, 0, 0, time.UTC)},
+	}
+	rotated := map[int64]bool{1: true}
+	at := time.Date(2023, time.February, 28, 8, 0, 0, 0, time.UTC)
+
+	require.Equal(t, 100, Compute(reminders, rotated, time.UTC, at).Score)
+
+	report := Compute(reminders, rotated, tokyo, at)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, []string{FactorOverdue}, report.Findings[0].Factors)
+}
+
+// fakeQuerier returns fixed reminders, rotations and preferences.
+type fakeQuerier struct {
+	db.Querier
+
+	reminders []db.Reminder
+	rotated   []int64
+	prefs     *db.UserPreference
+}
+
+func (q *fakeQuerier) GetUserPreferences(ctx context.Context, userID int64) (db.UserPreference, error) {
+	if q.prefs == nil {
+		return db.UserPreference{}, sql.ErrNoRows
+	}
+	return *q.prefs, nil
+}
+
+func (q *fakeQuerier) ListAllReminders(ctx context.Context, userID int64) ([]db.Reminder, error) {
+	return q.reminders, nil
+}
+
+func (q *fakeQuerier) ListRotatedReminderIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return q.rotated, nil
+}
+
+func TestLoad(t *testing.T) {
+	q := &fakeQuerier{
+		reminders: []db.Reminder{
+			{ID: 1, Interval: "P3M", UpdatedAt: now},
+			{ID: 2, Interval: "P3M", UpdatedAt: now},
+		},
+		rotated: []int64{1},
+	}
+
+	report, err := Load(context.Background(), q, 1, now)
+	require.NoError(t, err)
+	require.Equal(t, 90, report.Score)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, int64(2), report.Findings[0].Reminder.ID)
+}
+
+func TestLoadLocation(t *testing.T) {
+	q := &fakeQuerier{
+		reminders: []db.Reminder{
+			{ID: 1, Interval: "P1M", UpdatedAt: time.Date(2023, time.January, 30, 20, 0, 0, 0, time.UTC)},
+		},
+		rotated: []int64{1},
+		prefs:   &db.UserPreference{UserID: 1, TimeZone: "Asia/Tokyo"},
+	}
+
+	// The interval is counted in the user's time zone.
+	report, err := Load(context.Background(), q, 1, time.Date(2023, time.February, 28, 8, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, []string{FactorOverdue}, report.Findings[0].Factors)
+}
@@ -0,0 +1,194 @@
+// Package ical writes iCalendar feeds (RFC 5545) that calendar
+// applications can subscribe to.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxLineLength is the longest a content line may be, in octets,
+	// excluding the line break.
+	maxLineLength = 75
+
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+)
+
+// A Calendar is a feed of events.
+type Calendar struct {
+	// ProdID identifies the product that created the feed, such as
+	// "-//KeyKeeper//Calendar//EN".
+	ProdID string
+	// Name is shown by calendar applications as the calendar's title.
+	Name string
+	// RefreshInterval suggests how often subscribers poll the feed.
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// An Event is an all-day event, optionally recurring.
+type Event struct {
+	// UID identifies the event across versions of the feed.
+	UID string
+	// Stamp is when this version of the event was generated.
+	Stamp time.Time
+	// Date is the day of the event. Only its year, month and day are used.
+	Date time.Time
+	// RRule is a recurrence rule without the "RRULE:" prefix, such as
+	// "FREQ=MONTHLY;INTERVAL=3".
+	RRule        string
+	Summary      string
+	Description  string
+	URL          string
+	LastModified time.Time
+	Alarms       []Alarm
+}
+
+// An Alarm reminds the user of an event.
+type Alarm struct {
+	// Trigger is when the alarm goes off, relative to the start of the
+	// event's day. Negative values are before the event.
+	Trigger     time.Duration
+	Description string
+}
+
+// Encode writes c to w in iCalendar format.
+func (c Calendar) Encode(w io.Writer) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:" + c.ProdID)
+	e.line("CALSCALE:GREGORIAN")
+	e.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		e.line("NAME:" + escape(c.Name))
+		e.line("X-WR-CALNAME:" + escape(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		e.line("REFRESH-INTERVAL;VALUE=DURATION:" + formatDuration(c.RefreshInterval))
+		e.line("X-PUBLISHED-TTL:" + formatDuration(c.RefreshInterval))
+	}
+
+	for _, event := range c.Events {
+		e.event(event)
+	}
+
+	e.line("END:VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.w.Flush()
+}
+
+// An encoder writes content lines, remembering the first error.
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(event Event) {
+	e.line("BEGIN:VEVENT")
+	e.line("UID:" + escape(event.UID))
+	e.line("DTSTAMP:" + event.Stamp.UTC().Format(dateTimeFormat))
+	e.line("DTSTART;VALUE=DATE:" + event.Date.Format(dateFormat))
+	if event.RRule != "" {
+		e.line("RRULE:" + event.RRule)
+	}
+	e.line("SUMMARY:" + escape(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION:" + escape(event.Description))
+	}
+	if event.URL != "" {
+		e.line("URL:" + event.URL)
+	}
+	if !event.LastModified.IsZero() {
+		e.line("LAST-MODIFIED:" + event.LastModified.UTC().Format(dateTimeFormat))
+	}
+	// Reminders should not show the user as busy.
+	e.line("TRANSP:TRANSPARENT")
+
+	for _, alarm := range event.Alarms {
+		e.line("BEGIN:VALARM")
+		e.line("ACTION:DISPLAY")
+		e.line("TRIGGER;RELATED=START:" + formatDuration(alarm.Trigger))
+		e.line("DESCRIPTION:" + escape(alarm.Description))
+		e.line("END:VALARM")
+	}
+
+	e.line("END:VEVENT")
+}
+
+// line writes a content line, folded to maxLineLength octets without
+// splitting UTF-8 sequences, and terminated by CRLF.
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+
+	limit := maxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		_, e.err = e.w.WriteString(s[:cut] + "\r\n ")
+		if e.err != nil {
+			return
+		}
+		s = s[cut:]
+		// Continuation lines start with the folding space.
+		limit = maxLineLength - 1
+	}
+
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+// escape escapes a TEXT value.
+var escape = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+).Replace
+
+// formatDuration formats d as an RFC 5545 duration, such as "PT9H" or
+// "-P1D".
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	if d == 0 {
+		return "PT0S"
+	}
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%sP%dD", sign, d/(24*time.Hour))
+	}
+
+	var b strings.Builder
+	b.WriteString(sign + "PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	stamp := time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	cal := Calendar{
+		ProdID:          "-//KeyKeeper//Test//EN",
+		Name:            "Password rotations",
+		RefreshInterval: time.Hour,
+		Events: []Event{{
+			UID:          "reminder-1@keykeeper",
+			Stamp:        stamp,
+			Date:         time.Date(2023, time.May, 30, 0, 0, 0, 0, time.UTC),
+			RRule:        "FREQ=DAILY;INTERVAL=90",
+			Summary:      "Change password; example.com, now",
+			Description:  "Line one\nLine two",
+			URL:          "https://example.com/login",
+			LastModified: stamp,
+			Alarms: []Alarm{
+				{Trigger: 9 * time.Hour, Description: "Change password"},
+				{Trigger: -24 * time.Hour, Description: "Tomorrow"},
+			},
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, cal.Encode(&buf))
+
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//KeyKeeper//Test//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"NAME:Password rotations",
+		"X-WR-CALNAME:Password rotations",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+		"X-PUBLISHED-TTL:PT1H",
+		"BEGIN:VEVENT",
+		"UID:reminder-1@keykeeper",
+		"DTSTAMP:20230301T090000Z",
+		"DTSTART;VALUE=DATE:20230530",
+		"RRULE:FREQ=DAILY;INTERVAL=90",
+		`SUMMARY:Change password\; example.com\, now`,
+		`DESCRIPTION:Line one\nLine two`,
+		"URL:https://example.com/login",
+		"LAST-MODIFIED:20230301T090000Z",
+		"TRANSP:TRANSPARENT",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=START:PT9H",
+		"DESCRIPTION:Change password",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=START:-P1D",
+		"DESCRIPTION:Tomorrow",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	require.Equal(t, want, buf.String())
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("é", 100)
+
+	var buf bytes.Buffer
+	err := Calendar{Events: []Event{{Summary: summary}}}.Encode(&buf)
+	require.NoError(t, err)
+
+	var unfolded strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), maxLineLength)
+		require.True(t, strings.ToValidUTF8(line, "") == line, "line splits a UTF-8 sequence: %q", line)
+
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+
+	require.Contains(t, unfolded.String(), "\nSUMMARY:"+summary+"\n")
+}
+
+func TestFormatDuration(t *testing.T) {
+	testCases := map[time.Duration]string{
+		0:                         "PT0S",
+		9 * time.Hour:             "PT9H",
+		90 * time.Minute:          "PT1H30M",
+		-15 * time.Minute:         "-PT15M",
+		48 * time.Hour:            "P2D",
+		-24 * time.Hour:           "-P1D",
+		time.Hour + 5*time.Second: "PT1H5S",
+	}
+
+	for d, want := range testCases {
+		require.Equal(t, want, formatDuration(d))
+	}
+}
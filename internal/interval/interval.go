@@ -0,0 +1,340 @@
+// Package interval parses and normalizes reminder intervals.
+//
+// An interval is either an ISO 8601 duration with date components only, such
+// as "P90D", "P1Y6M" or "P2W", or a human-readable duration made of one or
+// more "<number> <unit>" terms, such as "90 days", "3 months" or
+// "1 year, 6 months". The units are day, week, month and year, in singular
+// or plural form, and terms may be separated by spaces, commas or "and".
+//
+// Intervals are normalized to ISO 8601 durations: weeks become days and
+// every 12 months become a year, so "2 weeks" and "P14D" are the same
+// interval.
+package interval
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// ErrSyntax is returned for strings that are not intervals.
+	ErrSyntax = errors.New(`must be an ISO 8601 duration such as "P90D" or a duration such as "90 days"`)
+
+	// ErrTimeComponent is returned for ISO 8601 durations with hours,
+	// minutes or seconds.
+	ErrTimeComponent = errors.New("must only contain years, months, weeks and days")
+
+	// ErrTooShort is returned for intervals shorter than Min.
+	ErrTooShort = errors.New("must be at least one day")
+
+	// ErrTooLong is returned for intervals longer than Max.
+	ErrTooLong = errors.New("must not be more than 10 years")
+)
+
+var (
+	// Min is the shortest accepted interval.
+	Min = Interval{Days: 1}
+
+	// Max is the longest accepted interval.
+	Max = Interval{Years: 10}
+)
+
+// An Interval is a calendar duration of whole years, months and days.
+type Interval struct {
+	Years  int
+	Months int
+	Days   int
+}
+
+// maxComponent bounds each number of a parsed interval, well above Max, so
+// that arithmetic on parsed intervals cannot overflow.
+const maxComponent = 100_000
+
+// Parse parses an ISO 8601 or human-readable interval between Min and Max
+// and returns it normalized.
+func Parse(s string) (Interval, error) {
+	s = strings.TrimSpace(s)
+
+	var (
+		i   Interval
+		err error
+	)
+	if len(s) > 0 && (s[0] == 'P' || s[0] == 'p') {
+		i, err = parseISO(s)
+	} else {
+		i, err = parseHuman(s)
+	}
+	if err != nil {
+		return Interval{}, err
+	}
+
+	i = i.normalize()
+
+	switch {
+	case i.less(Min):
+		return Interval{}, ErrTooShort
+	case Max.less(i):
+		return Interval{}, ErrTooLong
+	}
+
+	return i, nil
+}
+
+// parseISO parses an ISO 8601 duration such as "P1Y2M3W4D".
+func parseISO(s string) (Interval, error) {
+	rest := strings.ToUpper(s[1:])
+	if rest == "" {
+		return Interval{}, ErrSyntax
+	}
+
+	var i Interval
+	// order is the index in "YMWD" of the last designator seen; each may
+	// appear once and in that order.
+	order := -1
+	for rest != "" {
+		if rest[0] == 'T' {
+			return Interval{}, ErrTimeComponent
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if end <= 0 {
+			return Interval{}, ErrSyntax
+		}
+
+		n, err := parseNumber(rest[:end])
+		if err != nil {
+			return Interval{}, err
+		}
+
+		pos := strings.IndexByte("YMWD", rest[end])
+		if pos <= order {
+			return Interval{}, ErrSyntax
+		}
+		order = pos
+
+		switch rest[end] {
+		case 'Y':
+			i.Years = n
+		case 'M':
+			i.Months = n
+		case 'W':
+			i.Days += 7 * n
+		case 'D':
+			i.Days += n
+		}
+
+		rest = rest[end+1:]
+	}
+
+	return i, nil
+}
+
+// parseHuman parses a duration such as "1 year, 6 months and 2 weeks".
+func parseHuman(s string) (Interval, error) {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+
+	var (
+		i     Interval
+		terms int
+	)
+	for len(fields) > 0 {
+		if fields[0] == "and" && terms > 0 && len(fields) > 1 {
+			fields = fields[1:]
+			continue
+		}
+
+		if len(fields) < 2 {
+			return Interval{}, ErrSyntax
+		}
+
+		n, err := parseNumber(fields[0])
+		if err != nil {
+			return Interval{}, err
+		}
+
+		switch fields[1] {
+		case "day", "days":
+			i.Days += n
+		case "week", "weeks":
+			i.Days += 7 * n
+		case "month", "months":
+			i.Months += n
+		case "year", "years":
+			i.Years += n
+		default:
+			return Interval{}, ErrSyntax
+		}
+
+		fields = fields[2:]
+		terms++
+	}
+
+	if terms == 0 {
+		return Interval{}, ErrSyntax
+	}
+
+	return i, nil
+}
+
+// parseNumber parses a non-negative decimal number of at most maxComponent.
+func parseNumber(s string) (int, error) {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, ErrSyntax
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n > maxComponent {
+		return 0, ErrTooLong
+	}
+
+	return n, nil
+}
+
+func (i Interval) normalize() Interval {
+	i.Years += i.Months / 12
+	i.Months %= 12
+	return i
+}
+
+// reference is the fixed date from which intervals are compared.
+var reference = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// less reports whether i is shorter than j.
+func (i Interval) less(j Interval) bool {
+	return i.AddTo(reference).Before(j.AddTo(reference))
+}
+
+// Longer reports whether i is longer than j, counted from the same date.
+func (i Interval) Longer(j Interval) bool {
+	return j.less(i)
+}
+
+// String returns i as an ISO 8601 duration, such as "P1Y6M" or "P90D".
+func (i Interval) String() string {
+	var b strings.Builder
+	b.WriteByte('P')
+
+	if i.Years != 0 {
+		fmt.Fprintf(&b, "%dY", i.Years)
+	}
+	if i.Months != 0 {
+		fmt.Fprintf(&b, "%dM", i.Months)
+	}
+	if i.Days != 0 || b.Len() == 1 {
+		fmt.Fprintf(&b, "%dD", i.Days)
+	}
+
+	return b.String()
+}
+
+// Describe returns i in words, such as "1 year, 6 months and 14 days".
+func (i Interval) Describe() string {
+	var parts []string
+	for _, c := range []struct {
+		n    int
+		unit string
+	}{{i.Years, "year"}, {i.Months, "month"}, {i.Days, "day"}} {
+		switch {
+		case c.n == 1:
+			parts = append(parts, "1 "+c.unit)
+		case c.n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", c.n, c.unit))
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return "0 days"
+	case 1:
+		return parts[0]
+	default:
+		return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+}
+
+// AddTo returns t plus i, computed in UTC. Years and months are added first,
+// keeping the day of the month but clamping it to the length of the
+// resulting month, so one month after January 31 is the last day of
+// February. Days are added last.
+func (i Interval) AddTo(t time.Time) time.Time {
+	return i.AddIn(t, time.UTC)
+}
+
+// AddIn is like AddTo but computes the calendar arithmetic in loc, keeping
+// the wall clock time of t in loc across daylight saving time transitions.
+// A wall clock time skipped by a transition is moved forward by the length
+// of the transition.
+func (i Interval) AddIn(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+
+	// Day 0 of the month after the target month is its last day.
+	months := int(month) - 1 + i.Months + 12*i.Years
+	lastDay := time.Date(year, time.Month(months+2), 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(year, time.Month(months+1), day+i.Days, hour, min, sec, t.Nanosecond(), loc)
+}
+
+// RRule returns an RFC 5545 recurrence rule that repeats i from start, such
+// as "FREQ=MONTHLY;INTERVAL=3". The start day is taken in the location of
+// start, which must be the one its DTSTART is written in. As with AddTo, a
+// start day that is missing from a shorter month falls on that month's last
+// day. It returns false for
+// intervals that mix years or months with days, which no single rule can
+// express.
+func (i Interval) RRule(start time.Time) (string, bool) {
+	months := 12*i.Years + i.Months
+
+	switch {
+	case months == 0 && i.Days%7 == 0:
+		return withInterval("FREQ=WEEKLY", i.Days/7), true
+	case months == 0:
+		return withInterval("FREQ=DAILY", i.Days), true
+	case i.Days != 0:
+		return "", false
+	}
+
+	day := start.Day()
+
+	if months%12 == 0 {
+		rule := withInterval("FREQ=YEARLY", months/12)
+		if start.Month() == time.February && day == 29 {
+			rule += ";BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1"
+		}
+		return rule, true
+	}
+
+	rule := withInterval("FREQ=MONTHLY", months)
+	if day > 28 {
+		// Of the days from the 28th up to the start day, recur on the last
+		// one the month has.
+		days := make([]string, 0, 4)
+		for d := 28; d <= day; d++ {
+			days = append(days, strconv.Itoa(d))
+		}
+		rule += ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+	}
+
+	return rule, true
+}
+
+func withInterval(rule string, n int) string {
+	if n == 1 {
+		return rule
+	}
+
+	return rule + ";INTERVAL=" + strconv.Itoa(n)
+}
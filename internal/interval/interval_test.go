@@ -0,0 +1,264 @@
+package interval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "P90D", want: "P90D"},
+		{input: "p90d", want: "P90D"},
+		{input: "P2W", want: "P14D"},
+		{input: "P1Y6M", want: "P1Y6M"},
+		{input: "P18M", want: "P1Y6M"},
+		{input: "P1M2W3D", want: "P1M17D"},
+		{input: " P1Y ", want: "P1Y"},
+		{input: "90 days", want: "P90D"},
+		{input: "1 day", want: "P1D"},
+		{input: "2 weeks", want: "P14D"},
+		{input: "3 months", want: "P3M"},
+		{input: "12 Months", want: "P1Y"},
+		{input: "1 year, 6 months", want: "P1Y6M"},
+		{input: "1 year 2 months and 3 days", want: "P1Y2M3D"},
+		{input: "10 years", want: "P10Y"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			i, err := Parse(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, i.String())
+
+			// The normalized form parses to the same interval.
+			i2, err := Parse(i.String())
+			require.NoError(t, err)
+			require.Equal(t, i, i2)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testCases := []struct {
+		input string
+		err   error
+	}{
+		{input: "", err: ErrSyntax},
+		{input: "P", err: ErrSyntax},
+		{input: "P1", err: ErrSyntax},
+		{input: "PD", err: ErrSyntax},
+		{input: "P1.5D", err: ErrSyntax},
+		{input: "P-1D", err: ErrSyntax},
+		{input: "P1D1Y", err: ErrSyntax},
+		{input: "P1D1D", err: ErrSyntax},
+		{input: "P1DX", err: ErrSyntax},
+		{input: "PT12H", err: ErrTimeComponent},
+		{input: "P1DT12H", err: ErrTimeComponent},
+		{input: "2023-01-01T00:00:00Z", err: ErrSyntax},
+		{input: "90", err: ErrSyntax},
+		{input: "days", err: ErrSyntax},
+		{input: "90 fortnights", err: ErrSyntax},
+		{input: "-5 days", err: ErrSyntax},
+		{input: "5 days and", err: ErrSyntax},
+		{input: "and 5 days", err: ErrSyntax},
+		{input: "soon", err: ErrSyntax},
+		{input: "P0D", err: ErrTooShort},
+		{input: "0 days", err: ErrTooShort},
+		{input: "P10Y1D", err: ErrTooLong},
+		{input: "121 months", err: ErrTooLong},
+		{input: "99999999999999999999 days", err: ErrTooLong},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := Parse(tc.input)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestAddTo(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 30, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		interval Interval
+		from     time.Time
+		want     time.Time
+	}{
+		{interval: Interval{Days: 90}, from: date(2023, time.January, 1), want: date(2023, time.April, 1)},
+		{interval: Interval{Months: 1}, from: date(2023, time.January, 15), want: date(2023, time.February, 15)},
+		{interval: Interval{Months: 1}, from: date(2023, time.January, 31), want: date(2023, time.February, 28)},
+		{interval: Interval{Months: 1}, from: date(2024, time.January, 31), want: date(2024, time.February, 29)},
+		{interval: Interval{Years: 1}, from: date(2024, time.February, 29), want: date(2025, time.February, 28)},
+		{interval: Interval{Months: 11}, from: date(2023, time.March, 31), want: date(2024, time.February, 29)},
+		{interval: Interval{Months: 1, Days: 1}, from: date(2023, time.January, 31), want: date(2023, time.March, 1)},
+		{interval: Interval{Years: 1, Months: 6}, from: date(2023, time.August, 31), want: date(2025, time.February, 28)},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, tc.interval.AddTo(tc.from), "%s after %s", tc.interval, tc.from)
+	}
+
+	// The result does not depend on the location of the time.
+	from := date(2023, time.March, 25)
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	require.Equal(t, Interval{Days: 1}.AddTo(from), Interval{Days: 1}.AddTo(from.In(loc)))
+}
+
+func TestAddIn(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		interval Interval
+		from     time.Time
+		want     time.Time
+	}{
+		{
+			name:     "across the start of daylight saving time",
+			interval: Interval{Days: 1},
+			from:     time.Date(2023, time.March, 25, 10, 30, 0, 0, berlin),
+			want:     time.Date(2023, time.March, 26, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "across the end of daylight saving time",
+			interval: Interval{Months: 1},
+			from:     time.Date(2023, time.October, 15, 9, 0, 0, 0, berlin),
+			want:     time.Date(2023, time.November, 15, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "into a skipped hour",
+			interval: Interval{Days: 7},
+			from:     time.Date(2023, time.March, 19, 2, 30, 0, 0, berlin),
+			want:     time.Date(2023, time.March, 26, 3, 30, 0, 0, berlin),
+		},
+		{
+			name:     "month clamping on the local date",
+			interval: Interval{Months: 1},
+			from:     time.Date(2023, time.January, 31, 23, 30, 0, 0, newYork),
+			want:     time.Date(2023, time.February, 28, 23, 30, 0, 0, newYork),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.interval.AddIn(tc.from, tc.from.Location())
+			require.True(t, tc.want.Equal(got), "want %s, got %s", tc.want, got)
+
+			// The result is the same whatever the location of the time.
+			require.True(t, got.Equal(tc.interval.AddIn(tc.from.UTC(), tc.from.Location())))
+		})
+	}
+
+	// In UTC, AddIn is AddTo.
+	from := time.Date(2023, time.January, 31, 23, 30, 0, 0, newYork)
+	require.Equal(t, Interval{Months: 1}.AddTo(from), Interval{Months: 1}.AddIn(from, time.UTC))
+}
+
+func TestLonger(t *testing.T) {
+	year := Interval{Years: 1}
+
+	require.True(t, Interval{Days: 400}.Longer(year))
+	require.True(t, Interval{Years: 1, Days: 1}.Longer(year))
+	require.False(t, Interval{Months: 12}.Longer(year))
+	require.False(t, Interval{Days: 300}.Longer(year))
+	require.False(t, year.Longer(year))
+}
+
+func TestDescribe(t *testing.T) {
+	testCases := map[string]string{
+		"P1D":     "1 day",
+		"P14D":    "14 days",
+		"P1Y":     "1 year",
+		"P1Y6M":   "1 year and 6 months",
+		"P2Y1M3D": "2 years, 1 month and 3 days",
+	}
+
+	for input, want := range testCases {
+		i, err := Parse(input)
+		require.NoError(t, err)
+		require.Equal(t, want, i.Describe())
+
+		// Descriptions parse back to the same interval.
+		i2, err := Parse(i.Describe())
+		require.NoError(t, err)
+		require.Equal(t, i, i2)
+	}
+}
+
+func TestRRule(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 30, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		interval string
+		start    time.Time
+		want     string
+	}{
+		{interval: "P1D", start: date(2023, time.March, 1), want: "FREQ=DAILY"},
+		{interval: "P90D", start: date(2023, time.March, 1), want: "FREQ=DAILY;INTERVAL=90"},
+		{interval: "P2W", start: date(2023, time.March, 1), want: "FREQ=WEEKLY;INTERVAL=2"},
+		{interval: "P7D", start: date(2023, time.March, 1), want: "FREQ=WEEKLY"},
+		{interval: "P1M", start: date(2023, time.March, 15), want: "FREQ=MONTHLY"},
+		{interval: "P3M", start: date(2023, time.March, 15), want: "FREQ=MONTHLY;INTERVAL=3"},
+		{interval: "P1Y6M", start: date(2023, time.March, 15), want: "FREQ=MONTHLY;INTERVAL=18"},
+		{interval: "P1M", start: date(2023, time.January, 31), want: "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{interval: "P6M", start: date(2023, time.April, 29), want: "FREQ=MONTHLY;INTERVAL=6;BYMONTHDAY=28,29;BYSETPOS=-1"},
+		{interval: "P1Y", start: date(2023, time.March, 31), want: "FREQ=YEARLY"},
+		{interval: "P2Y", start: date(2024, time.February, 29), want: "FREQ=YEARLY;INTERVAL=2;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1"},
+	}
+
+	for _, tc := range testCases {
+		i, err := Parse(tc.interval)
+		require.NoError(t, err)
+
+		rule, ok := i.RRule(tc.start)
+		require.True(t, ok)
+		require.Equal(t, tc.want, rule, "%s from %s", tc.interval, tc.start)
+	}
+
+	_, ok := Interval{Months: 1, Days: 14}.RRule(date(2023, time.March, 1))
+	require.False(t, ok)
+}
+
+func TestRRuleLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		interval string
+		start    time.Time
+		want     string
+	}{
+		// Shortly after midnight in Tokyo, it is still the day before in
+		// UTC.
+		{interval: "P1M", start: time.Date(2023, time.January, 31, 0, 30, 0, 0, tokyo), want: "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{interval: "P1Y", start: time.Date(2024, time.February, 29, 0, 30, 0, 0, tokyo), want: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1"},
+		// Shortly before midnight in New York, it is already the day after
+		// in UTC.
+		{interval: "P1M", start: time.Date(2023, time.January, 30, 23, 30, 0, 0, newYork), want: "FREQ=MONTHLY;BYMONTHDAY=28,29,30;BYSETPOS=-1"},
+		{interval: "P1M", start: time.Date(2023, time.January, 27, 23, 30, 0, 0, newYork), want: "FREQ=MONTHLY"},
+	}
+
+	for _, tc := range testCases {
+		i, err := Parse(tc.interval)
+		require.NoError(t, err)
+
+		rule, ok := i.RRule(tc.start)
+		require.True(t, ok)
+		require.Equal(t, tc.want, rule, "%s from %s", tc.interval, tc.start)
+	}
+}
@@ -0,0 +1,62 @@
+// Package jobs runs background work from a queue kept in the jobs table.
+// Jobs are claimed with FOR UPDATE SKIP LOCKED, so any number of workers
+// can share the queue, and failed jobs are retried with exponential backoff
+// until they run out of attempts and are dead-lettered.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// DefaultMaxAttempts is how many times a job is run before it is
+// dead-lettered, unless its Args say otherwise.
+const DefaultMaxAttempts = 8
+
+// Args are the arguments of a job, stored as JSON. Kind names the handler
+// that runs the job and must be constant for a type.
+type Args interface {
+	Kind() string
+}
+
+// MaxAttempter may be implemented by Args to override DefaultMaxAttempts.
+type MaxAttempter interface {
+	MaxAttempts() int
+}
+
+// Enqueue queues a job to run as soon as possible. Queuing through the
+// Querier of a transaction makes the job part of it.
+func Enqueue(ctx context.Context, q db.Querier, args Args) (db.Job, error) {
+	return EnqueueAt(ctx, q, args, time.Now())
+}
+
+// EnqueueAt queues a job to run at runAt.
+func EnqueueAt(ctx context.Context, q db.Querier, args Args, runAt time.Time) (db.Job, error) {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return db.Job{}, err
+	}
+
+	maxAttempts := DefaultMaxAttempts
+	if m, ok := args.(MaxAttempter); ok {
+		maxAttempts = m.MaxAttempts()
+	}
+
+	return q.EnqueueJob(ctx, db.EnqueueJobParams{
+		Kind:        args.Kind(),
+		Args:        b,
+		RunAt:       runAt,
+		MaxAttempts: int32(maxAttempts),
+	})
+}
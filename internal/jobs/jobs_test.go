@@ -0,0 +1,398 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuerier keeps jobs in memory and claims them the way ClaimJobs does.
+type fakeQuerier struct {
+	db.Querier
+
+	mu   sync.Mutex
+	jobs map[int64]*db.Job
+}
+
+func newFakeQuerier() *fakeQuerier {
+	return &fakeQuerier{jobs: make(map[int64]*db.Job)}
+}
+
+func (q *fakeQuerier) EnqueueJob(ctx context.Context, arg db.EnqueueJobParams) (db.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := &db.Job{
+		ID:          int64(len(q.jobs) + 1),
+		Kind:        arg.Kind,
+		Args:        arg.Args,
+		Status:      StatusQueued,
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       arg.RunAt,
+		CreatedAt:   time.Now(),
+	}
+	q.jobs[job.ID] = job
+
+	return *job, nil
+}
+
+func (q *fakeQuerier) ClaimJobs(ctx context.Context, arg db.ClaimJobsParams) ([]db.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []*db.Job
+	for _, job := range q.jobs {
+		if job.Status == StatusQueued && !job.RunAt.After(time.Now()) ||
+			job.Status == StatusRunning && !job.LockedUntil.Time.After(time.Now()) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].RunAt.Before(due[j].RunAt) })
+
+	claimed := []db.Job{}
+	for _, job := range due {
+		if len(claimed) == int(arg.BatchSize) {
+			break
+		}
+		job.Status = StatusRunning
+		job.Attempts++
+		job.LockedUntil = arg.LockedUntil
+		claimed = append(claimed, *job)
+	}
+
+	return claimed, nil
+}
+
+func (q *fakeQuerier) CompleteJob(ctx context.Context, id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.jobs[id]
+	job.Status = StatusSucceeded
+	job.LockedUntil = sql.NullTime{}
+	job.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	return nil
+}
+
+func (q *fakeQuerier) RetryJob(ctx context.Context, arg db.RetryJobParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.jobs[arg.ID]
+	job.Status = StatusQueued
+	job.LockedUntil = sql.NullTime{}
+	job.RunAt = arg.RunAt
+	job.LastError = arg.LastError
+
+	return nil
+}
+
+func (q *fakeQuerier) DeadLetterJob(ctx context.Context, arg db.DeadLetterJobParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.jobs[arg.ID]
+	job.Status = StatusDead
+	job.LockedUntil = sql.NullTime{}
+	job.LastError = arg.LastError
+	job.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	return nil
+}
+
+func (q *fakeQuerier) DeleteSucceededJobsBefore(ctx context.Context, finishedAt sql.NullTime) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var n int64
+	for id, job := range q.jobs {
+		if job.Status == StatusSucceeded && job.FinishedAt.Time.Before(finishedAt.Time) {
+			delete(q.jobs, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (q *fakeQuerier) job(id int64) db.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return *q.jobs[id]
+}
+
+type greetArgs struct {
+	Name string `json:"name"`
+}
+
+func (greetArgs) Kind() string { return "test.greet" }
+
+type fragileArgs struct{}
+
+func (fragileArgs) Kind() string     { return "test.fragile" }
+func (fragileArgs) MaxAttempts() int { return 2 }
+
+func TestEnqueue(t *testing.T) {
+	q := newFakeQuerier()
+
+	job, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+	require.NoError(t, err)
+	require.Equal(t, "test.greet", job.Kind)
+	require.JSONEq(t, `{"name": "Ada"}`, string(job.Args))
+	require.Equal(t, StatusQueued, job.Status)
+	require.EqualValues(t, DefaultMaxAttempts, job.MaxAttempts)
+	require.WithinDuration(t, time.Now(), job.RunAt, time.Second)
+
+	runAt := time.Now().Add(time.Hour)
+	job, err = EnqueueAt(context.Background(), q, fragileArgs{}, runAt)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, job.MaxAttempts)
+	require.Equal(t, runAt, job.RunAt)
+}
+
+func TestWorkerRunsJobs(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, time.Hour)
+
+	var greeted []string
+	Handle(w, func(ctx context.Context, args greetArgs) error {
+		greeted = append(greeted, args.Name)
+		return nil
+	})
+
+	ada, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+	require.NoError(t, err)
+	later, err := EnqueueAt(context.Background(), q, greetArgs{Name: "Grace"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// Only jobs that are due run.
+	n, err := w.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"Ada"}, greeted)
+
+	job := q.job(ada.ID)
+	require.Equal(t, StatusSucceeded, job.Status)
+	require.EqualValues(t, 1, job.Attempts)
+	require.True(t, job.FinishedAt.Valid)
+
+	require.Equal(t, StatusQueued, q.job(later.ID).Status)
+}
+
+func TestWorkerRetries(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, time.Hour)
+
+	errFragile := errors.New("upstream unavailable")
+	Handle(w, func(ctx context.Context, args fragileArgs) error {
+		return errFragile
+	})
+
+	enqueued, err := Enqueue(context.Background(), q, fragileArgs{})
+	require.NoError(t, err)
+
+	// A failed job is retried after its backoff.
+	start := time.Now()
+	n, err := w.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	job := q.job(enqueued.ID)
+	require.Equal(t, StatusQueued, job.Status)
+	require.EqualValues(t, 1, job.Attempts)
+	require.Equal(t, errFragile.Error(), job.LastError.String)
+	require.WithinDuration(t, start.Add(Backoff(1)), job.RunAt, time.Second)
+
+	n, err = w.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// Once out of attempts, it is dead-lettered.
+	q.jobs[enqueued.ID].RunAt = time.Now()
+	_, err = w.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	job = q.job(enqueued.ID)
+	require.Equal(t, StatusDead, job.Status)
+	require.EqualValues(t, 2, job.Attempts)
+	require.True(t, job.FinishedAt.Valid)
+}
+
+func TestWorkerDeadLettersUnknownKinds(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, time.Hour)
+
+	enqueued, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+	require.NoError(t, err)
+
+	_, err = w.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	job := q.job(enqueued.ID)
+	require.Equal(t, StatusDead, job.Status)
+	require.EqualValues(t, 1, job.Attempts)
+	require.Contains(t, job.LastError.String, "no handler")
+}
+
+func TestWorkerRecoversPanics(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, time.Hour)
+
+	Handle(w, func(ctx context.Context, args greetArgs) error {
+		panic("boom")
+	})
+
+	enqueued, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+	require.NoError(t, err)
+
+	_, err = w.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	job := q.job(enqueued.ID)
+	require.Equal(t, StatusQueued, job.Status)
+	require.Equal(t, "panic: boom", job.LastError.String)
+}
+
+func TestWorkerTruncatesErrors(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, time.Hour)
+
+	// The cut falls in the middle of a two-byte character.
+	msg := "x" + strings.Repeat("é", maxErrorLength)
+	Handle(w, func(ctx context.Context, args greetArgs) error {
+		return errors.New(msg)
+	})
+
+	enqueued, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+	require.NoError(t, err)
+
+	_, err = w.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	job := q.job(enqueued.ID)
+	require.True(t, utf8.ValidString(job.LastError.String))
+	require.Equal(t, msg[:maxErrorLength-1], job.LastError.String)
+}
+
+func TestWorkerReclaimsExpiredLeases(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, time.Hour)
+
+	var runs int
+	Handle(w, func(ctx context.Context, args greetArgs) error {
+		runs++
+		return nil
+	})
+
+	enqueued, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+	require.NoError(t, err)
+
+	// A worker claimed the job and died before finishing it.
+	_, err = q.ClaimJobs(context.Background(), db.ClaimJobsParams{
+		LockedUntil: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+		BatchSize:   1,
+	})
+	require.NoError(t, err)
+
+	_, err = w.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, runs)
+
+	job := q.job(enqueued.ID)
+	require.Equal(t, StatusSucceeded, job.Status)
+	require.EqualValues(t, 2, job.Attempts)
+}
+
+func TestWorkerStartStop(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 4, 10*time.Millisecond, time.Hour)
+
+	var mu sync.Mutex
+	greeted := make(map[string]bool)
+	Handle(w, func(ctx context.Context, args greetArgs) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		greeted[args.Name] = true
+		return nil
+	})
+
+	w.Start()
+	w.Start()
+
+	names := []string{"Ada", "Grace", "Barbara", "Frances", "Radia", "Margaret"}
+	for _, name := range names {
+		_, err := Enqueue(context.Background(), q, greetArgs{Name: name})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(greeted) == len(names)
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, w.Stop(ctx))
+	require.NoError(t, w.Stop(ctx))
+
+	for id := range q.jobs {
+		require.Equal(t, StatusSucceeded, q.job(id).Status)
+	}
+}
+
+func TestWorkerPrune(t *testing.T) {
+	q := newFakeQuerier()
+	w := NewWorker(q, 1, time.Minute, 7*24*time.Hour)
+	Handle(w, func(ctx context.Context, args greetArgs) error { return nil })
+	Handle(w, func(ctx context.Context, args fragileArgs) error { return errors.New("still broken") })
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		job, err := Enqueue(context.Background(), q, greetArgs{Name: "Ada"})
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+	dead, err := Enqueue(context.Background(), q, fragileArgs{})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		q.jobs[dead.ID].RunAt = time.Now()
+		_, err = w.RunOnce(context.Background())
+		require.NoError(t, err)
+	}
+	require.Equal(t, StatusDead, q.job(dead.ID).Status)
+
+	// Two of the succeeded jobs and the dead one finished before the
+	// retention.
+	for _, id := range []int64{ids[0], ids[1], dead.ID} {
+		q.jobs[id].FinishedAt.Time = time.Now().AddDate(0, 0, -8)
+	}
+
+	n, err := w.Prune(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.Len(t, q.jobs, 2)
+	require.Equal(t, StatusSucceeded, q.job(ids[2]).Status)
+	require.Equal(t, StatusDead, q.job(dead.ID).Status)
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, 15*time.Second, Backoff(1))
+	require.Equal(t, 30*time.Second, Backoff(2))
+	require.Equal(t, maxBackoff, Backoff(100))
+}
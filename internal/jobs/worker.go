@@ -0,0 +1,290 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+const (
+	// baseBackoff is the delay before the first retry of a failed job. Each
+	// later retry waits twice as long as the one before, up to maxBackoff.
+	baseBackoff = 15 * time.Second
+	maxBackoff  = time.Hour
+
+	// jobTimeout bounds a single run of a job.
+	jobTimeout = 5 * time.Minute
+
+	// leaseDuration is how long a claimed job is hidden from other workers.
+	// A job still running when its lease ends is presumed lost with its
+	// worker and is claimed again.
+	leaseDuration = jobTimeout + time.Minute
+
+	// maxErrorLength caps the error message kept with a job.
+	maxErrorLength = 1024
+
+	// pruneInterval is how often succeeded jobs older than the retention
+	// are deleted.
+	pruneInterval = time.Hour
+)
+
+// Backoff returns how long to wait before retrying a job that has failed
+// attempts times.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+
+	return d
+}
+
+// A handler runs a job from its JSON arguments.
+type handler func(ctx context.Context, args json.RawMessage) error
+
+// errUnknownKind fails jobs that no handler is registered for. They are
+// dead-lettered without retries.
+var errUnknownKind = errors.New("no handler for this kind of job")
+
+// A Worker runs queued jobs on a pool of goroutines, and deletes the jobs
+// that succeeded longer ago than its retention. Dead jobs are kept until
+// they are retried.
+type Worker struct {
+	store       db.Querier
+	concurrency int
+	interval    time.Duration
+	retention   time.Duration
+	handlers    map[string]handler
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWorker creates a Worker that runs up to concurrency jobs at a time
+// and, when the queue is empty, polls store every interval. Succeeded jobs
+// are kept in store for retention.
+func NewWorker(store db.Querier, concurrency int, interval, retention time.Duration) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Worker{
+		store:       store,
+		concurrency: concurrency,
+		interval:    interval,
+		retention:   retention,
+		handlers:    make(map[string]handler),
+	}
+}
+
+// Handle registers fn to run the jobs of kind T. It must be called before
+// the worker is started.
+func Handle[T Args](w *Worker, fn func(ctx context.Context, args T) error) {
+	var zero T
+	w.handlers[zero.Kind()] = func(ctx context.Context, raw json.RawMessage) error {
+		var args T
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return fmt.Errorf("decode args: %w", err)
+		}
+
+		return fn(ctx, args)
+	}
+}
+
+// Start runs the worker pool until Stop is called. Calling Start on a
+// running worker does nothing.
+func (w *Worker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.prune(ctx)
+	}()
+
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(w.done)
+}
+
+// Stop stops the worker and waits for the jobs in progress to finish, or
+// for ctx to be done.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.cancel, w.done = nil, nil
+	w.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := w.work(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("jobs: %v", err)
+		}
+
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+func (w *Worker) prune(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := w.Prune(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("jobs: %v", err)
+			}
+		}
+	}
+}
+
+// Prune deletes the jobs that succeeded longer ago than the retention of
+// the worker, and returns how many were deleted.
+func (w *Worker) Prune(ctx context.Context) (int64, error) {
+	return w.store.DeleteSucceededJobsBefore(ctx, sql.NullTime{Time: time.Now().Add(-w.retention), Valid: true})
+}
+
+// RunOnce runs queued jobs that are due until none are left, and returns
+// how many were run.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	var total int
+
+	for ctx.Err() == nil {
+		ran, err := w.work(ctx)
+		if err != nil {
+			return total, err
+		}
+
+		if !ran {
+			break
+		}
+		total++
+	}
+
+	return total, nil
+}
+
+// work claims a single job and runs it. It reports whether there was a job
+// to run.
+func (w *Worker) work(ctx context.Context) (bool, error) {
+	claimed, err := w.store.ClaimJobs(ctx, db.ClaimJobsParams{
+		LockedUntil: sql.NullTime{Time: time.Now().Add(leaseDuration), Valid: true},
+		BatchSize:   1,
+	})
+	if err != nil || len(claimed) == 0 {
+		return false, err
+	}
+
+	return true, w.finish(claimed[0], w.execute(ctx, claimed[0]))
+}
+
+// execute runs job with its handler, turning a panic into an error.
+func (w *Worker) execute(ctx context.Context, job db.Job) (err error) {
+	h, ok := w.handlers[job.Kind]
+	if !ok {
+		return errUnknownKind
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	return h(ctx, job.Args)
+}
+
+// finish records the outcome of running job. It uses a context of its own,
+// so that a job interrupted by Stop is rescheduled rather than left to its
+// lease.
+func (w *Worker) finish(job db.Job, runErr error) error {
+	ctx := context.Background()
+
+	if runErr == nil {
+		return w.store.CompleteJob(ctx, job.ID)
+	}
+
+	msg := runErr.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	// Postgres rejects text that is not valid UTF-8, which the cut can
+	// leave by splitting a character, as can the error itself.
+	msg = strings.ToValidUTF8(msg, "")
+	lastError := sql.NullString{String: msg, Valid: true}
+
+	if runErr == errUnknownKind || job.Attempts >= job.MaxAttempts {
+		log.Printf("jobs: %s job %d is dead after %d attempts: %s", job.Kind, job.ID, job.Attempts, msg)
+		return w.store.DeadLetterJob(ctx, db.DeadLetterJobParams{
+			ID:        job.ID,
+			LastError: lastError,
+		})
+	}
+
+	return w.store.RetryJob(ctx, db.RetryJobParams{
+		ID:        job.ID,
+		RunAt:     time.Now().Add(Backoff(int(job.Attempts))),
+		LastError: lastError,
+	})
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer/smtptest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPMailerSend(t *testing.T) {
+	server, err := smtptest.NewServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	m := NewSMTPMailer(server.Host, server.Port, "", "", "KeyKeeper <no-reply@keykeeper.test>")
+
+	data := map[string]interface{}{
+		"FullName":  "Jane <Doe>",
+		"Token":     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"ExpiresIn": "30 minutes",
+	}
+
+	err = m.Send("jane@example.com", "password_reset.tmpl", data)
+	require.NoError(t, err)
+
+	var msg smtptest.Message
+	select {
+	case msg = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	require.Equal(t, "no-reply@keykeeper.test", msg.From)
+	require.Equal(t, []string{"jane@example.com"}, msg.To)
+	require.Equal(t, "Reset your KeyKeeper password", msg.Header("Subject"))
+	require.Equal(t, "jane@example.com", msg.Header("To"))
+
+	plain, err := msg.Body("text/plain")
+	require.NoError(t, err)
+	require.Contains(t, plain, "Hi Jane <Doe>,")
+	require.Contains(t, plain, data["Token"])
+
+	// The HTML part escapes user-controlled data.
+	html, err := msg.Body("text/html")
+	require.NoError(t, err)
+	require.Contains(t, html, "Hi Jane &lt;Doe&gt;,")
+	require.Contains(t, html, data["Token"])
+}
+
+func TestSMTPMailerUnknownTemplate(t *testing.T) {
+	m := NewSMTPMailer("127.0.0.1", 1, "", "", "no-reply@keykeeper.test")
+
+	err := m.Send("jane@example.com", "missing.tmpl", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,263 @@
+// Package smtptest provides an in-process SMTP server for testing code that
+// sends email.
+package smtptest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Message is an email accepted by a Server.
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Header returns the decoded value of a message header.
+func (m Message) Header(key string) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+
+	value := msg.Header.Get(key)
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+
+	return decoded
+}
+
+// Body returns the message part with the given media type, such as
+// "text/plain" or "text/html".
+func (m Message) Body(mediaType string) (string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return "", err
+	}
+
+	contentType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(contentType, "multipart/") {
+		if contentType != mediaType {
+			return "", fmt.Errorf("smtptest: message has no %s part", mediaType)
+		}
+		body, err := io.ReadAll(msg.Body)
+		return string(body), err
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", fmt.Errorf("smtptest: message has no %s part", mediaType)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			return "", err
+		}
+
+		if partType == mediaType {
+			body, err := io.ReadAll(part)
+			return string(body), err
+		}
+	}
+}
+
+// A Server is a minimal SMTP server listening on a loopback address. It
+// accepts every message without authentication or TLS.
+type Server struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	received chan Message
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewServer starts a Server. Callers must Close it when done.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	s := &Server{
+		Host:     host,
+		Port:     portNumber,
+		listener: listener,
+		received: make(chan Message, 100),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Close stops the server and waits for open connections to finish.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Messages returns every message accepted so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Received returns a channel on which each accepted message is delivered.
+func (s *Server) Received() <-chan Message {
+	return s.received
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+		w.Flush()
+	}
+
+	var msg Message
+	reply("220 smtptest ESMTP ready")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			reply("250-smtptest greets you")
+			reply("250 8BITMIME")
+		case "HELO":
+			reply("250 smtptest greets you")
+		case "MAIL":
+			msg = Message{From: addressArg(line)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, addressArg(line))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			msg.Data = data
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			select {
+			case s.received <- msg:
+			default:
+			}
+
+			reply("250 OK: queued")
+		case "RSET":
+			msg = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// addressArg extracts the address of a "MAIL FROM:<...>" or "RCPT TO:<...>"
+// command.
+func addressArg(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
+
+// readData reads a dot-terminated DATA block and undoes dot-stuffing.
+func readData(r *bufio.Reader) ([]byte, error) {
+	var data bytes.Buffer
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		if line == ".\r\n" || line == ".\n" {
+			return data.Bytes(), nil
+		}
+
+		line = strings.TrimPrefix(line, ".")
+		data.WriteString(line)
+	}
+}
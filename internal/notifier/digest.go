@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// DigestNotifier is a Notifier that holds back reminder alerts of users who
+// subscribed to a digest, leaving them for the digest to carry, and passes
+// every other notification on.
+type DigestNotifier struct {
+	store db.Querier
+	next  Notifier
+}
+
+// NewDigestNotifier creates a DigestNotifier that records held back alerts
+// in store and passes the others to next.
+func NewDigestNotifier(store db.Querier, next Notifier) *DigestNotifier {
+	return &DigestNotifier{store: store, next: next}
+}
+
+// Notify holds n back for the user's digest if n is a due reminder and the
+// user has a digest subscription, and otherwise passes it on.
+func (d *DigestNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Kind == KindReminderDue {
+		held, err := d.store.AddDigestItem(ctx, db.AddDigestItemParams{
+			UserID:     n.User.ID,
+			ReminderID: n.Reminder.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("digest: %w", err)
+		}
+
+		if held > 0 {
+			return nil
+		}
+	}
+
+	return d.next.Notify(ctx, n)
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+// digestQuerier holds back items for the users in subscribed.
+type digestQuerier struct {
+	db.Querier
+	subscribed map[int64]bool
+	items      []db.AddDigestItemParams
+}
+
+func (q *digestQuerier) AddDigestItem(ctx context.Context, arg db.AddDigestItemParams) (int64, error) {
+	if !q.subscribed[arg.UserID] {
+		return 0, nil
+	}
+
+	q.items = append(q.items, arg)
+	return 1, nil
+}
+
+func TestDigestNotifier(t *testing.T) {
+	var passed []Notification
+	next := notifierFunc(func(ctx context.Context, n Notification) error {
+		passed = append(passed, n)
+		return nil
+	})
+
+	q := &digestQuerier{subscribed: map[int64]bool{}}
+	notifier := NewDigestNotifier(q, next)
+	n := newTestNotification()
+
+	// Users without a digest are notified right away.
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Len(t, passed, 1)
+	require.Empty(t, q.items)
+
+	// Subscribed users get the alert in their digest instead.
+	q.subscribed[n.User.ID] = true
+	err = notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Len(t, passed, 1)
+	require.Equal(t, []db.AddDigestItemParams{{UserID: n.User.ID, ReminderID: n.Reminder.ID}}, q.items)
+
+	// Other kinds of notifications, escalated reminders among them, are
+	// never held back.
+	n.Kind = KindReminderEscalated
+	err = notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Len(t, passed, 2)
+	require.Len(t, q.items, 1)
+}
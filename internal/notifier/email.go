@@ -0,0 +1,70 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/interval"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+)
+
+// emailTemplates maps notification kinds to their mailer templates.
+var emailTemplates = map[Kind]string{
+	KindReminderDue:       "reminder_due.tmpl",
+	KindReminderEscalated: "reminder_escalated.tmpl",
+	KindReminderBreached:  "reminder_breached.tmpl",
+}
+
+// EmailNotifier is a Notifier that emails the user.
+type EmailNotifier struct {
+	mailer mailer.Mailer
+}
+
+// NewEmailNotifier creates an EmailNotifier that sends through m.
+func NewEmailNotifier(m mailer.Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: m}
+}
+
+// Notify emails n to its user. Nothing is sent to addresses that have not
+// been verified.
+func (e *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	if !n.User.IsEmailVerified {
+		return nil
+	}
+
+	templateFile, ok := emailTemplates[n.Kind]
+	if !ok {
+		return fmt.Errorf("email: no template for %q notifications", n.Kind)
+	}
+
+	data := map[string]interface{}{
+		"FullName":   n.User.FullName,
+		"WebsiteURL": n.Reminder.WebsiteUrl,
+		"Interval":   describeInterval(n.Reminder.Interval),
+		"UpdatedAt":  n.local(n.Reminder.UpdatedAt),
+	}
+	if n.Breach != nil {
+		data["BreachTitle"] = n.Breach.Title
+		data["BreachDate"] = n.Breach.BreachDate
+		data["DataClasses"] = strings.Join(n.Breach.DataClasses, ", ")
+	}
+
+	err := e.mailer.Send(n.User.Email, templateFile, data)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+
+	return nil
+}
+
+// describeInterval returns a stored interval in words, or as is if it does
+// not parse.
+func describeInterval(s string) string {
+	i, err := interval.Parse(s)
+	if err != nil {
+		return s
+	}
+
+	return i.Describe()
+}
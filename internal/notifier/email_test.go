@@ -0,0 +1,181 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer/smtptest"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestNotification() Notification {
+	return Notification{
+		Kind: KindReminderDue,
+		User: db.User{
+			ID:              1,
+			FullName:        "Jane <Doe>",
+			Email:           "jane@example.com",
+			IsActivated:     true,
+			IsEmailVerified: true,
+		},
+		Reminder: db.Reminder{
+			ID:         2,
+			UserID:     1,
+			WebsiteUrl: "https://example.com/login?a=1&b=2",
+			Interval:   "P90D",
+			UpdatedAt:  time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func newTestEmailNotifier(t *testing.T) (*EmailNotifier, *smtptest.Server) {
+	server, err := smtptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	m := mailer.NewSMTPMailer(server.Host, server.Port, "", "", "KeyKeeper <no-reply@keykeeper.test>")
+
+	return NewEmailNotifier(m), server
+}
+
+func TestEmailNotifierReminderDue(t *testing.T) {
+	notifier, server := newTestEmailNotifier(t)
+	n := newTestNotification()
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+
+	var msg smtptest.Message
+	select {
+	case msg = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	require.Equal(t, "no-reply@keykeeper.test", msg.From)
+	require.Equal(t, []string{"jane@example.com"}, msg.To)
+	require.Equal(t, "Time to change your password for https://example.com/login?a=1&b=2", msg.Header("Subject"))
+
+	plain, err := msg.Body("text/plain")
+	require.NoError(t, err)
+	require.Contains(t, plain, "Hi Jane <Doe>,")
+	require.Contains(t, plain, "last changed on 1 March 2023")
+	require.Contains(t, plain, "interval of 90 days")
+
+	// The HTML part escapes user-controlled data.
+	html, err := msg.Body("text/html")
+	require.NoError(t, err)
+	require.Contains(t, html, "Hi Jane &lt;Doe&gt;,")
+	require.Contains(t, html, "https://example.com/login?a=1&amp;b=2")
+}
+
+func TestEmailNotifierReminderEscalated(t *testing.T) {
+	notifier, server := newTestEmailNotifier(t)
+	n := newTestNotification()
+	n.Kind = KindReminderEscalated
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+
+	var msg smtptest.Message
+	select {
+	case msg = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	require.Equal(t, "Reminder: your password for https://example.com/login?a=1&b=2 is still due for a change", msg.Header("Subject"))
+
+	plain, err := msg.Body("text/plain")
+	require.NoError(t, err)
+	require.Contains(t, plain, "last changed on 1 March 2023")
+	require.Contains(t, plain, "still has not been changed")
+}
+
+// newTestBreach returns a breach of the website of newTestNotification.
+func newTestBreach() *db.Breach {
+	return &db.Breach{
+		ID:          3,
+		Name:        "Example",
+		Title:       "Example <Inc>",
+		Domain:      "example.com",
+		BreachDate:  time.Date(2023, time.April, 12, 0, 0, 0, 0, time.UTC),
+		DataClasses: []string{"Email addresses", "Passwords"},
+	}
+}
+
+func TestEmailNotifierReminderBreached(t *testing.T) {
+	notifier, server := newTestEmailNotifier(t)
+	n := newTestNotification()
+	n.Kind = KindReminderBreached
+	n.Breach = newTestBreach()
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+
+	var msg smtptest.Message
+	select {
+	case msg = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	require.Equal(t, "Urgent: change your password for https://example.com/login?a=1&b=2", msg.Header("Subject"))
+
+	plain, err := msg.Body("text/plain")
+	require.NoError(t, err)
+	require.Contains(t, plain, "Example <Inc> had a data breach on 12 April 2023")
+	require.Contains(t, plain, "last changed on 1 March 2023")
+	require.Contains(t, plain, "The breach exposed: Email addresses, Passwords.")
+
+	html, err := msg.Body("text/html")
+	require.NoError(t, err)
+	require.Contains(t, html, "Example &lt;Inc&gt; had a data breach")
+}
+
+func TestEmailNotifierUnverifiedAddress(t *testing.T) {
+	notifier, server := newTestEmailNotifier(t)
+	n := newTestNotification()
+	n.User.IsEmailVerified = false
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Empty(t, server.Messages())
+}
+
+func TestEmailNotifierUnknownKind(t *testing.T) {
+	notifier, server := newTestEmailNotifier(t)
+	n := newTestNotification()
+	n.Kind = "unknown"
+
+	err := notifier.Notify(context.Background(), n)
+	require.Error(t, err)
+	require.Empty(t, server.Messages())
+}
+
+func TestEmailNotifierTimeZone(t *testing.T) {
+	notifier, server := newTestEmailNotifier(t)
+	n := newTestNotification()
+
+	// 09:00 UTC on 1 March is still 28 February in Honolulu.
+	loc, err := time.LoadLocation("Pacific/Honolulu")
+	require.NoError(t, err)
+	n.Location = loc
+
+	err = notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+
+	var msg smtptest.Message
+	select {
+	case msg = <-server.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	plain, err := msg.Body("text/plain")
+	require.NoError(t, err)
+	require.Contains(t, plain, "last changed on 28 February 2023")
+}
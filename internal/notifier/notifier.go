@@ -0,0 +1,124 @@
+// Package notifier delivers notifications about reminders to users over
+// pluggable channels.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// A Kind identifies what a notification is about.
+type Kind string
+
+const (
+	// KindReminderDue notifies a user that the password of a reminder's
+	// website is due for rotation.
+	KindReminderDue Kind = "reminder.due"
+
+	// KindReminderEscalated notifies a user again, more urgently, about a
+	// reminder that stayed overdue for longer than their escalation policy
+	// allows.
+	KindReminderEscalated Kind = "reminder.escalated"
+
+	// KindReminderBreached urgently notifies a user that a reminder's
+	// website had a data breach since its password was last changed,
+	// whatever its interval.
+	KindReminderBreached Kind = "reminder.breached"
+)
+
+// Channels notifications can be delivered over.
+const (
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+// Channels lists every channel.
+var Channels = []string{ChannelEmail, ChannelPush}
+
+// A Notification is a message to a user about one of their reminders.
+type Notification struct {
+	Kind     Kind
+	User     db.User
+	Reminder db.Reminder
+
+	// Breach is the breach a KindReminderBreached notification is about.
+	Breach *db.Breach
+
+	// Location is the user's time zone, which dates are shown in. Nil
+	// means UTC.
+	Location *time.Location
+
+	// Channels restricts delivery to the named channels. Nil means every
+	// channel.
+	Channels []string
+}
+
+// local returns t in the time zone of the user of n.
+func (n Notification) local(t time.Time) time.Time {
+	if n.Location == nil {
+		return t.UTC()
+	}
+
+	return t.In(n.Location)
+}
+
+// allows reports whether n may be delivered over channel.
+func (n Notification) allows(channel string) bool {
+	if n.Channels == nil {
+		return true
+	}
+
+	for _, c := range n.Channels {
+		if c == channel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A Notifier delivers notifications over a channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Multi is a Notifier that delivers every notification over all of its
+// Notifiers. A failing channel does not stop delivery over the others.
+type Multi []Notifier
+
+// Notify calls Notify on every Notifier of m and returns an error describing
+// all failures, if any.
+func (m Multi) Notify(ctx context.Context, n Notification) error {
+	var errs []string
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("notify: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Channel is a Notifier that passes on only the notifications that may be
+// delivered over the channel Name.
+type Channel struct {
+	Name     string
+	Notifier Notifier
+}
+
+// Notify passes n to c.Notifier if n allows c.Name.
+func (c Channel) Notify(ctx context.Context, n Notification) error {
+	if !n.allows(c.Name) {
+		return nil
+	}
+
+	return c.Notifier.Notify(ctx, n)
+}
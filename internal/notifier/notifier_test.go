@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type notifierFunc func(ctx context.Context, n Notification) error
+
+func (f notifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+func TestMulti(t *testing.T) {
+	var calls int
+	ok := notifierFunc(func(ctx context.Context, n Notification) error {
+		calls++
+		return nil
+	})
+	failing := notifierFunc(func(ctx context.Context, n Notification) error {
+		calls++
+		return errors.New("channel down")
+	})
+
+	err := Multi{ok, ok}.Notify(context.Background(), newTestNotification())
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	// Every channel is tried even when one fails.
+	calls = 0
+	err = Multi{failing, ok}.Notify(context.Background(), newTestNotification())
+	require.EqualError(t, err, "notify: channel down")
+	require.Equal(t, 2, calls)
+}
+
+func TestChannel(t *testing.T) {
+	var calls int
+	email := Channel{Name: ChannelEmail, Notifier: notifierFunc(func(ctx context.Context, n Notification) error {
+		calls++
+		return nil
+	})}
+
+	// Notifications without channels go everywhere.
+	n := newTestNotification()
+	require.NoError(t, email.Notify(context.Background(), n))
+	require.Equal(t, 1, calls)
+
+	n.Channels = []string{ChannelPush, ChannelEmail}
+	require.NoError(t, email.Notify(context.Background(), n))
+	require.Equal(t, 2, calls)
+
+	n.Channels = []string{ChannelPush}
+	require.NoError(t, email.Notify(context.Background(), n))
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,153 @@
+package notifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+)
+
+// pushTTL is how long a push service holds a notification for an offline
+// browser. A reminder that arrives a day late is still worth showing.
+const pushTTL = 24 * time.Hour
+
+// A pushPayload is the JSON a service worker receives in its push event.
+type pushPayload struct {
+	Kind       Kind   `json:"kind"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	URL        string `json:"url"`
+	ReminderID int64  `json:"reminder_id"`
+}
+
+// WebPushNotifier is a Notifier that pushes notifications to every browser
+// the user has subscribed.
+type WebPushNotifier struct {
+	store db.Querier
+}
+
+// NewWebPushNotifier creates a WebPushNotifier that looks up subscriptions
+// and queues their PushArgs jobs in store. The jobs are run by the handler
+// HandlePush returns.
+func NewWebPushNotifier(store db.Querier) *WebPushNotifier {
+	return &WebPushNotifier{
+		store: store,
+	}
+}
+
+// Notify queues a PushArgs job for each of the subscriptions of n's user,
+// so that a subscription that fails is retried on its own instead of
+// pushing n again to those that got it.
+func (p *WebPushNotifier) Notify(ctx context.Context, n Notification) error {
+	payload, err := newPushPayload(n)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+
+	subs, err := p.store.ListPushSubscriptions(ctx, n.User.ID)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+
+	urgency := webpush.UrgencyNormal
+	if n.Kind == KindReminderEscalated || n.Kind == KindReminderBreached {
+		urgency = webpush.UrgencyHigh
+	}
+
+	for _, sub := range subs {
+		_, err := jobs.Enqueue(ctx, p.store, PushArgs{
+			SubscriptionID: sub.ID,
+			Payload:        payload,
+			Urgency:        urgency,
+			// A newer notification about the same reminder replaces one
+			// the browser has not received yet.
+			Topic: fmt.Sprintf("reminder-%d", n.Reminder.ID),
+		})
+		if err != nil {
+			return fmt.Errorf("webpush: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PushArgs are the arguments of the job that pushes a notification to one
+// subscription.
+type PushArgs struct {
+	SubscriptionID int64           `json:"subscription_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Urgency        webpush.Urgency `json:"urgency"`
+	Topic          string          `json:"topic"`
+}
+
+// Kind implements jobs.Args.
+func (PushArgs) Kind() string { return "push.send" }
+
+// HandlePush returns the handler of PushArgs jobs, which sends through
+// client. Subscriptions deleted since the job was queued are skipped, and
+// those the push service reports as gone are deleted.
+func HandlePush(q db.Querier, client *webpush.Client) func(ctx context.Context, args PushArgs) error {
+	return func(ctx context.Context, args PushArgs) error {
+		sub, err := q.GetPushSubscription(ctx, args.SubscriptionID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return fmt.Errorf("webpush: %w", err)
+		}
+
+		err = client.Send(ctx, webpush.Subscription{
+			Endpoint: sub.Endpoint,
+			P256dh:   sub.P256dh,
+			Auth:     sub.Auth,
+		}, args.Payload, webpush.Options{
+			TTL:     pushTTL,
+			Urgency: args.Urgency,
+			Topic:   args.Topic,
+		})
+
+		if errors.Is(err, webpush.ErrGone) {
+			err = q.DeletePushSubscription(ctx, sub.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("webpush: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// pushTitles maps notification kinds to the titles of their push messages.
+var pushTitles = map[Kind]string{
+	KindReminderDue:       "Time to change your password",
+	KindReminderEscalated: "Your password is still waiting to be changed",
+	KindReminderBreached:  "Change your password now",
+}
+
+func newPushPayload(n Notification) ([]byte, error) {
+	title, ok := pushTitles[n.Kind]
+	if !ok {
+		return nil, fmt.Errorf("no message for %q notifications", n.Kind)
+	}
+
+	body := fmt.Sprintf("Your password for %s was last changed on %s, and your reminder interval of %s has passed.",
+		n.Reminder.WebsiteUrl, n.local(n.Reminder.UpdatedAt).Format("2 January 2006"), describeInterval(n.Reminder.Interval))
+	if n.Kind == KindReminderBreached && n.Breach != nil {
+		body = fmt.Sprintf("%s had a data breach on %s, after your password for %s was last changed.",
+			n.Breach.Title, n.Breach.BreachDate.Format("2 January 2006"), n.Reminder.WebsiteUrl)
+	}
+
+	return json.Marshal(pushPayload{
+		Kind:       n.Kind,
+		Title:      title,
+		Body:       body,
+		URL:        n.Reminder.WebsiteUrl,
+		ReminderID: n.Reminder.ID,
+	})
+}
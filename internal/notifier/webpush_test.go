@@ -0,0 +1,237 @@
+package notifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush/webpushtest"
+	"github.com/stretchr/testify/require"
+)
+
+// pushQuerier is an in-memory store of push subscriptions and of the jobs
+// queued to push to them.
+type pushQuerier struct {
+	db.Querier
+	subs []db.PushSubscription
+	jobs []db.EnqueueJobParams
+}
+
+func (q *pushQuerier) EnqueueJob(ctx context.Context, arg db.EnqueueJobParams) (db.Job, error) {
+	q.jobs = append(q.jobs, arg)
+	return db.Job{ID: int64(len(q.jobs)), Kind: arg.Kind, Args: arg.Args}, nil
+}
+
+func (q *pushQuerier) GetPushSubscription(ctx context.Context, id int64) (db.PushSubscription, error) {
+	for _, sub := range q.subs {
+		if sub.ID == id {
+			return sub, nil
+		}
+	}
+	return db.PushSubscription{}, sql.ErrNoRows
+}
+
+func (q *pushQuerier) ListPushSubscriptions(ctx context.Context, userID int64) ([]db.PushSubscription, error) {
+	var subs []db.PushSubscription
+	for _, sub := range q.subs {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (q *pushQuerier) DeletePushSubscription(ctx context.Context, id int64) error {
+	for i, sub := range q.subs {
+		if sub.ID == id {
+			q.subs = append(q.subs[:i], q.subs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (q *pushQuerier) subscribe(t *testing.T, server *webpushtest.Server, client *webpush.Client, userID int64) webpush.Subscription {
+	sub, err := server.Subscribe(client.PublicKey())
+	require.NoError(t, err)
+
+	q.subs = append(q.subs, db.PushSubscription{
+		ID:       int64(len(q.subs) + 1),
+		UserID:   userID,
+		Endpoint: sub.Endpoint,
+		P256dh:   sub.P256dh,
+		Auth:     sub.Auth,
+	})
+
+	return sub
+}
+
+// run runs the queued push jobs with client, and returns the args of those
+// that failed, which are queued again.
+func (q *pushQuerier) run(t *testing.T, client *webpush.Client) []PushArgs {
+	handle := HandlePush(q, client)
+
+	queued := q.jobs
+	q.jobs = nil
+
+	var failed []PushArgs
+	for _, job := range queued {
+		require.Equal(t, PushArgs{}.Kind(), job.Kind)
+
+		var args PushArgs
+		require.NoError(t, json.Unmarshal(job.Args, &args))
+		if err := handle(context.Background(), args); err != nil {
+			failed = append(failed, args)
+			q.jobs = append(q.jobs, job)
+		}
+	}
+
+	return failed
+}
+
+func newTestWebPushNotifier(t *testing.T) (*WebPushNotifier, *pushQuerier, *webpush.Client, *webpushtest.Server) {
+	server := webpushtest.NewServer()
+	t.Cleanup(server.Close)
+
+	keys, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	client := webpush.NewClient(keys, "mailto:ops@keykeeper.test", nil)
+
+	q := &pushQuerier{}
+
+	return NewWebPushNotifier(q), q, client, server
+}
+
+func TestWebPushNotifierReminderDue(t *testing.T) {
+	notifier, q, client, server := newTestWebPushNotifier(t)
+	n := newTestNotification()
+
+	phone := q.subscribe(t, server, client, n.User.ID)
+	laptop := q.subscribe(t, server, client, n.User.ID)
+	q.subscribe(t, server, client, n.User.ID+1)
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Empty(t, q.run(t, client))
+
+	msgs := server.Messages()
+	require.Len(t, msgs, 2)
+	require.Equal(t, phone.Endpoint, msgs[0].Endpoint)
+	require.Equal(t, laptop.Endpoint, msgs[1].Endpoint)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(msgs[0].Payload, &payload))
+	require.Equal(t, "reminder.due", payload["kind"])
+	require.Equal(t, "Time to change your password", payload["title"])
+	require.Contains(t, payload["body"], "https://example.com/login?a=1&b=2 was last changed on 1 March 2023")
+	require.Contains(t, payload["body"], "interval of 90 days")
+	require.EqualValues(t, n.Reminder.ID, payload["reminder_id"])
+
+	require.Equal(t, "86400", msgs[0].Header.Get("TTL"))
+	require.Equal(t, "reminder-2", msgs[0].Header.Get("Topic"))
+}
+
+func TestWebPushNotifierReminderEscalated(t *testing.T) {
+	notifier, q, client, server := newTestWebPushNotifier(t)
+	n := newTestNotification()
+	n.Kind = KindReminderEscalated
+
+	q.subscribe(t, server, client, n.User.ID)
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Empty(t, q.run(t, client))
+
+	msgs := server.Messages()
+	require.Len(t, msgs, 1)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(msgs[0].Payload, &payload))
+	require.Equal(t, "reminder.escalated", payload["kind"])
+	require.Equal(t, "Your password is still waiting to be changed", payload["title"])
+	require.Equal(t, "high", msgs[0].Header.Get("Urgency"))
+}
+
+func TestWebPushNotifierReminderBreached(t *testing.T) {
+	notifier, q, client, server := newTestWebPushNotifier(t)
+	n := newTestNotification()
+	n.Kind = KindReminderBreached
+	n.Breach = newTestBreach()
+
+	q.subscribe(t, server, client, n.User.ID)
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Empty(t, q.run(t, client))
+
+	msgs := server.Messages()
+	require.Len(t, msgs, 1)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(msgs[0].Payload, &payload))
+	require.Equal(t, "reminder.breached", payload["kind"])
+	require.Equal(t, "Change your password now", payload["title"])
+	require.Equal(t, "Example <Inc> had a data breach on 12 April 2023, after your password for https://example.com/login?a=1&b=2 was last changed.", payload["body"])
+	require.Equal(t, "high", msgs[0].Header.Get("Urgency"))
+}
+
+func TestWebPushNotifierPrunesGoneSubscriptions(t *testing.T) {
+	notifier, q, client, server := newTestWebPushNotifier(t)
+	n := newTestNotification()
+
+	expired := q.subscribe(t, server, client, n.User.ID)
+	active := q.subscribe(t, server, client, n.User.ID)
+	server.Expire(expired)
+
+	err := notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Empty(t, q.run(t, client))
+
+	require.Len(t, server.Messages(), 1)
+	require.Len(t, q.subs, 1)
+	require.Equal(t, active.Endpoint, q.subs[0].Endpoint)
+}
+
+func TestWebPushNotifierFailure(t *testing.T) {
+	notifier, q, client, server := newTestWebPushNotifier(t)
+	n := newTestNotification()
+
+	// A subscription made for another server's key is rejected, but not
+	// deleted.
+	other, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	q.subscribe(t, server, webpush.NewClient(other, "mailto:ops@keykeeper.test", nil), n.User.ID)
+	q.subscribe(t, server, client, n.User.ID)
+
+	err = notifier.Notify(context.Background(), n)
+	require.NoError(t, err)
+	require.Len(t, q.jobs, 2)
+
+	failed := q.run(t, client)
+	require.Len(t, failed, 1)
+	require.Equal(t, q.subs[0].ID, failed[0].SubscriptionID)
+	require.Len(t, server.Messages(), 1)
+	require.Len(t, q.subs, 2)
+
+	err = HandlePush(q, client)(context.Background(), failed[0])
+	require.ErrorContains(t, err, "403 Forbidden")
+
+	// Retrying the failed subscription leaves the others alone.
+	require.Len(t, q.run(t, client), 1)
+	require.Len(t, server.Messages(), 1)
+}
+
+func TestWebPushNotifierUnknownKind(t *testing.T) {
+	notifier, q, client, server := newTestWebPushNotifier(t)
+	n := newTestNotification()
+	n.Kind = "unknown"
+	q.subscribe(t, server, client, n.User.ID)
+
+	err := notifier.Notify(context.Background(), n)
+	require.Error(t, err)
+	require.Empty(t, q.jobs)
+	require.Empty(t, server.Messages())
+}
@@ -0,0 +1,154 @@
+// Package preferences holds the delivery preferences of users: the time zone
+// their dates are computed in and the quiet hours during which they are not
+// notified.
+package preferences
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// A Clock is a wall clock time of day, in minutes after midnight.
+type Clock int
+
+// ParseClock parses a time of day written as "HH:MM", from "00:00" to
+// "23:59".
+func ParseClock(s string) (Clock, error) {
+	if len(s) != 5 || s[2] != ':' || !isDigits(s[:2]) || !isDigits(s[3:]) {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+
+	hour := int(s[0]-'0')*10 + int(s[1]-'0')
+	min := int(s[3]-'0')*10 + int(s[4]-'0')
+	if hour > 23 || min > 59 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+
+	return Clock(hour*60 + min), nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns c as "HH:MM".
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+// clockOf returns the wall clock time of t.
+func clockOf(t time.Time) Clock {
+	return Clock(t.Hour()*60 + t.Minute())
+}
+
+// QuietHours is the part of every day during which a user is not notified,
+// from Start up to End in the user's time zone. Quiet hours wrap past
+// midnight when Start is after End.
+type QuietHours struct {
+	Start Clock
+	End   Clock
+}
+
+// Contains reports whether c falls within q.
+func (q QuietHours) Contains(c Clock) bool {
+	if q.Start <= q.End {
+		return c >= q.Start && c < q.End
+	}
+
+	return c >= q.Start || c < q.End
+}
+
+// Preferences are a user's delivery preferences.
+type Preferences struct {
+	// Location is the user's time zone.
+	Location *time.Location
+
+	// QuietHours are nil when the user can be notified at any time.
+	QuietHours *QuietHours
+}
+
+// Default are the preferences of users who have not set any: UTC and no
+// quiet hours.
+var Default = Preferences{Location: time.UTC}
+
+// LoadLocation returns the location of an IANA time zone name. Unlike
+// time.LoadLocation, it rejects the empty name and "Local", which depend on
+// the server.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+
+	return time.LoadLocation(name)
+}
+
+// New returns the preferences stored in p.
+func New(p db.UserPreference) (Preferences, error) {
+	loc, err := LoadLocation(p.TimeZone)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	prefs := Preferences{Location: loc}
+	if p.QuietHoursStart.Valid && p.QuietHoursEnd.Valid {
+		prefs.QuietHours = &QuietHours{
+			Start: Clock(p.QuietHoursStart.Int32),
+			End:   Clock(p.QuietHoursEnd.Int32),
+		}
+	}
+
+	return prefs, nil
+}
+
+// Load returns the preferences of userID, or Default if the user has not
+// set any.
+func Load(ctx context.Context, q db.Querier, userID int64) (Preferences, error) {
+	p, err := q.GetUserPreferences(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Default, nil
+		}
+		return Preferences{}, err
+	}
+
+	return New(p)
+}
+
+// Quiet reports whether t falls within the user's quiet hours.
+func (p Preferences) Quiet(t time.Time) bool {
+	return p.QuietHours != nil && p.QuietHours.Contains(clockOf(t.In(p.Location)))
+}
+
+// Deliverable returns the earliest time, at or after t, that the user can
+// be notified at: t itself, or the end of the quiet hours t falls within.
+// The end is a wall clock time in the user's time zone, so it holds across
+// daylight saving time transitions; an end skipped by a transition is moved
+// forward by the length of the transition.
+func (p Preferences) Deliverable(t time.Time) time.Time {
+	if !p.Quiet(t) {
+		return t
+	}
+
+	local := t.In(p.Location)
+	year, month, day := local.Date()
+	end := p.QuietHours.End
+
+	// Quiet hours that wrap past midnight end tomorrow when they started
+	// today.
+	if clockOf(local) >= end {
+		day++
+	}
+
+	return time.Date(year, month, day, int(end)/60, int(end)%60, 0, 0, p.Location)
+}
@@ -0,0 +1,163 @@
+package preferences
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClock(t *testing.T) {
+	for s, want := range map[string]Clock{
+		"00:00": 0,
+		"07:30": 7*60 + 30,
+		"23:59": 23*60 + 59,
+	} {
+		c, err := ParseClock(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, c, s)
+		require.Equal(t, s, c.String())
+	}
+
+	for _, s := range []string{"", "7:30", "07:30:00", "24:00", "12:60", "+1:00", "ab:cd", "07-30"} {
+		_, err := ParseClock(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	day := QuietHours{Start: 12 * 60, End: 14 * 60}
+	require.False(t, day.Contains(11*60+59))
+	require.True(t, day.Contains(12*60))
+	require.True(t, day.Contains(13*60))
+	require.False(t, day.Contains(14*60))
+
+	// Quiet hours may wrap past midnight.
+	night := QuietHours{Start: 22 * 60, End: 7 * 60}
+	require.True(t, night.Contains(23*60))
+	require.True(t, night.Contains(0))
+	require.True(t, night.Contains(6*60+59))
+	require.False(t, night.Contains(7*60))
+	require.False(t, night.Contains(21*60+59))
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := LoadLocation("America/Sao_Paulo")
+	require.NoError(t, err)
+	require.Equal(t, "America/Sao_Paulo", loc.String())
+
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons", "../etc/passwd"} {
+		_, err := LoadLocation(name)
+		require.Error(t, err, name)
+	}
+}
+
+func TestDeliverable(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	prefs := Preferences{
+		Location:   berlin,
+		QuietHours: &QuietHours{Start: 22 * 60, End: 7 * 60},
+	}
+
+	testCases := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{
+			name: "outside quiet hours",
+			t:    time.Date(2023, time.June, 1, 12, 0, 0, 0, berlin),
+			want: time.Date(2023, time.June, 1, 12, 0, 0, 0, berlin),
+		},
+		{
+			name: "before midnight",
+			t:    time.Date(2023, time.June, 1, 23, 0, 0, 0, berlin),
+			want: time.Date(2023, time.June, 2, 7, 0, 0, 0, berlin),
+		},
+		{
+			name: "after midnight",
+			t:    time.Date(2023, time.June, 2, 3, 0, 0, 0, berlin),
+			want: time.Date(2023, time.June, 2, 7, 0, 0, 0, berlin),
+		},
+		{
+			// 23:00 UTC is 01:00 in Berlin, within quiet hours, although
+			// it is outside them in UTC.
+			name: "in the user's time zone",
+			t:    time.Date(2023, time.June, 1, 23, 0, 0, 0, time.UTC),
+			want: time.Date(2023, time.June, 2, 7, 0, 0, 0, berlin),
+		},
+		{
+			// The night of 25 March 2023 is an hour shorter.
+			name: "across the start of daylight saving time",
+			t:    time.Date(2023, time.March, 25, 23, 0, 0, 0, berlin),
+			want: time.Date(2023, time.March, 26, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			// The night of 29 October 2023 is an hour longer.
+			name: "across the end of daylight saving time",
+			t:    time.Date(2023, time.October, 28, 23, 0, 0, 0, berlin),
+			want: time.Date(2023, time.October, 29, 6, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := prefs.Deliverable(tc.t)
+			require.True(t, tc.want.Equal(got), "want %s, got %s", tc.want, got)
+			require.False(t, prefs.Quiet(got))
+		})
+	}
+
+	// Quiet hours that end in a skipped hour end when it is over.
+	prefs.QuietHours = &QuietHours{Start: 60, End: 2*60 + 30}
+	got := prefs.Deliverable(time.Date(2023, time.March, 26, 1, 30, 0, 0, berlin))
+	require.True(t, time.Date(2023, time.March, 26, 3, 30, 0, 0, berlin).Equal(got), got)
+
+	// Without quiet hours, any time is deliverable.
+	now := time.Now()
+	require.Equal(t, now, Default.Deliverable(now))
+	require.False(t, Default.Quiet(now))
+}
+
+// prefsQuerier stores the preferences of a single user.
+type prefsQuerier struct {
+	db.Querier
+	prefs db.UserPreference
+}
+
+func (q *prefsQuerier) GetUserPreferences(ctx context.Context, userID int64) (db.UserPreference, error) {
+	if userID != q.prefs.UserID {
+		return db.UserPreference{}, sql.ErrNoRows
+	}
+	return q.prefs, nil
+}
+
+func TestLoad(t *testing.T) {
+	q := &prefsQuerier{prefs: db.UserPreference{
+		UserID:          1,
+		TimeZone:        "Asia/Kolkata",
+		QuietHoursStart: sql.NullInt32{Int32: 21 * 60, Valid: true},
+		QuietHoursEnd:   sql.NullInt32{Int32: 6*60 + 30, Valid: true},
+	}}
+
+	prefs, err := Load(context.Background(), q, 1)
+	require.NoError(t, err)
+	require.Equal(t, "Asia/Kolkata", prefs.Location.String())
+	require.Equal(t, &QuietHours{Start: 21 * 60, End: 6*60 + 30}, prefs.QuietHours)
+
+	// Users who have not set any preferences get the defaults.
+	prefs, err = Load(context.Background(), q, 2)
+	require.NoError(t, err)
+	require.Equal(t, Default, prefs)
+
+	q.prefs.QuietHoursStart = sql.NullInt32{}
+	q.prefs.QuietHoursEnd = sql.NullInt32{}
+	prefs, err = Load(context.Background(), q, 1)
+	require.NoError(t, err)
+	require.Nil(t, prefs.QuietHours)
+}
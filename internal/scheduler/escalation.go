@@ -0,0 +1,324 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+)
+
+// escalates reports whether an overdue reminder that was notified
+// notifications times since overdueSince is to be escalated under policy at
+// now.
+func escalates(policy db.EscalationPolicy, notifications int32, overdueSince, now time.Time) bool {
+	if policy.AfterNotifications.Valid && notifications >= policy.AfterNotifications.Int32 {
+		return true
+	}
+
+	if policy.AfterDays.Valid && !now.Before(overdueSince.AddDate(0, 0, int(policy.AfterDays.Int32))) {
+		return true
+	}
+
+	return false
+}
+
+// recordNotification counts a notification of the overdue reminder
+// escalation is about at now, after escalating the reminder if the policy of
+// its owner says so. Escalating queues the email to the owner's contact, if
+// the contact verified their address.
+func recordNotification(ctx context.Context, q db.Querier, escalation db.ReminderEscalation, websiteURL string, now time.Time) error {
+	if !escalation.EscalatedAt.Valid {
+		policy, err := q.GetEscalationPolicy(ctx, escalation.UserID)
+		switch {
+		case err == nil:
+			if escalates(policy, escalation.Notifications, escalation.OverdueSince, now) {
+				escalation.EscalatedAt = sql.NullTime{Time: now, Valid: true}
+
+				if policy.ContactEmail.Valid && policy.ContactVerifiedAt.Valid {
+					_, err = jobs.Enqueue(ctx, q, ContactArgs{
+						ReminderID: escalation.ReminderID,
+						WebsiteURL: websiteURL,
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+	}
+
+	_, err := q.UpsertReminderEscalation(ctx, db.UpsertReminderEscalationParams{
+		ReminderID:     escalation.ReminderID,
+		UserID:         escalation.UserID,
+		Notifications:  escalation.Notifications + 1,
+		OverdueSince:   escalation.OverdueSince,
+		LastNotifiedAt: now,
+		EscalatedAt:    escalation.EscalatedAt,
+	})
+	return err
+}
+
+// TrackEscalation is a Dispatcher that counts the notification of each due
+// reminder towards its escalation. A reminder becomes overdue the first time
+// it is dispatched after being rotated; dispatching it again after a snooze
+// keeps counting.
+var TrackEscalation Dispatcher = DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+	now := time.Now()
+
+	escalation, err := q.GetReminderEscalation(ctx, reminder.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		escalation = db.ReminderEscalation{
+			ReminderID:   reminder.ID,
+			UserID:       reminder.UserID,
+			OverdueSince: now,
+		}
+	case err != nil:
+		return err
+	}
+
+	return recordNotification(ctx, q, escalation, reminder.WebsiteUrl, now)
+})
+
+// escalatedChannels returns the channels of the escalation policy of the
+// owner of reminder, and whether the reminder is escalated.
+func escalatedChannels(ctx context.Context, q db.Querier, reminder db.Reminder) ([]string, bool, error) {
+	escalation, err := q.GetReminderEscalation(ctx, reminder.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if !escalation.EscalatedAt.Valid {
+		return nil, false, nil
+	}
+
+	policy, err := q.GetEscalationPolicy(ctx, reminder.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return policy.Channels, true, nil
+}
+
+// hasChannel reports whether channels contains channel.
+func hasChannel(channels []string, channel string) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContactArgs are the arguments of the job that tells the contact named in
+// an escalation policy that a reminder was escalated.
+type ContactArgs struct {
+	ReminderID int64  `json:"reminder_id"`
+	WebsiteURL string `json:"website_url"`
+}
+
+// Kind implements jobs.Args.
+func (ContactArgs) Kind() string { return "reminder.escalation_contact" }
+
+// HandleContact returns the handler of ContactArgs jobs, which emails the
+// contact through m. Nothing is sent if the reminder was rotated or deleted,
+// its owner is inactive or the policy no longer names a contact that
+// verified their address.
+func HandleContact(q db.Querier, m mailer.Mailer) func(ctx context.Context, args ContactArgs) error {
+	return func(ctx context.Context, args ContactArgs) error {
+		reminder, err := q.GetReminder(ctx, db.GetReminderParams{
+			ID:         args.ReminderID,
+			WebsiteUrl: args.WebsiteURL,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		escalation, err := q.GetReminderEscalation(ctx, reminder.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if !escalation.EscalatedAt.Valid {
+			return nil
+		}
+
+		user, err := q.GetUser(ctx, reminder.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !user.IsActivated {
+			return nil
+		}
+
+		policy, err := q.GetEscalationPolicy(ctx, user.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if !policy.ContactEmail.Valid || !policy.ContactVerifiedAt.Valid {
+			return nil
+		}
+
+		prefs, err := preferences.Load(ctx, q, user.ID)
+		if err != nil {
+			return err
+		}
+
+		return m.Send(policy.ContactEmail.String, "escalation_contact.tmpl", map[string]interface{}{
+			"FullName":     user.FullName,
+			"WebsiteURL":   reminder.WebsiteUrl,
+			"OverdueSince": escalation.OverdueSince.In(prefs.Location),
+		})
+	}
+}
+
+// An Escalator periodically claims overdue reminders that their owner's
+// escalation policy says to notify again, and queues their notifications.
+// Claims use FOR UPDATE SKIP LOCKED, so any number of instances can run
+// against the same database.
+type Escalator struct {
+	store     db.Store
+	interval  time.Duration
+	batchSize int32
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEscalator creates an Escalator that polls store every interval and
+// claims up to batchSize reminders per transaction.
+func NewEscalator(store db.Store, interval time.Duration, batchSize int) *Escalator {
+	return &Escalator{
+		store:     store,
+		interval:  interval,
+		batchSize: int32(batchSize),
+	}
+}
+
+// Start runs the escalator in a new goroutine until Stop is called. Calling
+// Start on a running escalator does nothing.
+func (e *Escalator) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+
+	go e.run(ctx, e.done)
+}
+
+// Stop stops the escalator and waits for the batch in progress to finish,
+// or for ctx to be done.
+func (e *Escalator) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	cancel, done := e.cancel, e.done
+	e.cancel, e.done = nil, nil
+	e.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Escalator) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := e.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("escalator: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce notifies overdue reminders that are due to be notified again
+// batch by batch until none are left, and returns how many were notified.
+func (e *Escalator) RunOnce(ctx context.Context) (int, error) {
+	var total int
+
+	for ctx.Err() == nil {
+		n, err := e.store.DispatchDueEscalationsTx(ctx, db.DispatchDueEscalationsTxParams{
+			Limit:    e.batchSize,
+			Dispatch: dispatchEscalation,
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if n < int(e.batchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// dispatchEscalation counts another notification of a claimed overdue
+// reminder and queues it.
+func dispatchEscalation(ctx context.Context, q db.Querier, row db.ClaimDueEscalationsRow) error {
+	err := recordNotification(ctx, q, db.ReminderEscalation{
+		ReminderID:     row.ReminderID,
+		UserID:         row.UserID,
+		Notifications:  row.Notifications,
+		OverdueSince:   row.OverdueSince,
+		LastNotifiedAt: row.LastNotifiedAt,
+		EscalatedAt:    row.EscalatedAt,
+	}, row.WebsiteUrl, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return enqueueNotify(ctx, q, row.ReminderID, row.WebsiteUrl)
+}
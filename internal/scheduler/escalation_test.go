@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalates(t *testing.T) {
+	overdueSince := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		policy        db.EscalationPolicy
+		notifications int32
+		now           time.Time
+		want          bool
+	}{
+		{
+			name:          "too few notifications",
+			policy:        db.EscalationPolicy{AfterNotifications: sql.NullInt32{Int32: 3, Valid: true}},
+			notifications: 2,
+			now:           overdueSince.AddDate(1, 0, 0),
+			want:          false,
+		},
+		{
+			name:          "enough notifications",
+			policy:        db.EscalationPolicy{AfterNotifications: sql.NullInt32{Int32: 3, Valid: true}},
+			notifications: 3,
+			now:           overdueSince,
+			want:          true,
+		},
+		{
+			name:          "not overdue for long enough",
+			policy:        db.EscalationPolicy{AfterDays: sql.NullInt32{Int32: 7, Valid: true}},
+			notifications: 100,
+			now:           overdueSince.AddDate(0, 0, 7).Add(-time.Second),
+			want:          false,
+		},
+		{
+			name:          "overdue for long enough",
+			policy:        db.EscalationPolicy{AfterDays: sql.NullInt32{Int32: 7, Valid: true}},
+			notifications: 1,
+			now:           overdueSince.AddDate(0, 0, 7),
+			want:          true,
+		},
+		{
+			name: "whichever comes first",
+			policy: db.EscalationPolicy{
+				AfterNotifications: sql.NullInt32{Int32: 5, Valid: true},
+				AfterDays:          sql.NullInt32{Int32: 30, Valid: true},
+			},
+			notifications: 5,
+			now:           overdueSince.AddDate(0, 0, 1),
+			want:          true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, escalates(tc.policy, tc.notifications, overdueSince, tc.now))
+		})
+	}
+}
+
+func TestTrackEscalation(t *testing.T) {
+	reminder := db.Reminder{ID: 2, UserID: 1, WebsiteUrl: "example.com"}
+	q := &fakeQuerier{}
+
+	// Without a policy, notifications are only counted.
+	start := time.Now()
+	require.NoError(t, TrackEscalation.Dispatch(context.Background(), q, reminder))
+
+	escalation := q.escalations[reminder.ID]
+	require.Equal(t, int32(1), escalation.Notifications)
+	require.False(t, escalation.OverdueSince.Before(start))
+	require.False(t, escalation.EscalatedAt.Valid)
+	require.Empty(t, q.jobs)
+
+	q.policies = map[int64]db.EscalationPolicy{reminder.UserID: {
+		UserID:             reminder.UserID,
+		AfterNotifications: sql.NullInt32{Int32: 2, Valid: true},
+		ContactEmail:       sql.NullString{String: "joe@example.com", Valid: true},
+		ContactVerifiedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+	}}
+
+	require.NoError(t, TrackEscalation.Dispatch(context.Background(), q, reminder))
+	require.False(t, q.escalations[reminder.ID].EscalatedAt.Valid)
+	require.Empty(t, q.jobs)
+
+	// The notification after two unanswered ones is escalated, and the
+	// contact is told once.
+	require.NoError(t, TrackEscalation.Dispatch(context.Background(), q, reminder))
+	escalation = q.escalations[reminder.ID]
+	require.Equal(t, int32(3), escalation.Notifications)
+	require.True(t, escalation.EscalatedAt.Valid)
+	require.Len(t, q.jobs, 1)
+	require.Equal(t, "reminder.escalation_contact", q.jobs[0].Kind)
+	require.JSONEq(t, `{"reminder_id": 2, "website_url": "example.com"}`, string(q.jobs[0].Args))
+
+	require.NoError(t, TrackEscalation.Dispatch(context.Background(), q, reminder))
+	require.Equal(t, int32(4), q.escalations[reminder.ID].Notifications)
+	require.True(t, escalation.EscalatedAt.Time.Equal(q.escalations[reminder.ID].EscalatedAt.Time))
+	require.Len(t, q.jobs, 1)
+}
+
+func TestTrackEscalationUnverifiedContact(t *testing.T) {
+	reminder := db.Reminder{ID: 2, UserID: 1, WebsiteUrl: "example.com"}
+	q := &fakeQuerier{
+		policies: map[int64]db.EscalationPolicy{reminder.UserID: {
+			UserID:             reminder.UserID,
+			AfterNotifications: sql.NullInt32{Int32: 1, Valid: true},
+			ContactEmail:       sql.NullString{String: "joe@example.com", Valid: true},
+		}},
+	}
+
+	// The reminder escalates, but a contact who has not verified their
+	// address is not told.
+	require.NoError(t, TrackEscalation.Dispatch(context.Background(), q, reminder))
+	require.NoError(t, TrackEscalation.Dispatch(context.Background(), q, reminder))
+	require.True(t, q.escalations[reminder.ID].EscalatedAt.Valid)
+	require.Empty(t, q.jobs)
+}
+
+func TestDispatchEscalation(t *testing.T) {
+	overdueSince := time.Now().AddDate(0, 0, -10)
+	q := &fakeQuerier{
+		policies: map[int64]db.EscalationPolicy{1: {
+			UserID:    1,
+			AfterDays: sql.NullInt32{Int32: 7, Valid: true},
+		}},
+	}
+
+	err := dispatchEscalation(context.Background(), q, db.ClaimDueEscalationsRow{
+		ReminderID:     2,
+		UserID:         1,
+		Notifications:  1,
+		OverdueSince:   overdueSince,
+		LastNotifiedAt: overdueSince,
+		WebsiteUrl:     "example.com",
+	})
+	require.NoError(t, err)
+
+	escalation := q.escalations[2]
+	require.Equal(t, int32(2), escalation.Notifications)
+	require.True(t, escalation.EscalatedAt.Valid)
+	require.WithinDuration(t, time.Now(), escalation.LastNotifiedAt, time.Second)
+
+	// Without a contact, only the notifications are queued, one per
+	// channel.
+	require.Len(t, q.jobs, len(notifier.Channels))
+	for i, channel := range notifier.Channels {
+		require.Equal(t, "reminder.notify", q.jobs[i].Kind)
+		require.JSONEq(t, `{"reminder_id": 2, "website_url": "example.com", "channel": "`+channel+`"}`, string(q.jobs[i].Args))
+	}
+}
+
+func TestHandleNotifyEscalated(t *testing.T) {
+	user := db.User{ID: 1, Email: "jane@example.com", IsEmailVerified: true}
+	reminder := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "example.com"}
+
+	q := &fakeQuerier{
+		users:     map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{reminder.ID: reminder},
+		policies: map[int64]db.EscalationPolicy{user.ID: {
+			UserID:   user.ID,
+			Channels: []string{notifier.ChannelPush},
+		}},
+		escalations: map[int64]db.ReminderEscalation{reminder.ID: {
+			ReminderID:    reminder.ID,
+			UserID:        user.ID,
+			Notifications: 3,
+			EscalatedAt:   sql.NullTime{Time: time.Now(), Valid: true},
+		}},
+	}
+
+	var got []notifier.Notification
+	handle := HandleNotify(q, notifierFunc(func(ctx context.Context, n notifier.Notification) error {
+		got = append(got, n)
+		return nil
+	}))
+
+	args := NotifyArgs{ReminderID: reminder.ID, WebsiteURL: reminder.WebsiteUrl}
+	require.NoError(t, handle(context.Background(), args))
+	require.Len(t, got, 1)
+	require.Equal(t, notifier.KindReminderEscalated, got[0].Kind)
+	require.Equal(t, []string{notifier.ChannelPush}, got[0].Channels)
+
+	// The jobs of channels outside the policy do nothing, and the job of a
+	// channel of the policy only delivers over it.
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: reminder.ID, WebsiteURL: reminder.WebsiteUrl, Channel: notifier.ChannelEmail}))
+	require.Len(t, got, 1)
+
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: reminder.ID, WebsiteURL: reminder.WebsiteUrl, Channel: notifier.ChannelPush}))
+	require.Len(t, got, 2)
+	require.Equal(t, notifier.KindReminderEscalated, got[1].Kind)
+	require.Equal(t, []string{notifier.ChannelPush}, got[1].Channels)
+
+	// Without a policy, the reminder is notified as usual.
+	delete(q.policies, user.ID)
+	require.NoError(t, handle(context.Background(), args))
+	require.Len(t, got, 3)
+	require.Equal(t, notifier.KindReminderDue, got[2].Kind)
+	require.Nil(t, got[2].Channels)
+}
+
+type mailerFunc func(recipient, templateFile string, data interface{}) error
+
+func (f mailerFunc) Send(recipient, templateFile string, data interface{}) error {
+	return f(recipient, templateFile, data)
+}
+
+func TestHandleContact(t *testing.T) {
+	user := db.User{ID: 1, FullName: "Jane Doe", Email: "jane@example.com", IsActivated: true}
+	reminder := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "example.com"}
+	overdueSince := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	q := &fakeQuerier{
+		users:     map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{reminder.ID: reminder},
+		policies: map[int64]db.EscalationPolicy{user.ID: {
+			UserID:            user.ID,
+			ContactEmail:      sql.NullString{String: "joe@example.com", Valid: true},
+			ContactVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		}},
+		escalations: map[int64]db.ReminderEscalation{reminder.ID: {
+			ReminderID:   reminder.ID,
+			UserID:       user.ID,
+			OverdueSince: overdueSince,
+			EscalatedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+		}},
+	}
+
+	type email struct {
+		recipient    string
+		templateFile string
+		data         map[string]interface{}
+	}
+
+	var sent []email
+	handle := HandleContact(q, mailerFunc(func(recipient, templateFile string, data interface{}) error {
+		sent = append(sent, email{recipient, templateFile, data.(map[string]interface{})})
+		return nil
+	}))
+
+	args := ContactArgs{ReminderID: reminder.ID, WebsiteURL: reminder.WebsiteUrl}
+	require.NoError(t, handle(context.Background(), args))
+	require.Len(t, sent, 1)
+	require.Equal(t, "joe@example.com", sent[0].recipient)
+	require.Equal(t, "escalation_contact.tmpl", sent[0].templateFile)
+	require.Equal(t, "Jane Doe", sent[0].data["FullName"])
+	require.Equal(t, "example.com", sent[0].data["WebsiteURL"])
+	require.True(t, overdueSince.Equal(sent[0].data["OverdueSince"].(time.Time)))
+
+	// Nothing is sent once the reminder is rotated, the contact is replaced
+	// by one that is not verified yet or the contact is removed.
+	delete(q.escalations, reminder.ID)
+	require.NoError(t, handle(context.Background(), args))
+
+	q.escalations[reminder.ID] = db.ReminderEscalation{ReminderID: reminder.ID, EscalatedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	q.policies[user.ID] = db.EscalationPolicy{
+		UserID:       user.ID,
+		ContactEmail: sql.NullString{String: "ann@example.com", Valid: true},
+	}
+	require.NoError(t, handle(context.Background(), args))
+
+	q.policies[user.ID] = db.EscalationPolicy{UserID: user.ID}
+	require.NoError(t, handle(context.Background(), args))
+
+	require.Len(t, sent, 1)
+}
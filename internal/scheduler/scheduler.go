@@ -0,0 +1,273 @@
+// Package scheduler finds reminders that are due for a password rotation and
+// hands them to the notification pipeline.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/OCD-Labs/KeyKeeper/internal/preferences"
+)
+
+// A Dispatcher hands a due reminder to the notification pipeline. q runs
+// within the transaction that claimed the reminder, so work queued through
+// it is committed together with the reminder being marked as notified.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, q db.Querier, reminder db.Reminder) error
+}
+
+// The DispatcherFunc type is an adapter to allow the use of ordinary
+// functions as Dispatchers.
+type DispatcherFunc func(ctx context.Context, q db.Querier, reminder db.Reminder) error
+
+// Dispatch calls f(ctx, q, reminder).
+func (f DispatcherFunc) Dispatch(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+	return f(ctx, q, reminder)
+}
+
+// Multi is a Dispatcher that runs each of its Dispatchers in turn. The first
+// error stops the others, as it rolls the batch back anyway.
+type Multi []Dispatcher
+
+// Dispatch calls Dispatch on every Dispatcher of m until one fails.
+func (m Multi) Dispatch(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+	for _, dispatcher := range m {
+		if err := dispatcher.Dispatch(ctx, q, reminder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NotifyArgs are the arguments of the job that tells the owner of a due
+// reminder about it over one channel.
+type NotifyArgs struct {
+	ReminderID int64  `json:"reminder_id"`
+	WebsiteURL string `json:"website_url"`
+
+	// Channel is the channel the job delivers over. Jobs queued before
+	// notifications were split by channel have none and deliver over every
+	// channel.
+	Channel string `json:"channel,omitempty"`
+}
+
+// Kind implements jobs.Args.
+func (NotifyArgs) Kind() string { return "reminder.notify" }
+
+// EnqueueNotify is a Dispatcher that queues a NotifyArgs job per channel for
+// each due reminder. The jobs commit together with the reminder being marked
+// as notified, and each is retried on its own, so a failing channel only
+// delays its own notification rather than the whole batch or the other
+// channels.
+var EnqueueNotify Dispatcher = DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+	return enqueueNotify(ctx, q, reminder.ID, reminder.WebsiteUrl)
+})
+
+// enqueueNotify queues a NotifyArgs job per channel for a reminder.
+func enqueueNotify(ctx context.Context, q db.Querier, reminderID int64, websiteURL string) error {
+	for _, channel := range notifier.Channels {
+		_, err := jobs.Enqueue(ctx, q, NotifyArgs{
+			ReminderID: reminderID,
+			WebsiteURL: websiteURL,
+			Channel:    channel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HandleNotify returns the handler of NotifyArgs jobs, which tells the owner
+// of the reminder through n. Reminders deleted or rescheduled since they
+// were claimed are skipped. During the owner's quiet hours the job is queued
+// again for when they end. Escalated reminders are notified as such, over
+// the channels of the owner's escalation policy only.
+func HandleNotify(q db.Querier, n notifier.Notifier) func(ctx context.Context, args NotifyArgs) error {
+	return func(ctx context.Context, args NotifyArgs) error {
+		reminder, err := q.GetReminder(ctx, db.GetReminderParams{
+			ID:         args.ReminderID,
+			WebsiteUrl: args.WebsiteURL,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if reminder.NextDueAt.Valid {
+			return nil
+		}
+
+		user, err := q.GetUser(ctx, reminder.UserID)
+		if err != nil {
+			return err
+		}
+
+		prefs, err := preferences.Load(ctx, q, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if now := time.Now(); prefs.Quiet(now) {
+			_, err = jobs.EnqueueAt(ctx, q, args, prefs.Deliverable(now))
+			return err
+		}
+
+		notification := notifier.Notification{
+			Kind:     notifier.KindReminderDue,
+			User:     user,
+			Reminder: reminder,
+			Location: prefs.Location,
+		}
+		if args.Channel != "" {
+			notification.Channels = []string{args.Channel}
+		}
+
+		channels, escalated, err := escalatedChannels(ctx, q, reminder)
+		if err != nil {
+			return err
+		}
+
+		if escalated {
+			if args.Channel != "" && !hasChannel(channels, args.Channel) {
+				return nil
+			}
+
+			notification.Kind = notifier.KindReminderEscalated
+			if args.Channel == "" {
+				notification.Channels = channels
+			}
+		}
+
+		return n.Notify(ctx, notification)
+	}
+}
+
+// A Scheduler periodically claims due reminders and dispatches them. Claims
+// use FOR UPDATE SKIP LOCKED, so any number of instances can run against the
+// same database.
+type Scheduler struct {
+	store      db.Store
+	dispatcher Dispatcher
+	interval   time.Duration
+	batchSize  int32
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Scheduler that polls store every interval and claims up to
+// batchSize reminders per transaction.
+func New(store db.Store, dispatcher Dispatcher, interval time.Duration, batchSize int) *Scheduler {
+	return &Scheduler{
+		store:      store,
+		dispatcher: dispatcher,
+		interval:   interval,
+		batchSize:  int32(batchSize),
+	}
+}
+
+// Start runs the scheduler in a new goroutine until Stop is called. Calling
+// Start on a running scheduler does nothing.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.run(ctx, s.done)
+}
+
+// Stop stops the scheduler and waits for the batch in progress to finish, or
+// for ctx to be done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce dispatches due reminders batch by batch until none are left, and
+// returns how many were dispatched. Reminders whose dispatch fails are
+// deferred with the backoff of failed jobs, and do not stop the run; the
+// error of the first of them is returned once the rest are dispatched.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	var (
+		total   int
+		failure error
+	)
+
+	for ctx.Err() == nil {
+		result, err := s.store.DispatchDueRemindersTx(ctx, db.DispatchDueRemindersTxParams{
+			Limit:    s.batchSize,
+			Dispatch: s.dispatcher.Dispatch,
+			Backoff:  jobs.Backoff,
+		})
+		total += result.Dispatched
+		if err != nil {
+			if result.Failed == 0 {
+				return total, err
+			}
+			if failure == nil {
+				failure = err
+			}
+		}
+
+		if result.Dispatched+result.Failed < int(s.batchSize) {
+			break
+		}
+	}
+
+	return total, failure
+}
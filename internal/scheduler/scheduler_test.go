@@ -0,0 +1,390 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore hands out due reminders the way DispatchDueRemindersTx does:
+// at most Limit per call, marking each dispatched one as notified and
+// deferring the ones whose dispatch fails.
+type fakeStore struct {
+	db.Store
+
+	mu       sync.Mutex
+	due      []db.Reminder
+	deferred map[int64]time.Duration
+}
+
+func (s *fakeStore) DispatchDueRemindersTx(ctx context.Context, arg db.DispatchDueRemindersTxParams) (db.DispatchDueRemindersTxResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.due
+	if len(batch) > int(arg.Limit) {
+		batch = batch[:arg.Limit]
+	}
+	s.due = s.due[len(batch):]
+
+	var (
+		result  db.DispatchDueRemindersTxResult
+		failure error
+	)
+	for _, reminder := range batch {
+		if err := arg.Dispatch(ctx, nil, reminder); err != nil {
+			if s.deferred == nil {
+				s.deferred = make(map[int64]time.Duration)
+			}
+			s.deferred[reminder.ID] = arg.Backoff(int(reminder.DispatchFailures) + 1)
+			if failure == nil {
+				failure = err
+			}
+			result.Failed++
+			continue
+		}
+		result.Dispatched++
+	}
+
+	return result, failure
+}
+
+func newFakeStore(n int) *fakeStore {
+	store := &fakeStore{}
+	for i := 1; i <= n; i++ {
+		store.due = append(store.due, db.Reminder{ID: int64(i)})
+	}
+
+	return store
+}
+
+func TestRunOnce(t *testing.T) {
+	store := newFakeStore(7)
+
+	var dispatched []int64
+	dispatcher := DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+		dispatched = append(dispatched, reminder.ID)
+		return nil
+	})
+
+	s := New(store, dispatcher, time.Minute, 3)
+
+	// All due reminders are dispatched, in batches of at most 3.
+	n, err := s.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 7, n)
+	require.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, dispatched)
+	require.Empty(t, store.due)
+
+	n, err = s.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestRunOnceDispatchError(t *testing.T) {
+	store := newFakeStore(3)
+
+	errDispatch := errors.New("pipeline unavailable")
+	dispatcher := DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+		if reminder.ID == 2 {
+			return errDispatch
+		}
+		return nil
+	})
+
+	s := New(store, dispatcher, time.Minute, 10)
+
+	// The failed reminder is deferred, and does not hold back the rest of
+	// its batch.
+	n, err := s.RunOnce(context.Background())
+	require.ErrorIs(t, err, errDispatch)
+	require.Equal(t, 2, n)
+	require.Empty(t, store.due)
+	require.Equal(t, map[int64]time.Duration{2: jobs.Backoff(1)}, store.deferred)
+}
+
+func TestRunOnceFailedBatch(t *testing.T) {
+	store := newFakeStore(5)
+
+	errDispatch := errors.New("pipeline unavailable")
+	var dispatched []int64
+	dispatcher := DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+		if reminder.ID <= 2 {
+			return errDispatch
+		}
+		dispatched = append(dispatched, reminder.ID)
+		return nil
+	})
+
+	s := New(store, dispatcher, time.Minute, 2)
+
+	// A batch that fails entirely does not stop the run either.
+	n, err := s.RunOnce(context.Background())
+	require.ErrorIs(t, err, errDispatch)
+	require.Equal(t, 3, n)
+	require.Equal(t, []int64{3, 4, 5}, dispatched)
+	require.Len(t, store.deferred, 2)
+}
+
+func TestMulti(t *testing.T) {
+	var calls []string
+	record := func(name string, err error) Dispatcher {
+		return DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+
+	err := Multi{record("a", nil), record("b", nil)}.Dispatch(context.Background(), nil, db.Reminder{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, calls)
+
+	// A failing dispatcher stops the ones after it.
+	calls = nil
+	errDispatch := errors.New("queue unavailable")
+	err = Multi{record("a", errDispatch), record("b", nil)}.Dispatch(context.Background(), nil, db.Reminder{})
+	require.ErrorIs(t, err, errDispatch)
+	require.Equal(t, []string{"a"}, calls)
+}
+
+func TestStartStop(t *testing.T) {
+	store := newFakeStore(5)
+
+	dispatched := make(chan int64, 5)
+	dispatcher := DispatcherFunc(func(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+		dispatched <- reminder.ID
+		return nil
+	})
+
+	s := New(store, dispatcher, 10*time.Millisecond, 2)
+	s.Start()
+	s.Start()
+
+	for i := int64(1); i <= 5; i++ {
+		select {
+		case id := <-dispatched:
+			require.Equal(t, i, id)
+		case <-time.After(time.Second):
+			t.Fatal("reminder was not dispatched")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, s.Stop(ctx))
+	require.NoError(t, s.Stop(ctx))
+
+	// Reminders that become due after Stop are left alone.
+	store.mu.Lock()
+	store.due = append(store.due, db.Reminder{ID: 6})
+	store.mu.Unlock()
+
+	select {
+	case id := <-dispatched:
+		t.Fatalf("reminder %d was dispatched after Stop", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// fakeQuerier is the Querier that jobs get, or that a Dispatcher gets from a
+// claiming transaction.
+type fakeQuerier struct {
+	db.Querier
+
+	users       map[int64]db.User
+	reminders   map[int64]db.Reminder
+	prefs       map[int64]db.UserPreference
+	policies    map[int64]db.EscalationPolicy
+	escalations map[int64]db.ReminderEscalation
+	jobs        []db.EnqueueJobParams
+}
+
+func (q *fakeQuerier) GetUser(ctx context.Context, userID int64) (db.User, error) {
+	user, ok := q.users[userID]
+	if !ok {
+		return db.User{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (q *fakeQuerier) GetReminder(ctx context.Context, arg db.GetReminderParams) (db.Reminder, error) {
+	reminder, ok := q.reminders[arg.ID]
+	if !ok || reminder.WebsiteUrl != arg.WebsiteUrl {
+		return db.Reminder{}, sql.ErrNoRows
+	}
+
+	return reminder, nil
+}
+
+func (q *fakeQuerier) GetUserPreferences(ctx context.Context, userID int64) (db.UserPreference, error) {
+	prefs, ok := q.prefs[userID]
+	if !ok {
+		return db.UserPreference{}, sql.ErrNoRows
+	}
+
+	return prefs, nil
+}
+
+func (q *fakeQuerier) GetEscalationPolicy(ctx context.Context, userID int64) (db.EscalationPolicy, error) {
+	policy, ok := q.policies[userID]
+	if !ok {
+		return db.EscalationPolicy{}, sql.ErrNoRows
+	}
+
+	return policy, nil
+}
+
+func (q *fakeQuerier) GetReminderEscalation(ctx context.Context, reminderID int64) (db.ReminderEscalation, error) {
+	escalation, ok := q.escalations[reminderID]
+	if !ok {
+		return db.ReminderEscalation{}, sql.ErrNoRows
+	}
+
+	return escalation, nil
+}
+
+func (q *fakeQuerier) UpsertReminderEscalation(ctx context.Context, arg db.UpsertReminderEscalationParams) (db.ReminderEscalation, error) {
+	if q.escalations == nil {
+		q.escalations = make(map[int64]db.ReminderEscalation)
+	}
+
+	escalation := db.ReminderEscalation(arg)
+	if old, ok := q.escalations[arg.ReminderID]; ok {
+		escalation.OverdueSince = old.OverdueSince
+	}
+	q.escalations[arg.ReminderID] = escalation
+
+	return escalation, nil
+}
+
+func (q *fakeQuerier) EnqueueJob(ctx context.Context, arg db.EnqueueJobParams) (db.Job, error) {
+	q.jobs = append(q.jobs, arg)
+	return db.Job{ID: int64(len(q.jobs)), Kind: arg.Kind, Args: arg.Args}, nil
+}
+
+type notifierFunc func(ctx context.Context, n notifier.Notification) error
+
+func (f notifierFunc) Notify(ctx context.Context, n notifier.Notification) error {
+	return f(ctx, n)
+}
+
+func TestEnqueueNotify(t *testing.T) {
+	q := &fakeQuerier{}
+
+	reminder := db.Reminder{ID: 2, UserID: 1, WebsiteUrl: "example.com"}
+	require.NoError(t, EnqueueNotify.Dispatch(context.Background(), q, reminder))
+
+	// Each channel gets a job of its own, so that a failing channel is
+	// retried without notifying again over the others.
+	require.Len(t, q.jobs, 2)
+	for i, channel := range []string{notifier.ChannelEmail, notifier.ChannelPush} {
+		require.Equal(t, "reminder.notify", q.jobs[i].Kind)
+		require.JSONEq(t, `{"reminder_id": 2, "website_url": "example.com", "channel": "`+channel+`"}`, string(q.jobs[i].Args))
+	}
+}
+
+func TestHandleNotify(t *testing.T) {
+	user := db.User{ID: 1, Email: "jane@example.com", IsEmailVerified: true}
+	due := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "example.com"}
+	rotated := db.Reminder{
+		ID:         3,
+		UserID:     user.ID,
+		WebsiteUrl: "example.org",
+		NextDueAt:  sql.NullTime{Time: time.Now().AddDate(0, 0, 90), Valid: true},
+	}
+	orphan := db.Reminder{ID: 4, UserID: 42, WebsiteUrl: "example.net"}
+
+	q := &fakeQuerier{
+		users: map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{
+			due.ID:     due,
+			rotated.ID: rotated,
+			orphan.ID:  orphan,
+		},
+	}
+
+	var got []notifier.Notification
+	handle := HandleNotify(q, notifierFunc(func(ctx context.Context, n notifier.Notification) error {
+		got = append(got, n)
+		return nil
+	}))
+
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: due.ID, WebsiteURL: due.WebsiteUrl}))
+	require.Equal(t, []notifier.Notification{{Kind: notifier.KindReminderDue, User: user, Reminder: due, Location: time.UTC}}, got)
+
+	// Reminders rotated or deleted since they were claimed are skipped.
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: rotated.ID, WebsiteURL: rotated.WebsiteUrl}))
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: 99, WebsiteURL: "gone.com"}))
+	require.Len(t, got, 1)
+
+	// A reminder of an unknown user fails the job, so that it is retried.
+	err := handle(context.Background(), NotifyArgs{ReminderID: orphan.ID, WebsiteURL: orphan.WebsiteUrl})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Len(t, got, 1)
+
+	// The job of a channel only delivers over it.
+	require.NoError(t, handle(context.Background(), NotifyArgs{ReminderID: due.ID, WebsiteURL: due.WebsiteUrl, Channel: notifier.ChannelPush}))
+	require.Len(t, got, 2)
+	require.Equal(t, []string{notifier.ChannelPush}, got[1].Channels)
+}
+
+func TestHandleNotifyQuietHours(t *testing.T) {
+	user := db.User{ID: 1, Email: "jane@example.com", IsEmailVerified: true}
+	reminder := db.Reminder{ID: 2, UserID: user.ID, WebsiteUrl: "example.com"}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Quiet hours from an hour ago to an hour from now, in Tokyo.
+	now := time.Now().In(tokyo)
+	clock := now.Hour()*60 + now.Minute()
+	start := int32((clock - 60 + 24*60) % (24 * 60))
+	end := int32((clock + 60) % (24 * 60))
+
+	q := &fakeQuerier{
+		users:     map[int64]db.User{user.ID: user},
+		reminders: map[int64]db.Reminder{reminder.ID: reminder},
+		prefs: map[int64]db.UserPreference{user.ID: {
+			UserID:          user.ID,
+			TimeZone:        "Asia/Tokyo",
+			QuietHoursStart: sql.NullInt32{Int32: start, Valid: true},
+			QuietHoursEnd:   sql.NullInt32{Int32: end, Valid: true},
+		}},
+	}
+
+	var got []notifier.Notification
+	handle := HandleNotify(q, notifierFunc(func(ctx context.Context, n notifier.Notification) error {
+		got = append(got, n)
+		return nil
+	}))
+
+	// The notification waits for the quiet hours to end.
+	args := NotifyArgs{ReminderID: reminder.ID, WebsiteURL: reminder.WebsiteUrl}
+	require.NoError(t, handle(context.Background(), args))
+	require.Empty(t, got)
+	require.Len(t, q.jobs, 1)
+	require.Equal(t, "reminder.notify", q.jobs[0].Kind)
+	require.JSONEq(t, `{"reminder_id": 2, "website_url": "example.com"}`, string(q.jobs[0].Args))
+	require.WithinDuration(t, now.Add(time.Hour), q.jobs[0].RunAt, time.Minute)
+
+	// Outside quiet hours it is sent, with dates in the user's time zone.
+	prefs := q.prefs[user.ID]
+	prefs.QuietHoursStart.Int32 = end
+	prefs.QuietHoursEnd.Int32 = (end + 60) % (24 * 60)
+	q.prefs[user.ID] = prefs
+
+	require.NoError(t, handle(context.Background(), args))
+	require.Len(t, got, 1)
+	require.Equal(t, tokyo, got[0].Location)
+	require.Len(t, q.jobs, 1)
+}
@@ -1,12 +1,17 @@
 package token
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // A TokenMaker is an interface for managing tokens.
 type TokenMaker interface {
-	// CreateToken creates a new specific token for a user ID and duration
-	CreateToken(duration time.Duration, userID int64) (string, *Payload, error)
+	// CreateToken creates a new specific token of a type for a user ID,
+	// session ID and duration
+	CreateToken(typ Type, duration time.Duration, userID int64, sessionID uuid.UUID) (string, *Payload, error)
 
-	// verifyToken verifies if a token is valid or not.
-	verifyToken(token string) (*Payload, error)
+	// VerifyToken checks if a token is valid or not and returns its payload.
+	VerifyToken(token string) (*Payload, error)
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/aead/chacha20poly1305"
+	"github.com/google/uuid"
 	"github.com/o1egl/paseto"
 )
 
@@ -29,8 +30,8 @@ func NewPasetoMaker(symmetricKey string) (TokenMaker, error) {
 }
 
 // CreateToken create a PASETO based token.
-func (maker *PasetoMaker) CreateToken(duration time.Duration, userID int64) (string, *Payload, error) {
-	payload, err := NewPayload(duration, userID)
+func (maker *PasetoMaker) CreateToken(typ Type, duration time.Duration, userID int64, sessionID uuid.UUID) (string, *Payload, error) {
+	payload, err := NewPayload(typ, duration, userID, sessionID)
 	if err != nil {
 		return "", payload, err
 	}
@@ -40,8 +41,8 @@ func (maker *PasetoMaker) CreateToken(duration time.Duration, userID int64) (str
 	return token, payload, err
 }
 
-// verifyToken checks if the PASETO token is valid or not
-func (maker *PasetoMaker) verifyToken(token string) (*Payload, error) {
+// VerifyToken checks if the PASETO token is valid or not
+func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
 	payload := &Payload{}
 
 	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,21 +15,24 @@ func TestPasetoMaker(t *testing.T) {
 
 	duration := time.Minute
 	userID := util.RandomNumber(1, 10)
+	sessionID := uuid.New()
 
 	issuedAt := time.Now()
 	expiredAt := time.Now().Add(duration)
 
-	token, payload, err := maker.CreateToken(duration, userID)
+	token, payload, err := maker.CreateToken(TypeAccess, duration, userID, sessionID)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
 
-	payload, err = maker.verifyToken(token)
+	payload, err = maker.VerifyToken(token)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 
 	require.NotZero(t, payload.ID)
+	require.Equal(t, TypeAccess, payload.Type)
 	require.Equal(t, userID, payload.UserID)
+	require.Equal(t, sessionID, payload.SessionID)
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 }
@@ -37,13 +41,30 @@ func TestExpiredToken(t *testing.T) {
 	maker, err := NewPasetoMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	token, payload, err := maker.CreateToken(-time.Minute, util.RandomNumber(1, 10))
+	token, payload, err := maker.CreateToken(TypeAccess, -time.Minute, util.RandomNumber(1, 10), uuid.Nil)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
 
-	payload, err = maker.verifyToken(token)
+	payload, err = maker.VerifyToken(token)
 	require.Error(t, err)
 	require.EqualError(t, err, ErrExpiredToken.Error())
 	require.Nil(t, payload)
 }
+
+func TestInvalidToken(t *testing.T) {
+	maker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	// A token made with a different key must not verify.
+	otherMaker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, _, err := otherMaker.CreateToken(TypeAccess, time.Minute, util.RandomNumber(1, 10), uuid.Nil)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
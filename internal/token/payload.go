@@ -12,16 +12,30 @@ var (
 	ErrInvalidToken = errors.New("token is invalid")
 )
 
+// A Type is what a token is for. Each token may only be used for its type.
+type Type string
+
+const (
+	// TypeAccess is the type of tokens that authenticate requests.
+	TypeAccess Type = "access"
+
+	// TypeRefresh is the type of tokens that renew sessions.
+	TypeRefresh Type = "refresh"
+)
+
 // A Payload contains the payload data of a token.
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
+	Type      Type      `json:"type"`
 	UserID    int64     `json:"user_id"`
+	SessionID uuid.UUID `json:"session_id"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-// NewPayload creates a new payload with the user ID and duration.
-func NewPayload(duration time.Duration, userID int64) (*Payload, error) {
+// NewPayload creates a new payload of a type with the user ID, the ID of
+// the session it belongs to and duration.
+func NewPayload(typ Type, duration time.Duration, userID int64, sessionID uuid.UUID) (*Payload, error) {
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -29,7 +43,9 @@ func NewPayload(duration time.Duration, userID int64) (*Payload, error) {
 
 	payload := &Payload{
 		ID:        id,
+		Type:      typ,
 		UserID:    userID,
+		SessionID: sessionID,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
@@ -12,7 +12,30 @@ type Configs struct {
 	DBSource             string        `mapstructure:"DB_SOURCE"`
 	ServerAddress        string        `mapstructure:"SERVER_ADDRESS"`
 	SymmetricKey         string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
 	SessionTokenDuration time.Duration `mapstructure:"SESSION_TOKEN_DURATION"`
+	SMTPHost             string        `mapstructure:"SMTP_HOST"`
+	SMTPPort             int           `mapstructure:"SMTP_PORT"`
+	SMTPUsername         string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword         string        `mapstructure:"SMTP_PASSWORD"`
+	SMTPSender           string        `mapstructure:"SMTP_SENDER"`
+	SchedulerInterval    time.Duration `mapstructure:"SCHEDULER_INTERVAL"`
+	SchedulerBatchSize   int           `mapstructure:"SCHEDULER_BATCH_SIZE"`
+	WebhookInterval      time.Duration `mapstructure:"WEBHOOK_INTERVAL"`
+	WebhookBatchSize     int           `mapstructure:"WEBHOOK_BATCH_SIZE"`
+	JobConcurrency       int           `mapstructure:"JOB_CONCURRENCY"`
+	JobPollInterval      time.Duration `mapstructure:"JOB_POLL_INTERVAL"`
+	JobRetention         time.Duration `mapstructure:"JOB_RETENTION"`
+	VAPIDPublicKey       string        `mapstructure:"VAPID_PUBLIC_KEY"`
+	VAPIDPrivateKey      string        `mapstructure:"VAPID_PRIVATE_KEY"`
+	VAPIDSubject         string        `mapstructure:"VAPID_SUBJECT"`
+	EventsHeartbeat      time.Duration `mapstructure:"EVENTS_HEARTBEAT"`
+	EventsRetention      time.Duration `mapstructure:"EVENTS_RETENTION"`
+	PwnedPasswordsURL    string        `mapstructure:"PWNED_PASSWORDS_URL"`
+	PwnedCacheTTL        time.Duration `mapstructure:"PWNED_CACHE_TTL"`
+	PwnedCacheSize       int           `mapstructure:"PWNED_CACHE_SIZE"`
+	BreachFeedURL        string        `mapstructure:"BREACH_FEED_URL"`
+	BreachFeedInterval   time.Duration `mapstructure:"BREACH_FEED_INTERVAL"`
 }
 
 // ParseConfigs parses the configuration files.
@@ -21,6 +44,21 @@ func ParseConfigs(path string) (configs Configs, err error) {
 	viper.SetConfigName("secrets")
 	viper.SetConfigType("env")
 
+	viper.SetDefault("SCHEDULER_INTERVAL", time.Minute)
+	viper.SetDefault("SCHEDULER_BATCH_SIZE", 100)
+	viper.SetDefault("WEBHOOK_INTERVAL", 15*time.Second)
+	viper.SetDefault("WEBHOOK_BATCH_SIZE", 50)
+	viper.SetDefault("JOB_CONCURRENCY", 4)
+	viper.SetDefault("JOB_POLL_INTERVAL", 5*time.Second)
+	viper.SetDefault("JOB_RETENTION", 7*24*time.Hour)
+	viper.SetDefault("EVENTS_HEARTBEAT", 15*time.Second)
+	viper.SetDefault("EVENTS_RETENTION", 24*time.Hour)
+	viper.SetDefault("PWNED_PASSWORDS_URL", "https://api.pwnedpasswords.com")
+	viper.SetDefault("PWNED_CACHE_TTL", 24*time.Hour)
+	viper.SetDefault("PWNED_CACHE_SIZE", 1000)
+	viper.SetDefault("BREACH_FEED_URL", "")
+	viper.SetDefault("BREACH_FEED_INTERVAL", 24*time.Hour)
+
 	viper.AutomaticEnv()
 
 	err = viper.ReadInConfig()
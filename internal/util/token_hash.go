@@ -0,0 +1,34 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+)
+
+// GenerateToken returns a random, URL-safe token with 128 bits of entropy,
+// for single-use secrets such as password reset tokens.
+func GenerateToken() (string, error) {
+	buf := make([]byte, 16)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of an opaque token, for
+// tokens that must be looked up later but never stored in cleartext.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyTokenHash reports whether token hashes to tokenHash, in constant time.
+func VerifyTokenHash(tokenHash, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(tokenHash), []byte(HashToken(token))) == 1
+}
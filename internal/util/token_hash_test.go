@@ -0,0 +1,29 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashToken(t *testing.T) {
+	token := RandomString(32)
+
+	hash1 := HashToken(token)
+	require.Len(t, hash1, 64)
+	require.NotEqual(t, token, hash1)
+	require.Equal(t, hash1, HashToken(token))
+
+	require.True(t, VerifyTokenHash(hash1, token))
+	require.False(t, VerifyTokenHash(hash1, RandomString(32)))
+}
+
+func TestGenerateToken(t *testing.T) {
+	token1, err := GenerateToken()
+	require.NoError(t, err)
+	require.Len(t, token1, 26)
+
+	token2, err := GenerateToken()
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+}
@@ -0,0 +1,71 @@
+package validator
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EmailRX matches a syntactically valid email address.
+var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+// A Validator collects field validation errors.
+type Validator struct {
+	Errors map[string]string
+}
+
+// New creates an empty Validator.
+func New() *Validator {
+	return &Validator{Errors: make(map[string]string)}
+}
+
+// Valid reports whether no errors were collected.
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// AddError records message for key unless key already has an error.
+func (v *Validator) AddError(key, message string) {
+	if _, exists := v.Errors[key]; !exists {
+		v.Errors[key] = message
+	}
+}
+
+// Check records message for key when ok is false.
+func (v *Validator) Check(ok bool, key, message string) {
+	if !ok {
+		v.AddError(key, message)
+	}
+}
+
+// Error joins the collected errors, ordered by key.
+func (v *Validator) Error() string {
+	keys := make([]string, 0, len(v.Errors))
+	for key := range v.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		msgs = append(msgs, key+": "+v.Errors[key])
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Matches reports whether value matches the regular expression rx.
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
+}
+
+// PermittedValue reports whether value is one of permittedValues.
+func PermittedValue(value string, permittedValues ...string) bool {
+	for _, permitted := range permittedValues {
+		if value == permitted {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator(t *testing.T) {
+	v := New()
+	require.True(t, v.Valid())
+
+	v.Check(true, "website_url", "must be provided")
+	require.True(t, v.Valid())
+
+	// Only the first error for a key is kept.
+	v.Check(false, "website_url", "must be provided")
+	v.Check(false, "website_url", "must not be more than 2048 bytes long")
+	v.Check(false, "interval", "must be provided")
+	require.False(t, v.Valid())
+	require.Len(t, v.Errors, 2)
+
+	require.Equal(t, "interval: must be provided; website_url: must be provided", v.Error())
+}
+
+func TestMatchesEmail(t *testing.T) {
+	require.True(t, Matches("jane.doe@example.com", EmailRX))
+	require.False(t, Matches("jane.doe@", EmailRX))
+	require.False(t, Matches("example.com", EmailRX))
+}
+
+func TestPermittedValue(t *testing.T) {
+	require.True(t, PermittedValue("dead", "queued", "dead"))
+	require.False(t, PermittedValue("lost", "queued", "dead"))
+	require.False(t, PermittedValue("dead"))
+}
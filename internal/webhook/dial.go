@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ErrAddressNotAllowed is returned when an endpoint resolves to an address
+// that webhooks must not reach, such as a loopback or private address.
+var ErrAddressNotAllowed = errors.New("webhook: endpoint address is not allowed")
+
+// IsPublicIP reports whether ip may receive webhook requests: it must not be
+// a loopback, private, link-local, unspecified or multicast address.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast()
+}
+
+// IsPublicHost reports whether host, the host name or IP address of an
+// endpoint URL, may receive webhook requests as far as can be told without
+// resolving it. Names are checked again against the addresses they resolve
+// to when a request is sent.
+func IsPublicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPublicIP(ip)
+	}
+
+	return true
+}
+
+// dialControl refuses connections to addresses that are not public. It runs
+// after name resolution, for every address dialed, so a name cannot be made
+// to resolve to a private address between validation and delivery.
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrAddressNotAllowed
+	}
+
+	return nil
+}
+
+// newClient returns the default client of a Worker, which only connects to
+// public addresses. Proxies are not used, as the proxy's address would be
+// checked instead of the endpoint's.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: dialControl,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+	}
+}
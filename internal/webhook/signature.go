@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a webhook request.
+const (
+	HeaderEvent     = "X-KeyKeeper-Event"
+	HeaderEventID   = "X-KeyKeeper-Event-Id"
+	HeaderTimestamp = "X-KeyKeeper-Timestamp"
+	HeaderSignature = "X-KeyKeeper-Signature"
+)
+
+// signaturePrefix names the algorithm of a signature header value.
+const signaturePrefix = "sha256="
+
+// DefaultTolerance is how old a request may be before Verify rejects it as a
+// possible replay.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("webhook: missing signature or timestamp")
+	ErrInvalidSignature = errors.New("webhook: signature does not match")
+	ErrTimestampExpired = errors.New("webhook: timestamp outside the tolerance window")
+)
+
+// Sign returns the signature header value of body sent at timestamp: the
+// hex HMAC-SHA256, keyed with secret, of the Unix timestamp, a dot and body.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp.Unix(), 10), body))
+}
+
+// Verify checks the signature and timestamp headers of a webhook request
+// against its body. Requests whose timestamp is more than tolerance away
+// from now are rejected, so a captured request cannot be replayed later.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	ts, sig := header.Get(HeaderTimestamp), header.Get(HeaderSignature)
+	if ts == "" || !strings.HasPrefix(sig, signaturePrefix) {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+
+	age := time.Since(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrTimestampExpired
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, signaturePrefix))
+	if err != nil || !hmac.Equal(got, mac(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
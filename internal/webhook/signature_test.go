@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func signedHeader(secret string, timestamp time.Time, body []byte) http.Header {
+	header := http.Header{}
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	return header
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"webhook.test"}`)
+	now := time.Now()
+
+	testCases := []struct {
+		name   string
+		secret string
+		header http.Header
+		body   []byte
+		err    error
+	}{
+		{"Valid", secret, signedHeader(secret, now, body), body, nil},
+		{"WrongSecret", "whsec_other", signedHeader(secret, now, body), body, ErrInvalidSignature},
+		{"TamperedBody", secret, signedHeader(secret, now, body), []byte(`{"type":"reminder.deleted"}`), ErrInvalidSignature},
+		{"Replayed", secret, signedHeader(secret, now.Add(-DefaultTolerance-time.Minute), body), body, ErrTimestampExpired},
+		{"FromTheFuture", secret, signedHeader(secret, now.Add(DefaultTolerance+time.Minute), body), body, ErrTimestampExpired},
+		{"Missing", secret, http.Header{}, body, ErrMissingSignature},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.header, tc.body, DefaultTolerance)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+
+	// The timestamp is part of the signed content.
+	header := signedHeader(secret, now, body)
+	header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix()+1, 10))
+	require.ErrorIs(t, Verify(secret, header, body, DefaultTolerance), ErrInvalidSignature)
+}
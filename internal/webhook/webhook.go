@@ -0,0 +1,106 @@
+// Package webhook delivers events about a user's reminders to the HTTP
+// endpoints the user registers, signing every request so that receivers can
+// check where it came from.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/google/uuid"
+)
+
+// Event types sent to webhook endpoints.
+const (
+	EventReminderCreated = "reminder.created"
+	EventReminderDue     = "reminder.due"
+	EventReminderRotated = "reminder.rotated"
+	EventReminderSnoozed = "reminder.snoozed"
+	EventReminderUpdated = "reminder.updated"
+	EventReminderDeleted = "reminder.deleted"
+
+	// EventTest is only sent on request, to check that an endpoint works.
+	EventTest = "webhook.test"
+)
+
+// An Event is the JSON body of a webhook request.
+type Event struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewEvent creates an Event of the given type with a new ID.
+func NewEvent(eventType string, data interface{}) Event {
+	return Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// A Reminder is the representation of a reminder in event data.
+type Reminder struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	WebsiteURL string     `json:"website_url"`
+	Interval   string     `json:"interval"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	NextDueAt  *time.Time `json:"next_due_at"`
+}
+
+// ReminderData returns the data of an event about reminder.
+func ReminderData(reminder db.Reminder) map[string]Reminder {
+	r := Reminder{
+		ID:         reminder.ID,
+		UserID:     reminder.UserID,
+		WebsiteURL: reminder.WebsiteUrl,
+		Interval:   reminder.Interval,
+		UpdatedAt:  reminder.UpdatedAt,
+	}
+	if reminder.NextDueAt.Valid {
+		r.NextDueAt = &reminder.NextDueAt.Time
+	}
+
+	return map[string]Reminder{"reminder": r}
+}
+
+// Enqueue queues event for delivery to every active endpoint of a user.
+func Enqueue(ctx context.Context, q db.Querier, userID int64, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return q.EnqueueWebhookEvent(ctx, db.EnqueueWebhookEventParams{
+		EventID:   event.ID,
+		EventType: event.Type,
+		Payload:   payload,
+		UserID:    userID,
+	})
+}
+
+// EnqueueReminderDue queues a reminder.due event for the owner of reminder.
+// Its signature lets it serve as a scheduler dispatcher, so the event is
+// queued in the transaction that claims the reminder.
+func EnqueueReminderDue(ctx context.Context, q db.Querier, reminder db.Reminder) error {
+	return Enqueue(ctx, q, reminder.UserID, NewEvent(EventReminderDue, ReminderData(reminder)))
+}
+
+// NewSecret returns a random secret for signing the requests to an endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked as failed.
+	MaxAttempts = 8
+
+	// baseBackoff is the delay before the first retry. Each later retry
+	// waits twice as long as the one before, up to maxBackoff.
+	baseBackoff = time.Minute
+	maxBackoff  = 6 * time.Hour
+
+	// requestTimeout bounds a single delivery attempt.
+	requestTimeout = 10 * time.Second
+
+	// leaseDuration is how long a claimed delivery is hidden from other
+	// workers. It outlasts a batch, whose requests are sent concurrently.
+	leaseDuration = 5 * time.Minute
+
+	// unreachableMessage is the error kept in the delivery log when no
+	// response was received. The cause is only logged, so that the delivery
+	// log cannot be used to probe the network the worker runs in.
+	unreachableMessage = "endpoint could not be reached"
+)
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed attempts times.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+
+	return d
+}
+
+// A Worker periodically claims pending deliveries and sends them. Claims
+// use FOR UPDATE SKIP LOCKED and a lease, so any number of instances can run
+// against the same database.
+type Worker struct {
+	store     db.Querier
+	client    *http.Client
+	interval  time.Duration
+	batchSize int32
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWorker creates a Worker that polls store every interval and claims up
+// to batchSize deliveries at a time. A nil client selects a default one that
+// only connects to public addresses. Redirects are never followed.
+func NewWorker(store db.Querier, client *http.Client, interval time.Duration, batchSize int) *Worker {
+	if client == nil {
+		client = newClient()
+	}
+
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Worker{
+		store:     store,
+		client:    &c,
+		interval:  interval,
+		batchSize: int32(batchSize),
+	}
+}
+
+// Start runs the worker in a new goroutine until Stop is called. Calling
+// Start on a running worker does nothing.
+func (w *Worker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	go w.run(ctx, w.done)
+}
+
+// Stop stops the worker and waits for the batch in progress to finish, or
+// for ctx to be done.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.cancel, w.done = nil, nil
+	w.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("webhook: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends pending deliveries batch by batch until none are left, and
+// returns how many attempts were made.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	var total int
+
+	for ctx.Err() == nil {
+		deliveries, err := w.store.ClaimWebhookDeliveries(ctx, db.ClaimWebhookDeliveriesParams{
+			LeaseUntil: time.Now().Add(leaseDuration),
+			BatchSize:  w.batchSize,
+		})
+		if err != nil {
+			return total, err
+		}
+
+		errs := make([]error, len(deliveries))
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = w.deliver(ctx, deliveries[i])
+			}(i)
+		}
+		wg.Wait()
+
+		total += len(deliveries)
+		for _, err := range errs {
+			if err != nil {
+				return total, err
+			}
+		}
+
+		if len(deliveries) < int(w.batchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// deliver sends one delivery and records the outcome of the attempt.
+func (w *Worker) deliver(ctx context.Context, d db.ClaimWebhookDeliveriesRow) error {
+	responseStatus, err := w.send(ctx, d)
+
+	arg := db.RecordWebhookDeliveryAttemptParams{
+		ID:             d.ID,
+		Status:         StatusSucceeded,
+		ResponseStatus: responseStatus,
+		NextAttemptAt:  time.Now(),
+	}
+
+	if err != nil {
+		msg := unreachableMessage
+		if responseStatus.Valid {
+			msg = err.Error()
+		} else if ctx.Err() == nil {
+			log.Printf("webhook: delivery %d: %v", d.ID, err)
+		}
+		arg.LastError = sql.NullString{String: msg, Valid: true}
+
+		if d.Attempts >= MaxAttempts {
+			arg.Status = StatusFailed
+		} else {
+			arg.Status = StatusPending
+			arg.NextAttemptAt = time.Now().Add(Backoff(int(d.Attempts)))
+		}
+	}
+
+	// The attempt is recorded even if ctx was cancelled mid-request, so the
+	// delivery is retried after its backoff instead of its lease.
+	return w.store.RecordWebhookDeliveryAttempt(context.Background(), arg)
+}
+
+// send posts the payload of d to its endpoint. Any response other than 2xx
+// is an error.
+func (w *Worker) send(ctx context.Context, d db.ClaimWebhookDeliveriesRow) (sql.NullInt32, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "KeyKeeper-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderEventID, d.EventID.String())
+
+	now := time.Now()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, now, d.Payload))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	status := sql.NullInt32{Int32: int32(res.StatusCode), Valid: true}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return status, fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+
+	return status, nil
+}
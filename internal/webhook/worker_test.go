@@ -0,0 +1,264 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuerier hands out pending deliveries the way ClaimWebhookDeliveries
+// does and keeps the attempts recorded for them.
+type fakeQuerier struct {
+	db.Querier
+
+	mu         sync.Mutex
+	deliveries map[int64]*db.ClaimWebhookDeliveriesRow
+	enqueued   []db.EnqueueWebhookEventParams
+}
+
+func (q *fakeQuerier) ClaimWebhookDeliveries(ctx context.Context, arg db.ClaimWebhookDeliveriesParams) ([]db.ClaimWebhookDeliveriesRow, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := []db.ClaimWebhookDeliveriesRow{}
+	for _, d := range q.deliveries {
+		if len(items) == int(arg.BatchSize) {
+			break
+		}
+		if d.Status != StatusPending || d.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		d.Attempts++
+		d.NextAttemptAt = arg.LeaseUntil
+		items = append(items, *d)
+	}
+
+	return items, nil
+}
+
+func (q *fakeQuerier) RecordWebhookDeliveryAttempt(ctx context.Context, arg db.RecordWebhookDeliveryAttemptParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	d := q.deliveries[arg.ID]
+	d.Status = arg.Status
+	d.ResponseStatus = arg.ResponseStatus
+	d.LastError = arg.LastError
+	d.NextAttemptAt = arg.NextAttemptAt
+
+	return nil
+}
+
+func (q *fakeQuerier) EnqueueWebhookEvent(ctx context.Context, arg db.EnqueueWebhookEventParams) error {
+	q.enqueued = append(q.enqueued, arg)
+	return nil
+}
+
+func (q *fakeQuerier) add(url string, attempts int32) *db.ClaimWebhookDeliveriesRow {
+	if q.deliveries == nil {
+		q.deliveries = make(map[int64]*db.ClaimWebhookDeliveriesRow)
+	}
+
+	event := NewEvent(EventTest, map[string]int64{"webhook_id": 1})
+	payload, _ := json.Marshal(event)
+
+	d := &db.ClaimWebhookDeliveriesRow{
+		ID:            int64(len(q.deliveries) + 1),
+		EndpointID:    1,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       payload,
+		Status:        StatusPending,
+		Attempts:      attempts,
+		NextAttemptAt: time.Now().Add(-time.Second),
+		Url:           url,
+		Secret:        "whsec_test",
+	}
+	q.deliveries[d.ID] = d
+
+	return d
+}
+
+func TestWorkerDelivers(t *testing.T) {
+	var received []*http.Request
+	var bodies [][]byte
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	q := &fakeQuerier{}
+	d := q.add(server.URL, 0)
+
+	n, err := NewWorker(q, server.Client(), time.Minute, 10).RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	require.Len(t, received, 1)
+	r := received[0]
+	require.Equal(t, http.MethodPost, r.Method)
+	require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	require.Equal(t, EventTest, r.Header.Get(HeaderEvent))
+	require.Equal(t, d.EventID.String(), r.Header.Get(HeaderEventID))
+	require.JSONEq(t, string(d.Payload), string(bodies[0]))
+	require.NoError(t, Verify("whsec_test", r.Header, bodies[0], DefaultTolerance))
+
+	require.Equal(t, StatusSucceeded, d.Status)
+	require.EqualValues(t, 1, d.Attempts)
+	require.EqualValues(t, http.StatusOK, d.ResponseStatus.Int32)
+	require.False(t, d.LastError.Valid)
+}
+
+func TestWorkerRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	q := &fakeQuerier{}
+	retried := q.add(server.URL, 2)
+	exhausted := q.add(server.URL, MaxAttempts-1)
+	unreachable := q.add("http://127.0.0.1:1", 0)
+
+	start := time.Now()
+	n, err := NewWorker(q, server.Client(), time.Minute, 10).RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	// The third attempt failed, so the fourth waits for the third backoff.
+	require.Equal(t, StatusPending, retried.Status)
+	require.EqualValues(t, 3, retried.Attempts)
+	require.EqualValues(t, http.StatusInternalServerError, retried.ResponseStatus.Int32)
+	require.Contains(t, retried.LastError.String, "500")
+	require.WithinDuration(t, start.Add(Backoff(3)), retried.NextAttemptAt, 5*time.Second)
+
+	require.Equal(t, StatusFailed, exhausted.Status)
+	require.EqualValues(t, MaxAttempts, exhausted.Attempts)
+
+	require.Equal(t, StatusPending, unreachable.Status)
+	require.False(t, unreachable.ResponseStatus.Valid)
+	require.Equal(t, unreachableMessage, unreachable.LastError.String)
+
+	// Nothing is due until the backoff has passed.
+	n, err = NewWorker(q, server.Client(), time.Minute, 10).RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestWorkerDoesNotFollowRedirects(t *testing.T) {
+	var followed bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+
+	q := &fakeQuerier{}
+	d := q.add(server.URL, 0)
+
+	_, err := NewWorker(q, server.Client(), time.Minute, 10).RunOnce(context.Background())
+	require.NoError(t, err)
+	require.False(t, followed)
+	require.Equal(t, StatusPending, d.Status)
+	require.EqualValues(t, http.StatusTemporaryRedirect, d.ResponseStatus.Int32)
+}
+
+func TestWorkerRefusesPrivateAddresses(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	q := &fakeQuerier{}
+	loopback := q.add(server.URL, 0)
+	// A name that resolves to a loopback address is refused when dialed.
+	named := q.add(strings.Replace(server.URL, "127.0.0.1", "localhost", 1), 0)
+
+	// The default client only connects to public addresses.
+	_, err := NewWorker(q, nil, time.Minute, 10).RunOnce(context.Background())
+	require.NoError(t, err)
+	require.False(t, called)
+
+	for _, d := range []*db.ClaimWebhookDeliveriesRow{loopback, named} {
+		require.Equal(t, StatusPending, d.Status)
+		require.False(t, d.ResponseStatus.Valid)
+		require.Equal(t, unreachableMessage, d.LastError.String)
+	}
+}
+
+func TestIsPublicHost(t *testing.T) {
+	for host, public := range map[string]bool{
+		"hooks.example.com": true,
+		"93.184.216.34":     true,
+		"2606:4700::1111":   true,
+		"localhost":         false,
+		"api.localhost":     false,
+		"127.0.0.1":         false,
+		"10.1.2.3":          false,
+		"172.16.0.1":        false,
+		"192.168.1.1":       false,
+		"169.254.169.254":   false,
+		"0.0.0.0":           false,
+		"224.0.0.1":         false,
+		"::1":               false,
+		"fd00::1":           false,
+		"fe80::1":           false,
+		"::ffff:127.0.0.1":  false,
+	} {
+		require.Equal(t, public, IsPublicHost(host), host)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, time.Minute, Backoff(1))
+	require.Equal(t, 2*time.Minute, Backoff(2))
+	require.Equal(t, 64*time.Minute, Backoff(7))
+	require.Equal(t, maxBackoff, Backoff(100))
+}
+
+func TestEnqueueReminderDue(t *testing.T) {
+	q := &fakeQuerier{}
+	reminder := db.Reminder{ID: 7, UserID: 3, WebsiteUrl: "https://example.com", Interval: "P90D"}
+
+	err := EnqueueReminderDue(context.Background(), q, reminder)
+	require.NoError(t, err)
+	require.Len(t, q.enqueued, 1)
+
+	arg := q.enqueued[0]
+	require.EqualValues(t, 3, arg.UserID)
+	require.Equal(t, EventReminderDue, arg.EventType)
+	require.NotEqual(t, uuid.Nil, arg.EventID)
+
+	var event struct {
+		ID   uuid.UUID `json:"id"`
+		Type string    `json:"type"`
+		Data struct {
+			Reminder Reminder `json:"reminder"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(arg.Payload, &event))
+	require.Equal(t, arg.EventID, event.ID)
+	require.Equal(t, EventReminderDue, event.Type)
+	require.EqualValues(t, 7, event.Data.Reminder.ID)
+	require.Equal(t, "https://example.com", event.Data.Reminder.WebsiteURL)
+	require.Nil(t, event.Data.Reminder.NextDueAt)
+}
@@ -0,0 +1,120 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// recordSize is the aes128gcm record size. Messages are sent as a
+	// single record.
+	recordSize = 4096
+
+	// headerLength is the length of the aes128gcm header: a 16-byte salt,
+	// the 4-byte record size, the key ID length and the 65-byte key ID.
+	headerLength = 16 + 4 + 1 + 65
+
+	// MaxPayloadLength is the largest payload that fits in a push message.
+	// Push services accept bodies of up to 4096 bytes, which include the
+	// header, the padding delimiter and the 16-byte authentication tag.
+	MaxPayloadLength = recordSize - headerLength - 1 - 16
+)
+
+// ErrPayloadTooLarge is returned when a payload exceeds MaxPayloadLength.
+var ErrPayloadTooLarge = errors.New("webpush: payload too large")
+
+// encrypt encrypts plaintext for the user agent as described in RFC 8291,
+// with a fresh ephemeral key pair and salt.
+func encrypt(plaintext, uaPublic, authSecret []byte) ([]byte, error) {
+	asPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return encryptWith(plaintext, uaPublic, authSecret, asPrivate, salt)
+}
+
+// encryptWith encrypts plaintext with the given application server key pair
+// and salt. It produces a single aes128gcm record (RFC 8188) with no
+// padding.
+func encryptWith(plaintext, uaPublic, authSecret []byte, asPrivate *ecdsa.PrivateKey, salt []byte) ([]byte, error) {
+	if len(plaintext) > MaxPayloadLength {
+		return nil, ErrPayloadTooLarge
+	}
+
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, uaPublic)
+	if x == nil {
+		return nil, errors.New("webpush: invalid p256dh key")
+	}
+	if len(authSecret) != 16 {
+		return nil, errors.New("webpush: invalid auth secret")
+	}
+
+	sx, _ := curve.ScalarMult(x, y, asPrivate.D.Bytes())
+	ecdhSecret := sx.FillBytes(make([]byte, 32))
+	asPublic := elliptic.Marshal(curve, asPrivate.X, asPrivate.Y)
+
+	// The input keying material mixes the shared secret with the auth
+	// secret and both public keys.
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := expand(hkdf.New(sha256.New, ecdhSecret, authSecret, keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, err := expand(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, headerLength, headerLength+len(plaintext)+1+gcm.Overhead())
+	copy(body, salt)
+	binary.BigEndian.PutUint32(body[16:], recordSize)
+	body[20] = byte(len(asPublic))
+	copy(body[21:], asPublic)
+
+	// 0x02 marks the last (and only) record.
+	record := append(append([]byte(nil), plaintext...), 0x02)
+
+	return gcm.Seal(body, nonce, record, nil), nil
+}
+
+// expand reads n bytes of output keying material from r.
+func expand(r io.Reader, n int) ([]byte, error) {
+	key := make([]byte, n)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, fmt.Errorf("webpush: derive key: %w", err)
+	}
+
+	return key, nil
+}
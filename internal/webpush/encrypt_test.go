@@ -0,0 +1,57 @@
+package webpush
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeTestKey(t *testing.T, s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// TestEncryptRFC8291 checks encryption against the example in Appendix A of
+// RFC 8291.
+func TestEncryptRFC8291(t *testing.T) {
+	plaintext := []byte("When I grow up, I want to be a watermelon")
+	asPrivateKey := decodeTestKey(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	asPublicKey := decodeTestKey(t, "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8")
+	uaPublicKey := decodeTestKey(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	authSecret := decodeTestKey(t, "BTBZMqHH6r4Tts7J_aSIgg")
+	salt := decodeTestKey(t, "DGv6ra1nlYgDCS1FRnbzlw")
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), asPublicKey)
+	require.NotNil(t, x)
+	asPrivate := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y},
+		D:         new(big.Int).SetBytes(asPrivateKey),
+	}
+
+	body, err := encryptWith(plaintext, uaPublicKey, authSecret, asPrivate, salt)
+	require.NoError(t, err)
+	require.Equal(t,
+		"DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN",
+		base64.RawURLEncoding.EncodeToString(body),
+	)
+}
+
+func TestEncryptPayloadTooLarge(t *testing.T) {
+	uaPublicKey := decodeTestKey(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	authSecret := decodeTestKey(t, "BTBZMqHH6r4Tts7J_aSIgg")
+
+	body, err := encrypt(make([]byte, MaxPayloadLength), uaPublicKey, authSecret)
+	require.NoError(t, err)
+	require.Len(t, body, recordSize)
+
+	_, err = encrypt(make([]byte, MaxPayloadLength+1), uaPublicKey, authSecret)
+	require.ErrorIs(t, err, ErrPayloadTooLarge)
+
+	_, err = encrypt([]byte("hi"), uaPublicKey[1:], authSecret)
+	require.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package webpush
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vapidTokenDuration is how long a VAPID token is valid for. RFC 8292 caps
+// it at 24 hours.
+const vapidTokenDuration = 12 * time.Hour
+
+// VAPIDKeys is the key pair an application server identifies itself with to
+// push services (RFC 8292). Browsers are given the public key when they
+// subscribe, and only accept messages signed with the matching private key.
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+}
+
+// GenerateVAPIDKeys creates a new key pair.
+func GenerateVAPIDKeys() (*VAPIDKeys, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VAPIDKeys{private: private}, nil
+}
+
+// ParseVAPIDKeys parses a key pair from the URL-safe base64 encodings of the
+// uncompressed public point and the private scalar, as produced by
+// PublicKey and PrivateKey.
+func ParseVAPIDKeys(publicKey, privateKey string) (*VAPIDKeys, error) {
+	d, err := decodeKey(privateKey)
+	if err != nil || len(d) != 32 {
+		return nil, errors.New("webpush: invalid VAPID private key")
+	}
+
+	curve := elliptic.P256()
+	private := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	private.Curve = curve
+	private.X, private.Y = curve.ScalarBaseMult(d)
+
+	keys := &VAPIDKeys{private: private}
+	if keys.PublicKey() != strings.TrimRight(publicKey, "=") {
+		return nil, errors.New("webpush: VAPID public key does not match the private key")
+	}
+
+	return keys, nil
+}
+
+// PublicKey returns the public key in the form browsers expect as the
+// applicationServerKey of a subscription.
+func (k *VAPIDKeys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(elliptic.Marshal(k.private.Curve, k.private.X, k.private.Y))
+}
+
+// PrivateKey returns the private key, to be kept secret.
+func (k *VAPIDKeys) PrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.private.D.FillBytes(make([]byte, 32)))
+}
+
+// authorization returns the Authorization header value for a request to
+// endpoint: a JWT signed with ES256 whose audience is the origin of the push
+// service, together with the public key.
+func (k *VAPIDKeys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenDuration).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("webpush: sign VAPID token: %w", err)
+	}
+
+	// JWS encodes an ES256 signature as the two 32-byte integers r and s.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	return "vapid t=" + token + ", k=" + k.PublicKey(), nil
+}
+
+// decodeKey decodes URL-safe base64 with or without padding, which is how
+// browsers and key generators variously encode keys.
+func decodeKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
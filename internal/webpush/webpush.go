@@ -0,0 +1,190 @@
+// Package webpush sends Web Push messages (RFC 8030) to browsers. Payloads
+// are encrypted for the subscription (RFC 8291) and requests are signed
+// with the application server's VAPID keys (RFC 8292).
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds each request to a push service.
+const requestTimeout = 10 * time.Second
+
+// ErrGone is returned when the push service reports that a subscription
+// has expired or was revoked by the user. It must not be used again.
+var ErrGone = errors.New("webpush: subscription is gone")
+
+// topicRX matches a valid Topic header: at most 32 characters of the URL-safe
+// base64 alphabet.
+var topicRX = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// A Subscription is what a browser's PushManager.subscribe returns: the push
+// service endpoint and the keys to encrypt messages with.
+type Subscription struct {
+	Endpoint string
+	// P256dh is the user agent's P-256 public key, in URL-safe base64.
+	P256dh string
+	// Auth is the 16-byte authentication secret, in URL-safe base64.
+	Auth string
+}
+
+// Validate reports whether s can be sent to.
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.New("webpush: endpoint must be an absolute URL")
+	}
+
+	key, err := decodeKey(s.P256dh)
+	if err != nil {
+		return errors.New("webpush: p256dh must be URL-safe base64")
+	}
+	if x, _ := elliptic.Unmarshal(elliptic.P256(), key); x == nil {
+		return errors.New("webpush: p256dh must be an uncompressed P-256 public key")
+	}
+
+	auth, err := decodeKey(s.Auth)
+	if err != nil || len(auth) != 16 {
+		return errors.New("webpush: auth must be 16 bytes of URL-safe base64")
+	}
+
+	return nil
+}
+
+// An Urgency tells the push service how soon to deliver a message, which
+// lets devices save battery on low priority messages.
+type Urgency string
+
+// Urgency levels, from RFC 8030.
+const (
+	UrgencyVeryLow Urgency = "very-low"
+	UrgencyLow     Urgency = "low"
+	UrgencyNormal  Urgency = "normal"
+	UrgencyHigh    Urgency = "high"
+)
+
+// Options are the delivery options of a message.
+type Options struct {
+	// TTL is how long the push service keeps the message while the device
+	// is offline. Zero means it is dropped unless it can be delivered
+	// immediately.
+	TTL time.Duration
+	// Urgency defaults to UrgencyNormal.
+	Urgency Urgency
+	// Topic, if set, replaces any undelivered message with the same topic.
+	Topic string
+}
+
+// A StatusError is returned when a push service rejects a message.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("webpush: push service responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+
+	return msg
+}
+
+// A Client sends push messages on behalf of an application server.
+type Client struct {
+	keys    *VAPIDKeys
+	subject string
+	client  *http.Client
+}
+
+// NewClient creates a Client that signs its requests with keys. The subject
+// is a "mailto:" or "https:" URL push services can use to contact the
+// operator. A nil client uses one with a 10s timeout.
+func NewClient(keys *VAPIDKeys, subject string, client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	return &Client{
+		keys:    keys,
+		subject: subject,
+		client:  client,
+	}
+}
+
+// PublicKey returns the VAPID public key browsers must subscribe with.
+func (c *Client) PublicKey() string {
+	return c.keys.PublicKey()
+}
+
+// Send encrypts payload for sub and hands it to its push service. It returns
+// ErrGone if the subscription no longer exists, and a *StatusError for any
+// other rejection.
+func (c *Client) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	if opts.Topic != "" && !topicRX.MatchString(opts.Topic) {
+		return fmt.Errorf("webpush: invalid topic %q", opts.Topic)
+	}
+	if opts.Urgency == "" {
+		opts.Urgency = UrgencyNormal
+	}
+
+	uaPublic, err := decodeKey(sub.P256dh)
+	if err != nil {
+		return errors.New("webpush: invalid p256dh key")
+	}
+	authSecret, err := decodeKey(sub.Auth)
+	if err != nil {
+		return errors.New("webpush: invalid auth secret")
+	}
+
+	body, err := encrypt(payload, uaPublic, authSecret)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := c.keys.authorization(sub.Endpoint, c.subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL/time.Second)))
+	req.Header.Set("Urgency", string(opts.Urgency))
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		return nil
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return ErrGone
+	default:
+		return &StatusError{StatusCode: res.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+}
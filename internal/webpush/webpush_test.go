@@ -0,0 +1,143 @@
+package webpush_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush/webpushtest"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*webpush.Client, *webpushtest.Server) {
+	server := webpushtest.NewServer()
+	t.Cleanup(server.Close)
+
+	keys, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	return webpush.NewClient(keys, "mailto:ops@keykeeper.test", nil), server
+}
+
+func TestClientSend(t *testing.T) {
+	client, server := newTestClient(t)
+
+	sub, err := server.Subscribe(client.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, sub.Validate())
+
+	err = client.Send(context.Background(), sub, []byte(`{"title": "Hello"}`), webpush.Options{
+		TTL:   time.Hour,
+		Topic: "reminder-42",
+	})
+	require.NoError(t, err)
+
+	msgs := server.Messages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, sub.Endpoint, msgs[0].Endpoint)
+	require.JSONEq(t, `{"title": "Hello"}`, string(msgs[0].Payload))
+	require.Equal(t, "3600", msgs[0].Header.Get("TTL"))
+	require.Equal(t, "normal", msgs[0].Header.Get("Urgency"))
+	require.Equal(t, "reminder-42", msgs[0].Header.Get("Topic"))
+}
+
+func TestClientSendWrongKey(t *testing.T) {
+	client, server := newTestClient(t)
+
+	// The subscription was made for another application server.
+	other, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	sub, err := server.Subscribe(other.PublicKey())
+	require.NoError(t, err)
+
+	err = client.Send(context.Background(), sub, []byte("hi"), webpush.Options{})
+
+	var statusErr *webpush.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	require.Empty(t, server.Messages())
+}
+
+func TestClientSendGone(t *testing.T) {
+	client, server := newTestClient(t)
+
+	sub, err := server.Subscribe(client.PublicKey())
+	require.NoError(t, err)
+	server.Expire(sub)
+
+	err = client.Send(context.Background(), sub, []byte("hi"), webpush.Options{})
+	require.ErrorIs(t, err, webpush.ErrGone)
+
+	// Unknown endpoints are gone too.
+	sub.Endpoint = server.URL + "/push/unknown"
+	err = client.Send(context.Background(), sub, []byte("hi"), webpush.Options{})
+	require.ErrorIs(t, err, webpush.ErrGone)
+}
+
+func TestClientSendServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	keys, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	client := webpush.NewClient(keys, "mailto:ops@keykeeper.test", nil)
+
+	push := webpushtest.NewServer()
+	defer push.Close()
+	sub, err := push.Subscribe(client.PublicKey())
+	require.NoError(t, err)
+	sub.Endpoint = server.URL + "/push/1"
+
+	err = client.Send(context.Background(), sub, []byte("hi"), webpush.Options{})
+	require.EqualError(t, err, "webpush: push service responded with 429 Too Many Requests: slow down")
+
+	err = client.Send(context.Background(), sub, []byte("hi"), webpush.Options{Topic: "not a topic"})
+	require.Error(t, err)
+}
+
+func TestVAPIDKeys(t *testing.T) {
+	keys, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	require.Len(t, keys.PublicKey(), 87)
+	require.Len(t, keys.PrivateKey(), 43)
+
+	parsed, err := webpush.ParseVAPIDKeys(keys.PublicKey(), keys.PrivateKey())
+	require.NoError(t, err)
+	require.Equal(t, keys.PublicKey(), parsed.PublicKey())
+
+	other, err := webpush.GenerateVAPIDKeys()
+	require.NoError(t, err)
+	_, err = webpush.ParseVAPIDKeys(other.PublicKey(), keys.PrivateKey())
+	require.Error(t, err)
+
+	_, err = webpush.ParseVAPIDKeys(keys.PublicKey(), "not a key")
+	require.Error(t, err)
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	server := webpushtest.NewServer()
+	defer server.Close()
+
+	sub, err := server.Subscribe("key")
+	require.NoError(t, err)
+	require.NoError(t, sub.Validate())
+
+	invalid := sub
+	invalid.Endpoint = "/push/1"
+	require.Error(t, invalid.Validate())
+
+	invalid = sub
+	invalid.P256dh = strings.Repeat("A", 87)
+	require.Error(t, invalid.Validate())
+
+	invalid = sub
+	invalid.Auth = "c2hvcnQ"
+	require.Error(t, invalid.Validate())
+}
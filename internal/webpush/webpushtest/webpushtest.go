@@ -0,0 +1,370 @@
+// Package webpushtest provides an in-process push service for testing code
+// that sends Web Push messages. It checks VAPID authorization and decrypts
+// each message like a browser would.
+package webpushtest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+	"golang.org/x/crypto/hkdf"
+)
+
+// maxBodyLength is the largest message body push services must accept.
+const maxBodyLength = 4096
+
+// A Message is a push message accepted by a Server.
+type Message struct {
+	Endpoint string
+	Header   http.Header
+	// Payload is the decrypted payload.
+	Payload []byte
+}
+
+// subscription is the browser side of a subscription.
+type subscription struct {
+	applicationServerKey string
+	private              *ecdsa.PrivateKey
+	authSecret           []byte
+	gone                 bool
+}
+
+// A Server is a push service listening on a loopback address.
+type Server struct {
+	URL string
+
+	server   *httptest.Server
+	received chan Message
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	messages      []Message
+}
+
+// NewServer starts a Server. Callers must Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		received:      make(chan Message, 100),
+		subscriptions: make(map[string]*subscription),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Subscribe creates a subscription bound to applicationServerKey, the VAPID
+// public key of the sender, as PushManager.subscribe does.
+func (s *Server) Subscribe(applicationServerKey string) (webpush.Subscription, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return webpush.Subscription{}, err
+	}
+
+	authSecret := make([]byte, 16)
+	if _, err := rand.Read(authSecret); err != nil {
+		return webpush.Subscription{}, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return webpush.Subscription{}, err
+	}
+	endpoint := s.URL + "/push/" + hex.EncodeToString(id)
+
+	s.mu.Lock()
+	s.subscriptions[endpoint] = &subscription{
+		applicationServerKey: applicationServerKey,
+		private:              private,
+		authSecret:           authSecret,
+	}
+	s.mu.Unlock()
+
+	return webpush.Subscription{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(elliptic.Marshal(private.Curve, private.X, private.Y)),
+		Auth:     base64.RawURLEncoding.EncodeToString(authSecret),
+	}, nil
+}
+
+// Expire makes the push service answer 410 Gone for sub from now on, as if
+// the user had revoked it.
+func (s *Server) Expire(sub webpush.Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ua, ok := s.subscriptions[sub.Endpoint]; ok {
+		ua.gone = true
+	}
+}
+
+// Messages returns every message accepted so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Received returns a channel on which each accepted message is delivered.
+func (s *Server) Received() <-chan Message {
+	return s.received
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	endpoint := s.URL + r.URL.Path
+
+	s.mu.Lock()
+	ua, ok := s.subscriptions[endpoint]
+	gone := ok && ua.gone
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		http.Error(w, "no such subscription", http.StatusNotFound)
+		return
+	case gone:
+		http.Error(w, "subscription expired", http.StatusGone)
+		return
+	}
+
+	status, err := s.authorize(r, ua)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if r.Header.Get("TTL") == "" {
+		http.Error(w, "missing TTL header", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Content-Encoding") != "aes128gcm" {
+		http.Error(w, "content encoding must be aes128gcm", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyLength+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBodyLength {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	payload, err := decrypt(body, ua)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg := Message{
+		Endpoint: endpoint,
+		Header:   r.Header.Clone(),
+		Payload:  payload,
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	n := len(s.messages)
+	s.mu.Unlock()
+
+	select {
+	case s.received <- msg:
+	default:
+	}
+
+	w.Header().Set("Location", endpoint+"/messages/"+strconv.Itoa(n))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// authorize checks the VAPID Authorization header of r. It returns the
+// status to respond with when the header is missing or invalid.
+func (s *Server) authorize(r *http.Request, ua *subscription) (int, error) {
+	value := r.Header.Get("Authorization")
+	if !strings.HasPrefix(value, "vapid ") {
+		return http.StatusUnauthorized, errors.New("missing VAPID authorization")
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(value, "vapid "), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		params[k] = v
+	}
+
+	if params["k"] != ua.applicationServerKey {
+		return http.StatusForbidden, errors.New("key does not match the subscription")
+	}
+
+	claims, err := verifyJWT(params["t"], params["k"])
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+
+	var exp int64
+	if f, ok := claims["exp"].(float64); ok {
+		exp = int64(f)
+	}
+	now := time.Now()
+
+	switch {
+	case claims["aud"] != s.URL:
+		return http.StatusForbidden, fmt.Errorf("audience must be %s", s.URL)
+	case exp <= now.Unix() || exp > now.Add(24*time.Hour).Unix():
+		return http.StatusForbidden, errors.New("token must expire within 24 hours")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if !strings.HasPrefix(sub, "mailto:") && !strings.HasPrefix(sub, "https:") {
+		return http.StatusForbidden, errors.New("subject must be a mailto: or https: URL")
+	}
+
+	return http.StatusOK, nil
+}
+
+// verifyJWT checks the ES256 signature of token with the public key k and
+// returns its claims.
+func verifyJWT(token, k string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "ES256" {
+		return nil, errors.New("token must be signed with ES256")
+	}
+
+	key, err := base64.RawURLEncoding.DecodeString(k)
+	if err != nil {
+		return nil, errors.New("malformed key")
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), key)
+	if x == nil {
+		return nil, errors.New("malformed key")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, errors.New("malformed signature")
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	if !ecdsa.Verify(public, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed claims")
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// decrypt decrypts a single-record aes128gcm body as described in RFC 8291.
+func decrypt(body []byte, ua *subscription) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("truncated header")
+	}
+
+	salt := body[:16]
+	rs := binary.BigEndian.Uint32(body[16:20])
+	idLength := int(body[20])
+	if len(body) < 21+idLength {
+		return nil, errors.New("truncated header")
+	}
+	asPublic := body[21 : 21+idLength]
+	ciphertext := body[21+idLength:]
+
+	if int(rs) < len(ciphertext) {
+		return nil, errors.New("message must be a single record")
+	}
+
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, asPublic)
+	if x == nil {
+		return nil, errors.New("key ID must be an uncompressed P-256 public key")
+	}
+	sx, _ := curve.ScalarMult(x, y, ua.private.D.Bytes())
+	ecdhSecret := sx.FillBytes(make([]byte, 32))
+
+	uaPublic := elliptic.Marshal(curve, ua.private.X, ua.private.Y)
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+
+	ikm := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, ecdhSecret, ua.authSecret, keyInfo), ikm)
+
+	cek := make([]byte, 16)
+	io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek)
+	nonce := make([]byte, 12)
+	io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("decryption failed")
+	}
+
+	// Strip the padding: zeros after a 0x02 last-record delimiter.
+	end := len(record) - 1
+	for end >= 0 && record[end] == 0 {
+		end--
+	}
+	if end < 0 || record[end] != 0x02 {
+		return nil, errors.New("missing last record delimiter")
+	}
+
+	return record[:end], nil
+}
@@ -0,0 +1,81 @@
+package website
+
+import (
+	"context"
+	"sort"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+)
+
+// A Merge merges the reminders a user has for one website.
+type Merge struct {
+	// Site is the canonical form of the website.
+	Site Site
+
+	// Into is the reminder that is kept: the one whose password was changed
+	// last, or the oldest of those changed at the same time.
+	Into db.Reminder
+
+	// From are the duplicates of Into, which are merged into it.
+	From []db.Reminder
+}
+
+// Plan groups the reminders of a user by the domain of their website and
+// returns the merges that leave one canonicalized reminder per domain.
+// Reminders that are canonicalized and have no duplicate need no merge.
+// Reminders whose URL is invalid are returned apart and left alone.
+func Plan(reminders []db.Reminder) (merges []Merge, invalid []db.Reminder) {
+	sites := make(map[int64]Site)
+	groups := make(map[string][]db.Reminder)
+
+	for _, reminder := range reminders {
+		site := Site{Host: reminder.WebsiteHost.String, Domain: reminder.WebsiteDomain.String}
+		if !reminder.WebsiteDomain.Valid {
+			var err error
+			site, err = Parse(reminder.WebsiteUrl)
+			if err != nil {
+				invalid = append(invalid, reminder)
+				continue
+			}
+		}
+
+		sites[reminder.ID] = site
+		groups[site.Domain] = append(groups[site.Domain], reminder)
+	}
+
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if !group[i].UpdatedAt.Equal(group[j].UpdatedAt) {
+				return group[i].UpdatedAt.After(group[j].UpdatedAt)
+			}
+			return group[i].ID < group[j].ID
+		})
+
+		if len(group) == 1 && group[0].WebsiteDomain.Valid {
+			continue
+		}
+
+		merges = append(merges, Merge{Site: sites[group[0].ID], Into: group[0], From: group[1:]})
+	}
+
+	sort.Slice(merges, func(i, j int) bool {
+		return merges[i].Into.ID < merges[j].Into.ID
+	})
+
+	return merges, invalid
+}
+
+// Apply runs m against store.
+func (m Merge) Apply(ctx context.Context, store db.Store) (db.Reminder, error) {
+	fromIDs := make([]int64, len(m.From))
+	for i, reminder := range m.From {
+		fromIDs[i] = reminder.ID
+	}
+
+	return store.MergeRemindersTx(ctx, db.MergeRemindersTxParams{
+		IntoID:        m.Into.ID,
+		FromIDs:       fromIDs,
+		WebsiteHost:   m.Site.Host,
+		WebsiteDomain: m.Site.Domain,
+	})
+}
@@ -0,0 +1,75 @@
+package website
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func canonical(host, domain string) (sql.NullString, sql.NullString) {
+	return sql.NullString{String: host, Valid: true}, sql.NullString{String: domain, Valid: true}
+}
+
+func TestPlan(t *testing.T) {
+	now := time.Now()
+
+	typed := db.Reminder{ID: 1, WebsiteUrl: "https://www.Example.com/login", UpdatedAt: now.AddDate(0, -2, 0)}
+	bare := db.Reminder{ID: 2, WebsiteUrl: "example.com", UpdatedAt: now}
+	mobile := db.Reminder{ID: 3, WebsiteUrl: "m.example.com", UpdatedAt: now}
+	alone := db.Reminder{ID: 4, WebsiteUrl: "news.bbc.co.uk", UpdatedAt: now}
+	done := db.Reminder{ID: 5, WebsiteUrl: "github.com", UpdatedAt: now}
+	done.WebsiteHost, done.WebsiteDomain = canonical("github.com", "github.com")
+	invalid := db.Reminder{ID: 6, WebsiteUrl: "co.uk", UpdatedAt: now}
+	newer := db.Reminder{ID: 7, WebsiteUrl: "https://gist.github.com", UpdatedAt: now.AddDate(0, 0, 1)}
+
+	merges, skipped := Plan([]db.Reminder{typed, bare, mobile, alone, done, invalid, newer})
+	require.Equal(t, []db.Reminder{invalid}, skipped)
+	require.Equal(t, []Merge{
+		// Reminders changed at the same time keep the oldest.
+		{Site: Site{"example.com", "example.com"}, Into: bare, From: []db.Reminder{mobile, typed}},
+		// Reminders without duplicates are canonicalized.
+		{Site: Site{"news.bbc.co.uk", "bbc.co.uk"}, Into: alone, From: []db.Reminder{}},
+		// A newer duplicate of a canonicalized reminder wins.
+		{Site: Site{"gist.github.com", "github.com"}, Into: newer, From: []db.Reminder{done}},
+	}, merges)
+
+	// Canonicalized reminders without duplicates need no merge.
+	merges, skipped = Plan([]db.Reminder{done})
+	require.Empty(t, merges)
+	require.Empty(t, skipped)
+}
+
+// fakeStore records the merges it is asked to make.
+type fakeStore struct {
+	db.Store
+
+	merged []db.MergeRemindersTxParams
+}
+
+func (s *fakeStore) MergeRemindersTx(ctx context.Context, arg db.MergeRemindersTxParams) (db.Reminder, error) {
+	s.merged = append(s.merged, arg)
+	return db.Reminder{ID: arg.IntoID}, nil
+}
+
+func TestMergeApply(t *testing.T) {
+	store := &fakeStore{}
+
+	m := Merge{
+		Site: Site{"example.com", "example.com"},
+		Into: db.Reminder{ID: 2},
+		From: []db.Reminder{{ID: 3}, {ID: 1}},
+	}
+	reminder, err := m.Apply(context.Background(), store)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), reminder.ID)
+	require.Equal(t, []db.MergeRemindersTxParams{{
+		IntoID:        2,
+		FromIDs:       []int64{3, 1},
+		WebsiteHost:   "example.com",
+		WebsiteDomain: "example.com",
+	}}, store.merged)
+}
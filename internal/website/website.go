@@ -0,0 +1,79 @@
+// Package website canonicalizes the website URLs of reminders, so that the
+// many ways of typing the address of a website name the same site.
+//
+// The canonical host of a URL is its host in lower case, converted to
+// punycode, without a port, a trailing dot or a "www." label. Its domain is
+// the registrable domain of the host (eTLD+1) according to the public
+// suffix list, so "https://www.Example.com/login", "example.com" and
+// "m.example.com" all have the domain "example.com", while
+// "alice.github.io" and "bob.github.io" stay apart.
+package website
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrInvalid is returned for URLs that do not name a website.
+var ErrInvalid = errors.New("website: invalid URL")
+
+// A Site is the canonical form of a website URL.
+type Site struct {
+	// Host is the canonical host.
+	Host string
+
+	// Domain is the registrable domain of Host. It is Host itself for IP
+	// addresses and single-label hosts such as "localhost".
+	Domain string
+}
+
+// Parse returns the canonical form of rawURL. A URL without a scheme, such
+// as "example.com/login", is read as an https URL.
+func Parse(rawURL string) (Site, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + strings.TrimPrefix(rawURL, "//")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Site{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if host == "" {
+		return Site{}, fmt.Errorf("%w: missing host", ErrInvalid)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return Site{Host: ip.String(), Domain: ip.String()}, nil
+	}
+
+	host, err = idna.Lookup.ToASCII(host)
+	if err != nil {
+		return Site{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if rest := strings.TrimPrefix(host, "www."); strings.Contains(rest, ".") {
+		host = rest
+	}
+
+	if !strings.Contains(host, ".") {
+		return Site{Host: host, Domain: host}, nil
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// The host is a public suffix, such as "co.uk", which no website
+		// can be registered as.
+		return Site{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return Site{Host: host, Domain: domain}, nil
+}
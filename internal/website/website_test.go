@@ -0,0 +1,52 @@
+package website
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rawURL string
+		want   Site
+	}{
+		{"example.com", Site{"example.com", "example.com"}},
+		{"https://www.Example.com/login", Site{"example.com", "example.com"}},
+		{"m.example.com", Site{"m.example.com", "example.com"}},
+		{"  http://jane@accounts.example.com:8080/?next=/ ", Site{"accounts.example.com", "example.com"}},
+		{"//example.com.", Site{"example.com", "example.com"}},
+		{"www.bbc.co.uk", Site{"bbc.co.uk", "bbc.co.uk"}},
+		{"news.bbc.co.uk", Site{"news.bbc.co.uk", "bbc.co.uk"}},
+		{"alice.github.io", Site{"alice.github.io", "alice.github.io"}},
+		{"https://bücher.example/", Site{"xn--bcher-kva.example", "xn--bcher-kva.example"}},
+		{"https://www.MÜNCHEN.de", Site{"xn--mnchen-3ya.de", "xn--mnchen-3ya.de"}},
+		{"http://192.168.1.1:8080/admin", Site{"192.168.1.1", "192.168.1.1"}},
+		{"http://[::1]:8080", Site{"::1", "::1"}},
+		{"localhost:3000", Site{"localhost", "localhost"}},
+		{"www.com", Site{"www.com", "www.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rawURL, func(t *testing.T) {
+			got, err := Parse(tt.rawURL)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rawURL := range []string{
+		"",
+		"https://",
+		"https://exa mple.com",
+		"co.uk",
+		"https://%zz",
+	} {
+		t.Run(rawURL, func(t *testing.T) {
+			_, err := Parse(rawURL)
+			require.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
@@ -1,28 +1,224 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	_ "embed"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // users' time zones must load on hosts without zoneinfo
 
 	"github.com/OCD-Labs/KeyKeeper/cmd/api"
+	db "github.com/OCD-Labs/KeyKeeper/db/sqlc"
+	"github.com/OCD-Labs/KeyKeeper/internal/breach"
+	"github.com/OCD-Labs/KeyKeeper/internal/digest"
+	"github.com/OCD-Labs/KeyKeeper/internal/events"
+	"github.com/OCD-Labs/KeyKeeper/internal/jobs"
+	"github.com/OCD-Labs/KeyKeeper/internal/mailer"
+	"github.com/OCD-Labs/KeyKeeper/internal/notifier"
+	"github.com/OCD-Labs/KeyKeeper/internal/scheduler"
+	"github.com/OCD-Labs/KeyKeeper/internal/token"
 	"github.com/OCD-Labs/KeyKeeper/internal/util"
+	"github.com/OCD-Labs/KeyKeeper/internal/webhook"
+	"github.com/OCD-Labs/KeyKeeper/internal/webpush"
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned"
+	"github.com/lib/pq"
 )
 
 //go:embed "docs/specs.yaml"
 var embeddedSwaggerSpec []byte
 
+// shutdownTimeout bounds how long in-flight requests and background work
+// get to finish on shutdown.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	config, err := util.ParseConfigs("./")
 	if err != nil {
 		log.Fatalf("failed to parse configurations: %v", err)
 	}
 
-	app := api.KeyKeeper{
+	tokenMaker, err := token.NewPasetoMaker(config.SymmetricKey)
+	if err != nil {
+		log.Fatalf("failed to create token maker: %v", err)
+	}
+
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		log.Fatalf("failed to open db connection: %v", err)
+	}
+	defer conn.Close()
+
+	store := db.NewStore(conn)
+
+	listener := pq.NewListener(config.DBSource, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	err = listener.Listen(events.Channel)
+	if err != nil {
+		log.Fatalf("failed to listen for events: %v", err)
+	}
+
+	broker := events.NewBroker(store, listener.Notify, config.EventsRetention)
+
+	app := &api.KeyKeeper{
 		SwaggerSpec: embeddedSwaggerSpec,
-		Config: config,
+		Config:      config,
+		Store:       store,
+		TokenMaker:  tokenMaker,
+		Mailer:      mailer.NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.SMTPSender),
+		Events:      broker,
+		Pwned:       pwned.NewCache(pwned.NewClient(config.PwnedPasswordsURL), config.PwnedCacheTTL, config.PwnedCacheSize),
+	}
+
+	notifiers := notifier.Multi{
+		notifier.Channel{
+			Name:     notifier.ChannelEmail,
+			Notifier: notifier.NewDigestNotifier(store, notifier.NewEmailNotifier(app.Mailer)),
+		},
+	}
+	var pushClient *webpush.Client
+	if config.VAPIDPrivateKey != "" {
+		keys, err := webpush.ParseVAPIDKeys(config.VAPIDPublicKey, config.VAPIDPrivateKey)
+		if err != nil {
+			log.Fatalf("failed to parse VAPID keys: %v", err)
+		}
+		if config.VAPIDSubject == "" {
+			log.Fatal("VAPID_SUBJECT must be set to a mailto: or https: contact URL")
+		}
+		pushClient = webpush.NewClient(keys, config.VAPIDSubject, nil)
+		notifiers = append(notifiers, notifier.Channel{
+			Name:     notifier.ChannelPush,
+			Notifier: notifier.NewWebPushNotifier(store),
+		})
+	}
+	worker := jobs.NewWorker(store, config.JobConcurrency, config.JobPollInterval, config.JobRetention)
+	if pushClient != nil {
+		jobs.Handle(worker, notifier.HandlePush(store, pushClient))
+	}
+	jobs.Handle(worker, scheduler.HandleNotify(store, notifiers))
+	jobs.Handle(worker, scheduler.HandleContact(store, app.Mailer))
+	jobs.Handle(worker, digest.Handle(store, app.Mailer))
+	jobs.Handle(worker, breach.HandleNotify(store, notifiers))
+
+	dispatcher := scheduler.Multi{
+		scheduler.DispatcherFunc(webhook.EnqueueReminderDue),
+		scheduler.DispatcherFunc(events.PublishReminderDue),
+		scheduler.TrackEscalation,
+		scheduler.EnqueueNotify,
+	}
+	sched := scheduler.New(store, dispatcher, config.SchedulerInterval, config.SchedulerBatchSize)
+	escalator := scheduler.NewEscalator(store, config.SchedulerInterval, config.SchedulerBatchSize)
+	digests := digest.NewScheduler(store, config.SchedulerInterval, config.SchedulerBatchSize)
+	webhooks := webhook.NewWorker(store, nil, config.WebhookInterval, config.WebhookBatchSize)
+
+	// Breach feeds are opt-in: without a source there is nothing to import.
+	var breaches *breach.Importer
+	if config.BreachFeedURL != "" {
+		breaches = breach.NewImporter(store, config.BreachFeedURL, config.BreachFeedInterval)
+	}
+
+	srv := &http.Server{
+		Addr:    ":8081",
+		Handler: app.Routes(),
+	}
+	srv.RegisterOnShutdown(broker.CloseStreams)
+
+	shutdownErr := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		log.Printf("Shutting down server (%s)...", s)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		err = broker.Stop(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		err = sched.Stop(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		err = escalator.Stop(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		err = digests.Stop(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		err = webhooks.Stop(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		if breaches != nil {
+			err = breaches.Stop(ctx)
+			if err != nil {
+				shutdownErr <- err
+				return
+			}
+		}
+
+		err = worker.Stop(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		app.Wait()
+		shutdownErr <- nil
+	}()
+
+	broker.Start()
+	worker.Start()
+	sched.Start()
+	escalator.Start()
+	digests.Start()
+	webhooks.Start()
+	if breaches != nil {
+		breaches.Start()
 	}
 
 	log.Println("Starting server...")
-	http.ListenAndServe(":8081", app.Routes())
+	err = srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server failed: %v", err)
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		log.Fatalf("failed to shut down cleanly: %v", err)
+	}
+
+	log.Println("Server stopped")
 }
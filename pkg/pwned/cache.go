@@ -0,0 +1,95 @@
+package pwned
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Cache keeps the range responses of a client for a while, so that
+// popular prefixes are not fetched again for every request.
+type Cache struct {
+	client *Client
+	ttl    time.Duration
+	size   int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// NewCache returns a cache of the ranges of client, each kept for ttl. At
+// most size ranges are kept; when it is full, the range closest to expiry
+// makes room for the new one.
+func NewCache(client *Client, ttl time.Duration, size int) *Cache {
+	return &Cache{
+		client:  client,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// TTL returns how long the cache keeps a range.
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Range returns the range response of prefix, from the cache if it has not
+// expired. Errors are not cached.
+func (c *Cache) Range(ctx context.Context, prefix string) ([]byte, error) {
+	prefix, err := NormalizePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[prefix]
+	c.mu.Unlock()
+
+	if ok && c.now().Before(entry.expires) {
+		return entry.body, nil
+	}
+
+	body, err := c.client.Range(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	c.put(prefix, body)
+
+	return body, nil
+}
+
+func (c *Cache) put(prefix string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	if _, ok := c.entries[prefix]; !ok && len(c.entries) >= c.size {
+		var oldest string
+		for key, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, key)
+				continue
+			}
+			if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+				oldest = key
+			}
+		}
+		if len(c.entries) >= c.size {
+			delete(c.entries, oldest)
+		}
+	}
+
+	if c.size > 0 {
+		c.entries[prefix] = cacheEntry{body: body, expires: now.Add(c.ttl)}
+	}
+}
@@ -0,0 +1,64 @@
+package pwned
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	var requests []string
+	var fail bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		requests = append(requests, prefix)
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s:%d\r\n", strings.Repeat("0", 35), len(requests))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	c := NewCache(NewClient(server.URL), time.Hour, 2)
+	c.now = func() time.Time { return now }
+
+	get := func(prefix string) string {
+		body, err := c.Range(context.Background(), prefix)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	first := get("aaaaa")
+	require.Equal(t, first, get("AAAAA"))
+	require.Equal(t, []string{"AAAAA"}, requests)
+
+	// Expired ranges are fetched again.
+	now = now.Add(time.Hour)
+	require.NotEqual(t, first, get("AAAAA"))
+	require.Len(t, requests, 2)
+
+	// A full cache drops the range closest to expiry.
+	now = now.Add(time.Minute)
+	get("BBBBB")
+	get("CCCCC")
+	require.Len(t, c.entries, 2)
+	require.NotContains(t, c.entries, "AAAAA")
+
+	// Errors are not cached.
+	fail = true
+	_, err := c.Range(context.Background(), "DDDDD")
+	require.Error(t, err)
+	require.NotContains(t, c.entries, "DDDDD")
+
+	_, err = c.Range(context.Background(), "XXXXX")
+	require.ErrorIs(t, err, ErrPrefix)
+	require.Len(t, requests, 5)
+}
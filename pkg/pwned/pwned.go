@@ -0,0 +1,187 @@
+// Package pwned checks passwords against a Pwned Passwords range API without
+// disclosing them. Only the first five characters of the SHA-1 hash of a
+// password are sent; the API answers with the suffixes of every breached
+// hash sharing that prefix, and the password's own suffix is looked up
+// locally (k-anonymity).
+//
+// The same client talks to api.pwnedpasswords.com or to the KeyKeeper proxy
+// at /v1/pwned-passwords, which needs an access token:
+//
+//	c := pwned.NewClient("https://keykeeper.example.com/v1/pwned-passwords")
+//	c.Header.Set("Authorization", "Bearer "+accessToken)
+//	n, err := c.Count(ctx, password)
+package pwned
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the base URL of the public Pwned Passwords API.
+const DefaultURL = "https://api.pwnedpasswords.com"
+
+// PrefixLength is the number of hexadecimal characters of a hash sent to
+// the API.
+const PrefixLength = 5
+
+const (
+	// requestTimeout bounds each request of a client without its own
+	// http.Client.
+	requestTimeout = 10 * time.Second
+
+	// maxRangeLength bounds the size of a range response, which is usually
+	// around 30 kB.
+	maxRangeLength = 1 << 20
+)
+
+// ErrPrefix is returned for prefixes that are not PrefixLength hexadecimal
+// characters.
+var ErrPrefix = errors.New("pwned: prefix must be 5 hexadecimal characters")
+
+// Hash returns the SHA-1 hash of password in upper-case hexadecimal, as the
+// API lists them.
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// NormalizePrefix returns prefix in upper case, or ErrPrefix if it is not a
+// valid prefix.
+func NormalizePrefix(prefix string) (string, error) {
+	if len(prefix) != PrefixLength {
+		return "", ErrPrefix
+	}
+	if _, err := hex.DecodeString(prefix + "0"); err != nil {
+		return "", ErrPrefix
+	}
+
+	return strings.ToUpper(prefix), nil
+}
+
+// A Client queries a range API.
+type Client struct {
+	// BaseURL is the URL the range of a prefix is fetched from, with
+	// "/range/<prefix>" appended.
+	BaseURL string
+
+	// HTTPClient sends the requests. If nil, a client with a 10 second
+	// timeout is used.
+	HTTPClient *http.Client
+
+	// Header is added to every request.
+	Header http.Header
+}
+
+// NewClient returns a client of the range API at baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Header:  make(http.Header),
+	}
+}
+
+// A StatusError is returned when the API answers with a status other than
+// 200 OK.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("pwned: unexpected status %d", e.StatusCode)
+}
+
+// Range returns the range response of prefix: one "SUFFIX:COUNT" line for
+// each breached hash starting with prefix.
+func (c *Client) Range(ctx context.Context, prefix string) ([]byte, error) {
+	prefix, err := NormalizePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/range/"+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", "KeyKeeper")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(res.Body, maxRangeLength))
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxRangeLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRangeLength {
+		return nil, errors.New("pwned: range response is too large")
+	}
+
+	return body, nil
+}
+
+// Count returns how many times password appears in breaches, zero if it
+// does not.
+func (c *Client) Count(ctx context.Context, password string) (int, error) {
+	hash := Hash(password)
+
+	body, err := c.Range(ctx, hash[:PrefixLength])
+	if err != nil {
+		return 0, err
+	}
+
+	return Lookup(body, hash[PrefixLength:])
+}
+
+// Lookup returns the count of suffix in the range response body, zero if
+// it is not listed. Padding entries, which have a count of zero, are
+// ignored like any other.
+func Lookup(body []byte, suffix string) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		s, count, ok := strings.Cut(line, ":")
+		if !ok {
+			return 0, fmt.Errorf("pwned: malformed range line %q", line)
+		}
+		if !strings.EqualFold(s, suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("pwned: malformed range line %q", line)
+		}
+		return n, nil
+	}
+
+	return 0, scanner.Err()
+}
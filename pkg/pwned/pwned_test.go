@@ -0,0 +1,95 @@
+package pwned_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned"
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned/pwnedtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	require.Equal(t, "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", pwned.Hash("password"))
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	prefix, err := pwned.NormalizePrefix("5baa6")
+	require.NoError(t, err)
+	require.Equal(t, "5BAA6", prefix)
+
+	for _, invalid := range []string{"", "5BAA", "5BAA61", "5BAG6", "5BA 6", "../.."} {
+		_, err := pwned.NormalizePrefix(invalid)
+		require.ErrorIs(t, err, pwned.ErrPrefix, invalid)
+	}
+}
+
+func TestCount(t *testing.T) {
+	server := pwnedtest.NewServer(map[string]int{"password": 9659365, "hunter2": 17})
+	defer server.Close()
+
+	c := pwned.NewClient(server.URL + "/")
+
+	n, err := c.Count(context.Background(), "password")
+	require.NoError(t, err)
+	require.Equal(t, 9659365, n)
+
+	n, err = c.Count(context.Background(), "correct horse battery staple")
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// Only prefixes are sent.
+	require.Equal(t, []string{"5BAA6", pwned.Hash("correct horse battery staple")[:5]}, server.Requests())
+
+	server.Fail(http.StatusServiceUnavailable)
+	_, err = c.Count(context.Background(), "hunter2")
+	var statusErr *pwned.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+}
+
+func TestClientHeader(t *testing.T) {
+	server := pwnedtest.NewServer(nil)
+	defer server.Close()
+
+	var got http.Header
+	c := pwned.NewClient(server.URL)
+	c.Header.Set("Authorization", "Bearer token")
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	_, err := c.Range(context.Background(), "00000")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token", got.Get("Authorization"))
+
+	_, err = c.Range(context.Background(), "0000")
+	require.ErrorIs(t, err, pwned.ErrPrefix)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestLookup(t *testing.T) {
+	body := []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n\r\nFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:0\r\n")
+
+	n, err := pwned.Lookup(body, "1e4c9b93f3f0682250b6cf8331b7ee68fd8")
+	require.NoError(t, err)
+	require.Equal(t, 9659365, n)
+
+	n, err = pwned.Lookup(body, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFE")
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	_, err = pwned.Lookup([]byte("garbage"), "1E4C9B93F3F0682250B6CF8331B7EE68FD8")
+	require.Error(t, err)
+
+	_, err = pwned.Lookup([]byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:many"), "1E4C9B93F3F0682250B6CF8331B7EE68FD8")
+	require.Error(t, err)
+}
@@ -0,0 +1,92 @@
+// Package pwnedtest provides an in-process range API for testing code that
+// checks passwords with package pwned.
+package pwnedtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/OCD-Labs/KeyKeeper/pkg/pwned"
+)
+
+// A Server serves the ranges of a fixed set of breached passwords.
+type Server struct {
+	*httptest.Server
+
+	hashes map[string]int
+
+	mu       sync.Mutex
+	requests []string
+	status   int
+}
+
+// NewServer starts a server of the ranges of passwords, which maps each
+// breached password to the number of times it was seen. It must be closed
+// when done.
+func NewServer(passwords map[string]int) *Server {
+	s := &Server{hashes: make(map[string]int, len(passwords))}
+	for password, count := range passwords {
+		s.hashes[pwned.Hash(password)] = count
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveRange))
+
+	return s
+}
+
+// Requests returns the prefixes requested so far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Fail makes the server answer every request with status, or serve ranges
+// again if status is zero.
+func (s *Server) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = status
+}
+
+func (s *Server) serveRange(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+
+	s.mu.Lock()
+	s.requests = append(s.requests, prefix)
+	status := s.status
+	s.mu.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/range/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefix, err := pwned.NormalizePrefix(prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var lines []string
+	for hash, count := range s.hashes {
+		if strings.HasPrefix(hash, prefix) {
+			lines = append(lines, fmt.Sprintf("%s:%d\r\n", hash[pwned.PrefixLength:], count))
+		}
+	}
+	sort.Strings(lines)
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, strings.Join(lines, ""))
+}